@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAllPages(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	for _, url := range []string{"Apple", "Banana", "Cherry", "Talk:Apple", "Talk:Banana"} {
+		mustPostArticle(t, a, url, "Content.", 0)
+	}
+	admin, err := a.GetUserByScreenName("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postAs(t, a, admin, "Periwiki:Common.css", "body {}", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Special:AllPages", nil, nil)).Body.String()
+	for _, want := range []string{`href="/wiki/Apple"`, `href="/wiki/Cherry"`, `href="/wiki/Talk:Banana"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the index to link %s, got:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "Periwiki:Common.css") {
+		t.Error("expected Periwiki pages to be left out by default")
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:AllPages?limit=2", nil, nil)).Body.String()
+	if !strings.Contains(page, `href="/wiki/Banana"`) || strings.Contains(page, `href="/wiki/Cherry"`) {
+		t.Errorf("expected the first page to stop at Banana, got:\n%s", page)
+	}
+	next := "/wiki/Special:AllPages?from=Cherry&amp;limit=2"
+	if !strings.Contains(page, next) {
+		t.Fatalf("expected a link to the next page, got:\n%s", page)
+	}
+	page = serve(a, newRequest(http.MethodGet, strings.ReplaceAll(next, "&amp;", "&"), nil, nil)).Body.String()
+	if strings.Contains(page, `href="/wiki/Banana"`) || !strings.Contains(page, `href="/wiki/Cherry"`) || !strings.Contains(page, `href="/wiki/Talk:Apple"`) {
+		t.Errorf("expected the second page to start at Cherry, got:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:AllPages?namespace=talk&from=B", nil, nil)).Body.String()
+	if !strings.Contains(page, `href="/wiki/Talk:Banana"`) || strings.Contains(page, `href="/wiki/Talk:Apple"`) || strings.Contains(page, `href="/wiki/Cherry"`) {
+		t.Errorf("expected only talk pages from Talk:B, got:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:AllPages?namespace=Periwiki", nil, nil)).Body.String()
+	if !strings.Contains(page, `href="/wiki/Periwiki:Common.css"`) || strings.Contains(page, `href="/wiki/Apple"`) {
+		t.Errorf("expected only Periwiki pages, got:\n%s", page)
+	}
+
+	for _, bad := range []string{"namespace=Nope", "limit=0"} {
+		if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:AllPages?"+bad, nil, nil)); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, rr.Code)
+		}
+	}
+}
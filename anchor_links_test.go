@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func newAnchorApp(t *testing.T) *app {
+	return newTestApp(t, func(c *wiki.Config) { c.CheckWikiLinkAnchors = true })
+}
+
+func TestDeadAnchors(t *testing.T) {
+	a := newAnchorApp(t)
+
+	mustPostArticle(t, a, "Target", "## Setup\n\nText.\n", 0)
+	article := mustPostArticle(t, a, "Linker", "## Intro\n\n[[Target#Setup]] [[Target#Usage]] [[#Intro]] [[#Missing]] [[Nowhere#Setup]]\n", 0)
+
+	for _, want := range []string{
+		`<a href="/wiki/Target#setup" rel="nofollow">`,
+		`<a href="/wiki/Target#usage" class="pw-deadanchor" rel="nofollow">`,
+		`<a href="#intro" rel="nofollow">`,
+		`<a href="#missing" class="pw-deadanchor" rel="nofollow">`,
+		`<a href="/wiki/Nowhere#setup" class="pw-deadlink" rel="nofollow">`,
+	} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s:\n%s", want, article.HTML)
+		}
+	}
+
+	// Adding the section re-renders the articles that link to it.
+	target, err := a.GetArticle("Target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPostArticle(t, a, "Target", "## Setup\n\nText.\n\n## Usage\n\nMore.\n", target.ID)
+	waitForHTML(t, a, "Linker", func(html string) bool {
+		return strings.Count(html, "pw-deadanchor") == 1
+	})
+
+	plain := newTestApp(t)
+	mustPostArticle(t, plain, "Target", "## Setup\n", 0)
+	article = mustPostArticle(t, plain, "Linker", "[[Target#Usage]] [[#Missing]]\n", 0)
+	if strings.Contains(article.HTML, "pw-deadanchor") {
+		t.Errorf("expected anchors to go unchecked without CheckWikiLinkAnchors:\n%s", article.HTML)
+	}
+}
+
+func TestIndexAnchors(t *testing.T) {
+	a := newAnchorApp(t)
+	mustPostArticle(t, a, "Target", "## Setup\n", 0)
+
+	// As if upgraded from a version that didn't record anchors.
+	execSQL(t, a, `DELETE FROM Anchor`)
+	execSQL(t, a, `DELETE FROM Preference WHERE pref_label = 'anchors_indexed'`)
+
+	if n, err := a.IndexAnchors(); err != nil || n != 1 {
+		t.Fatalf("expected 1 article to be indexed, got %d, %v", n, err)
+	}
+	article := mustPostArticle(t, a, "Linker", "[[Target#Setup]]\n", 0)
+	if strings.Contains(article.HTML, "pw-deadanchor") {
+		t.Errorf("expected the indexed section to be found:\n%s", article.HTML)
+	}
+	if n, err := a.IndexAnchors(); err != nil || n != 0 {
+		t.Errorf("expected anchors to be indexed only once, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestAutoCreateTalkPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "enabled", enabled: true},
+		{name: "disabled", enabled: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *wiki.Config) {
+				c.AutoCreateTalkPages = test.enabled
+			})
+
+			mustPostArticle(t, a, "Foo", "Foo is a placeholder.", 0)
+
+			talk, err := a.GetArticle("Talk:Foo")
+			if test.enabled {
+				if err != nil {
+					t.Fatalf("expected Talk:Foo to exist, got %v", err)
+				}
+				if talk.Markdown == "" {
+					t.Error("expected Talk:Foo to have default content")
+				}
+			} else if err != wiki.ErrGenericNotFound {
+				t.Fatalf("expected no talk page, got %v (err %v)", talk, err)
+			}
+
+			if _, err := a.GetArticle("Talk:Talk:Foo"); err != wiki.ErrGenericNotFound {
+				t.Errorf("talk page should not get its own talk page, got err %v", err)
+			}
+		})
+	}
+}
+
+func TestFailedTalkPageKeepsSubject(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.AutoCreateTalkPages = true
+	})
+	execSQL(t, a, `CREATE TRIGGER NoTalk BEFORE INSERT ON Article WHEN NEW.url LIKE 'Talk:%'
+		BEGIN SELECT RAISE(ABORT, 'no talk pages'); END`)
+
+	article := wiki.NewArticle("Foo", "Foo", "Foo is a placeholder.")
+	article.Creator = wiki.AnonymousUser()
+	if err := a.PostArticle(article); err != nil {
+		t.Fatalf("expected the subject to be saved despite its talk page failing, got %v", err)
+	}
+	if _, err := a.GetArticle("Foo"); err != nil {
+		t.Errorf("expected Foo to exist, got %v", err)
+	}
+	if _, err := a.GetArticle("Talk:Foo"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected no talk page, got %v", err)
+	}
+}
+
+func TestTalkPageRequiresSubject(t *testing.T) {
+	a := newTestApp(t)
+
+	article := wiki.NewArticle("Talk:Missing", "Talk:Missing", "Anyone?")
+	article.Creator = wiki.AnonymousUser()
+
+	if err := a.PostArticle(article); err != wiki.ErrTalkSubjectNotFound {
+		t.Fatalf("expected ErrTalkSubjectNotFound, got %v", err)
+	}
+}
+
+const mergeBase = `The first paragraph talks about apples and their many varieties.
+
+The second paragraph is about oranges, which are citrus fruits.
+
+The third paragraph covers bananas, a popular snack around the world.`
+
+func TestTryMergeClean(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.MergeEditConflicts = true
+	})
+
+	base := mustPostArticle(t, a, "Fruit", mergeBase, 0)
+	theirs := strings.Replace(mergeBase, "apples", "green apples", 1)
+	mustPostArticle(t, a, "Fruit", theirs, base.ID)
+
+	mine := wiki.NewArticle("Fruit", "Fruit", strings.Replace(mergeBase, "bananas", "ripe bananas", 1))
+	mine.Creator = wiki.AnonymousUser()
+	mine.PreviousID = base.ID
+
+	if err := a.PostArticle(mine); err != wiki.ErrRevisionAlreadyExists {
+		t.Fatalf("expected a conflicting save, got %v", err)
+	}
+
+	if err := a.postMergedArticle(mine); err != nil {
+		t.Fatalf("expected clean merge, got %v", err)
+	}
+
+	head, err := a.GetArticle("Fruit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(head.Markdown, "green apples") || !strings.Contains(head.Markdown, "ripe bananas") {
+		t.Errorf("merged article lost an edit:\n%s", head.Markdown)
+	}
+	if head.Comment != "merged edit" {
+		t.Errorf("expected merged edit comment, got %q", head.Comment)
+	}
+}
+
+func TestTryMergeConflict(t *testing.T) {
+	a := newTestApp(t)
+
+	base := mustPostArticle(t, a, "Fruit", mergeBase, 0)
+	mustPostArticle(t, a, "Fruit", strings.Replace(mergeBase, "oranges", "lemons", 1), base.ID)
+
+	_, err := a.TryMerge("Fruit", base.ID, strings.Replace(mergeBase, "oranges", "limes", 1))
+	if err != wiki.ErrMergeConflict {
+		t.Fatalf("expected ErrMergeConflict, got %v", err)
+	}
+}
+
+func TestArticlePrintView(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Printable", "Worth printing.", 0)
+
+	chrome := []string{`class="pw-tabs"`, `id="sidebar"`, `id="login-bar"`, "/r/1/edit"}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Printable", nil, nil))
+	for _, want := range chrome {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("expected the default view to contain %q", want)
+		}
+	}
+
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Printable?print", nil, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"<h1>Printable</h1>", "Worth printing.", "Retrieved from <a href=\"http://example.com/wiki/Printable\">"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the print view to contain %q", want)
+		}
+	}
+	for _, unwanted := range chrome {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("expected the print view to omit %q", unwanted)
+		}
+	}
+}
+
+func TestDefinitionAnchorLinks(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.DefinitionAnchors = true
+	})
+	glossary := mustPostArticle(t, a, "Glossary", "Render queue\n: Where renders wait.\n", 0)
+	if !strings.Contains(glossary.HTML, `<dt id="render-queue">`) {
+		t.Errorf("expected the term id to survive sanitizing, got %q", glossary.HTML)
+	}
+
+	article := mustPostArticle(t, a, "Renders", "See [[Glossary#Render queue]].", 0)
+	if !strings.Contains(article.HTML, `href="/wiki/Glossary#render-queue"`) || strings.Contains(article.HTML, "pw-deadlink") {
+		t.Errorf("expected a live link to the term, got %q", article.HTML)
+	}
+
+	backlinks, err := a.GetBacklinks("Glossary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlinks) != 1 || backlinks[0].URL != "Renders" {
+		t.Errorf("expected the fragment link to count as a backlink, got %v", backlinks)
+	}
+}
+
+func TestEmptyArticleRejected(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, body := range []string{"", "   \n\t\n  ", "---\nreviewed: 2024-01-15\n---\n\n"} {
+		article := wiki.NewArticle("Blank", "Blank", body)
+		article.Creator = wiki.AnonymousUser()
+		if err := a.PostArticle(article); err != wiki.ErrEmptyArticle {
+			t.Errorf("expected ErrEmptyArticle for %q, got %v", body, err)
+		}
+	}
+
+	// Pages without any text still render to something.
+	mustPostArticle(t, a, "Image_Only", "![Logo](/static/logo.svg)", 0)
+	mustPostArticle(t, a, "Table_Only", "| | |\n|-|-|\n| | |\n", 0)
+
+	existing := mustPostArticle(t, a, "Existing", "Some text.", 0)
+	whitespace := wiki.NewArticle("Existing", "Existing", "  \n")
+	whitespace.Creator = wiki.AnonymousUser()
+	whitespace.PreviousID = existing.ID
+	if err := a.PostArticle(whitespace); err != wiki.ErrEmptyArticle {
+		t.Errorf("expected ErrEmptyArticle rather than %v when emptying a page by accident", err)
+	}
+
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Existing/r/1", url.Values{"title": {"Existing"}, "body": {" "}, "action": {"submit"}}, nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Article is empty") {
+		t.Errorf("expected a 400 explaining the article is empty, got %d", rr.Code)
+	}
+
+	rr = serve(a, newRequest(http.MethodPost, "/wiki/Existing/r/1", url.Values{"title": {"Existing"}, "body": {""}, "blank": {"1"}, "action": {"submit"}}, nil))
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected blanking a page on purpose to be allowed, got %d", rr.Code)
+	}
+}
+
+func TestTaskLists(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.TaskLists = true
+	})
+
+	article := mustPostArticle(t, a, "Todo", "- [x] done\n- [ ] todo\n- [ ] <input type=\"checkbox\" onclick=\"alert(1)\">\n", 0)
+
+	if !strings.Contains(article.HTML, `<li><input checked="" disabled="" type="checkbox"> done</li>`) {
+		t.Errorf("expected a checked, disabled checkbox:\n%s", article.HTML)
+	}
+	if !strings.Contains(article.HTML, `<li><input disabled="" type="checkbox"> todo</li>`) {
+		t.Errorf("expected an unchecked, disabled checkbox:\n%s", article.HTML)
+	}
+	if strings.Count(article.HTML, "<input") != 3 || strings.Count(article.HTML, `disabled=""`) != 3 {
+		t.Errorf("expected only the disabled task list checkboxes to be rendered:\n%s", article.HTML)
+	}
+	if strings.Contains(article.HTML, "onclick") {
+		t.Errorf("expected event handlers to be stripped:\n%s", article.HTML)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Todo", "- [x] done\n", 0)
+	if strings.Contains(article.HTML, "<input") {
+		t.Errorf("expected task lists to be off unless configured:\n%s", article.HTML)
+	}
+}
+
+func TestSyntaxHighlighting(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.SyntaxHighlighting = "github"
+	})
+
+	article := mustPostArticle(t, a, "Code", "```go\nfunc main() {}\n```\n", 0)
+	if !strings.Contains(article.HTML, `<pre class="pw-hl-chroma">`) || !strings.Contains(article.HTML, `<span class="pw-hl-kd">func</span>`) {
+		t.Errorf("expected the highlighting classes to survive sanitizing:\n%s", article.HTML)
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Code", nil, nil)).Body.String()
+	if !strings.Contains(page, `href="/highlight.css?style=github"`) {
+		t.Errorf("expected the page to link the highlighting stylesheet:\n%s", page)
+	}
+	rr := serve(a, newRequest(http.MethodGet, "/highlight.css?style=github", nil, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), ".pw-hl-kd") {
+		t.Errorf("expected the stylesheet, got %d:\n%s", rr.Code, rr.Body)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Code", "```go\nfunc main() {}\n```\n", 0)
+	if strings.Contains(article.HTML, "pw-hl-") {
+		t.Errorf("expected highlighting to be off unless configured:\n%s", article.HTML)
+	}
+	if rr := serve(plain, newRequest(http.MethodGet, "/highlight.css", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected no stylesheet when highlighting is off, got %d", rr.Code)
+	}
+}
+
+func TestMathRendering(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.MathRendering = true
+	})
+
+	md := "Inline $x^2$ and\n\n$$\n\\frac{a}{b}\n$$\n\n<math><mi xlink:href=\"javascript:alert(1)\">x</mi></math>\n"
+	article := mustPostArticle(t, a, "Maths", md, 0)
+	for _, want := range []string{`<span class="math inline">\(x^2\)</span>`, `<div class="math display">\[\frac{a}{b}`} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s to survive sanitizing:\n%s", want, article.HTML)
+		}
+	}
+	if strings.Contains(article.HTML, "<math") || strings.Contains(article.HTML, "javascript:") {
+		t.Errorf("expected raw MathML to be stripped:\n%s", article.HTML)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Maths", "Inline $x^2$", 0)
+	if strings.Contains(article.HTML, "math inline") {
+		t.Errorf("expected math rendering to be off unless configured:\n%s", article.HTML)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ShowReadingTime = true
+		c.ReadingWordsPerMinute = 2
+	})
+	mustPostArticle(t, a, "Short", "Three [[Linked_words|little words]].\n\n```\nnot counted\n```\n", 0)
+	mustPostArticle(t, a, "Code_Only", "```\nno prose\n```\n", 0)
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Short", nil, nil)).Body.String()
+	if !strings.Contains(body, `<span class="pw-reading-time">3 words, about 2 min to read</span>`) {
+		t.Errorf("expected the word count and reading time, got:\n%s", body)
+	}
+	if body := serve(a, newRequest(http.MethodGet, "/wiki/Code_Only", nil, nil)).Body.String(); strings.Contains(body, "pw-reading-time") {
+		t.Error("expected no reading time for an article without prose")
+	}
+	if body := serve(newTestApp(t), newRequest(http.MethodGet, "/wiki/Short", nil, nil)).Body.String(); strings.Contains(body, "pw-reading-time") {
+		t.Error("expected no reading time unless ShowReadingTime is set")
+	}
+}
+
+func TestLastEditedBy(t *testing.T) {
+	a := newTestApp(t)
+	bob := mustRegister(t, a, "bob")
+
+	first := mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, " by Anonymous</span>") {
+		t.Errorf("expected an anonymous edit to be attributed to Anonymous, got:\n%s", page)
+	}
+
+	article := wiki.NewArticle("Cats", "Cats", "Cats are very nice.")
+	article.Creator = bob
+	article.PreviousID = first.ID
+	if err := a.PostArticle(article); err != nil {
+		t.Fatal(err)
+	}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, ` by <a href="/profile/bob">bob</a></span>`) {
+		t.Errorf("expected the head revision's author, got:\n%s", page)
+	}
+}
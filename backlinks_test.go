@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/renderqueue"
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestInvalidateBacklinkersAsync(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		// Long enough that only FlushBacklinkInvalidations starts the batch.
+		c.BacklinkBatchWindow = time.Hour
+	})
+
+	const backlinkers = 50
+	for i := 0; i < backlinkers; i++ {
+		mustPostArticle(t, a, fmt.Sprintf("Source_%d", i), "See [[Target]] for details.", 0)
+	}
+
+	source, err := a.GetArticle("Source_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(source.HTML, "pw-deadlink") {
+		t.Fatalf("expected a dead link before Target exists:\n%s", source.HTML)
+	}
+
+	backlinks, err := a.GetBacklinks("Target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlinks) != backlinkers {
+		t.Fatalf("expected %d backlinks, got %d", backlinkers, len(backlinks))
+	}
+
+	// Pause the workers so the queued re-renders can be inspected.
+	settings := a.Settings()
+	settings.RenderWorkers = 0
+	if err := a.UpdateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	mustPostArticle(t, a, "Target", "Here at last.", 0)
+
+	// Re-rendering is batched, so nothing has been touched yet.
+	for _, backlink := range backlinks {
+		article, err := a.GetArticle(backlink.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(article.HTML, "pw-deadlink") {
+			t.Fatalf("expected %s to be re-rendered in the background, not inline", backlink.URL)
+		}
+	}
+
+	a.FlushBacklinkInvalidations()
+	snapshot, _ := a.RenderQueueSnapshot()
+	if n := snapshot.Pending[renderqueue.TierBackground]; n != backlinkers {
+		t.Errorf("expected %d background re-renders, got %d", backlinkers, n)
+	}
+	if n := snapshot.Pending[renderqueue.TierInteractive]; n != 0 {
+		t.Errorf("expected no interactive re-renders, got %d", n)
+	}
+
+	settings.RenderWorkers = 2
+	if err := a.UpdateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	for _, backlink := range backlinks {
+		waitForHTML(t, a, backlink.URL, func(html string) bool { return !strings.Contains(html, "pw-deadlink") })
+	}
+}
+
+func TestLinksToPendingArticlesAreDead(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ModerateNewArticles = true
+		c.BacklinkBatchWindow = 10 * time.Millisecond
+	})
+
+	mustPostArticle(t, a, "Hidden", "Awaiting review.", 0)
+	linker := mustPostArticle(t, a, "Linker", "See [[Hidden]].", 0)
+	if !strings.Contains(linker.HTML, "pw-deadlink") {
+		t.Errorf("expected a link to a pending article to be dead:\n%s", linker.HTML)
+	}
+
+	if err := a.ApproveArticle("Hidden"); err != nil {
+		t.Fatal(err)
+	}
+	waitForHTML(t, a, "Linker", func(html string) bool { return !strings.Contains(html, "pw-deadlink") })
+}
+
+func TestGetBacklinksPaged(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.BacklinksPageSize = 7
+	})
+
+	const backlinkers = 23
+	for i := 0; i < backlinkers; i++ {
+		mustPostArticle(t, a, fmt.Sprintf("Source_%02d", i), "See [[Target]] for details.", 0)
+	}
+	mustPostArticle(t, a, "Unrelated", "Nothing to see here.", 0)
+
+	seen := make(map[string]bool)
+	var urls []string
+	limit := a.BacklinksPerPage()
+	for offset := 0; ; offset += limit {
+		page, total, err := a.GetBacklinksPaged("Target", limit, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != backlinkers {
+			t.Fatalf("expected a total of %d, got %d", backlinkers, total)
+		}
+		if len(page) > limit {
+			t.Fatalf("expected at most %d backlinks per page, got %d", limit, len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, backlink := range page {
+			if seen[backlink.URL] {
+				t.Fatalf("%s appeared on more than one page", backlink.URL)
+			}
+			seen[backlink.URL] = true
+			urls = append(urls, backlink.URL)
+		}
+	}
+
+	if len(urls) != backlinkers {
+		t.Fatalf("expected %d backlinks across all pages, got %d", backlinkers, len(urls))
+	}
+	for i, url := range urls {
+		if want := fmt.Sprintf("Source_%02d", i); url != want {
+			t.Fatalf("expected backlink %d to be %s, got %s", i, want, url)
+		}
+	}
+
+	rr := serve(a, newRequest("GET", "/wiki/Special:WhatLinksHere?target=Target&offset=21", nil, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Source_22") || strings.Contains(body, "Source_20") {
+		t.Errorf("expected only the last page of backlinks:\n%s", body)
+	}
+	if !strings.Contains(body, "offset=14") || strings.Contains(body, "Next page") {
+		t.Errorf("expected a link to the previous page only:\n%s", body)
+	}
+
+	rr = serve(a, newRequest("GET", "/wiki/Special:WhatLinksHere?target=Target&offset=-1", nil, nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative offset, got %d", rr.Code)
+	}
+}
+
+func TestWantedLinksOnArticles(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ShowWantedLinksOnArticles = true
+	})
+	mustPostArticle(t, a, "Exists", "Already here.", 0)
+	mustPostArticle(t, a, "Source", "See [[Exists]] and [[Missing Page]].\n\nOr [[Missing Page]] again.", 0)
+
+	wanted, err := a.GetOutboundDeadlinks("Source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wanted) != 1 || wanted[0] != "Missing_Page" {
+		t.Fatalf("expected only Missing_Page to be wanted, got %v", wanted)
+	}
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Source", nil, nil)).Body.String()
+	_, list, found := strings.Cut(body, `<div class="pw-wanted-links">`)
+	list, _, _ = strings.Cut(list, "</div>")
+	if !found || !strings.Contains(list, `href="/wiki/Missing_Page"`) || strings.Contains(list, "Exists") {
+		t.Errorf("expected only the missing page to be listed:\n%s", body)
+	}
+
+	if body := serve(a, newRequest(http.MethodGet, "/wiki/Exists", nil, nil)).Body.String(); strings.Contains(body, "pw-wanted-links") {
+		t.Errorf("expected no list for an article without dead links:\n%s", body)
+	}
+}
+
+func TestWantedLinksAreEscaped(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ShowWantedLinksOnArticles = true
+	})
+	mustPostArticle(t, a, "Source", `See [[X"><svg/onload=alert(1)>]].`, 0)
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Source", nil, nil)).Body.String()
+	_, list, found := strings.Cut(body, `<div class="pw-wanted-links">`)
+	list, _, _ = strings.Cut(list, "</div>")
+	if !found {
+		t.Fatalf("expected the dead link to be listed:\n%s", body)
+	}
+	if strings.Contains(list, "<svg") || strings.Contains(list, `X">`) {
+		t.Errorf("expected the link target to be escaped:\n%s", list)
+	}
+	if !strings.Contains(list, "&lt;svg/onload=alert(1)&gt;") {
+		t.Errorf("expected the link target to be shown escaped:\n%s", list)
+	}
+}
+
+func TestWhatLinksHere(t *testing.T) {
+	a := newTestApp(t)
+	alice := mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Old_Target", "Soon moved.", 0)
+	mustPostArticle(t, a, "Linker", "See [[Target]].", 0)
+	if err := a.MoveArticle("Old_Target", "Target", alice); err != nil {
+		t.Fatal(err)
+	}
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Special:WhatLinksHere?target=Target", nil, nil)).Body.String()
+	if !strings.Contains(body, "2 articles link") {
+		t.Errorf("expected a count of 2 backlinks, got:\n%s", body)
+	}
+	if !strings.Contains(body, `<a href="/wiki/Linker">Linker</a></li>`) {
+		t.Errorf("expected Linker as a live link, got:\n%s", body)
+	}
+	if !strings.Contains(body, `<a href="/wiki/Old_Target?redirect=no">Old_Target</a> <span class="pw-redirect">(redirect page)</span>`) {
+		t.Errorf("expected Old_Target as a redirect, got:\n%s", body)
+	}
+
+	for _, target := range []string{"", "Nowhere"} {
+		rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:WhatLinksHere?target="+target, nil, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected 200 for target %q, got %d", target, rr.Code)
+		}
+		if target == "" && !strings.Contains(rr.Body.String(), `name="target"`) {
+			t.Errorf("expected a form asking for a target, got:\n%s", rr.Body)
+		}
+		if target != "" && !strings.Contains(rr.Body.String(), "No articles link to") {
+			t.Errorf("expected an empty state for %q, got:\n%s", target, rr.Body)
+		}
+	}
+}
+
+func TestLinkReports(t *testing.T) {
+	a := newNavboxApp(t)
+	mustPostArticle(t, a, "Main_Page", "Start at [[Linked]].", 0)
+	mustPostArticle(t, a, "Linked", "See [[Dead_End]] and [[Linked]].\n\n{{nav:Navbox}}\n", 0)
+	mustPostArticle(t, a, "Dead_End", "Nowhere to go.", 0)
+	mustPostArticle(t, a, "Navbox", "[[Linked]]", 0)
+	mustPostArticle(t, a, "Lonely", "Only links to [[Lonely]] and [[Main_Page]].", 0)
+	mustPostArticle(t, a, "Talk:Lonely", "Nobody links here.", 0)
+
+	orphans := serve(a, newRequest(http.MethodGet, "/wiki/Special:OrphanedPages", nil, nil)).Body.String()
+	if !strings.Contains(orphans, `href="/wiki/Lonely"`) {
+		t.Errorf("expected Lonely to be orphaned, got:\n%s", orphans)
+	}
+	for _, linked := range []string{"Main_Page", "Linked", "Dead_End", "Navbox", "Talk:Lonely"} {
+		if strings.Contains(orphans, `href="/wiki/`+linked+`"`) {
+			t.Errorf("expected %s not to be listed as orphaned", linked)
+		}
+	}
+
+	deadEnds := serve(a, newRequest(http.MethodGet, "/wiki/Special:DeadEndPages", nil, nil)).Body.String()
+	for _, deadEnd := range []string{"Dead_End", "Talk:Lonely"} {
+		if !strings.Contains(deadEnds, `href="/wiki/`+deadEnd+`"`) {
+			t.Errorf("expected %s to be a dead end, got:\n%s", deadEnd, deadEnds)
+		}
+	}
+	for _, linking := range []string{"Main_Page", "Linked", "Navbox", "Lonely"} {
+		if strings.Contains(deadEnds, `href="/wiki/`+linking+`"`) {
+			t.Errorf("expected %s not to be listed as a dead end", linking)
+		}
+	}
+}
+
+func TestWantedPages(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Source", "See [[Popular]], [[Rare]] and [[Special:Random]].", 0)
+	mustPostArticle(t, a, "Another", "Also [[Popular]] and [[Source]].", 0)
+
+	wanted, err := a.GetWantedPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []wiki.WantedPage{{URL: "Popular", References: 2}, {URL: "Rare", References: 1}}
+	if !reflect.DeepEqual(wanted, expected) {
+		t.Errorf("expected %v, got %v", expected, wanted)
+	}
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Special:WantedPages", nil, nil)).Body.String()
+	popular, rare := strings.Index(body, `href="/wiki/Popular"`), strings.Index(body, `href="/wiki/Rare"`)
+	if popular < 0 || rare < 0 || popular > rare {
+		t.Errorf("expected Popular to be listed before Rare, got:\n%s", body)
+	}
+	if strings.Contains(body, `href="/wiki/Source"`) {
+		t.Error("expected existing articles not to be wanted")
+	}
+}
+
+func TestBacklinksNormalizeTargets(t *testing.T) {
+	a := newTestApp(t)
+	// Written with e and a combining acute accent.
+	mustPostArticle(t, a, "Linker", "See [[Target B]] and [[ Cafe\u0301  au lait ]].", 0)
+
+	for _, target := range []string{"Target_B", "Target B", " Target  B", "Caf\u00e9_au_lait", "Cafe\u0301 au lait"} {
+		backlinks, err := a.GetBacklinks(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(backlinks) != 1 || backlinks[0].URL != "Linker" {
+			t.Errorf("%q: expected Linker to link here, got %v", target, backlinks)
+		}
+	}
+}
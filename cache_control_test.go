@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestArticleCacheControl(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+
+	mustPostArticle(t, a, "Normal", "Cache me.", 0)
+	mustPostArticle(t, a, "Dashboard", "---\ncache: none\n---\nLive figures.", 0)
+	mustPostArticle(t, a, "Static", "---\ncache: long\n---\nNever changes.", 0)
+	mustPostArticle(t, a, "Typo", "---\ncache: forever\n---\nUnknown policy.", 0)
+
+	tests := []struct {
+		url    string
+		logged bool
+		want   string
+	}{
+		{"/wiki/Normal", false, "public, no-cache"},
+		{"/wiki/Dashboard", false, "no-store"},
+		{"/wiki/Static", false, "public, max-age=86400"},
+		{"/wiki/Typo", false, "public, no-cache"},
+		{"/wiki/Normal", true, "private, no-cache"},
+		{"/wiki/Dashboard", true, "no-store"},
+	}
+
+	cookie := login(t, a, "alice")
+	for _, test := range tests {
+		req := newRequest(http.MethodGet, test.url, nil, nil)
+		if test.logged {
+			req.AddCookie(cookie)
+		}
+		if got := serve(a, req).Header().Get("Cache-Control"); got != test.want {
+			t.Errorf("%s (logged in: %t): expected Cache-Control %q, got %q", test.url, test.logged, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCategories(t *testing.T) {
+	a := newTestApp(t)
+
+	sparrow := mustPostArticle(t, a, "Sparrow", "A small bird.\n\n[[Category:Birds]] [[Category:Garden wildlife]]\n", 0)
+	if strings.Contains(sparrow.HTML, "Category:") || strings.Contains(sparrow.HTML, "<p></p>") {
+		t.Errorf("expected category links not to be rendered:\n%s", sparrow.HTML)
+	}
+	if len(sparrow.Links) != 0 {
+		t.Errorf("expected category links not to count as links, got %v", sparrow.Links)
+	}
+	mustPostArticle(t, a, "Robin", "Red breast. [[Category:Birds]]", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Sparrow", nil, nil)).Body.String()
+	for _, want := range []string{`href="/wiki/Special:Category/Birds">Birds</a>`, `href="/wiki/Special:Category/Garden_wildlife">Garden wildlife</a>`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the article to list its categories with %s:\n%s", want, page)
+		}
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Categories", nil, nil)).Body.String()
+	if !strings.Contains(page, `>Birds</a> (2 articles)`) || !strings.Contains(page, `>Garden wildlife</a> (1 article)`) {
+		t.Errorf("expected each category with its member count:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Category/Birds", nil, nil)).Body.String()
+	if !strings.Contains(page, `<a href="/wiki/Robin">Robin</a>`) || !strings.Contains(page, `<a href="/wiki/Sparrow">Sparrow</a>`) {
+		t.Errorf("expected the category to list its members:\n%s", page)
+	}
+
+	mustPostArticle(t, a, "Sparrow", "A small bird.\n\n[[Category:Birds]]\n", sparrow.ID)
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Category/Garden_wildlife", nil, nil)).Body.String()
+	if strings.Contains(page, "/wiki/Sparrow") || !strings.Contains(page, "No articles are in this category yet.") {
+		t.Errorf("expected removing the category link to remove the membership:\n%s", page)
+	}
+
+	if err := a.DeleteArticle("Robin"); err != nil {
+		t.Fatal(err)
+	}
+	categories, err := a.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || categories[0].Name != "Birds" || categories[0].Members != 1 {
+		t.Errorf("expected deleting an article to remove its memberships, got %+v", categories)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Category/%3Cscript%3E", nil, nil)).Body.String()
+	if strings.Contains(page, "<script>") {
+		t.Errorf("expected the category name to be escaped:\n%s", page)
+	}
+}
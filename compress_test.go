@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// rawMarkdown reads a revision's markdown column as stored, bypassing
+// decompression.
+func rawMarkdown(t *testing.T, file string, id int) []byte {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var raw []byte
+	if err := conn.QueryRow(`SELECT markdown FROM Revision WHERE id = ?`, id).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestCompressedRevisionsRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "periwiki.db")
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.DatabaseFile = file
+		c.CompressRevisions = true
+	})
+
+	markdown := strings.Repeat("A long and repetitive paragraph. ", 200)
+	posted := mustPostArticle(t, a, "Compressed", markdown, 0)
+
+	article, err := a.GetArticle("Compressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Markdown != markdown {
+		t.Errorf("markdown did not round-trip")
+	}
+	if !strings.Contains(article.HTML, "A long and repetitive paragraph.") {
+		t.Errorf("html did not round-trip, got %q", article.HTML)
+	}
+
+	if raw := rawMarkdown(t, file, posted.ID); len(raw) >= len(markdown) || bytes.Contains(raw, []byte("repetitive")) {
+		t.Errorf("expected markdown to be stored compressed, got %d bytes", len(raw))
+	}
+
+	history, err := a.GetRevisionHistory("Compressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := history[0].Markdown, fmt.Sprint(len(markdown)); got != want {
+		t.Errorf("history length = %s, want %s", got, want)
+	}
+}
+
+func TestCompressedRevisionsMixedRows(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "periwiki.db")
+
+	plain := newTestApp(t, func(c *wiki.Config) { c.DatabaseFile = file })
+	first := mustPostArticle(t, plain, "Mixed", strings.Repeat("Written before compression. ", 50), 0)
+	plain.Close()
+
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.DatabaseFile = file
+		c.CompressRevisions = true
+	})
+	second := mustPostArticle(t, a, "Mixed", strings.Repeat("Written after compression. ", 50), first.ID)
+
+	for _, want := range []*wiki.Article{first, second} {
+		article, err := a.GetArticleByRevisionID("Mixed", want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if article.Markdown != want.Markdown {
+			t.Errorf("revision %d: markdown = %q, want %q", want.ID, article.Markdown, want.Markdown)
+		}
+	}
+
+	if raw := rawMarkdown(t, file, first.ID); string(raw) != first.Markdown {
+		t.Errorf("expected the earlier revision to remain uncompressed")
+	}
+}
+
+func TestRevisionSizesAreBackfilled(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "periwiki.db")
+	markdown := strings.Repeat("Written before sizes were stored. ", 50)
+
+	old := newTestApp(t, func(c *wiki.Config) {
+		c.DatabaseFile = file
+		c.CompressRevisions = true
+	})
+	mustPostArticle(t, old, "Sized", markdown, 0)
+	old.Close()
+	execSQL(t, old, `UPDATE Revision SET size = NULL`)
+
+	a := newTestApp(t, func(c *wiki.Config) { c.DatabaseFile = file })
+	history, err := a.GetRevisionHistory("Sized")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := history[0].Markdown, fmt.Sprint(len(markdown)); got != want {
+		t.Errorf("history length = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestConcurrentFirstRegistration(t *testing.T) {
+	a := newTestApp(t)
+
+	const users = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, users)
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user%d", i)
+			errs <- a.PostUser(&wiki.User{ScreenName: name, Email: name + "@example.com", RawPassword: testPassword})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("PostUser: %v", err)
+		}
+	}
+
+	admins := 0
+	for i := 0; i < users; i++ {
+		user, err := a.GetUserByScreenName(fmt.Sprintf("user%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.IsAdmin() {
+			admins++
+		}
+	}
+	if admins != 1 {
+		t.Errorf("expected exactly one admin, got %d", admins)
+	}
+}
+
+// Concurrent creators of the same article, such as several instances seeding
+// a shared database, must not duplicate it or fail with anything but a
+// revision conflict.
+func TestConcurrentArticleCreation(t *testing.T) {
+	a := newTestApp(t)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			article := wiki.NewArticle("Main_Page", "Main Page", "Welcome to the wiki.")
+			article.Creator = wiki.AnonymousUser()
+			errs <- a.PostArticle(article)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			created++
+		case wiki.ErrRevisionAlreadyExists:
+		default:
+			t.Errorf("PostArticle: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one writer to create the article, got %d", created)
+	}
+
+	history, err := a.GetRevisionHistory("Main_Page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Errorf("expected a single revision, got %d", len(history))
+	}
+}
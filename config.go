@@ -9,6 +9,7 @@ import (
 
 	"github.com/danielledeleo/periwiki/wiki"
 	"github.com/gorilla/securecookie"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
@@ -20,6 +21,73 @@ func SetupConfig() *wiki.Config {
 	viper.SetDefault("min_password_length", 8)
 	viper.SetDefault("cookie_expiry", 86400*7) // a week
 	viper.SetDefault("host", "0.0.0.0:8080")
+	viper.SetDefault("site_name", "periwiki")
+	viper.SetDefault("auto_create_talk_pages", false)
+	viper.SetDefault("merge_edit_conflicts", false)
+	viper.SetDefault("normalize_article_urls", true)
+	viper.SetDefault("moderate_new_articles", false)
+	viper.SetDefault("render_workers", 2)
+	viper.SetDefault("max_concurrent_renders", 4)
+	viper.SetDefault("backlink_batch_window", "250ms")
+	viper.SetDefault("watch_notification_delay", "5m")
+	viper.SetDefault("backlinks_page_size", 100)
+	viper.SetDefault("navboxes", true)
+	viper.SetDefault("transclusion", true)
+	viper.SetDefault("mark_external_links", false)
+	viper.SetDefault("external_link_icon", true)
+	viper.SetDefault("trusted_proxies", []string{})
+	viper.SetDefault("attribution_template", wiki.DefaultAttributionTemplate)
+	viper.SetDefault("allow_empty_articles", false)
+	viper.SetDefault("max_nesting_depth", 32)
+	viper.SetDefault("task_lists", true)
+	viper.SetDefault("syntax_highlighting", "github")
+	viper.SetDefault("math_rendering", false)
+	viper.SetDefault("table_of_contents", true)
+	viper.SetDefault("diagrams", false)
+	viper.SetDefault("dot_path", "dot")
+	viper.SetDefault("show_wanted_links_on_articles", false)
+	viper.SetDefault("show_reading_time", false)
+	viper.SetDefault("reading_words_per_minute", 200)
+	viper.SetDefault("editor_toolbar", wiki.DefaultEditorToolbar)
+	viper.SetDefault("title_casing", wiki.TitleCasingFirstLetter)
+	viper.SetDefault("theme", wiki.ThemeLight)
+	viper.SetDefault("diff_mode", wiki.DiffModeInline)
+	viper.SetDefault("large_article_render_bytes", 256<<10)
+	viper.SetDefault("case_insensitive_usernames", true)
+	viper.SetDefault("stale_content_age", "4320h") // 180 days
+	viper.SetDefault("protected_articles", []string{})
+	viper.SetDefault("namespace_aliases", map[string]string{})
+	viper.SetDefault("require_login_to_edit", false)
+	viper.SetDefault("allow_anonymous_preview", false)
+	viper.SetDefault("read_only", false)
+	viper.SetDefault("embed_allowed_origins", []string{})
+	viper.SetDefault("check_wikilink_anchors", true)
+	viper.SetDefault("definition_anchors", false)
+	viper.SetDefault("allow_custom_js", false)
+	viper.SetDefault("not_found_suggestions", 5)
+	viper.SetDefault("compress_revisions", false)
+	viper.SetDefault("duplicate_content_threshold", 0.0)
+	viper.SetDefault("rerender_outdated_html", true)
+	viper.SetDefault("sitemap_priorities", map[string]float64{"Main_Page": 1.0})
+	viper.SetDefault("sitemap_exclude_namespaces", []string{})
+	viper.SetDefault("sitemap_special_pages", []string{})
+	viper.SetDefault("editor_preview_by_default", false)
+	viper.SetDefault("editor_monospace", false)
+	viper.SetDefault("editor_tab_size", 4)
+	viper.SetDefault("cleanup_interval", "1h")
+	viper.SetDefault("site_notice", "")
+	viper.SetDefault("content_license_name", "")
+	viper.SetDefault("content_license_url", "")
+	viper.SetDefault("confirm_revert_comments", true)
+	viper.SetDefault("content_negotiation", true)
+	viper.SetDefault("login_rate_limit", 10)
+	viper.SetDefault("login_rate_window", "15m")
+	viper.SetDefault("require_email_verification", false)
+	viper.SetDefault("remember_me_expiry", "720h") // 30 days
+	viper.SetDefault("enable_metrics", false)
+	viper.SetDefault("diff_cache_size", 256)
+	viper.SetDefault("max_render_duration", "30s")
+	viper.SetDefault("background_render_max_wait", "1m")
 
 	viper.SetConfigFile(configFilename)
 	viper.AddConfigPath(".")
@@ -75,6 +143,75 @@ func SetupConfig() *wiki.Config {
 		CookieSecret:          secretBytes,
 		CookieExpiry:          viper.GetInt("cookie_expiry"),
 		Host:                  viper.GetString("host"),
+		SiteName:              viper.GetString("site_name"),
+		AutoCreateTalkPages:   viper.GetBool("auto_create_talk_pages"),
+		MergeEditConflicts:    viper.GetBool("merge_edit_conflicts"),
+		NormalizeArticleURLs:  viper.GetBool("normalize_article_urls"),
+		ModerateNewArticles:   viper.GetBool("moderate_new_articles"),
+		RenderWorkers:         viper.GetInt("render_workers"),
+		MaxConcurrentRenders:  viper.GetInt("max_concurrent_renders"),
+		BacklinkBatchWindow:   viper.GetDuration("backlink_batch_window"),
+		BacklinksPageSize:     viper.GetInt("backlinks_page_size"),
+		Navboxes:              viper.GetBool("navboxes"),
+		Transclusion:          viper.GetBool("transclusion"),
+		MarkExternalLinks:     viper.GetBool("mark_external_links"),
+		ExternalLinkIcon:      viper.GetBool("external_link_icon"),
+		TrustedProxies:        viper.GetStringSlice("trusted_proxies"),
+		AttributionTemplate:   viper.GetString("attribution_template"),
+		AllowEmptyArticles:    viper.GetBool("allow_empty_articles"),
+		MaxNestingDepth:       viper.GetInt("max_nesting_depth"),
+		TaskLists:             viper.GetBool("task_lists"),
+		SyntaxHighlighting:    viper.GetString("syntax_highlighting"),
+		MathRendering:         viper.GetBool("math_rendering"),
+		TableOfContents:       viper.GetBool("table_of_contents"),
+		Diagrams:              viper.GetBool("diagrams"),
+		DotPath:               viper.GetString("dot_path"),
+
+		ShowWantedLinksOnArticles: viper.GetBool("show_wanted_links_on_articles"),
+		ShowReadingTime:           viper.GetBool("show_reading_time"),
+		ReadingWordsPerMinute:     viper.GetInt("reading_words_per_minute"),
+		EditorToolbar:             viper.GetStringSlice("editor_toolbar"),
+		TitleCasing:               viper.GetString("title_casing"),
+
+		LargeArticleRenderBytes:   viper.GetInt("large_article_render_bytes"),
+		CaseInsensitiveUsernames:  viper.GetBool("case_insensitive_usernames"),
+		StaleContentAge:           viper.GetDuration("stale_content_age"),
+		ProtectedArticles:         viper.GetStringSlice("protected_articles"),
+		NamespaceAliases:          viper.GetStringMapString("namespace_aliases"),
+		RequireLoginToEdit:        viper.GetBool("require_login_to_edit"),
+		AllowAnonymousPreview:     viper.GetBool("allow_anonymous_preview"),
+		ReadOnly:                  viper.GetBool("read_only"),
+		EmbedAllowedOrigins:       viper.GetStringSlice("embed_allowed_origins"),
+		CheckWikiLinkAnchors:      viper.GetBool("check_wikilink_anchors"),
+		DefinitionAnchors:         viper.GetBool("definition_anchors"),
+		AllowCustomJS:             viper.GetBool("allow_custom_js"),
+		NotFoundSuggestions:       viper.GetInt("not_found_suggestions"),
+		CompressRevisions:         viper.GetBool("compress_revisions"),
+		DuplicateContentThreshold: viper.GetFloat64("duplicate_content_threshold"),
+		RerenderOutdatedHTML:      viper.GetBool("rerender_outdated_html"),
+		SitemapPriorities:         sitemapPriorities(),
+		SitemapExcludeNamespaces:  viper.GetStringSlice("sitemap_exclude_namespaces"),
+		SitemapSpecialPages:       viper.GetStringSlice("sitemap_special_pages"),
+		EditorPreviewByDefault:    viper.GetBool("editor_preview_by_default"),
+		EditorMonospace:           viper.GetBool("editor_monospace"),
+		EditorTabSize:             viper.GetInt("editor_tab_size"),
+		Theme:                     viper.GetString("theme"),
+		DiffMode:                  viper.GetString("diff_mode"),
+		CleanupInterval:           viper.GetDuration("cleanup_interval"),
+		SiteNotice:                viper.GetString("site_notice"),
+		ContentLicenseName:        viper.GetString("content_license_name"),
+		ContentLicenseURL:         viper.GetString("content_license_url"),
+		WatchNotificationDelay:    viper.GetDuration("watch_notification_delay"),
+		ConfirmRevertComments:     viper.GetBool("confirm_revert_comments"),
+		ContentNegotiation:        viper.GetBool("content_negotiation"),
+		LoginRateLimit:            viper.GetInt("login_rate_limit"),
+		LoginRateWindow:           viper.GetDuration("login_rate_window"),
+		RequireEmailVerification:  viper.GetBool("require_email_verification"),
+		RememberMeExpiry:          viper.GetDuration("remember_me_expiry"),
+		EnableMetrics:             viper.GetBool("enable_metrics"),
+		DiffCacheSize:             viper.GetInt("diff_cache_size"),
+		MaxRenderDuration:         viper.GetDuration("max_render_duration"),
+		BackgroundRenderMaxWait:   viper.GetDuration("background_render_max_wait"),
 	}
 
 	if createDefaultConfigFile {
@@ -91,3 +228,13 @@ func SetupConfig() *wiki.Config {
 
 	return config
 }
+
+// sitemapPriorities reads sitemap_priorities, which viper can only return
+// with untyped values.
+func sitemapPriorities() map[string]float64 {
+	priorities := make(map[string]float64)
+	for pattern, priority := range viper.GetStringMap("sitemap_priorities") {
+		priorities[pattern] = cast.ToFloat64(priority)
+	}
+	return priorities
+}
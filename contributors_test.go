@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// postAs saves a revision of the article at url as user, returning its ID.
+func postAs(t *testing.T, a *app, user *wiki.User, url, markdown string, previousID int) int {
+	t.Helper()
+
+	article := wiki.NewArticle(url, url, markdown)
+	article.Creator = user
+	article.PreviousID = previousID
+	if err := a.PostArticle(article); err != nil {
+		t.Fatalf("PostArticle(%q): %v", url, err)
+	}
+	return article.ID
+}
+
+func TestGetContributors(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ContentNegotiation = true
+	})
+	alice := mustRegister(t, a, "alice")
+	bob := mustRegister(t, a, "bob")
+
+	id := postAs(t, a, alice, "Shared", "one", 0)
+	id = postAs(t, a, bob, "Shared", "two", id)
+	id = postAs(t, a, alice, "Shared", "three", id)
+	id = postAs(t, a, wiki.AnonymousUser(), "Shared", "four", id)
+	postAs(t, a, alice, "Shared", "five", id)
+	postAs(t, a, bob, "Elsewhere", "unrelated", 0)
+
+	contributors, err := a.GetContributors("Shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []wiki.Contributor{{ScreenName: "alice", Edits: 3}, {ScreenName: "Anonymous", Edits: 1}, {ScreenName: "bob", Edits: 1}}
+	if len(contributors) != len(want) {
+		t.Fatalf("expected %d contributors, got %d", len(want), len(contributors))
+	}
+	for i, c := range contributors {
+		if *c != want[i] {
+			t.Errorf("expected contributor %d to be %+v, got %+v", i, want[i], *c)
+		}
+	}
+
+	for _, target := range []string{"/wiki/Shared", "/wiki/Shared?print"} {
+		body := serve(a, newRequest(http.MethodGet, target, nil, nil)).Body.String()
+		if !strings.Contains(body, `<p class="pw-attribution">Contributors to “Shared”: alice (3), Anonymous (1), bob (1).</p>`) {
+			t.Errorf("expected %s to credit every contributor:\n%s", target, body)
+		}
+	}
+
+	req := newRequest(http.MethodGet, "/wiki/Shared", nil, nil)
+	req.Header.Set("Accept", "application/json")
+	var exported struct {
+		Contributors []wiki.Contributor `json:"contributors"`
+	}
+	if err := json.NewDecoder(serve(a, req).Body).Decode(&exported); err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Contributors) != 3 || exported.Contributors[0] != want[0] {
+		t.Errorf("expected the JSON export to list the contributors, got %+v", exported.Contributors)
+	}
+}
+
+func TestAttributionTemplate(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.AttributionTemplate = `CC BY-SA: {{.Title}} by {{range .Contributors}}<{{.ScreenName}}>{{end}}`
+	})
+	mallory := mustRegister(t, a, "mallory")
+	postAs(t, a, mallory, "Licensed", "text", 0)
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Licensed", nil, nil)).Body.String()
+	if !strings.Contains(body, "CC BY-SA: Licensed by &lt;mallory") {
+		t.Errorf("expected the configured, escaped attribution:\n%s", body)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/sessions"
+)
+
+// csrfSession is the session carrying a visitor's CSRF token.
+const csrfSession = "periwiki-csrf"
+
+// csrfHeader may carry the CSRF token instead of the form, for clients other
+// than browsers.
+const csrfHeader = "X-CSRF-Token"
+
+var errCSRF = errors.New("this form has expired or didn't come from this wiki; go back, reload the page and try again")
+
+// newCSRFStore returns the session store for CSRF tokens. Its sessions live
+// in cookies, rather than the database like login sessions, so that anonymous
+// visitors, who must send tokens too, don't each take up a row. Its key is
+// derived from secret so that it differs from the login sessions'.
+func newCSRFStore(secret []byte) sessions.Store {
+	key := sha256.Sum256(append([]byte(csrfSession+":"), secret...))
+	return sessions.NewCookieStore(key[:])
+}
+
+// CSRFMiddleware gives each visitor a CSRF token, kept in their session and
+// available to templates through csrfField, and refuses any request that
+// changes state without sending it back with 403 Forbidden. The token is read
+// from the X-CSRF-Token header, then the form, or, for multipart forms, whose
+// body is left for the handler to read, the query string.
+func (a *app) CSRFMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// A session that can't be decoded, say because the cookie secret
+		// changed, is replaced.
+		session, _ := a.csrf.Get(req, csrfSession)
+		token, _ := session.Values["token"].(string)
+		if token == "" {
+			var err error
+			token, err = newCSRFToken()
+			if err != nil {
+				a.errorHandler(http.StatusInternalServerError, rw, req, err)
+				return
+			}
+			session.Values["token"] = token
+			session.Options = &sessions.Options{
+				Path:     "/",
+				MaxAge:   int(a.CookieExpiryDuration().Seconds()),
+				Secure:   isHTTPS(req),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			}
+			if err := session.Save(req, rw); err != nil {
+				a.errorHandler(http.StatusInternalServerError, rw, req, err)
+				return
+			}
+		}
+		// Pages with forms carry the token, so they mustn't be shared.
+		rw.Header().Add("Vary", "Cookie")
+		req = req.WithContext(context.WithValue(req.Context(), wiki.CSRFTokenKey, token))
+
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			sent := req.Header.Get(csrfHeader)
+			if sent == "" {
+				if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+					sent = req.URL.Query().Get(wiki.CSRFField)
+				} else {
+					sent = req.PostFormValue(wiki.CSRFField)
+				}
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				a.errorHandler(http.StatusForbidden, rw, req, errCSRF)
+				return
+			}
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
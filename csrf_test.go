@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// csrfForm loads the login form with cookies and returns the CSRF token it
+// carries and the CSRF cookie set with it.
+func csrfForm(t *testing.T, a *app, cookies ...*http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+
+	req := newRequest(http.MethodGet, "/user/login", nil, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := serveWithoutCSRF(a, req)
+	match := csrfFieldRegexp.FindStringSubmatch(rr.Body.String())
+	if match == nil {
+		t.Fatalf("expected a CSRF field in the login form, got:\n%s", rr.Body)
+	}
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == csrfSession {
+			return match[1], cookie
+		}
+	}
+	return match[1], nil
+}
+
+func TestCSRF(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+
+	token, cookie := csrfForm(t, a)
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("expected an HttpOnly CSRF cookie, got %+v", cookie)
+	}
+	if again, _ := csrfForm(t, a, cookie); again != token {
+		t.Error("expected the token to last for the session")
+	}
+	other, otherCookie := csrfForm(t, a)
+	if other == token {
+		t.Error("expected each visitor to get their own token")
+	}
+
+	form := url.Values{"screenname": {"alice"}, "password": {testPassword}}
+	for _, test := range []struct {
+		name   string
+		token  string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"no token", "", cookie, http.StatusForbidden},
+		{"no cookie", token, nil, http.StatusForbidden},
+		{"another visitor's token", other, cookie, http.StatusForbidden},
+		{"another visitor's cookie", token, otherCookie, http.StatusForbidden},
+		{"matching", token, cookie, http.StatusSeeOther},
+	} {
+		sent := url.Values{wiki.CSRFField: {test.token}}
+		for key, values := range form {
+			sent[key] = values
+		}
+		rr := serveWithoutCSRF(a, newRequest(http.MethodPost, "/user/login", sent, test.cookie))
+		if rr.Code != test.want {
+			t.Errorf("%s: expected %d, got %d", test.name, test.want, rr.Code)
+		}
+	}
+}
+
+func TestCSRFMultipart(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+	token, cookie := csrfForm(t, a, admin)
+
+	for _, target := range []string{"/manage/settings", "/manage/settings?" + wiki.CSRFField + "=" + token} {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("site_name", "Catipedia")
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, target, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.AddCookie(admin)
+		req.AddCookie(cookie)
+		rr := serveWithoutCSRF(a, req)
+
+		want := http.StatusSeeOther
+		if target == "/manage/settings" {
+			want = http.StatusForbidden
+		}
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, rr.Code)
+		}
+	}
+}
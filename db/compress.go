@@ -0,0 +1,60 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// compressedPrefix marks a gzipped revision column. Markdown and HTML never
+// start with a NUL byte, so uncompressed rows written before compression was
+// enabled, or too small to benefit, are read back unchanged.
+var compressedPrefix = []byte("\x00gz")
+
+// pack returns the value to store for a revision's markdown or html column.
+// With compression enabled it is gzipped, unless that wouldn't save space.
+func (db *sqliteDb) pack(s string) interface{} {
+	if !db.compress {
+		return s
+	}
+
+	var buf bytes.Buffer
+	buf.Write(compressedPrefix)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		return s
+	}
+	if err := zw.Close(); err != nil {
+		return s
+	}
+
+	if buf.Len() >= len(s) {
+		return s
+	}
+	return buf.Bytes()
+}
+
+// unpack reverses pack. It accepts both compressed and uncompressed values.
+func unpack(s string) (string, error) {
+	if !bytes.HasPrefix([]byte(s), compressedPrefix) {
+		return s, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(s[len(compressedPrefix):])))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	return string(b), err
+}
+
+// unpackRevision decompresses the columns of r read from the database.
+func unpackRevision(markdown, html *string) (err error) {
+	if *markdown, err = unpack(*markdown); err != nil {
+		return err
+	}
+	*html, err = unpack(*html)
+	return err
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/jmoiron/sqlx"
+)
+
+// columnMigrations add columns introduced after a table was first created.
+// schema.sql only creates missing tables, so databases created by older
+// versions need these applied. Each must be safe to run repeatedly.
+var columnMigrations = []string{
+	`ALTER TABLE User ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`,
+	`ALTER TABLE Revision ADD COLUMN pending INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE Article ADD COLUMN reviewed_at TIMESTAMP`,
+	`ALTER TABLE Revision ADD COLUMN render_fingerprint TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE Article ADD COLUMN protected_level TEXT NOT NULL DEFAULT 'none'`,
+	// Accounts made before verification was introduced count as verified.
+	`ALTER TABLE User ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE User ADD COLUMN verification_token TEXT`,
+	`ALTER TABLE Article ADD COLUMN deleted_at TIMESTAMP`,
+	`ALTER TABLE User ADD COLUMN verification_expires TIMESTAMP`,
+	// Filled in by backfillRevisionSizes.
+	`ALTER TABLE Revision ADD COLUMN size INTEGER`,
+	// Filled in by backfillShingles.
+	`ALTER TABLE Article ADD COLUMN shingles INTEGER`,
+}
+
+// anonymousEditTable replaces an AnonymousEdit table whose foreign key
+// referenced the non-unique Revision.id, which made every delete from Revision
+// fail. The table was never written to, so it is safe to recreate.
+const anonymousEditTable = `
+	DROP TABLE AnonymousEdit;
+	CREATE TABLE AnonymousEdit (
+		id INT PRIMARY KEY NOT NULL,
+		ip TEXT NOT NULL,
+		revision_id INT NOT NULL,
+		article_id INT NOT NULL,
+		FOREIGN KEY(revision_id, article_id) REFERENCES Revision(id, article_id)
+	);`
+
+func migrate(conn *sqlx.DB) error {
+	var fixed bool
+	err := conn.Get(&fixed, `SELECT COUNT(*) FROM pragma_table_info('AnonymousEdit') WHERE name = 'article_id'`)
+	if err != nil {
+		return err
+	}
+	if !fixed {
+		if _, err := conn.Exec(anonymousEditTable); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range columnMigrations {
+		if _, err := conn.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+	if err := backfillRevisionSizes(conn); err != nil {
+		return err
+	}
+	return backfillShingles(conn)
+}
+
+// backfillRevisionSizes records the size of revisions written before
+// Revision.size was added.
+func backfillRevisionSizes(conn *sqlx.DB) error {
+	revisions := []struct {
+		ID        int    `db:"id"`
+		ArticleID int    `db:"article_id"`
+		Markdown  string `db:"markdown"`
+	}{}
+	err := conn.Select(&revisions, `SELECT id, article_id, markdown FROM Revision WHERE size IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	for _, revision := range revisions {
+		markdown, err := unpack(revision.Markdown)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Exec(`UPDATE Revision SET size = ? WHERE id = ? AND article_id = ?`,
+			len(markdown), revision.ID, revision.ArticleID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillShingles indexes the shingles of articles saved before the Shingle
+// table was added.
+func backfillShingles(conn *sqlx.DB) error {
+	articles := []struct {
+		URL      string `db:"url"`
+		Markdown string `db:"markdown"`
+	}{}
+	err := conn.Select(&articles, `
+		SELECT url, markdown
+			FROM Article JOIN Revision ON Revision.article_id = Article.id
+			WHERE Article.shingles IS NULL
+				AND Revision.id = (SELECT MAX(id) FROM Revision WHERE article_id = Article.id)`)
+	if err != nil {
+		return err
+	}
+
+	for _, article := range articles {
+		markdown, err := unpack(article.Markdown)
+		if err != nil {
+			return err
+		}
+
+		tx, err := conn.Beginx()
+		if err != nil {
+			return err
+		}
+		if err := replaceShingles(tx, article.URL, wiki.ShingleHashes(markdown)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,13 +2,21 @@ PRAGMA foreign_keys = ON;
 
 CREATE TABLE IF NOT EXISTS Article (
     id INTEGER PRIMARY KEY,
-    url TEXT NOT NULL UNIQUE
+    url TEXT NOT NULL UNIQUE,
+    reviewed_at TIMESTAMP,
+    protected_level TEXT NOT NULL DEFAULT 'none',
+    deleted_at TIMESTAMP, -- NULL unless in the recycle bin
+    shingles INTEGER -- how many Shingle rows it has, NULL until indexed
 );
 
 CREATE TABLE IF NOT EXISTS User (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     email TEXT NOT NULL UNIQUE,
-    screenname TEXT NOT NULL UNIQUE
+    screenname TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL DEFAULT 'user',
+    email_verified INTEGER NOT NULL DEFAULT 1,
+    verification_token TEXT,
+    verification_expires TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS Revision (
@@ -22,11 +30,71 @@ CREATE TABLE IF NOT EXISTS Revision (
     created TIMESTAMP NOT NULL,
     previous_id INT NOT NULL,
     comment TEXT,
+    pending INTEGER NOT NULL DEFAULT 0,
+    render_fingerprint TEXT NOT NULL DEFAULT '',
+    -- size is the length of the uncompressed markdown, in bytes.
+    size INTEGER,
     PRIMARY KEY (id, article_id),
     FOREIGN KEY(article_id) REFERENCES Article(id),
     FOREIGN KEY(user_id) REFERENCES User(id)
 );
 
+-- RevisionTag labels revisions of an article as milestones, e.g. "stable".
+-- Each tag names one revision of an article at a time.
+CREATE TABLE IF NOT EXISTS RevisionTag (
+    article_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    revision_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created TIMESTAMP NOT NULL,
+    PRIMARY KEY (article_id, tag),
+    FOREIGN KEY(revision_id, article_id) REFERENCES Revision(id, article_id),
+    FOREIGN KEY(user_id) REFERENCES User(id)
+);
+
+CREATE TABLE IF NOT EXISTS Link (
+    source_id INTEGER NOT NULL,
+    target TEXT NOT NULL,
+    PRIMARY KEY (source_id, target),
+    FOREIGN KEY(source_id) REFERENCES Article(id)
+);
+
+CREATE INDEX IF NOT EXISTS LinkTarget ON Link(target);
+
+CREATE TABLE IF NOT EXISTS Embed (
+    source_id INTEGER NOT NULL,
+    target TEXT NOT NULL,
+    PRIMARY KEY (source_id, target),
+    FOREIGN KEY(source_id) REFERENCES Article(id)
+);
+CREATE INDEX IF NOT EXISTS EmbedTarget ON Embed(target);
+
+CREATE TABLE IF NOT EXISTS Anchor (
+    source_id INTEGER NOT NULL,
+    anchor TEXT NOT NULL,
+    PRIMARY KEY (source_id, anchor),
+    FOREIGN KEY(source_id) REFERENCES Article(id)
+);
+
+CREATE TABLE IF NOT EXISTS Category (
+    source_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    PRIMARY KEY (source_id, name),
+    FOREIGN KEY(source_id) REFERENCES Article(id)
+);
+CREATE INDEX IF NOT EXISTS CategoryName ON Category(name);
+
+-- Shingle indexes the word shingles of each article's current content, as
+-- hashed by wiki.ShingleHashes, so duplicates can be found without reading
+-- every article.
+CREATE TABLE IF NOT EXISTS Shingle (
+    source_id INTEGER NOT NULL,
+    hash INTEGER NOT NULL,
+    PRIMARY KEY (source_id, hash),
+    FOREIGN KEY(source_id) REFERENCES Article(id)
+);
+CREATE INDEX IF NOT EXISTS ShingleHash ON Shingle(hash);
+
 CREATE TABLE IF NOT EXISTS Password (
     user_id INTEGER PRIMARY KEY NOT NULL,
     passwordhash TEXT NOT NULL,
@@ -37,7 +105,8 @@ CREATE TABLE IF NOT EXISTS AnonymousEdit (
     id INT PRIMARY KEY NOT NULL,
     ip TEXT NOT NULL,
     revision_id INT NOT NULL,
-    FOREIGN KEY(revision_id) REFERENCES Revision(id)
+    article_id INT NOT NULL,
+    FOREIGN KEY(revision_id, article_id) REFERENCES Revision(id, article_id)
 );
 
 CREATE TABLE IF NOT EXISTS Preference (
@@ -50,6 +119,23 @@ CREATE TABLE IF NOT EXISTS Preference (
     pref_selection INT -- type 2 
 );
 
+CREATE TABLE IF NOT EXISTS UserPreference (
+    user_id INTEGER NOT NULL,
+    pref_label TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (user_id, pref_label),
+    FOREIGN KEY (user_id) REFERENCES User(id)
+);
+
+-- Watchlist holds the URLs of the pages each user watches. Pages needn't
+-- exist to be watched.
+CREATE TABLE IF NOT EXISTS Watchlist (
+    user_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    PRIMARY KEY (user_id, url),
+    FOREIGN KEY (user_id) REFERENCES User(id)
+);
+
 CREATE TABLE IF NOT EXISTS PreferenceSelection (
     pref_id INT,
     val INT,
@@ -75,4 +161,23 @@ CREATE TABLE IF NOT EXISTS PreferencePage (
     FOREIGN KEY (pref_group) REFERENCES PreferenceGroup(group_id)
 );
 
+-- LoginSession records who logged in to each row of the session store's
+-- sessions table, and from where.
+CREATE TABLE IF NOT EXISTS LoginSession (
+    session_id INTEGER PRIMARY KEY NOT NULL,
+    user_id INTEGER NOT NULL,
+    ip TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES User(id)
+);
+
+CREATE INDEX IF NOT EXISTS LoginSessionUser ON LoginSession(user_id);
+
+CREATE TABLE IF NOT EXISTS Asset (
+    name TEXT PRIMARY KEY NOT NULL,
+    content_type TEXT NOT NULL,
+    data BLOB NOT NULL
+);
+
 INSERT OR IGNORE INTO User(id, email, screenname) VALUES (0, "", "Anonymous");
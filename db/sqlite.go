@@ -20,8 +20,12 @@ type sqliteDb struct {
 	selectArticleByLatestRevisionStmt *sqlx.Stmt
 	selectArticleByRevisionHashStmt   *sqlx.Stmt
 	selectArticleByRevisionIDStmt     *sqlx.Stmt
+	selectRevisionAsOfStmt            *sqlx.Stmt
 	selectUserScreennameStmt          *sqlx.Stmt
 	selectUserScreennameWithHashStmt  *sqlx.Stmt
+
+	// compress gzips the markdown and html of new revisions.
+	compress bool
 }
 
 func Init(config *wiki.Config) (*sqliteDb, error) {
@@ -42,16 +46,23 @@ func Init(config *wiki.Config) (*sqliteDb, error) {
 		return nil, err
 	}
 
-	db := &sqliteDb{conn: conn}
+	if err = migrate(conn); err != nil {
+		return nil, err
+	}
+
+	db := &sqliteDb{conn: conn, compress: config.CompressRevisions}
 	db.SqliteStore, err = sqlitestore.NewSqliteStoreFromConnection(conn, "sessions", "/", config.CookieExpiry, config.CookieSecret)
 	if err != nil {
 		return nil, err
 	}
 
 	// Add prepared statements
-	q := `SELECT url, Revision.id, title, markdown, html, hashval, created, previous_id, comment 
-			FROM Article JOIN Revision ON Article.id = Revision.article_id WHERE Article.url = ?`
-	db.selectArticleByLatestRevisionStmt, err = db.conn.Preparex(q + ` ORDER BY created DESC LIMIT 1`)
+	q := `SELECT url, reviewed_at, Revision.id, title, markdown, html, render_fingerprint, hashval, created, previous_id, comment,
+			User.id AS "creator.id", User.screenname AS "creator.screenname",
+			(SELECT pending FROM Revision AS First WHERE First.article_id = Article.id AND First.id = 1) AS pending
+			FROM Article JOIN Revision ON Article.id = Revision.article_id
+				JOIN User ON Revision.user_id = User.id WHERE Article.url = ? AND Article.deleted_at IS NULL`
+	db.selectArticleByLatestRevisionStmt, err = db.conn.Preparex(q + ` ORDER BY Revision.id DESC LIMIT 1`)
 	if err != nil {
 		return nil, err
 	}
@@ -66,13 +77,26 @@ func Init(config *wiki.Config) (*sqliteDb, error) {
 		return nil, err
 	}
 
-	db.selectUserScreennameStmt, err = db.conn.Preparex(`SELECT id, screenname, email FROM User WHERE screenname = ?`)
+	db.selectRevisionAsOfStmt, err = db.conn.Preparex(q + ` AND Revision.created <= ? ORDER BY Revision.id DESC LIMIT 1`)
+	if err != nil {
+		return nil, err
+	}
+
+	screennameMatch := `screenname = ?`
+	if config.CaseInsensitiveUsernames {
+		screennameMatch += ` COLLATE NOCASE`
+		if _, err = db.conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS UserScreennameNoCase ON User(screenname COLLATE NOCASE)`); err != nil {
+			return nil, errors.Wrap(err, "screennames differing only by case already exist")
+		}
+	}
+
+	db.selectUserScreennameStmt, err = db.conn.Preparex(`SELECT id, screenname, email, role, email_verified FROM User WHERE ` + screennameMatch)
 	if err != nil {
 		return nil, err
 	}
 
 	db.selectUserScreennameWithHashStmt, err = db.conn.Preparex(`
-		SELECT id, screenname, email, passwordhash FROM User JOIN Password ON Password.user_id = User.id WHERE screenname = ?`)
+		SELECT id, screenname, email, role, email_verified, passwordhash FROM User JOIN Password ON Password.user_id = User.id WHERE ` + screennameMatch)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +121,12 @@ func (db *sqliteDb) InsertUser(user *wiki.User) (err error) {
 		}
 	}()
 
-	_, err = tx.Exec(`INSERT INTO User(screenname, email) VALUES (?, ?)`, user.ScreenName, user.Email)
+	// The first registered user administers the wiki.
+	_, err = tx.Exec(`INSERT INTO User(screenname, email, role, email_verified, verification_token, verification_expires)
+		VALUES (?, ?,
+			CASE WHEN EXISTS (SELECT 1 FROM User WHERE id != 0) THEN 'user' ELSE 'admin' END,
+			0, ?, ?)`,
+		user.ScreenName, user.Email, user.VerificationToken, user.VerificationExpires.UTC())
 
 	if err != nil {
 		if err.Error() == "UNIQUE constraint failed: User.screenname" {
@@ -107,16 +136,79 @@ func (db *sqliteDb) InsertUser(user *wiki.User) (err error) {
 		}
 		return
 	}
-	if _, err = tx.Exec(`INSERT INTO Password(user_id, passwordhash) VALUES (last_insert_rowid(), ?)`, user.PasswordHash); err != nil {
+	if err = tx.Get(user, `SELECT id, role, email_verified FROM User WHERE id = last_insert_rowid()`); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`INSERT INTO Password(user_id, passwordhash) VALUES (?, ?)`, user.ID, user.PasswordHash); err != nil {
 		return
 	}
 
 	return nil
 }
 
+// SelectUsers selects every registered user, by screenname.
+func (db *sqliteDb) SelectUsers() ([]*wiki.User, error) {
+	var users []*wiki.User
+	err := db.conn.Select(&users, `SELECT id, screenname, email, role, email_verified FROM User WHERE id != 0 ORDER BY screenname`)
+	return users, err
+}
+
+// UpdateUserRole sets the role of the user with the screenname, or returns
+// sql.ErrNoRows if there is no such user.
+func (db *sqliteDb) UpdateUserRole(screenname string, role wiki.Role) error {
+	result, err := db.conn.Exec(`UPDATE User SET role = ? WHERE screenname = ? AND id != 0`, role, screenname)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateVerificationToken replaces the token that verifies a user's email
+// address, and when it expires.
+func (db *sqliteDb) UpdateVerificationToken(userID int, token string, expires time.Time) error {
+	_, err := db.conn.Exec(`UPDATE User SET verification_token = ?, verification_expires = ? WHERE id = ?`, token, expires.UTC(), userID)
+	return err
+}
+
+// UpdateEmailVerified marks the user with the verification token as verified
+// and returns their screenname, or sql.ErrNoRows if no one has the token or
+// it expired before now. Tokens issued before expiry was recorded have none,
+// and count as expired.
+func (db *sqliteDb) UpdateEmailVerified(token string, now time.Time) (screenname string, err error) {
+	tx, err := db.conn.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	holder := struct {
+		Screenname string       `db:"screenname"`
+		Expires    sql.NullTime `db:"verification_expires"`
+	}{}
+	if err := tx.Get(&holder, `SELECT screenname, verification_expires FROM User WHERE verification_token = ?`, token); err != nil {
+		return "", err
+	}
+	if !holder.Expires.Valid || !now.Before(holder.Expires.Time) {
+		return "", sql.ErrNoRows
+	}
+	screenname = holder.Screenname
+	if _, err := tx.Exec(`UPDATE User SET email_verified = 1, verification_token = NULL WHERE verification_token = ?`, token); err != nil {
+		return "", err
+	}
+	return screenname, tx.Commit()
+}
+
 func (db *sqliteDb) InsertPreference(pref *wiki.Preference) error {
-	_, err := db.conn.Exec(`INSERT OR REPLACE INTO Preference (pref_label, pref_type, help_text, pref_int, pref_text, pref_selection) 
-		VALUES(?, ?, ?, ?, ?, ?)`,
+	// Preference.id is not a rowid alias, so it has to be assigned here.
+	_, err := db.conn.Exec(`INSERT OR REPLACE INTO Preference (id, pref_label, pref_type, help_text, pref_int, pref_text, pref_selection) 
+		VALUES(COALESCE((SELECT id FROM Preference WHERE pref_label = ?), (SELECT IFNULL(MAX(id), 0) + 1 FROM Preference)),
+			?, ?, ?, ?, ?, ?)`,
+		pref.Label,
 		pref.Label,
 		pref.Type,
 		pref.HelpText,
@@ -127,9 +219,186 @@ func (db *sqliteDb) InsertPreference(pref *wiki.Preference) error {
 	return err
 }
 
+// SelectUserPreferences selects the preferences saved by a user, by label.
+func (db *sqliteDb) SelectUserPreferences(userID int) (map[string]string, error) {
+	rows, err := db.conn.Query(`SELECT pref_label, value FROM UserPreference WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]string)
+	for rows.Next() {
+		var label, value string
+		if err := rows.Scan(&label, &value); err != nil {
+			return nil, err
+		}
+		prefs[label] = value
+	}
+	return prefs, rows.Err()
+}
+
+// InsertUserPreferences saves a user's preferences, replacing any previous
+// values with the same labels.
+func (db *sqliteDb) InsertUserPreferences(userID int, prefs map[string]string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for label, value := range prefs {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO UserPreference (user_id, pref_label, value) VALUES (?, ?, ?)`,
+			userID, label, value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *sqliteDb) InsertWatch(userID int, url string) error {
+	_, err := db.conn.Exec(`INSERT OR IGNORE INTO Watchlist (user_id, url) VALUES (?, ?)`, userID, url)
+	return err
+}
+
+func (db *sqliteDb) DeleteWatch(userID int, url string) error {
+	_, err := db.conn.Exec(`DELETE FROM Watchlist WHERE user_id = ? AND url = ?`, userID, url)
+	return err
+}
+
+func (db *sqliteDb) SelectWatchers(url string) ([]*wiki.User, error) {
+	watchers := make([]*wiki.User, 0)
+	err := db.conn.Select(&watchers, `
+		SELECT id, screenname, email, role, email_verified
+			FROM Watchlist JOIN User ON Watchlist.user_id = User.id
+			WHERE Watchlist.url = ? ORDER BY screenname`, url)
+	return watchers, err
+}
+
+func (db *sqliteDb) SelectWatching(userID int, url string) (bool, error) {
+	var watching bool
+	err := db.conn.Get(&watching, `SELECT EXISTS (SELECT 1 FROM Watchlist WHERE user_id = ? AND url = ?)`, userID, url)
+	return watching, err
+}
+
+// InsertRevisionTag tags revision id of the article at url, moving the tag
+// from any other revision of it, or returns sql.ErrNoRows if there is no such
+// revision.
+func (db *sqliteDb) InsertRevisionTag(url string, id int, tag string, userID int) error {
+	result, err := db.conn.Exec(`
+		INSERT OR REPLACE INTO RevisionTag (article_id, tag, revision_id, user_id, created)
+			SELECT Article.id, ?, Revision.id, ?, strftime("%Y-%m-%d %H:%M:%f", "now")
+			FROM Article JOIN Revision ON Article.id = Revision.article_id
+			WHERE Article.url = ? AND Revision.id = ? AND Article.deleted_at IS NULL`, tag, userID, url, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *sqliteDb) SelectRevisionTags(url string) ([]*wiki.RevisionTag, error) {
+	tags := make([]*wiki.RevisionTag, 0)
+	err := db.conn.Select(&tags, `
+		SELECT revision_id, tag FROM RevisionTag JOIN Article ON Article.id = RevisionTag.article_id
+			WHERE Article.url = ? AND Article.deleted_at IS NULL ORDER BY revision_id DESC, tag`, url)
+	return tags, err
+}
+
+func (db *sqliteDb) SelectTaggedRevisionID(url, tag string) (int, error) {
+	var id int
+	err := db.conn.Get(&id, `
+		SELECT revision_id FROM RevisionTag JOIN Article ON Article.id = RevisionTag.article_id
+			WHERE Article.url = ? AND Article.deleted_at IS NULL AND tag = ?`, url, tag)
+	return id, err
+}
+
+// DeleteExpiredSessions deletes the sessions that expired before now.
+func (db *sqliteDb) DeleteExpiredSessions(now time.Time) (int, error) {
+	// The session store writes expires_on with its time zone offset, so the
+	// times are compared as instants rather than as text.
+	result, err := db.conn.Exec(`DELETE FROM sessions WHERE julianday(expires_on) < julianday(?)`, now)
+	if err != nil {
+		return 0, err
+	}
+	// Logging out deletes from sessions too, so this also catches those.
+	if _, err := db.conn.Exec(`DELETE FROM LoginSession WHERE session_id NOT IN (SELECT id FROM sessions)`); err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// InsertLoginSession records who logged in to a session, replacing any
+// earlier record of it.
+func (db *sqliteDb) InsertLoginSession(info *wiki.SessionInfo, userID int) error {
+	_, err := db.conn.Exec(`INSERT OR REPLACE INTO LoginSession (session_id, user_id, ip, user_agent, created) VALUES (?, ?, ?, ?, ?)`,
+		info.ID, userID, info.IPAddress, info.UserAgent, info.Created)
+	return err
+}
+
+// SelectUserSessions selects a user's sessions that haven't expired by now,
+// most recent first.
+func (db *sqliteDb) SelectUserSessions(userID int, now time.Time) ([]*wiki.SessionInfo, error) {
+	var sessions []*wiki.SessionInfo
+	err := db.conn.Select(&sessions, `
+		SELECT LoginSession.session_id, ip, user_agent, created, expires_on
+		FROM LoginSession JOIN sessions ON sessions.id = LoginSession.session_id
+		WHERE user_id = ? AND julianday(expires_on) >= julianday(?)
+		ORDER BY created DESC, LoginSession.session_id DESC`, userID, now)
+	return sessions, err
+}
+
+// DeleteUserSession deletes one of a user's sessions, or returns
+// sql.ErrNoRows if they have no such session.
+func (db *sqliteDb) DeleteUserSession(userID int, sessionID string) error {
+	tx, err := db.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`DELETE FROM LoginSession WHERE session_id = ? AND user_id = ?`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteUserSessions deletes all of a user's sessions.
+func (db *sqliteDb) DeleteUserSessions(userID int) error {
+	tx, err := db.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM LoginSession WHERE user_id = ?)`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM LoginSession WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (db *sqliteDb) SelectPreference(key string) (*wiki.Preference, error) {
 	pref := &wiki.Preference{}
-	err := db.conn.Select(`SELECT * FROM Preference WHERE pref_label = ?`, key)
+	err := db.conn.Get(pref, `SELECT * FROM Preference WHERE pref_label = ?`, key)
 	if err == sql.ErrNoRows {
 		return nil, wiki.ErrGenericNotFound
 	} else if err != nil {
@@ -138,6 +407,23 @@ func (db *sqliteDb) SelectPreference(key string) (*wiki.Preference, error) {
 	return pref, err
 }
 
+func (db *sqliteDb) InsertAsset(asset *wiki.Asset) error {
+	_, err := db.conn.Exec(`INSERT OR REPLACE INTO Asset (name, content_type, data) VALUES (?, ?, ?)`,
+		asset.Name, asset.ContentType, asset.Data)
+	return err
+}
+
+func (db *sqliteDb) SelectAsset(name string) (*wiki.Asset, error) {
+	asset := &wiki.Asset{}
+	err := db.conn.Get(asset, `SELECT name, content_type, data FROM Asset WHERE name = ?`, name)
+	if err == sql.ErrNoRows {
+		return nil, wiki.ErrGenericNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
 func (db *sqliteDb) SelectArticle(url string) (*wiki.Article, error) {
 	article := &wiki.Article{}
 	article.Revision = &wiki.Revision{}
@@ -145,7 +431,7 @@ func (db *sqliteDb) SelectArticle(url string) (*wiki.Article, error) {
 	if err != nil {
 		return nil, err
 	}
-	return article, err
+	return article, unpackRevision(&article.Markdown, &article.HTML)
 }
 
 func (db *sqliteDb) SelectArticleByRevisionHash(url string, hash string) (*wiki.Article, error) {
@@ -157,7 +443,7 @@ func (db *sqliteDb) SelectArticleByRevisionHash(url string, hash string) (*wiki.
 		return nil, err
 	}
 
-	return article, err
+	return article, unpackRevision(&article.Markdown, &article.HTML)
 }
 
 func (db *sqliteDb) SelectArticleByRevisionID(url string, id int) (*wiki.Article, error) {
@@ -169,7 +455,23 @@ func (db *sqliteDb) SelectArticleByRevisionID(url string, id int) (*wiki.Article
 		return nil, err
 	}
 
-	return article, err
+	return article, unpackRevision(&article.Markdown, &article.HTML)
+}
+
+// SelectRevisionAsOf selects the latest revision of url created at or
+// before t.
+func (db *sqliteDb) SelectRevisionAsOf(url string, t time.Time) (*wiki.Article, error) {
+	article := &wiki.Article{}
+	article.Revision = &wiki.Revision{}
+
+	// created is stored by SQLite's strftime in UTC, so the bound must match
+	// its format to compare correctly as text.
+	err := db.selectRevisionAsOfStmt.Get(article, url, t.UTC().Format("2006-01-02 15:04:05.000"))
+	if err != nil {
+		return nil, err
+	}
+
+	return article, unpackRevision(&article.Markdown, &article.HTML)
 }
 
 func (db *sqliteDb) SelectRevision(hash string) (*wiki.Revision, error) {
@@ -192,17 +494,17 @@ func (db *sqliteDb) SelectRevision(hash string) (*wiki.Revision, error) {
 
 func (db *sqliteDb) SelectRevisionHistory(url string) ([]*wiki.Revision, error) {
 	rows, err := db.conn.Queryx(
-		`SELECT Revision.id, title, hashval, created, comment, User.screenname, length(markdown)
+		`SELECT Revision.id, previous_id, title, hashval, created, comment, User.screenname, size
 			FROM Article JOIN Revision ON Article.id = Revision.article_id 
 					     JOIN User ON Revision.user_id = User.id
-			WHERE Article.url = ? ORDER BY created DESC`, url)
+			WHERE Article.url = ? AND Article.deleted_at IS NULL ORDER BY Revision.id DESC`, url)
 	if err != nil {
 		return nil, err
 	}
 	result := struct {
 		Title, Hashval, Comment, Screenname string
-		ID                                  int
-		Length                              int `db:"length(markdown)"`
+		ID, Size                            int
+		PreviousID                          int `db:"previous_id"`
 		Created                             time.Time
 	}{}
 	results := make([]*wiki.Revision, 0)
@@ -216,8 +518,9 @@ func (db *sqliteDb) SelectRevisionHistory(url string) ([]*wiki.Revision, error)
 		rev.Created = result.Created
 		rev.Hash = result.Hashval
 		rev.ID = result.ID
+		rev.PreviousID = result.PreviousID
 		rev.Comment = result.Comment
-		rev.Markdown = fmt.Sprint(result.Length) // dirty hack
+		rev.Markdown = fmt.Sprint(result.Size) // dirty hack
 		rev.Creator.ScreenName = result.Screenname
 		results = append(results, rev)
 	}
@@ -242,6 +545,9 @@ func (db *sqliteDb) SelectUserByScreenname(screenname string, withHash bool) (*w
 
 func (db *sqliteDb) InsertArticle(article *wiki.Article) (err error) {
 	testArticle, insertErr := db.SelectArticle(article.URL)
+	if insertErr != nil && insertErr != sql.ErrNoRows {
+		return insertErr
+	}
 
 	var tx *sqlx.Tx
 	tx, err = db.conn.Beginx()
@@ -266,19 +572,26 @@ func (db *sqliteDb) InsertArticle(article *wiki.Article) (err error) {
 
 	if insertErr == sql.ErrNoRows { // New article.
 		if _, err = tx.Exec(`INSERT INTO Article (url) VALUES (?);`, article.URL); err != nil {
+			// Another writer created the article since it was looked up.
+			if err.Error() == "UNIQUE constraint failed: Article.url" {
+				return wiki.ErrRevisionAlreadyExists
+			}
 			return
 		}
 
-		_, err = tx.Exec(`INSERT INTO Revision (id, title, hashval, markdown, html, article_id, user_id, created, previous_id, comment)
-			VALUES (?, ?, ?, ?, ?, last_insert_rowid(), ?, strftime("%Y-%m-%d %H:%M:%f", "now"), ?, ?)`,
+		_, err = tx.Exec(`INSERT INTO Revision (id, title, hashval, markdown, size, html, render_fingerprint, article_id, user_id, created, previous_id, comment, pending)
+			VALUES (?, ?, ?, ?, ?, ?, ?, last_insert_rowid(), ?, strftime("%Y-%m-%d %H:%M:%f", "now"), ?, ?, ?)`,
 			article.PreviousID+1,
 			article.Title,
 			article.Hash,
-			article.Markdown,
-			article.HTML,
+			db.pack(article.Markdown),
+			len(article.Markdown),
+			db.pack(article.HTML),
+			article.RenderFingerprint,
 			article.Creator.ID,
 			article.PreviousID,
-			article.Comment)
+			article.Comment,
+			article.Pending)
 
 		if err != nil {
 			return
@@ -293,13 +606,15 @@ func (db *sqliteDb) InsertArticle(article *wiki.Article) (err error) {
 
 	} else if insertErr == nil && testArticle != nil { // New revision to article
 
-		_, err = tx.Exec(`INSERT INTO Revision (id, title, hashval, markdown, html, article_id, user_id, created, previous_id, comment)
-			VALUES (?, ?, ?, ?, ?, (SELECT Article.id FROM Article WHERE url = ?), ?, strftime("%Y-%m-%d %H:%M:%f", "now"), ?, ?)`,
+		_, err = tx.Exec(`INSERT INTO Revision (id, title, hashval, markdown, size, html, render_fingerprint, article_id, user_id, created, previous_id, comment)
+			VALUES (?, ?, ?, ?, ?, ?, ?, (SELECT Article.id FROM Article WHERE url = ?), ?, strftime("%Y-%m-%d %H:%M:%f", "now"), ?, ?)`,
 			article.PreviousID+1,
 			article.Title,
 			article.Hash,
-			article.Markdown,
-			article.HTML,
+			db.pack(article.Markdown),
+			len(article.Markdown),
+			db.pack(article.HTML),
+			article.RenderFingerprint,
 			article.URL,
 			article.Creator.ID,
 			article.PreviousID,
@@ -309,6 +624,7 @@ func (db *sqliteDb) InsertArticle(article *wiki.Article) (err error) {
 			if err.Error() == "UNIQUE constraint failed: Revision.id, Revision.article_id" {
 				return wiki.ErrRevisionAlreadyExists
 			}
+			return
 		}
 
 		// if article.Creator.ID == 0 { // Anonymous
@@ -320,6 +636,620 @@ func (db *sqliteDb) InsertArticle(article *wiki.Article) (err error) {
 		// }
 	}
 
+	if article.ReviewedAt.Valid {
+		if _, err = tx.Exec(`UPDATE Article SET reviewed_at = ? WHERE url = ?`, article.ReviewedAt.Time.UTC(), article.URL); err != nil {
+			return
+		}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM Link WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, article.URL); err != nil {
+		return
+	}
+	for _, target := range article.Links {
+		if _, err = tx.Exec(`INSERT INTO Link (source_id, target) VALUES ((SELECT id FROM Article WHERE url = ?), ?)`,
+			article.URL, target); err != nil {
+			return
+		}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM Embed WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, article.URL); err != nil {
+		return
+	}
+	for _, target := range article.Embeds {
+		if _, err = tx.Exec(`INSERT INTO Embed (source_id, target) VALUES ((SELECT id FROM Article WHERE url = ?), ?)`,
+			article.URL, target); err != nil {
+			return
+		}
+	}
+
+	if err = replaceAnchors(tx, article.URL, article.Anchors); err != nil {
+		return
+	}
+
+	if err = replaceShingles(tx, article.URL, article.Shingles); err != nil {
+		return
+	}
+
+	if _, err = tx.Exec(`DELETE FROM Category WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, article.URL); err != nil {
+		return
+	}
+	for _, name := range article.Categories {
+		if _, err = tx.Exec(`INSERT INTO Category (source_id, name) VALUES ((SELECT id FROM Article WHERE url = ?), ?)`,
+			article.URL, name); err != nil {
+			return
+		}
+	}
+
 	// Success!
+	article.ID = article.PreviousID + 1
+	return nil
+}
+
+func (db *sqliteDb) SelectPendingArticles() ([]*wiki.Article, error) {
+	rows, err := db.conn.Queryx(`
+		SELECT url, title, created, User.screenname
+			FROM Article JOIN Revision ON Article.id = Revision.article_id
+					     JOIN User ON Revision.user_id = User.id
+			WHERE Revision.id = 1 AND Revision.pending AND Article.deleted_at IS NULL ORDER BY created`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := struct {
+		URL, Title, Screenname string
+		Created                time.Time
+	}{}
+	articles := make([]*wiki.Article, 0)
+	for rows.Next() {
+		if err := rows.StructScan(&result); err != nil {
+			return nil, err
+		}
+		article := wiki.NewArticle(result.URL, result.Title, "")
+		article.Pending = true
+		article.Created = result.Created
+		article.Creator = &wiki.User{ScreenName: result.Screenname}
+		articles = append(articles, article)
+	}
+	return articles, rows.Err()
+}
+
+func (db *sqliteDb) ApproveArticle(url string) error {
+	_, err := db.conn.Exec(`UPDATE Revision SET pending = 0
+		WHERE article_id = (SELECT id FROM Article WHERE url = ?)`, url)
+	return err
+}
+
+func (db *sqliteDb) DeleteArticle(url string) (err error) {
+	var tx *sqlx.Tx
+	tx, err = db.conn.Beginx()
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Println(rbErr)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM RevisionTag WHERE article_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Revision WHERE article_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Link WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Embed WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Category WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Anchor WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Shingle WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return
+	}
+	_, err = tx.Exec(`DELETE FROM Article WHERE url = ?`, url)
+	return
+}
+
+// SoftDeleteArticle moves the article at url to the recycle bin, or returns
+// sql.ErrNoRows if there is no such article outside it.
+func (db *sqliteDb) SoftDeleteArticle(url string) error {
+	result, err := db.conn.Exec(`UPDATE Article SET deleted_at = strftime("%Y-%m-%d %H:%M:%f", "now")
+		WHERE url = ? AND deleted_at IS NULL`, url)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RestoreArticle takes the article at url out of the recycle bin, or returns
+// sql.ErrNoRows if it isn't there.
+func (db *sqliteDb) RestoreArticle(url string) error {
+	result, err := db.conn.Exec(`UPDATE Article SET deleted_at = NULL WHERE url = ? AND deleted_at IS NOT NULL`, url)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *sqliteDb) ArticleDeleted(url string) (bool, error) {
+	var deleted bool
+	err := db.conn.Get(&deleted, `SELECT EXISTS (SELECT 1 FROM Article WHERE url = ? AND deleted_at IS NOT NULL)`, url)
+	return deleted, err
+}
+
+func (db *sqliteDb) SelectDeletedArticles() ([]*wiki.DeletedArticle, error) {
+	articles := make([]*wiki.DeletedArticle, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title, deleted_at
+			FROM Article
+			WHERE deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC, url`)
+	return articles, err
+}
+
+// MoveArticle changes the URL of the article at oldURL, keeping its
+// revisions, and points links to oldURL at newURL.
+func (db *sqliteDb) MoveArticle(oldURL, newURL string) (err error) {
+	var tx *sqlx.Tx
+	tx, err = db.conn.Beginx()
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Println(rbErr)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`UPDATE Article SET url = ? WHERE url = ?`, newURL, oldURL); err != nil {
+		return
+	}
+	// An article linking to both URLs keeps a single link.
+	if _, err = tx.Exec(`UPDATE OR IGNORE Link SET target = ? WHERE target = ?`, newURL, oldURL); err != nil {
+		return
+	}
+	if _, err = tx.Exec(`DELETE FROM Link WHERE target = ?`, oldURL); err != nil {
+		return
+	}
+	// Watchers of the article go on watching it, as well as the redirect left
+	// behind.
+	_, err = tx.Exec(`INSERT OR IGNORE INTO Watchlist (user_id, url) SELECT user_id, ? FROM Watchlist WHERE url = ?`, newURL, oldURL)
+	return
+}
+
+func (db *sqliteDb) SelectBacklinks(target string) ([]*wiki.ArticleSummary, error) {
+	backlinks := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&backlinks, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Link JOIN Article ON Link.source_id = Article.id
+			WHERE Link.target = ? AND Article.deleted_at IS NULL ORDER BY url`, target)
+	return backlinks, err
+}
+
+func (db *sqliteDb) SelectBacklinksPaged(target string, limit, offset int) ([]*wiki.ArticleSummary, int, error) {
+	var total int
+	err := db.conn.Get(&total, `
+		SELECT COUNT(*) FROM Link JOIN Article ON Link.source_id = Article.id
+			WHERE Link.target = ? AND Article.deleted_at IS NULL`, target)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	backlinks := make([]*wiki.ArticleSummary, 0)
+	err = db.conn.Select(&backlinks, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Link JOIN Article ON Link.source_id = Article.id
+			WHERE Link.target = ? AND Article.deleted_at IS NULL ORDER BY url LIMIT ? OFFSET ?`, target, limit, offset)
+	return backlinks, total, err
+}
+
+func (db *sqliteDb) SelectOrphanedArticles() ([]*wiki.ArticleSummary, error) {
+	articles := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Article
+			WHERE NOT EXISTS (SELECT 1 FROM Link WHERE Link.target = Article.url AND Link.source_id != Article.id)
+				AND NOT EXISTS (SELECT 1 FROM Embed WHERE Embed.target = Article.url AND Embed.source_id != Article.id)
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND Article.deleted_at IS NULL
+			ORDER BY url`)
+	return articles, err
+}
+
+func (db *sqliteDb) SelectDeadEndArticles() ([]*wiki.ArticleSummary, error) {
+	articles := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Article
+			WHERE NOT EXISTS (SELECT 1 FROM Link WHERE Link.source_id = Article.id AND Link.target != Article.url)
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND Article.deleted_at IS NULL
+			ORDER BY url`)
+	return articles, err
+}
+
+func (db *sqliteDb) SelectWikiStatistics() (*wiki.Stats, error) {
+	stats := &wiki.Stats{}
+	err := db.conn.Get(stats, `
+		SELECT
+			(SELECT COUNT(*) FROM Article
+				WHERE NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+					AND deleted_at IS NULL) AS articles,
+			(SELECT COUNT(*) FROM Revision) AS revisions,
+			(SELECT COUNT(*) FROM User WHERE id != 0) AS users,
+			(SELECT COUNT(*) FROM Revision WHERE user_id != 0) AS registered_edits,
+			(SELECT COUNT(*) FROM Revision WHERE user_id = 0) AS anonymous_edits,
+			(SELECT COUNT(*) FROM Link) AS links`)
+	return stats, err
+}
+
+func (db *sqliteDb) SelectWantedPages() ([]wiki.WantedPage, error) {
+	wanted := make([]wiki.WantedPage, 0)
+	err := db.conn.Select(&wanted, `
+		SELECT target, COUNT(*) AS refs FROM Link
+			WHERE target NOT IN (SELECT url FROM Article WHERE deleted_at IS NULL)
+				AND source_id IN (SELECT id FROM Article WHERE deleted_at IS NULL)
+				AND substr(target, 1, length(?)) != ?
+			GROUP BY target
+			ORDER BY refs DESC, target`, wiki.SpecialNamespace, wiki.SpecialNamespace)
+	return wanted, err
+}
+
+func (db *sqliteDb) SelectOutboundDeadlinks(url string) ([]string, error) {
+	targets := make([]string, 0)
+	err := db.conn.Select(&targets, `
+		SELECT target FROM Link
+			WHERE source_id = (SELECT id FROM Article WHERE url = ?)
+				AND target NOT IN (SELECT url FROM Article WHERE deleted_at IS NULL)
+			ORDER BY target`, url)
+	return targets, err
+}
+
+func (db *sqliteDb) SelectCategories() ([]*wiki.Category, error) {
+	categories := make([]*wiki.Category, 0)
+	err := db.conn.Select(&categories, `
+		SELECT name, COUNT(*) AS members FROM Category JOIN Article ON Category.source_id = Article.id
+			WHERE Article.deleted_at IS NULL GROUP BY name ORDER BY name`)
+	return categories, err
+}
+
+func (db *sqliteDb) SelectCategoryMembers(name string) ([]*wiki.ArticleSummary, error) {
+	members := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&members, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Category JOIN Article ON Category.source_id = Article.id
+			WHERE Category.name = ? AND Article.deleted_at IS NULL ORDER BY url`, name)
+	return members, err
+}
+
+func (db *sqliteDb) SelectArticleCategories(url string) ([]*wiki.Category, error) {
+	categories := make([]*wiki.Category, 0)
+	err := db.conn.Select(&categories, `
+		SELECT name, (SELECT COUNT(*) FROM Category AS Member JOIN Article ON Member.source_id = Article.id
+					WHERE Member.name = Category.name AND Article.deleted_at IS NULL) AS members
+			FROM Category
+			WHERE source_id = (SELECT id FROM Article WHERE url = ?)
+			ORDER BY name`, url)
+	return categories, err
+}
+
+func (db *sqliteDb) SelectEmbedders(target string) ([]*wiki.ArticleSummary, error) {
+	embedders := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&embedders, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Embed JOIN Article ON Embed.source_id = Article.id
+			WHERE Embed.target = ? AND Article.deleted_at IS NULL ORDER BY url`, target)
+	return embedders, err
+}
+
+func (db *sqliteDb) SelectContributors(url string) ([]*wiki.Contributor, error) {
+	contributors := make([]*wiki.Contributor, 0)
+	err := db.conn.Select(&contributors, `
+		SELECT User.screenname, COUNT(*) AS edits
+			FROM Article JOIN Revision ON Article.id = Revision.article_id
+					     JOIN User ON Revision.user_id = User.id
+			WHERE Article.url = ?
+			GROUP BY User.id ORDER BY edits DESC, User.screenname`, url)
+	return contributors, err
+}
+
+func (db *sqliteDb) SelectStaleArticles(olderThan time.Duration) ([]*wiki.Article, error) {
+	rows, err := db.conn.Queryx(`
+		SELECT url, reviewed_at, title
+			FROM Article JOIN Revision ON Article.id = Revision.article_id
+			WHERE Revision.id = (SELECT MAX(id) FROM Revision WHERE article_id = Article.id)
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND (reviewed_at IS NULL OR reviewed_at < ?)
+				AND Article.deleted_at IS NULL
+			ORDER BY reviewed_at, url`, time.Now().Add(-olderThan).UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := struct {
+		URL, Title string
+		ReviewedAt sql.NullTime `db:"reviewed_at"`
+	}{}
+	articles := make([]*wiki.Article, 0)
+	for rows.Next() {
+		if err := rows.StructScan(&result); err != nil {
+			return nil, err
+		}
+		article := wiki.NewArticle(result.URL, result.Title, "")
+		article.ReviewedAt = result.ReviewedAt
+		articles = append(articles, article)
+	}
+	return articles, rows.Err()
+}
+
+func (db *sqliteDb) UpdateArticleReviewed(url string, reviewed time.Time) error {
+	_, err := db.conn.Exec(`UPDATE Article SET reviewed_at = ? WHERE url = ?`, reviewed.UTC(), url)
+	return err
+}
+
+func (db *sqliteDb) SelectProtection(url string) (wiki.ProtectionLevel, error) {
+	var level wiki.ProtectionLevel
+	err := db.conn.Get(&level, `SELECT protected_level FROM Article WHERE url = ?`, url)
+	return level, err
+}
+
+func (db *sqliteDb) UpdateProtection(url string, level wiki.ProtectionLevel) error {
+	_, err := db.conn.Exec(`UPDATE Article SET protected_level = ? WHERE url = ?`, level, url)
+	return err
+}
+
+func (db *sqliteDb) SelectArticleSummariesByLength(length, minLength, maxLength, limit int) ([]*wiki.ArticleSummary, error) {
+	articles := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Article
+			WHERE length(url) BETWEEN ? AND ?
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND Article.deleted_at IS NULL
+			ORDER BY abs(length(url) - ?), url
+			LIMIT ?`, minLength, maxLength, length, limit)
+	return articles, err
+}
+
+func (db *sqliteDb) SelectArticleURLs() ([]string, error) {
+	urls := make([]string, 0)
+	err := db.conn.Select(&urls, `SELECT url FROM Article WHERE deleted_at IS NULL ORDER BY url`)
+	return urls, err
+}
+
+func (db *sqliteDb) SelectArticleSummariesFrom(from, prefix, exclude string, limit int) ([]*wiki.ArticleSummary, error) {
+	articles := make([]*wiki.ArticleSummary, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title
+			FROM Article
+			WHERE url >= ?
+				AND substr(url, 1, length(?)) = ?
+				AND (? = '' OR substr(url, 1, length(?)) != ?)
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND Article.deleted_at IS NULL
+			ORDER BY url
+			LIMIT ?`, from, prefix, prefix, exclude, exclude, exclude, limit)
+	return articles, err
+}
+
+// SelectRecentRevisions selects up to limit revisions of any article, newest
+// first, created before the given time, if it isn't zero.
+func (db *sqliteDb) SelectRecentRevisions(limit int, before time.Time) ([]*wiki.RevisionSummary, error) {
+	return db.selectRecentRevisions("", nil, limit, before)
+}
+
+// SelectWatchedRevisions is SelectRecentRevisions limited to the articles on
+// the user's watchlist.
+func (db *sqliteDb) SelectWatchedRevisions(userID, limit int, before time.Time) ([]*wiki.RevisionSummary, error) {
+	return db.selectRecentRevisions(`JOIN Watchlist ON Watchlist.url = Article.url AND Watchlist.user_id = ?`,
+		[]interface{}{userID}, limit, before)
+}
+
+// selectRecentRevisions selects recent revisions as SelectRecentRevisions
+// does, with join, whose placeholders take joinArgs, narrowing them down.
+func (db *sqliteDb) selectRecentRevisions(join string, joinArgs []interface{}, limit int, before time.Time) ([]*wiki.RevisionSummary, error) {
+	bound := ""
+	if !before.IsZero() {
+		bound = before.UTC().Format("2006-01-02 15:04:05.000")
+	}
+	args := append(append([]interface{}{}, joinArgs...), bound, bound, limit)
+	rows, err := db.conn.Queryx(`
+		SELECT Article.url, Revision.id, Revision.previous_id, Revision.title, screenname,
+				COALESCE(Revision.comment, '') AS comment, Revision.created, Revision.pending,
+				Revision.size, COALESCE(Previous.size, 0) AS previous_size
+			FROM Revision JOIN Article ON Article.id = Revision.article_id
+				JOIN User ON User.id = Revision.user_id
+				`+join+`
+				LEFT JOIN Revision AS Previous
+					ON Previous.article_id = Revision.article_id AND Previous.id = Revision.previous_id
+			WHERE Article.deleted_at IS NULL AND (? = '' OR Revision.created < ?)
+			ORDER BY Revision.created DESC, Revision.id DESC
+			LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*wiki.RevisionSummary, 0)
+	for rows.Next() {
+		rev := &wiki.RevisionSummary{}
+		if err := rows.StructScan(rev); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// SelectArticleActivity selects the current markdown of every article not
+// awaiting moderation, when it was last edited and how many revisions it has
+// had since the given time.
+func (db *sqliteDb) SelectArticleActivity(since time.Time) ([]*wiki.ArticleActivity, error) {
+	articles := make([]*wiki.ArticleActivity, 0)
+	err := db.conn.Select(&articles, `
+		SELECT url, markdown, created AS last_modified,
+				(SELECT COUNT(*) FROM Revision AS Recent
+					WHERE Recent.article_id = Article.id AND Recent.created >= ?) AS recent_edits
+			FROM Article JOIN Revision ON Revision.article_id = Article.id
+			WHERE Revision.id = (SELECT MAX(id) FROM Revision WHERE article_id = Article.id)
+				AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+				AND Article.deleted_at IS NULL
+			ORDER BY url`, since.UTC().Format("2006-01-02 15:04:05.000"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, article := range articles {
+		if article.Markdown, err = unpack(article.Markdown); err != nil {
+			return nil, err
+		}
+	}
+	return articles, nil
+}
+
+func (db *sqliteDb) ArticleExists(url string) (bool, error) {
+	var exists bool
+	err := db.conn.Get(&exists, `SELECT EXISTS (SELECT 1 FROM Article WHERE url = ? AND deleted_at IS NULL)`, url)
+	return exists, err
+}
+
+func (db *sqliteDb) CountRevisionsWithHTML(html string) (int, error) {
+	// Match the column whether or not it was compressed when written.
+	var n int
+	err := db.conn.Get(&n, `SELECT COUNT(*) FROM Revision WHERE html IN (?, ?)`, html, db.pack(html))
+	return n, err
+}
+
+func (db *sqliteDb) SelectQueuedRevisions() ([]*wiki.QueuedRevision, error) {
+	queued := make([]*wiki.QueuedRevision, 0)
+	err := db.conn.Select(&queued, `
+		SELECT Article.url AS url, Revision.id AS id FROM Revision
+			JOIN Article ON Article.id = Revision.article_id
+			WHERE Revision.html IN (?, ?) AND Article.deleted_at IS NULL
+			ORDER BY Revision.created, Revision.id`, wiki.RenderPendingHTML, db.pack(wiki.RenderPendingHTML))
+	return queued, err
+}
+
+// replaceAnchors records anchors as the section ids of the article at url.
+func replaceAnchors(tx *sqlx.Tx, url string, anchors []string) error {
+	if _, err := tx.Exec(`DELETE FROM Anchor WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return err
+	}
+	for _, anchor := range anchors {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO Anchor (source_id, anchor) VALUES ((SELECT id FROM Article WHERE url = ?), ?)`,
+			url, anchor); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// replaceShingles indexes hashes as the shingles of the article at url.
+func replaceShingles(tx *sqlx.Tx, url string, hashes []int64) error {
+	if _, err := tx.Exec(`DELETE FROM Shingle WHERE source_id = (SELECT id FROM Article WHERE url = ?)`, url); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO Shingle (source_id, hash) VALUES ((SELECT id FROM Article WHERE url = ?), ?)`,
+			url, hash); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`UPDATE Article SET shingles = (SELECT COUNT(*) FROM Shingle WHERE source_id = Article.id) WHERE url = ?`, url)
+	return err
+}
+
+// shingleBatchSize bounds how many hashes SelectShingleOverlaps binds to a
+// single query.
+const shingleBatchSize = 500
+
+func (db *sqliteDb) SelectShingleOverlaps(hashes []int64) ([]*wiki.ShingleOverlap, error) {
+	overlaps := make([]*wiki.ShingleOverlap, 0)
+	byURL := make(map[string]*wiki.ShingleOverlap)
+	for start := 0; start < len(hashes); start += shingleBatchSize {
+		end := start + shingleBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batch := hashes[start:end]
+		query, args, err := sqlx.In(`
+			SELECT url, (SELECT title FROM Revision WHERE article_id = Article.id ORDER BY id DESC LIMIT 1) AS title,
+					Article.shingles, COUNT(*) AS shared
+				FROM Shingle JOIN Article ON Shingle.source_id = Article.id
+				WHERE Shingle.hash IN (?)
+					AND NOT (SELECT pending FROM Revision WHERE article_id = Article.id AND id = 1)
+					AND Article.deleted_at IS NULL
+				GROUP BY Article.id`, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		found := make([]*wiki.ShingleOverlap, 0)
+		if err := db.conn.Select(&found, db.conn.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		for _, overlap := range found {
+			if seen, ok := byURL[overlap.URL]; ok {
+				seen.Shared += overlap.Shared
+				continue
+			}
+			byURL[overlap.URL] = overlap
+			overlaps = append(overlaps, overlap)
+		}
+	}
+	return overlaps, nil
+}
+
+func (db *sqliteDb) UpdateAnchors(url string, anchors []string) error {
+	tx, err := db.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	if err = replaceAnchors(tx, url, anchors); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *sqliteDb) SelectAnchors(url string) ([]string, error) {
+	anchors := make([]string, 0)
+	err := db.conn.Select(&anchors, `
+		SELECT anchor FROM Anchor JOIN Article ON Anchor.source_id = Article.id
+			WHERE Article.url = ? ORDER BY anchor`, url)
+	return anchors, err
+}
+
+func (db *sqliteDb) UpdateRevisionHTML(url string, id int, html, fingerprint string) error {
+	_, err := db.conn.Exec(`UPDATE Revision SET html = ?, render_fingerprint = ?
+		WHERE id = ? AND article_id = (SELECT id FROM Article WHERE url = ?)`, db.pack(html), fingerprint, id, url)
+	return err
+}
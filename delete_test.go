@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestDeleteArticle(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Doomed", "Soon gone.", 0)
+	mustPostArticle(t, a, "Doomed", "Soon gone, really.", 1)
+	mustPostArticle(t, a, "Linker", "See [[Doomed]].", 0)
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Doomed?delete", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected a non-admin to be forbidden, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Doomed?delete", nil, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected an anonymous user to be forbidden, got %d", rr.Code)
+	}
+
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Doomed?delete", nil, login(t, a, "admin")))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Fatalf("expected a redirect home, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	if _, err := a.GetArticle("Doomed"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected the article to be gone, got %v", err)
+	}
+	if _, err := a.GetArticleByRevisionID("Doomed", 1); err != wiki.ErrRevisionNotFound {
+		t.Errorf("expected its revisions to be gone, got %v", err)
+	}
+	if backlinks, err := a.GetBacklinks("Doomed"); err != nil || len(backlinks) != 1 || backlinks[0].URL != "Linker" {
+		t.Errorf("expected only Linker's link to Doomed to remain, got %v (%v)", backlinks, err)
+	}
+	waitForHTML(t, a, "Linker", func(html string) bool { return strings.Contains(html, "pw-deadlink") })
+
+	var flash *http.Cookie
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == flashCookie {
+			flash = cookie
+		}
+	}
+	if flash == nil {
+		t.Fatal("expected a flash message")
+	}
+	home := serve(a, newRequest(http.MethodGet, "/", nil, flash))
+	if !strings.Contains(home.Body.String(), `id="flash"`) || !strings.Contains(home.Body.String(), "Deleted Doomed.") {
+		t.Errorf("expected the flash message on the home page, got:\n%s", home.Body)
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Doomed?delete", nil, login(t, a, "admin"))); rr.Code != http.StatusNotFound {
+		t.Errorf("expected deleting a missing article to 404, got %d", rr.Code)
+	}
+}
+
+func TestRecycleBin(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Binned", "In the bin.", 0)
+	mustPostArticle(t, a, "Linker", "See [[Binned]].", 0)
+	admin := login(t, a, "admin")
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Binned?delete", nil, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after deleting, got %d", rr.Code)
+	}
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Binned", nil, admin))
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "This article has been deleted.") {
+		t.Errorf("expected a deleted article to 404 as deleted, got %d:\n%s", rr.Code, rr.Body)
+	}
+	waitForHTML(t, a, "Linker", func(html string) bool { return strings.Contains(html, "pw-deadlink") })
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:RecycleBin", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the recycle bin to be for admins only, got %d", rr.Code)
+	}
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Special:RecycleBin", nil, admin))
+	if !strings.Contains(rr.Body.String(), `<input type="hidden" name="url" value="Binned">`) {
+		t.Errorf("expected the deleted article in the recycle bin, got:\n%s", rr.Body)
+	}
+
+	article := wiki.NewArticle("Binned", "Binned", "A new article.")
+	article.Creator = wiki.AnonymousUser()
+	if err := a.PostArticle(article); err != wiki.ErrArticleDeleted {
+		t.Errorf("expected creating an article over a deleted one to fail, got %v", err)
+	}
+
+	form := url.Values{"url": {"Binned"}, "action": {"restore"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:RecycleBin", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after restoring, got %d", rr.Code)
+	}
+	if restored, err := a.GetArticle("Binned"); err != nil || restored.Markdown != "In the bin." {
+		t.Errorf("expected the article to be restored, got %v", err)
+	}
+	waitForHTML(t, a, "Linker", func(html string) bool { return !strings.Contains(html, "pw-deadlink") })
+
+	if err := a.SoftDeleteArticle("Binned"); err != nil {
+		t.Fatal(err)
+	}
+	form.Set("action", "purge")
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:RecycleBin", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after purging, got %d", rr.Code)
+	}
+	if deleted, err := a.GetDeletedArticles(); err != nil || len(deleted) != 0 {
+		t.Errorf("expected the recycle bin to be empty, got %v (%v)", deleted, err)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:RecycleBin", form, admin)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected purging an article not in the recycle bin to 404, got %d", rr.Code)
+	}
+	mustPostArticle(t, a, "Binned", "A new article.", 0)
+}
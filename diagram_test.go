@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// fakeDotSVG is trimmed Graphviz output, with some hostile additions.
+const fakeDotSVG = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg width="62pt" height="116pt" viewBox="0.00 0.00 62.00 116.00" xmlns="http://www.w3.org/2000/svg" onload="alert(1)">
+<g id="graph0" class="graph" transform="scale(1 1) rotate(0) translate(4 112)">
+<title>G</title>
+<script>alert(2)</script>
+<g id="node1" class="node">
+<ellipse fill="none" stroke="black" cx="27" cy="-90" rx="27" ry="18"/>
+<text text-anchor="middle" x="27" y="-86.3" font-family="Times,serif" font-size="14.00" onclick="alert(3)">a</text>
+</g>
+<path fill="url(javascript:alert(4))" stroke="black" d="M27,-71.7C27,-63.98 27,-54.71 27,-46.11"/>
+<foreignObject><iframe src="javascript:alert(5)"></iframe></foreignObject>
+</g>
+</svg>
+`
+
+// fakeDot writes a script standing in for dot, which prints fakeDotSVG, or
+// fails for input containing "error". Each run is counted in the returned
+// file.
+func fakeDot(t *testing.T) (binPath, runs string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	binPath = filepath.Join(dir, "dot")
+	runs = filepath.Join(dir, "runs")
+	if err := os.WriteFile(filepath.Join(dir, "out.svg"), []byte(fakeDotSVG), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho run >> " + runs + "\n" +
+		"if grep -q error; then echo 'Error: syntax error in line 1 near <x>' >&2; exit 1; fi\n" +
+		"cat " + filepath.Join(dir, "out.svg") + "\n"
+	if err := os.WriteFile(binPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binPath, runs
+}
+
+func TestDiagrams(t *testing.T) {
+	binPath, runs := fakeDot(t)
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.Diagrams = true
+		c.DotPath = binPath
+	})
+
+	md := "```graphviz\ndigraph G { a -> b }\n```\n\n```go\ndigraph := 1\n```\n"
+	article := mustPostArticle(t, a, "Graph", md, 0)
+	for _, want := range []string{`<div class="pw-diagram"><svg width="62pt"`, `viewbox="0.00 0.00 62.00 116.00"`, `<ellipse fill="none" stroke="black"`, `>a</text>`, `<pre><code>digraph := 1`} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s:\n%s", want, article.HTML)
+		}
+	}
+	for _, unwanted := range []string{"<?xml", "DOCTYPE", "alert", "<script", "<title", "foreignObject", "<iframe", "xmlns"} {
+		if strings.Contains(article.HTML, unwanted) {
+			t.Errorf("expected %s to be stripped:\n%s", unwanted, article.HTML)
+		}
+	}
+
+	mustPostArticle(t, a, "Graph", md+"\nMore text.\n", article.ID)
+	if out, err := os.ReadFile(runs); err != nil || strings.Count(string(out), "run") != 1 {
+		t.Errorf("expected an unchanged diagram to be drawn once, got %q, %v", out, err)
+	}
+
+	article = mustPostArticle(t, a, "Broken", "```dot\nerror\n```\n", 0)
+	if !strings.Contains(article.HTML, `<pre class="pw-diagram-error">dot: Error: syntax error in line 1 near &lt;x&gt;</pre>`) {
+		t.Errorf("expected dot's error to be shown:\n%s", article.HTML)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Graph", md, 0)
+	if !strings.Contains(article.HTML, `<pre><code>digraph G { a -&gt; b }`) {
+		t.Errorf("expected diagrams to be shown as code unless configured:\n%s", article.HTML)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// renderDiff returns the character-level diff of original and new as HTML.
+func renderDiff(original, new string) string {
+	dmp := diffmatchpatch.New()
+	return diffHTML(dmp.DiffMain(original, new, false))
+}
+
+// renderWordDiff returns the word-level diff of original and new as HTML.
+// Changed words are marked whole, rather than the characters that differ.
+func renderWordDiff(original, new string) string {
+	return diffHTML(diffTokens(original, new, splitWords))
+}
+
+// Styles of inserted and deleted text. SecurityHeadersMiddleware allows them
+// by hash, so they must not vary.
+const (
+	diffInsertStyle = "background:#e6ffe6;"
+	diffDeleteStyle = "background:#ffe6e6;"
+)
+
+// diffHTML formats diffs as HTML.
+func diffHTML(diffs []diffmatchpatch.Diff) string {
+	var buff bytes.Buffer
+	for _, diff := range diffs {
+		text := html.EscapeString(diff.Text)
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			_, _ = buff.WriteString(`<ins style="` + diffInsertStyle + `">`)
+			_, _ = buff.WriteString(text)
+			_, _ = buff.WriteString("</ins>")
+		case diffmatchpatch.DiffDelete:
+			_, _ = buff.WriteString(`<del style="` + diffDeleteStyle + `">`)
+			_, _ = buff.WriteString(text)
+			_, _ = buff.WriteString("</del>")
+		case diffmatchpatch.DiffEqual:
+			_, _ = buff.WriteString("<span>")
+			_, _ = buff.WriteString(text)
+			_, _ = buff.WriteString("</span>")
+		}
+	}
+	return buff.String()
+}
+
+// renderContextDiff returns a line-level diff of original and new as HTML,
+// keeping context unchanged lines around each change. Longer runs of
+// unchanged lines are collapsed into a marker linking to expandURL. With
+// words, lines that were replaced are diffed a word at a time.
+func renderContextDiff(original, new string, context int, expandURL string, words bool) string {
+	diffs := diffLines(original, new)
+
+	var buff bytes.Buffer
+	for i := 0; i < len(diffs); i++ {
+		diff := diffs[i]
+		if words && diff.Type == diffmatchpatch.DiffDelete &&
+			i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert {
+			_, _ = buff.WriteString(diffHTML(diffTokens(diff.Text, diffs[i+1].Text, splitWords)))
+			i++
+			continue
+		}
+
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			_, _ = buff.WriteString(`<ins style="` + diffInsertStyle + `">`)
+			_, _ = buff.WriteString(html.EscapeString(diff.Text))
+			_, _ = buff.WriteString("</ins>")
+		case diffmatchpatch.DiffDelete:
+			_, _ = buff.WriteString(`<del style="` + diffDeleteStyle + `">`)
+			_, _ = buff.WriteString(html.EscapeString(diff.Text))
+			_, _ = buff.WriteString("</del>")
+		case diffmatchpatch.DiffEqual:
+			equal := strings.SplitAfter(diff.Text, "\n")
+			if equal[len(equal)-1] == "" {
+				equal = equal[:len(equal)-1]
+			}
+
+			// Context is only kept on the sides that border a change.
+			head, tail := context, context
+			if i == 0 {
+				head = 0
+			}
+			if i == len(diffs)-1 {
+				tail = 0
+			}
+
+			_, _ = buff.WriteString("<span>")
+			if len(equal) <= head+tail {
+				_, _ = buff.WriteString(html.EscapeString(diff.Text))
+			} else {
+				_, _ = buff.WriteString(html.EscapeString(strings.Join(equal[:head], "")))
+				fmt.Fprintf(&buff, "<a class=\"pw-diff-collapsed\" href=\"%s\">… %d unchanged lines …</a>\n",
+					html.EscapeString(expandURL), len(equal)-head-tail)
+				_, _ = buff.WriteString(html.EscapeString(strings.Join(equal[len(equal)-tail:], "")))
+			}
+			_, _ = buff.WriteString("</span>")
+		}
+	}
+	return buff.String()
+}
+
+// diffLines diffs original and new a line at a time.
+func diffLines(original, new string) []diffmatchpatch.Diff {
+	return diffTokens(original, new, func(text string) []string {
+		return strings.SplitAfter(text, "\n")
+	})
+}
+
+// wordPattern matches a word, a run of whitespace or a single other
+// character, so that punctuation next to a word doesn't change it.
+var wordPattern = regexp.MustCompile(`[\pL\pN_]+|\s+|.`)
+
+func splitWords(text string) []string {
+	return wordPattern.FindAllString(text, -1)
+}
+
+// diffTokens diffs original and new a token at a time, as split by tokenize.
+// Each distinct token is mapped to a single rune so diffmatchpatch can compare
+// whole tokens; the library's own DiffLinesToChars mangles texts with more
+// than a few lines.
+func diffTokens(original, new string, tokenize func(string) []string) []diffmatchpatch.Diff {
+	var tokens []string
+	index := make(map[string]rune)
+
+	encode := func(text string) []rune {
+		var runes []rune
+		for _, token := range tokenize(text) {
+			if token == "" {
+				continue
+			}
+			r, ok := index[token]
+			if !ok {
+				r = tokenRune(len(tokens))
+				index[token] = r
+				tokens = append(tokens, token)
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+
+	a, b := encode(original), encode(new)
+	diffs := diffmatchpatch.New().DiffMainRunes(a, b, false)
+
+	for i, diff := range diffs {
+		var text strings.Builder
+		for _, r := range diff.Text {
+			text.WriteString(tokens[tokenIndex(r)])
+		}
+		diffs[i].Text = text.String()
+	}
+	return diffs
+}
+
+// surrogateMin and surrogateCount give the range of runes, reserved for
+// UTF-16, that don't survive conversion to a string and so can't stand for
+// tokens.
+const surrogateMin, surrogateCount = 0xD800, 0x800
+
+// tokenRune returns the rune standing for the i'th distinct token.
+func tokenRune(i int) rune {
+	r := rune(i + 1)
+	if r >= surrogateMin {
+		r += surrogateCount
+	}
+	return r
+}
+
+// tokenIndex reverses tokenRune.
+func tokenIndex(r rune) int {
+	if r >= surrogateMin+surrogateCount {
+		r -= surrogateCount
+	}
+	return int(r) - 1
+}
+
+// diffLine is one numbered line of a revision in a split diff.
+type diffLine struct {
+	Number int
+	Text   string
+}
+
+// splitDiffRow is a row of a split diff: a line of the old revision beside
+// the line of the new revision it corresponds to. Either is nil where the
+// other revision has no corresponding line.
+type splitDiffRow struct {
+	OldLine, NewLine *diffLine
+	// Type is "equal", "delete", "insert" or "change".
+	Type string
+}
+
+// splitDiff aligns the lines of original and new for showing side by side.
+// Lines deleted just before lines were inserted are paired up as changed.
+func splitDiff(original, new string) []splitDiffRow {
+	var rows []splitDiffRow
+	oldNumber, newNumber := 0, 0
+	nextOld := func(text string) *diffLine {
+		oldNumber++
+		return &diffLine{Number: oldNumber, Text: text}
+	}
+	nextNew := func(text string) *diffLine {
+		newNumber++
+		return &diffLine{Number: newNumber, Text: text}
+	}
+
+	diffs := diffLines(original, new)
+	for i := 0; i < len(diffs); i++ {
+		lines := splitDiffText(diffs[i].Text)
+		switch diffs[i].Type {
+		case diffmatchpatch.DiffEqual:
+			for _, line := range lines {
+				rows = append(rows, splitDiffRow{OldLine: nextOld(line), NewLine: nextNew(line), Type: "equal"})
+			}
+		case diffmatchpatch.DiffInsert:
+			for _, line := range lines {
+				rows = append(rows, splitDiffRow{NewLine: nextNew(line), Type: "insert"})
+			}
+		case diffmatchpatch.DiffDelete:
+			var inserted []string
+			if i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert {
+				inserted = splitDiffText(diffs[i+1].Text)
+				i++
+			}
+			for j := 0; j < len(lines) || j < len(inserted); j++ {
+				row := splitDiffRow{Type: "change"}
+				if j < len(lines) {
+					row.OldLine = nextOld(lines[j])
+				} else {
+					row.Type = "insert"
+				}
+				if j < len(inserted) {
+					row.NewLine = nextNew(inserted[j])
+				} else {
+					row.Type = "delete"
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// splitDiffText splits the text of a line diff into its lines, without their
+// line endings.
+func splitDiffText(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
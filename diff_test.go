@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestDiffContextCollapse(t *testing.T) {
+	a := newTestApp(t)
+
+	lines := make([]string, 200)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("Line %d of a long article.", i+1)
+	}
+	original := strings.Join(lines, "\n") + "\n"
+	lines[99] = "Line 100 has been rewritten."
+	changed := strings.Join(lines, "\n") + "\n"
+
+	first := mustPostArticle(t, a, "Long", original, 0)
+	second := mustPostArticle(t, a, "Long", changed, first.ID)
+
+	path := fmt.Sprintf("/wiki/Long/diff/%d/%d", first.ID, second.ID)
+
+	rr := serve(a, httptest.NewRequest(http.MethodGet, path+"?context=3", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+
+	for _, want := range []string{
+		"… 96 unchanged lines …",
+		"… 97 unchanged lines …",
+		"Line 97 of",
+		"Line 99 of",
+		"<del style=\"background:#ffe6e6;\">Line 100 of a long article.\n</del>",
+		"<ins style=\"background:#e6ffe6;\">Line 100 has been rewritten.\n</ins>",
+		"Line 101 of",
+		"Line 103 of",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected diff to contain %q", want)
+		}
+	}
+
+	for _, hidden := range []string{"Line 1 of", "Line 50 of", "Line 96 of", "Line 104 of", "Line 200 of"} {
+		if strings.Contains(body, hidden) {
+			t.Errorf("expected %q to be collapsed", hidden)
+		}
+	}
+
+	rr = serve(a, httptest.NewRequest(http.MethodGet, path, nil))
+	if !strings.Contains(rr.Body.String(), "Line 50 of") {
+		t.Error("expected the default diff to show everything")
+	}
+}
+
+// setCreated backdates a revision of url, as if it had been saved at created.
+func setCreated(t *testing.T, a *app, url string, id int, created string) {
+	t.Helper()
+
+	execSQL(t, a, `UPDATE Revision SET created = ?
+		WHERE id = ? AND article_id = (SELECT id FROM Article WHERE url = ?)`, created, id, url)
+}
+
+func TestGetArticleAsOf(t *testing.T) {
+	a := newTestApp(t)
+
+	first := mustPostArticle(t, a, "Dated", "January.", 0)
+	second := mustPostArticle(t, a, "Dated", "February.", first.ID)
+	third := mustPostArticle(t, a, "Dated", "March.", second.ID)
+	setCreated(t, a, "Dated", first.ID, "2024-01-10 09:00:00.000")
+	setCreated(t, a, "Dated", second.ID, "2024-02-10 09:00:00.000")
+	setCreated(t, a, "Dated", third.ID, "2024-03-10 09:00:00.000")
+
+	for _, test := range []struct {
+		asOf string
+		want int
+	}{
+		{"2024-01-10 09:00:00", first.ID},
+		{"2024-02-01 00:00:00", first.ID},
+		{"2024-02-10 12:00:00", second.ID},
+		{"2025-01-01 00:00:00", third.ID},
+	} {
+		asOf, _ := time.Parse("2006-01-02 15:04:05", test.asOf)
+		article, err := a.GetArticleAsOf("Dated", asOf)
+		if err != nil {
+			t.Fatalf("GetArticleAsOf(%s): %v", test.asOf, err)
+		}
+		if article.ID != test.want {
+			t.Errorf("GetArticleAsOf(%s) = revision %d, want %d", test.asOf, article.ID, test.want)
+		}
+	}
+
+	asOf, _ := time.Parse("2006-01-02", "2023-12-31")
+	if _, err := a.GetArticleAsOf("Dated", asOf); err != wiki.ErrRevisionNotFound {
+		t.Errorf("expected ErrRevisionNotFound before the article existed, got %v", err)
+	}
+}
+
+func TestDiffSince(t *testing.T) {
+	a := newTestApp(t)
+
+	first := mustPostArticle(t, a, "Dated", "Written in January.\n", 0)
+	second := mustPostArticle(t, a, "Dated", "Written in March.\n", first.ID)
+	setCreated(t, a, "Dated", first.ID, "2024-01-10 09:00:00.000")
+	setCreated(t, a, "Dated", second.ID, "2024-03-10 09:00:00.000")
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Dated?diff&since=2024-02-01&context=3", nil, nil)).Body.String()
+	for _, want := range []string{
+		"<del style=\"background:#ffe6e6;\">Written in January.\n</del>",
+		"<ins style=\"background:#e6ffe6;\">Written in March.\n</ins>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected diff to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	// The whole of the since date counts, so an edit made during it is the
+	// old side.
+	body = serve(a, newRequest(http.MethodGet, "/wiki/Dated?diff&since=2024-03-10", nil, nil)).Body.String()
+	if strings.Contains(body, "<ins") || strings.Contains(body, "<del") {
+		t.Errorf("expected no changes since 2024-03-10, got:\n%s", body)
+	}
+
+	body = serve(a, newRequest(http.MethodGet, "/wiki/Dated?diff&since=2023-01-01", nil, nil)).Body.String()
+	if !strings.Contains(body, "<ins style=\"background:#e6ffe6;\">Written in March.\n</ins>") || strings.Contains(body, "January") {
+		t.Errorf("expected a date before the article existed to diff against nothing, got:\n%s", body)
+	}
+
+	for _, bad := range []string{"yesterday", "2024-13-01", ""} {
+		rr := serve(a, newRequest(http.MethodGet, "/wiki/Dated?diff&since="+bad, nil, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("since=%q: expected 400, got %d", bad, rr.Code)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Missing?diff&since=2024-01-01", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing article, got %d", rr.Code)
+	}
+}
+
+func TestHistoryLinksToDiffs(t *testing.T) {
+	a := newTestApp(t)
+	first := mustPostArticle(t, a, "Linked", "One.", 0)
+	mustPostArticle(t, a, "Linked", "Two.", first.ID)
+
+	body := serve(a, newRequest(http.MethodGet, "/wiki/Linked/history", nil, nil)).Body.String()
+	if !strings.Contains(body, `<a href="/wiki/Linked/diff/1/2">diff</a>`) {
+		t.Errorf("expected the edit to link to its diff:\n%s", body)
+	}
+	if strings.Count(body, ">diff</a>") != 1 {
+		t.Errorf("expected no diff link for the revision creating the article:\n%s", body)
+	}
+}
+
+func TestDiffCache(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.DiffCacheSize = 2
+	})
+
+	first := mustPostArticle(t, a, "Cached", "Old text.\n", 0)
+	second := mustPostArticle(t, a, "Cached", "New text.\n", first.ID)
+	path := fmt.Sprintf("/wiki/Cached/diff/%d/%d", first.ID, second.ID)
+
+	body := serve(a, newRequest(http.MethodGet, path, nil, nil)).Body.String()
+	if !strings.Contains(body, "New") || a.diffs.len() != 1 {
+		t.Fatalf("expected the diff to be rendered and cached, got %d entries:\n%s", a.diffs.len(), body)
+	}
+	if again := serve(a, newRequest(http.MethodGet, path, nil, nil)).Body.String(); again != body {
+		t.Error("expected the cached diff to be served unchanged")
+	}
+
+	// Recreating the article reuses its revision IDs, but not its diffs.
+	if err := a.DeleteArticle("Cached"); err != nil {
+		t.Fatal(err)
+	}
+	first = mustPostArticle(t, a, "Cached", "Other old text.\n", 0)
+	mustPostArticle(t, a, "Cached", "Other new text.\n", first.ID)
+	body = serve(a, newRequest(http.MethodGet, path, nil, nil)).Body.String()
+	if !strings.Contains(body, "Other") {
+		t.Errorf("expected the recreated article's diff, got:\n%s", body)
+	}
+
+	serve(a, newRequest(http.MethodGet, path+"?context=1", nil, nil))
+	if n := a.diffs.len(); n != 2 {
+		t.Errorf("expected the cache to be limited to 2 diffs, got %d", n)
+	}
+
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+	form := url.Values{"site_name": {"periwiki"}, "diff_cache_size": {"-1"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a negative cache size to be rejected, got %d", rr.Code)
+	}
+	form.Set("diff_cache_size", "0")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+	}
+	serve(a, newRequest(http.MethodGet, path+"?context=2", nil, nil))
+	if n := a.diffs.len(); n != 0 {
+		t.Errorf("expected a size of 0 to empty the cache, got %d diffs", n)
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	a := newTestApp(t)
+	first := mustPostArticle(t, a, "Words", "The quick brown fox <jumps>.\n", 0)
+	second := mustPostArticle(t, a, "Words", "The quack brown fox <leaps>!\n", first.ID)
+	path := fmt.Sprintf("/wiki/Words/diff/%d/%d", first.ID, second.ID)
+
+	body := serve(a, newRequest(http.MethodGet, path+"?mode=word", nil, nil)).Body.String()
+	for _, want := range []string{
+		`<del style="background:#ffe6e6;">quick</del><ins style="background:#e6ffe6;">quack</ins>`,
+		`<del style="background:#ffe6e6;">jumps</del><ins style="background:#e6ffe6;">leaps</ins>`,
+		`<span>&gt;</span><del style="background:#ffe6e6;">.</del><ins style="background:#e6ffe6;">!</ins>`,
+		`<strong>words</strong>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the word diff to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<jumps>") || strings.Contains(body, "<leaps>") {
+		t.Error("expected the word diff to be escaped")
+	}
+
+	body = serve(a, newRequest(http.MethodGet, path, nil, nil)).Body.String()
+	if !strings.Contains(body, `<span>The qu</span><del style="background:#ffe6e6;">i</del>`) || !strings.Contains(body, `<strong>characters</strong>`) {
+		t.Errorf("expected a character diff by default, got:\n%s", body)
+	}
+
+	// Context diffs keep the word mode for the lines that changed.
+	body = serve(a, newRequest(http.MethodGet, path+"?mode=word&context=3", nil, nil)).Body.String()
+	if !strings.Contains(body, `<del style="background:#ffe6e6;">quick</del><ins style="background:#e6ffe6;">quack</ins>`) {
+		t.Errorf("expected the context diff to be a word diff, got:\n%s", body)
+	}
+	body = serve(a, newRequest(http.MethodGet, path+"?mode=char&context=3", nil, nil)).Body.String()
+	if !strings.Contains(body, `<del style="background:#ffe6e6;">The quick brown fox`) {
+		t.Errorf("expected the character context diff to mark whole lines, got:\n%s", body)
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, path+"?mode=line", nil, nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown mode, got %d", rr.Code)
+	}
+
+	// The mode links keep ?since= and still route to the since diff.
+	body = serve(a, newRequest(http.MethodGet, "/wiki/Words?diff&since=2000-01-01", nil, nil)).Body.String()
+	link := "/wiki/Words?diff=&amp;mode=word&amp;since=2000-01-01"
+	if !strings.Contains(body, link) {
+		t.Fatalf("expected a link to %q, got:\n%s", link, body)
+	}
+	body = serve(a, newRequest(http.MethodGet, html.UnescapeString(link), nil, nil)).Body.String()
+	if !strings.Contains(body, `<ins style="background:#e6ffe6;">The quack brown fox`) {
+		t.Errorf("expected the linked word diff against nothing, got:\n%s", body)
+	}
+}
+
+func TestDiffTokensBeyondSurrogates(t *testing.T) {
+	var original strings.Builder
+	for i := 0; i < 0xE000; i++ {
+		fmt.Fprintf(&original, "w%d ", i)
+	}
+	new := original.String() + "added"
+
+	diffs := diffTokens(original.String(), new, splitWords)
+	last := diffs[len(diffs)-1]
+	if last.Text != "added" {
+		t.Errorf("expected the last token to survive encoding, got %q", last.Text)
+	}
+}
+
+func TestSplitDiff(t *testing.T) {
+	rows := splitDiff("one\ntwo\nthree\nfour\n", "one\n2\nthree\nfour\nfive\n")
+	want := []struct {
+		old, new int
+		typ      string
+	}{
+		{1, 1, "equal"},
+		{2, 2, "change"},
+		{3, 3, "equal"},
+		{4, 4, "equal"},
+		{0, 5, "insert"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for i, w := range want {
+		row := rows[i]
+		old, new := 0, 0
+		if row.OldLine != nil {
+			old = row.OldLine.Number
+		}
+		if row.NewLine != nil {
+			new = row.NewLine.Number
+		}
+		if old != w.old || new != w.new || row.Type != w.typ {
+			t.Errorf("row %d: expected %d/%d %s, got %d/%d %s", i, w.old, w.new, w.typ, old, new, row.Type)
+		}
+	}
+
+	rows = splitDiff("a\nb\nc\n", "x\n")
+	if len(rows) != 3 || rows[0].Type != "change" || rows[1].Type != "delete" || rows[2].NewLine != nil {
+		t.Errorf("expected a change followed by deletions, got %+v", rows)
+	}
+}
+
+func TestSplitDiffPage(t *testing.T) {
+	a := newTestApp(t)
+	first := mustPostArticle(t, a, "Split", "Same.\n<script>old()</script>\n", 0)
+	second := mustPostArticle(t, a, "Split", "Same.\n<script>new()</script>\n", first.ID)
+	path := fmt.Sprintf("/wiki/Split/diff/%d/%d", first.ID, second.ID)
+
+	body := serve(a, newRequest(http.MethodGet, path+"?view=split", nil, nil)).Body.String()
+	for _, want := range []string{
+		`<table class="pw-diff-split">`,
+		`<tr class="pw-diff-change">`,
+		`<td class="pw-diff-old"><code>&lt;script&gt;old()&lt;/script&gt;</code></td>`,
+		`<td class="pw-diff-new"><code>&lt;script&gt;new()&lt;/script&gt;</code></td>`,
+		`href="` + path + `?view=inline"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the split diff to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>") {
+		t.Error("expected the split diff to be escaped")
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, path+"?view=columns", nil, nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown view, got %d", rr.Code)
+	}
+}
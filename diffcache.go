@@ -0,0 +1,79 @@
+package main
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// diffCache keeps the most recently used rendered diffs. Entries are keyed by
+// the hashes of the revisions as well as their IDs, so a diff is never served
+// for an article that was deleted and recreated with the same revision IDs.
+// Revisions are otherwise immutable, so entries never need invalidating.
+type diffCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order lists entries from most to least recently used.
+	order *list.List
+}
+
+type diffCacheEntry struct {
+	key  string
+	html string
+}
+
+func newDiffCache() *diffCache {
+	return &diffCache{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// diffCacheKey identifies the diff from original to new served at path with
+// the given ?mode= and ?context=.
+func diffCacheKey(path, mode, context string, original, new *wiki.Article) string {
+	return strings.Join([]string{
+		path, mode, context,
+		strconv.Itoa(original.ID), original.Hash,
+		strconv.Itoa(new.ID), new.Hash,
+	}, "\x00")
+}
+
+func (c *diffCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*diffCacheEntry).html, true
+}
+
+// put stores html under key, evicting the least recently used entries to
+// keep at most size. A size of zero empties the cache.
+func (c *diffCache) put(key, html string, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*diffCacheEntry).html = html
+		c.order.MoveToFront(element)
+	} else if size > 0 {
+		c.entries[key] = c.order.PushFront(&diffCacheEntry{key: key, html: html})
+	}
+
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*diffCacheEntry).key)
+	}
+}
+
+func (c *diffCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestDisplayPreferences(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.EditorTabSize = 4
+		c.EditorToolbar = wiki.DefaultEditorToolbar
+	})
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Cats", "Cats are great.", 0)
+	mustPostArticle(t, a, "Cats", "Cats are very great.", 1)
+	cookie := login(t, a, "alice")
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, cookie)).Body.String()
+	if !strings.Contains(page, `<html data-theme="light">`) || strings.Contains(page, "dark.css") {
+		t.Errorf("expected the light theme before any preferences are saved, got:\n%s", page)
+	}
+
+	form := url.Values{"tab_size": {"4"}, "theme": {"dark"}, "diff_mode": {"split"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, cookie)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected preferences to be saved, got %d", rr.Code)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, cookie)).Body.String()
+	if !strings.Contains(page, `<html data-theme="dark">`) || !strings.Contains(page, `href="/static/themes/dark.css"`) {
+		t.Errorf("expected the dark theme, got:\n%s", page)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/diff/1/2", nil, cookie)).Body.String(); !strings.Contains(page, "pw-diff-split") {
+		t.Errorf("expected diffs side by side by default, got:\n%s", page)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/diff/1/2?mode=char", nil, cookie)).Body.String(); strings.Contains(page, "pw-diff-split") {
+		t.Errorf("expected ?mode= to override the diff mode, got:\n%s", page)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/2/edit", nil, cookie)).Body.String(); strings.Contains(page, "pw-toolbar") {
+		t.Errorf("expected the unchecked toolbar to be hidden, got:\n%s", page)
+	}
+
+	// Anonymous users get the site defaults.
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/diff/1/2", nil, nil)).Body.String()
+	if strings.Contains(page, "pw-diff-split") || strings.Contains(page, "dark.css") {
+		t.Errorf("expected anonymous users to get the site defaults, got:\n%s", page)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/2/edit", nil, nil)).Body.String(); !strings.Contains(page, "pw-toolbar") {
+		t.Errorf("expected anonymous users to get the toolbar, got:\n%s", page)
+	}
+
+	for _, form := range []url.Values{
+		{"tab_size": {"4"}, "theme": {"neon"}},
+		{"tab_size": {"4"}, "diff_mode": {"sideways"}},
+	} {
+		if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, cookie)); rr.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", form, rr.Code)
+		}
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/user/preferences", nil, cookie))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/user/settings" {
+		t.Errorf("expected /user/preferences to redirect to /user/settings, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+const otterText = "The sea otter is a marine mammal native to the coasts of the northern and eastern North Pacific Ocean. " +
+	"Adult sea otters typically weigh between 14 and 45 kg, making them the heaviest members of the weasel family."
+
+func TestFindSimilarContent(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Sea_Otter", otterText, 0)
+	mustPostArticle(t, a, "Talk:Sea_Otter", otterText, 0)
+	mustPostArticle(t, a, "Badger", "Badgers are short-legged omnivores that dig burrows called setts.", 0)
+
+	matches, err := a.FindSimilarContent(otterText, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].URL != "Sea_Otter" || matches[0].Similarity != 1 {
+		t.Fatalf("expected an exact match with Sea_Otter only, got %+v", matches)
+	}
+
+	edited := strings.Replace(otterText, "heaviest", "largest", 1)
+	matches, err = a.FindSimilarContent(edited, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].URL != "Sea_Otter" || matches[0].Similarity >= 1 {
+		t.Errorf("expected a near match with Sea_Otter, got %+v", matches)
+	}
+
+	for _, distinct := range []string{"Pangolins are scaly anteaters found in Asia and Africa.", "  "} {
+		matches, err = a.FindSimilarContent(distinct, 0.8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 0 {
+			t.Errorf("expected no matches for %q, got %+v", distinct, matches)
+		}
+	}
+}
+
+func TestSimilarContentIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "periwiki.db")
+	old := newTestApp(t, func(c *wiki.Config) { c.DatabaseFile = file })
+	mustPostArticle(t, old, "Sea_Otter", otterText, 0)
+	old.Close()
+	// As saved before shingles were indexed.
+	execSQL(t, old, `DELETE FROM Shingle`)
+	execSQL(t, old, `UPDATE Article SET shingles = NULL`)
+
+	a := newTestApp(t, func(c *wiki.Config) { c.DatabaseFile = file })
+	matches, err := a.FindSimilarContent(otterText, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].URL != "Sea_Otter" {
+		t.Fatalf("expected the existing article to be indexed, got %+v", matches)
+	}
+
+	// Only the current revision is indexed.
+	article, err := a.GetArticle("Sea_Otter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPostArticle(t, a, "Sea_Otter", "Sea otters were hunted for their fur.", article.ID)
+	matches, err = a.FindSimilarContent(otterText, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected the replaced content not to match, got %+v", matches)
+	}
+}
+
+func TestDuplicateContentWarning(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.DuplicateContentThreshold = 0.8
+	})
+	mustPostArticle(t, a, "Sea_Otter", otterText, 0)
+
+	preview := func(body string) string {
+		form := url.Values{"title": {"Otter"}, "body": {body}, "action": {"preview"}}
+		rr := serve(a, newRequest(http.MethodPost, "/wiki/Otter/r/0", form, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	if page := preview(otterText); !strings.Contains(page, `<a href="/wiki/Sea_Otter">Sea_Otter</a> (100% similar)`) {
+		t.Errorf("expected the duplicate to be flagged, got:\n%s", page)
+	}
+	if page := preview("Otters hold hands while they sleep."); strings.Contains(page, "pw-duplicates") {
+		t.Errorf("expected distinct content not to be flagged")
+	}
+
+	// The warning doesn't stop the article being saved.
+	form := url.Values{"title": {"Otter"}, "body": {otterText}, "action": {"submit"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Otter/r/0", form, nil)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected the duplicate to be saved, got %d", rr.Code)
+	}
+}
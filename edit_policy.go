@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// EditDenialReason says why an edit was refused, and so how to respond.
+type EditDenialReason int
+
+const (
+	EditAllowed EditDenialReason = iota
+	// EditDenialLoginRequired is given to anonymous users when editing
+	// requires an account. They are sent to log in.
+	EditDenialLoginRequired
+	// EditDenialProtected is given for pages the user may not edit.
+	EditDenialProtected
+	// EditDenialReadOnly is given to everyone while the wiki is read-only.
+	EditDenialReadOnly
+	// EditDenialPreviewOnly is given instead of EditDenialLoginRequired when
+	// AllowAnonymousPreview is set. Anonymous users may open the editor and
+	// preview, but their saves are refused.
+	EditDenialPreviewOnly
+	// EditDenialUnverified is given to users whose email address isn't
+	// verified, when editing requires an account and accounts require
+	// verification.
+	EditDenialUnverified
+)
+
+var errReadOnly = errors.New("the wiki is read-only for maintenance")
+var errLoginToSave = errors.New("log in to save your changes")
+var errVerifyToEdit = errors.New("verify your email address to edit")
+
+// Status is the HTTP status of a response to a denied edit.
+func (reason EditDenialReason) Status() int {
+	switch reason {
+	case EditDenialLoginRequired:
+		return http.StatusSeeOther
+	case EditDenialProtected, EditDenialPreviewOnly, EditDenialUnverified:
+		return http.StatusForbidden
+	case EditDenialReadOnly:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// EditPolicy decides who may edit what. Handlers consult it rather than
+// checking permissions themselves.
+type EditPolicy struct {
+	*wiki.WikiModel
+}
+
+// EditPolicy returns the app's edit policy.
+func (a *app) EditPolicy() EditPolicy {
+	return EditPolicy{a.WikiModel}
+}
+
+// Evaluate reports whether user may edit article and, if not, why. Read-only
+// mode takes precedence over the page's protection, which takes precedence
+// over whether the user is logged in and verified.
+func (policy EditPolicy) Evaluate(article *wiki.Article, user *wiki.User) (allowed bool, reason EditDenialReason) {
+	switch {
+	case policy.ReadOnly:
+		return false, EditDenialReadOnly
+	case policy.CanEdit(article, user) != wiki.EditActionEdit:
+		return false, EditDenialProtected
+	case policy.RequireLoginToEdit && user.IsAnonymous() && policy.AllowAnonymousPreview:
+		return false, EditDenialPreviewOnly
+	case policy.RequireLoginToEdit && user.IsAnonymous():
+		return false, EditDenialLoginRequired
+	case policy.RequireLoginToEdit && policy.RequiresVerification(user):
+		return false, EditDenialUnverified
+	}
+	return true, EditAllowed
+}
+
+// CanPreview reports whether user may open the editor for article and
+// preview changes, whether or not they may save them.
+func (policy EditPolicy) CanPreview(article *wiki.Article, user *wiki.User) bool {
+	allowed, reason := policy.Evaluate(article, user)
+	return allowed || reason == EditDenialPreviewOnly
+}
+
+// EditAction is what the policy lets user do with article's source.
+func (policy EditPolicy) EditAction(article *wiki.Article, user *wiki.User) wiki.EditAction {
+	if policy.CanPreview(article, user) {
+		return wiki.EditActionEdit
+	}
+	return wiki.EditActionViewSource
+}
+
+// denyEdit responds to an edit refused for reason.
+func (a *app) denyEdit(reason EditDenialReason, rw http.ResponseWriter, req *http.Request) {
+	switch reason {
+	case EditDenialLoginRequired:
+		http.Redirect(rw, req, "/user/login", reason.Status())
+	case EditDenialProtected:
+		a.errorHandler(reason.Status(), rw, req, wiki.ErrArticleProtected)
+	case EditDenialReadOnly:
+		a.errorHandler(reason.Status(), rw, req, errReadOnly)
+	case EditDenialPreviewOnly:
+		a.errorHandler(reason.Status(), rw, req, errLoginToSave)
+	case EditDenialUnverified:
+		a.errorHandler(reason.Status(), rw, req, errVerifyToEdit)
+	}
+}
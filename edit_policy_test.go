@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestEditPolicy(t *testing.T) {
+	anonymous := wiki.AnonymousUser()
+	registered := &wiki.User{ID: 2, ScreenName: "bob", Role: wiki.RoleUser}
+	admin := &wiki.User{ID: 1, ScreenName: "admin", Role: wiki.RoleAdmin}
+
+	tests := []struct {
+		name          string
+		article       string
+		user          *wiki.User
+		requireLogin  bool
+		readOnly      bool
+		expectAllowed bool
+		expectReason  EditDenialReason
+	}{
+		{"anonymous edits ordinary page", "Cats", anonymous, false, false, true, EditAllowed},
+		{"anonymous edits when login required", "Cats", anonymous, true, false, false, EditDenialLoginRequired},
+		{"anonymous edits protected page", "Policy", anonymous, false, false, false, EditDenialProtected},
+		{"anonymous edits protected page when login required", "Policy", anonymous, true, false, false, EditDenialProtected},
+		{"anonymous edits while read-only", "Cats", anonymous, true, true, false, EditDenialReadOnly},
+		{"registered edits ordinary page", "Cats", registered, true, false, true, EditAllowed},
+		{"registered edits protected page", "Policy", registered, true, false, false, EditDenialProtected},
+		{"registered edits while read-only", "Cats", registered, false, true, false, EditDenialReadOnly},
+		{"admin edits protected page", "Policy", admin, true, false, true, EditAllowed},
+		{"admin edits special page", "Special:StaleContent", admin, false, false, false, EditDenialProtected},
+		{"admin edits while read-only", "Policy", admin, false, true, false, EditDenialReadOnly},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *wiki.Config) {
+				c.ProtectedArticles = []string{"Policy"}
+				c.RequireLoginToEdit = test.requireLogin
+				c.ReadOnly = test.readOnly
+			})
+
+			allowed, reason := a.EditPolicy().Evaluate(wiki.NewArticle(test.article, test.article, ""), test.user)
+			if allowed != test.expectAllowed || reason != test.expectReason {
+				t.Errorf("expected (%v, %v), got (%v, %v)", test.expectAllowed, test.expectReason, allowed, reason)
+			}
+		})
+	}
+}
+
+func TestEditPolicyResponses(t *testing.T) {
+	form := url.Values{"title": {"Cats"}, "body": {"Cats."}, "action": {"submit"}}
+
+	a := newTestApp(t, func(c *wiki.Config) { c.RequireLoginToEdit = true })
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/0", form, nil))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("expected anonymous edits to be sent to log in, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	a = newTestApp(t, func(c *wiki.Config) { c.ReadOnly = true })
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/0", form, nil)); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while read-only, got %d", rr.Code)
+	}
+}
+
+func TestAnonymousPreviewOnly(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RequireLoginToEdit = true
+		c.AllowAnonymousPreview = true
+	})
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/0/edit", nil, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected anonymous users to open the editor, got %d", rr.Code)
+	} else if !strings.Contains(rr.Body.String(), "must <a href=\"/user/login\">log in</a> to save") {
+		t.Error("expected the editor to say saving requires logging in")
+	}
+
+	form := url.Values{"title": {"Cats"}, "body": {"Cats are *great*."}, "action": {"preview"}}
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/0", form, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<em>great</em>") {
+		t.Errorf("expected an anonymous preview to render, got %d", rr.Code)
+	}
+
+	form.Set("action", "submit")
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/0", form, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected an anonymous save to be refused with 403, got %d", rr.Code)
+	}
+	if _, err := a.GetArticle("Cats"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected nothing to be saved, got %v", err)
+	}
+}
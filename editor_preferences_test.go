@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestEditorPreferences(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.EditorTabSize = 4 })
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Cats", "Cats are *great*.", 0)
+	cookie := login(t, a, "alice")
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, cookie)).Body.String()
+	if strings.Contains(page, "pw-preview") || strings.Contains(page, "pw-monospace") {
+		t.Errorf("expected the site defaults before any preferences are saved, got:\n%s", page)
+	}
+
+	form := url.Values{"preview_by_default": {"on"}, "monospace": {"on"}, "tab_size": {"2"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, cookie)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected preferences to be saved, got %d", rr.Code)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, cookie)).Body.String()
+	for _, want := range []string{`<article class="pw-preview">`, "<em>great</em>", `class="pw-monospace"`, "tab-size: 2;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the editor to contain %q, got:\n%s", want, page)
+		}
+	}
+
+	// Anonymous users get the site defaults.
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, nil)).Body.String()
+	if strings.Contains(page, "pw-preview") || !strings.Contains(page, "tab-size: 4;") {
+		t.Errorf("expected anonymous users to get the site defaults, got:\n%s", page)
+	}
+}
+
+func TestEditorPreferencesValidation(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+	cookie := login(t, a, "alice")
+
+	for _, tabSize := range []string{"0", "17", "wide"} {
+		form := url.Values{"tab_size": {tabSize}}
+		if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, cookie)); rr.Code != http.StatusBadRequest {
+			t.Errorf("tab_size=%s: expected 400, got %d", tabSize, rr.Code)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/user/settings", nil, nil)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected anonymous users to be sent to log in, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+// frameAncestors returns the CSP frame-ancestors directive allowing this
+// wiki and the configured EmbedAllowedOrigins to frame embeds.
+func (a *app) frameAncestors() string {
+	return strings.Join(append([]string{"frame-ancestors", "'self'"}, a.EmbedAllowedOrigins...), " ")
+}
+
+// embedHandler serves an article's rendered HTML without the wiki's
+// navigation, for other sites to show in an iframe.
+func (a *app) embedHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	article, err := a.GetArticle(mux.Vars(req)["article"])
+	if err == nil && !canView(article, user) {
+		err = wiki.ErrGenericNotFound
+	}
+	if err == wiki.ErrGenericNotFound {
+		http.NotFound(rw, req)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", cacheControl(article, user))
+	rw.Header().Set("Content-Security-Policy", withCSPDirective(rw.Header().Get("Content-Security-Policy"), a.frameAncestors()))
+	if len(a.EmbedAllowedOrigins) == 0 {
+		// For browsers that predate frame-ancestors.
+		rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	} else {
+		rw.Header().Del("X-Frame-Options")
+	}
+
+	err = a.RenderTemplate(rw, "article.html", "embed.html", map[string]interface{}{
+		"Article":      article,
+		"Context":      req.Context(),
+		"PermanentURL": absoluteURL(req, "/wiki/"+article.URL),
+	})
+	check(err)
+}
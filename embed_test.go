@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestEmbed(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.EmbedAllowedOrigins = []string{"https://intranet.example.com"}
+	})
+	mustPostArticle(t, a, "Cats", "---\ncache: short\n---\nCats are *nice*.", 0)
+
+	rr := serve(a, newRequest(http.MethodGet, "/embed/Cats", nil, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != wiki.CacheControlShort {
+		t.Errorf("expected the article's Cache-Control, got %q", cc)
+	}
+
+	page := rr.Body.String()
+	if !strings.Contains(page, "Cats are <em>nice</em>.") {
+		t.Errorf("expected the rendered article, got:\n%s", page)
+	}
+	for _, chrome := range []string{`id="sidebar"`, `id="login-bar"`, "pw-tabs", "/r/1/edit"} {
+		if strings.Contains(page, chrome) {
+			t.Errorf("expected no %s in an embed", chrome)
+		}
+	}
+
+	csp := cspDirective(rr.Header().Get("Content-Security-Policy"), "frame-ancestors")
+	if csp != "frame-ancestors 'self' https://intranet.example.com" {
+		t.Errorf("expected frame-ancestors to allow the configured origin, got %q", csp)
+	}
+	if strings.Contains(csp, "*") {
+		t.Error("expected no wildcard in frame-ancestors")
+	}
+	if xfo := rr.Header().Get("X-Frame-Options"); xfo != "" {
+		t.Errorf("expected no X-Frame-Options to override frame-ancestors, got %q", xfo)
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/embed/Dogs", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing article, got %d", rr.Code)
+	}
+}
+
+func TestEmbedWithoutAllowedOrigins(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	rr := serve(a, newRequest(http.MethodGet, "/embed/Cats", nil, nil))
+	if csp := cspDirective(rr.Header().Get("Content-Security-Policy"), "frame-ancestors"); csp != "frame-ancestors 'self'" {
+		t.Errorf("expected only the wiki itself to be allowed to frame, got %q", csp)
+	}
+	if xfo := rr.Header().Get("X-Frame-Options"); xfo != "SAMEORIGIN" {
+		t.Errorf("expected X-Frame-Options SAMEORIGIN, got %q", xfo)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// exportHandler streams a ZIP of every article's latest markdown, as
+// {url}.md, followed by a manifest.json describing them.
+func (a *app) exportHandler(rw http.ResponseWriter, req *http.Request) {
+	filename := fmt.Sprintf("periwiki-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Once the archive has started the status can't be changed, so errors
+	// are only logged and leave the client with a truncated archive.
+	archive := zip.NewWriter(rw)
+	manifest := make([]wiki.ExportEntry, 0)
+	err := a.ExportArticles(func(article *wiki.Article) error {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     article.URL + ".md",
+			Method:   zip.Deflate,
+			Modified: article.Created,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write([]byte(article.Markdown)); err != nil {
+			return err
+		}
+		manifest = append(manifest, wiki.ExportEntry{
+			URL:      article.URL,
+			Title:    article.Title,
+			Revision: article.ID,
+			Created:  article.Created,
+		})
+		return nil
+	})
+	if err == nil {
+		err = writeManifest(archive, manifest)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func writeManifest(archive *zip.Writer, manifest []wiki.ExportEntry) error {
+	file, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
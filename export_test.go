@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestExport(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	admin := login(t, a, "admin")
+
+	first := mustPostArticle(t, a, "Alpha", "First.", 0).ID
+	mustPostArticle(t, a, "Alpha", "Second.", first)
+	mustPostArticle(t, a, "Talk:Alpha", "Discussion.", 0)
+
+	if rr := serve(a, newRequest(http.MethodGet, "/manage/export", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/manage/export", nil, admin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("expected an attachment, got Content-Disposition %q", cd)
+	}
+
+	body := rr.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+
+	if files["Alpha.md"] != "Second." {
+		t.Errorf("expected the latest markdown for Alpha, got %q", files["Alpha.md"])
+	}
+	if files["Talk:Alpha.md"] != "Discussion." {
+		t.Errorf("expected Talk:Alpha to be exported, got %q", files["Talk:Alpha.md"])
+	}
+
+	var manifest []wiki.ExportEntry
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 || manifest[0].URL != "Alpha" || manifest[1].URL != "Talk:Alpha" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	if manifest[0].Revision <= first || manifest[0].Created.IsZero() {
+		t.Errorf("expected the manifest to describe Alpha's latest revision, got %+v", manifest[0])
+	}
+}
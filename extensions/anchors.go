@@ -0,0 +1,43 @@
+package extensions
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+type definitionAnchors struct{}
+
+// DefinitionAnchors enables definition lists and gives each definition term
+// an id, so that `[[Page#Term]]` can link to it. IDs are generated like
+// automatic heading IDs and share their namespace, so a term repeating a
+// heading or another term gets a numbered suffix.
+var DefinitionAnchors = &definitionAnchors{}
+
+func (e *definitionAnchors) Extend(m goldmark.Markdown) {
+	extension.DefinitionList.Extend(m)
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&definitionAnchorTransformer{}, 100),
+		),
+	)
+}
+
+type definitionAnchorTransformer struct{}
+
+func (t *definitionAnchorTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if term, ok := n.(*extast.DefinitionTerm); ok && entering {
+			if _, ok := term.AttributeString("id"); !ok {
+				term.SetAttributeString("id", pc.IDs().Generate(term.Text(source), term.Kind()))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+}
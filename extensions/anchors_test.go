@@ -0,0 +1,63 @@
+package extensions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func renderWithAnchors(t *testing.T, md string) string {
+	t.Helper()
+
+	markdown := goldmark.New(
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithExtensions(DefinitionAnchors, NewWikiLinker(WithUnderscoreResolver())),
+	)
+
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(md), &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDefinitionAnchors(t *testing.T) {
+	out := renderWithAnchors(t, "Render queue\n: Where renders wait.\n")
+	if !strings.Contains(out, `<dt id="render-queue">Render queue</dt>`) {
+		t.Errorf("expected the term to get an id, got:\n%s", out)
+	}
+
+	if again := renderWithAnchors(t, "Render queue\n: Where renders wait.\n"); again != out {
+		t.Errorf("expected ids to be stable, got:\n%s\nthen:\n%s", out, again)
+	}
+}
+
+func TestDefinitionAnchorsDisambiguate(t *testing.T) {
+	out := renderWithAnchors(t, "## Tier\n\nTier\n: A priority.\n\nTier\n: A level.\n")
+
+	for _, want := range []string{`<h2 id="tier">`, `<dt id="tier-1">`, `<dt id="tier-2">`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWikiLinkFragment(t *testing.T) {
+	tests := []struct {
+		md   string
+		href string
+	}{
+		{md: "[[Glossary#Render queue]]", href: `href="/wiki/Glossary#render-queue"`},
+		{md: "[[Tiers and queues#Tier 2|tiers]]", href: `href="/wiki/Tiers_and_queues#tier-2"`},
+		{md: "[[#Render queue]]", href: `href="#render-queue"`},
+	}
+
+	for _, test := range tests {
+		if out := renderWithAnchors(t, test.md); !strings.Contains(out, test.href) {
+			t.Errorf("%s: expected %s, got:\n%s", test.md, test.href, out)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package ast
+
+import (
+	gast "github.com/yuin/goldmark/ast"
+)
+
+// Diagram is a fenced code block in a diagram language, to be drawn rather
+// than shown as code.
+type Diagram struct {
+	gast.BaseBlock
+	Language string
+	Source   []byte
+}
+
+// IsRaw implements Node.IsRaw.
+func (n *Diagram) IsRaw() bool {
+	return true
+}
+
+// Dump implements Node.Dump.
+func (n *Diagram) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"Language": n.Language}, nil)
+}
+
+// KindDiagram is a NodeKind of the Diagram node.
+var KindDiagram = gast.NewNodeKind("Diagram")
+
+// Kind implements Node.Kind.
+func (n *Diagram) Kind() gast.NodeKind {
+	return KindDiagram
+}
+
+// NewDiagram returns a new Diagram node drawing source in language.
+func NewDiagram(language string, source []byte) *Diagram {
+	return &Diagram{Language: language, Source: source}
+}
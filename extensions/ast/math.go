@@ -0,0 +1,60 @@
+package ast
+
+import (
+	gast "github.com/yuin/goldmark/ast"
+)
+
+// Math is TeX written inline between single dollar signs, e.g. `$x^2$`, or
+// between double ones to be displayed on a line of its own.
+type Math struct {
+	gast.BaseInline
+	TeX     []byte
+	Display bool
+}
+
+// Dump implements Node.Dump.
+func (n *Math) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// KindMath is a NodeKind of the Math node.
+var KindMath = gast.NewNodeKind("Math")
+
+// Kind implements Node.Kind.
+func (n *Math) Kind() gast.NodeKind {
+	return KindMath
+}
+
+// NewMath returns a new inline Math node for tex.
+func NewMath(tex []byte, display bool) *Math {
+	return &Math{TeX: tex, Display: display}
+}
+
+// MathBlock is TeX displayed as a block between lines of double dollar
+// signs. Its lines hold the TeX.
+type MathBlock struct {
+	gast.BaseBlock
+}
+
+// IsRaw implements Node.IsRaw.
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+// Dump implements Node.Dump.
+func (n *MathBlock) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, nil, nil)
+}
+
+// KindMathBlock is a NodeKind of the MathBlock node.
+var KindMathBlock = gast.NewNodeKind("MathBlock")
+
+// Kind implements Node.Kind.
+func (n *MathBlock) Kind() gast.NodeKind {
+	return KindMathBlock
+}
+
+// NewMathBlock returns a new, empty MathBlock node.
+func NewMathBlock() *MathBlock {
+	return &MathBlock{}
+}
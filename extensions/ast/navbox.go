@@ -0,0 +1,29 @@
+package ast
+
+import (
+	gast "github.com/yuin/goldmark/ast"
+)
+
+// Navbox is a block embedding the rendered HTML of another article.
+type Navbox struct {
+	gast.BaseBlock
+	Target []byte
+}
+
+// Dump implements Node.Dump.
+func (n *Navbox) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"Target": string(n.Target)}, nil)
+}
+
+// KindNavbox is a NodeKind of the Navbox node.
+var KindNavbox = gast.NewNodeKind("Navbox")
+
+// Kind implements Node.Kind.
+func (n *Navbox) Kind() gast.NodeKind {
+	return KindNavbox
+}
+
+// NewNavbox returns a new Navbox node embedding the article at target.
+func NewNavbox(target []byte) *Navbox {
+	return &Navbox{Target: target}
+}
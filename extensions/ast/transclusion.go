@@ -0,0 +1,34 @@
+package ast
+
+import (
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Transclusion is a `{{Page_Name}}` reference to an article whose markdown
+// is spliced in its place.
+type Transclusion struct {
+	gast.BaseInline
+	Target []byte
+	// Segment spans the whole reference, braces included, in the source.
+	Segment text.Segment
+}
+
+// Dump implements Node.Dump.
+func (n *Transclusion) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"Target": string(n.Target)}, nil)
+}
+
+// KindTransclusion is a NodeKind of the Transclusion node.
+var KindTransclusion = gast.NewNodeKind("Transclusion")
+
+// Kind implements Node.Kind.
+func (n *Transclusion) Kind() gast.NodeKind {
+	return KindTransclusion
+}
+
+// NewTransclusion returns a new Transclusion node of the article at target,
+// referenced by the source in segment.
+func NewTransclusion(target []byte, segment text.Segment) *Transclusion {
+	return &Transclusion{Target: target, Segment: segment}
+}
@@ -11,6 +11,8 @@ type WikiLink struct {
 	Link         *gast.Link
 	OriginalDest []byte
 	Classes      [][]byte
+	// Description, if set, is the link's title in place of OriginalDest.
+	Description []byte
 }
 
 // Dump implements Node.Dump.
@@ -20,6 +22,7 @@ func (l *WikiLink) Dump(source []byte, level int) {
 	m["Destination(original)"] = string(l.OriginalDest)
 	m["Title"] = string(l.Link.Title)
 	m["Classes"] = fmt.Sprintf("%s", l.Classes)
+	m["Description"] = string(l.Description)
 
 	gast.DumpHelper(l, source, level, m, nil)
 }
@@ -0,0 +1,87 @@
+package extensions
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// CategoryPrefix starts the WikiLinks that put an article in a category,
+// e.g. `[[Category:Birds]]`.
+const CategoryPrefix = "Category:"
+
+var categoriesKey = parser.NewContextKey()
+
+// Categories returns the names of the categories, in order of first
+// appearance, that the document parsed with pc was put in.
+func Categories(pc parser.Context) []string {
+	names, _ := pc.Get(categoriesKey).([]string)
+	return names
+}
+
+type categoryTransformer struct{}
+
+// Transform removes category WikiLinks from doc, noting their names in pc,
+// along with any paragraphs left empty.
+func (t *categoryTransformer) Transform(doc *gast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var links []*ast.WikiLink
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if link, ok := n.(*ast.WikiLink); ok && entering {
+			if bytes.HasPrefix(bytes.TrimLeft(link.OriginalDest, " \t"), []byte(CategoryPrefix)) {
+				links = append(links, link)
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, link := range links {
+		page, _, _ := strings.Cut(string(link.Link.Destination), "#")
+		name := strings.TrimPrefix(page, "/wiki/"+CategoryPrefix)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+
+		parent := link.Parent()
+		parent.RemoveChild(parent, link)
+		if parent.Kind() == gast.KindParagraph && isBlankParagraph(parent, source) {
+			parent.Parent().RemoveChild(parent.Parent(), parent)
+		}
+	}
+	pc.Set(categoriesKey, names)
+}
+
+// isBlankParagraph reports whether paragraph holds nothing but whitespace.
+func isBlankParagraph(paragraph gast.Node, source []byte) bool {
+	for child := paragraph.FirstChild(); child != nil; child = child.NextSibling() {
+		text, ok := child.(*gast.Text)
+		if !ok || !util.IsBlank(text.Segment.Value(source)) {
+			return false
+		}
+	}
+	return true
+}
+
+type categoryLinks struct{}
+
+// CategoryLinks takes `[[Category:Name]]` WikiLinks out of the document, to
+// put the article in the named categories rather than link to them. See
+// Categories.
+var CategoryLinks = &categoryLinks{}
+
+func (e *categoryLinks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(&categoryTransformer{}, 100),
+	))
+}
@@ -0,0 +1,108 @@
+package extensions
+
+import (
+	"bytes"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DiagramClass is the class of the div wrapping a drawn diagram, and
+// DiagramErrorClass that of the message shown when drawing fails.
+const (
+	DiagramClass      = "pw-diagram"
+	DiagramErrorClass = "pw-diagram-error"
+)
+
+// DiagramLanguages maps the fenced code block languages drawn as diagrams to
+// the language passed to the draw func.
+var DiagramLanguages = map[string]string{
+	"graphviz": "dot",
+	"dot":      "dot",
+}
+
+// DrawFunc draws source, written in language, as SVG.
+type DrawFunc func(language string, source []byte) (svg []byte, err error)
+
+type diagrammer struct {
+	draw DrawFunc
+}
+
+// NewDiagrammer draws fenced code blocks in the DiagramLanguages, e.g.
+// "```graphviz", with draw, and inlines the SVG in a div of DiagramClass. If
+// draw fails, its error is shown in their place.
+func NewDiagrammer(draw DrawFunc) goldmark.Extender {
+	return &diagrammer{draw: draw}
+}
+
+func (e *diagrammer) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(&diagramTransformer{}, 100),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&diagramRenderer{draw: e.draw}, 100),
+	))
+}
+
+type diagramTransformer struct{}
+
+// Transform replaces the fenced code blocks in diagram languages with
+// Diagram nodes.
+func (t *diagramTransformer) Transform(doc *gast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var blocks []*gast.FencedCodeBlock
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if block, ok := n.(*gast.FencedCodeBlock); ok && entering && block.Info != nil {
+			if _, ok := DiagramLanguages[codeLanguage(string(block.Info.Segment.Value(source)))]; ok {
+				blocks = append(blocks, block)
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		var code bytes.Buffer
+		lines := block.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			code.Write(line.Value(source))
+		}
+		language := DiagramLanguages[codeLanguage(string(block.Info.Segment.Value(source)))]
+		block.Parent().ReplaceChild(block.Parent(), block, ast.NewDiagram(language, code.Bytes()))
+	}
+}
+
+type diagramRenderer struct {
+	draw DrawFunc
+}
+
+func (r *diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindDiagram, r.renderDiagram)
+}
+
+func (r *diagramRenderer) renderDiagram(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkSkipChildren, nil
+	}
+	n := node.(*ast.Diagram)
+
+	svg, err := r.draw(n.Language, n.Source)
+	if err != nil {
+		_, _ = w.WriteString(`<pre class="` + DiagramErrorClass + `">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(err.Error())))
+		_, _ = w.WriteString("</pre>\n")
+		return gast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.WriteString(`<div class="` + DiagramClass + `">`)
+	_, _ = w.Write(svg)
+	_, _ = w.WriteString("</div>\n")
+	return gast.WalkSkipChildren, nil
+}
@@ -0,0 +1,114 @@
+package extensions
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// HighlightClassPrefix prefixes the CSS classes of highlighted code, so the
+// sanitizer can let exactly those through.
+const HighlightClassPrefix = "pw-hl-"
+
+// HighlightFormatter formats highlighted code as HTML with CSS classes, for
+// both the blocks and their stylesheet.
+var HighlightFormatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix(HighlightClassPrefix))
+
+type syntaxHighlighter struct {
+	style *chroma.Style
+}
+
+// NewSyntaxHighlighter highlights fenced code blocks whose info string names
+// a language chroma knows, e.g. "```go" or "``` {.go}", for style. Other code
+// blocks are rendered as usual.
+func NewSyntaxHighlighter(style *chroma.Style) goldmark.Extender {
+	return &syntaxHighlighter{style: style}
+}
+
+func (e *syntaxHighlighter) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&highlightRenderer{style: e.style}, 100),
+	))
+}
+
+type highlightRenderer struct {
+	style *chroma.Style
+}
+
+func (r *highlightRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+// codeLanguage returns the language named by a fenced code block's info
+// string, accepting the "{.lang}" attribute form as well as a bare name.
+func codeLanguage(info string) string {
+	info = strings.TrimSpace(info)
+	if strings.HasPrefix(info, "{") {
+		info = strings.Trim(info, "{}")
+		for _, field := range strings.Fields(info) {
+			if strings.HasPrefix(field, ".") {
+				return field[1:]
+			}
+		}
+		return ""
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func (r *highlightRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	language := ""
+	if n.Info != nil {
+		language = codeLanguage(string(n.Info.Segment.Value(source)))
+	}
+
+	// Only an explicitly named language is highlighted; guessing would make
+	// the output depend on chroma's heuristics.
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(language)
+	}
+	if lexer != nil {
+		tokens, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+		if err == nil {
+			var out bytes.Buffer
+			if err := HighlightFormatter.Format(&out, r.style, tokens); err == nil {
+				_, _ = w.Write(out.Bytes())
+				_ = w.WriteByte('\n')
+				return ast.WalkSkipChildren, nil
+			}
+		}
+	}
+
+	_, _ = w.WriteString("<pre><code")
+	if language != "" {
+		_, _ = w.WriteString(` class="language-`)
+		_, _ = w.Write(util.EscapeHTML([]byte(language)))
+		_ = w.WriteByte('"')
+	}
+	_ = w.WriteByte('>')
+	_, _ = w.Write(util.EscapeHTML(code.Bytes()))
+	_, _ = w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
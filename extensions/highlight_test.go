@@ -0,0 +1,50 @@
+package extensions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+)
+
+func renderHighlighted(t *testing.T, md string) string {
+	t.Helper()
+
+	markdown := goldmark.New(goldmark.WithExtensions(NewSyntaxHighlighter(styles.Get("github"))))
+
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(md), &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestSyntaxHighlighting(t *testing.T) {
+	for _, fence := range []string{"```go", "``` {.go}", "```go title=main.go"} {
+		out := renderHighlighted(t, fence+"\nfunc main() {}\n```\n")
+		if !strings.Contains(out, `<pre tabindex="0" class="pw-hl-chroma">`) || !strings.Contains(out, `<span class="pw-hl-kd">func</span>`) {
+			t.Errorf("%s: expected highlighted Go, got:\n%s", fence, out)
+		}
+		if again := renderHighlighted(t, fence+"\nfunc main() {}\n```\n"); again != out {
+			t.Errorf("%s: expected the same HTML every time, got:\n%s\nthen:\n%s", fence, out, again)
+		}
+	}
+}
+
+func TestSyntaxHighlightingFallsBack(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string
+	}{
+		{"```\n<b>plain</b>\n```\n", "<pre><code>&lt;b&gt;plain&lt;/b&gt;\n</code></pre>\n"},
+		{"```nosuchlanguage\nx\n```\n", "<pre><code class=\"language-nosuchlanguage\">x\n</code></pre>\n"},
+	}
+
+	for _, test := range tests {
+		if out := renderHighlighted(t, test.md); out != test.want {
+			t.Errorf("expected %q, got %q", test.want, out)
+		}
+	}
+}
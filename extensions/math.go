@@ -0,0 +1,178 @@
+package extensions
+
+import (
+	"bytes"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var mathDelimiter = []byte("$$")
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows Pandoc's rules for dollar-delimited math, so that prices
+// aren't mistaken for it: the opening $ must be followed by a non-space, and
+// the closing $ preceded by a non-space and not followed by a digit. Math
+// doesn't span lines.
+func (p *mathInlineParser) Parse(parent gast.Node, block text.Reader, pc parser.Context) gast.Node {
+	line, _ := block.PeekLine()
+	display := bytes.HasPrefix(line, mathDelimiter)
+	open := 1
+	if display {
+		open = 2
+	}
+	if len(line) <= open || util.IsSpace(line[open]) {
+		return nil
+	}
+
+	for i := open; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] != '$' || util.IsSpace(line[i-1]):
+		case display:
+			if i+1 < len(line) && line[i+1] == '$' {
+				block.Advance(i + 2)
+				return ast.NewMath(line[open:i], true)
+			}
+		case i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9':
+		default:
+			block.Advance(i + 1)
+			return ast.NewMath(line[open:i], false)
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Open starts a block at a line of just "$$", or takes a whole line of the
+// form "$$ ... $$" as one. Other lines starting with "$$" are left to the
+// inline parser.
+func (p *mathBlockParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	trimmed := util.TrimRightSpace(util.TrimLeftSpace(line))
+	if !bytes.HasPrefix(trimmed, mathDelimiter) {
+		return nil, parser.NoChildren
+	}
+
+	node := ast.NewMathBlock()
+	start := segment.Start + bytes.Index(line, mathDelimiter) + len(mathDelimiter)
+	if len(trimmed) == len(mathDelimiter) {
+		reader.Advance(segment.Len() - 1)
+		return node, parser.NoChildren
+	}
+	if len(trimmed) > 2*len(mathDelimiter) && bytes.HasSuffix(trimmed, mathDelimiter) {
+		node.Lines().Append(text.NewSegment(start, start+len(trimmed)-2*len(mathDelimiter)))
+		reader.Advance(segment.Len() - 1)
+		return node, parser.Close
+	}
+	return nil, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	trimmed := util.TrimRightSpace(line)
+	if bytes.HasSuffix(trimmed, mathDelimiter) {
+		if content := len(trimmed) - len(mathDelimiter); len(util.TrimLeftSpace(trimmed[:content])) > 0 {
+			node.Lines().Append(text.NewSegment(segment.Start, segment.Start+content))
+		}
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node gast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathHTMLRenderer struct{}
+
+func (r *mathHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindMath, r.renderMath)
+	reg.Register(ast.KindMathBlock, r.renderMathBlock)
+}
+
+// renderMath writes the TeX escaped within \( \) or \[ \], as Pandoc does for
+// KaTeX, for a script to typeset.
+func (r *mathHTMLRenderer) renderMath(w util.BufWriter, source []byte, n gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkSkipChildren, nil
+	}
+	math := n.(*ast.Math)
+	if math.Display {
+		_, _ = w.WriteString(`<span class="math display">\[`)
+		_, _ = w.Write(util.EscapeHTML(math.TeX))
+		_, _ = w.WriteString(`\]</span>`)
+	} else {
+		_, _ = w.WriteString(`<span class="math inline">\(`)
+		_, _ = w.Write(util.EscapeHTML(math.TeX))
+		_, _ = w.WriteString(`\)</span>`)
+	}
+	return gast.WalkSkipChildren, nil
+}
+
+func (r *mathHTMLRenderer) renderMathBlock(w util.BufWriter, source []byte, n gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString(`<div class="math display">\[`)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		_, _ = w.Write(util.EscapeHTML(line.Value(source)))
+	}
+	_, _ = w.WriteString("\\]</div>\n")
+	return gast.WalkSkipChildren, nil
+}
+
+type math struct{}
+
+// Math parses TeX between dollar signs: `$...$` inline, and `$$...$$`
+// inline or on lines of its own for display. It is rendered as the escaped
+// TeX in `<span class="math inline">` or `<span|div class="math display">`,
+// for KaTeX or MathJax to typeset in the browser. Code is left alone.
+var Math = &math{}
+
+func (e *math) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(&mathBlockParser{}, 150),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(&mathInlineParser{}, 150),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&mathHTMLRenderer{}, 500),
+	))
+}
@@ -0,0 +1,38 @@
+package extensions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string
+	}{
+		{"Euler: $e^{i\\pi} + 1 = 0$.", "<p>Euler: <span class=\"math inline\">\\(e^{i\\pi} + 1 = 0\\)</span>.</p>\n"},
+		{"It costs $5 or $10.", "<p>It costs $5 or $10.</p>\n"},
+		{"From $ x $ to $x$", "<p>From $ x $ to <span class=\"math inline\">\\(x\\)</span></p>\n"},
+		{"Not $x$5 but \\$x\\$", "<p>Not $x$5 but $x$</p>\n"},
+		{"$a<b$", "<p><span class=\"math inline\">\\(a&lt;b\\)</span></p>\n"},
+		{"So $$\\sum x$$ is", "<p>So <span class=\"math display\">\\[\\sum x\\]</span> is</p>\n"},
+		{"$$x^2$$\n", "<div class=\"math display\">\\[x^2\\]</div>\n"},
+		{"Before\n$$\n\\frac{1}{2}\n</div><script>\n$$\nafter\n", "<p>Before</p>\n<div class=\"math display\">\\[\\frac{1}{2}\n&lt;/div&gt;&lt;script&gt;\n\\]</div>\n<p>after</p>\n"},
+		{"`$x$`", "<p><code>$x$</code></p>\n"},
+		{"```\n$$\nx\n$$\n```\n", "<pre><code>$$\nx\n$$\n</code></pre>\n"},
+		{"    $$x$$\n", "<pre><code>$$x$$\n</code></pre>\n"},
+	}
+
+	markdown := goldmark.New(goldmark.WithExtensions(Math))
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(test.md), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%q: expected:\n%s\ngot:\n%s", test.md, test.want, buf.String())
+		}
+	}
+}
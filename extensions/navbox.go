@@ -0,0 +1,84 @@
+package extensions
+
+import (
+	"regexp"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// NavboxClass is applied to the placeholder a Navbox renders to.
+const NavboxClass = "pw-navbox"
+
+var navboxRegexp = regexp.MustCompile(`^\{\{\s*nav:\s*([^{}|#]+?)\s*\}\}\s*$`)
+
+type navboxParser struct{}
+
+func (p *navboxParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (p *navboxParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	m := navboxRegexp.FindSubmatch(line)
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+	reader.Advance(len(line))
+	return ast.NewNavbox([]byte(NormalizeURL(string(m[1])))), parser.NoChildren
+}
+
+func (p *navboxParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (p *navboxParser) Close(node gast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *navboxParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *navboxParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type navboxHTMLRenderer struct{}
+
+func (r *navboxHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindNavbox, r.renderNavbox)
+}
+
+// renderNavbox writes an empty placeholder naming the embedded article. The
+// article's HTML is filled in after rendering, since it is already rendered.
+func (r *navboxHTMLRenderer) renderNavbox(w util.BufWriter, source []byte, n gast.Node, entering bool) (gast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(`<div class="` + NavboxClass + `" data-navbox="`)
+		_, _ = w.Write(util.EscapeHTML(n.(*ast.Navbox).Target))
+		_, _ = w.WriteString("\"></div>\n")
+	}
+	return gast.WalkSkipChildren, nil
+}
+
+type navboxes struct{}
+
+// Navboxes parses lines of the form `{{nav:Some Navbox}}` into Navbox nodes,
+// rendered as empty `<div class="pw-navbox" data-navbox="Some_Navbox">`
+// placeholders for the caller to fill with the named article's HTML.
+var Navboxes = &navboxes{}
+
+func (e *navboxes) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(&navboxParser{}, 150),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&navboxHTMLRenderer{}, 500),
+	))
+}
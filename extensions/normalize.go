@@ -0,0 +1,15 @@
+package extensions
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeURL returns the canonical form of the article URL raw: trimmed,
+// with each run of whitespace made a single underscore, in Unicode NFC. It
+// is the rule every WikiLink, navbox and transclusion target follows; see
+// wiki.NormalizeURL.
+func NormalizeURL(raw string) string {
+	return norm.NFC.String(underscoreRegexp.ReplaceAllString(strings.TrimSpace(raw), "_"))
+}
@@ -0,0 +1,67 @@
+package extensions
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var transclusionRegexp = regexp.MustCompile(`^\{\{\s*([^{}|#\s][^{}|#\n]*?)\s*\}\}`)
+
+type transclusionParser struct{}
+
+func (p *transclusionParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (p *transclusionParser) Parse(parent gast.Node, block text.Reader, pc parser.Context) gast.Node {
+	line, segment := block.PeekLine()
+	m := transclusionRegexp.FindSubmatchIndex(line)
+	if m == nil || bytes.HasPrefix(line[m[2]:m[3]], []byte("nav:")) {
+		return nil
+	}
+	block.Advance(m[1])
+	target := []byte(NormalizeURL(string(line[m[2]:m[3]])))
+	return ast.NewTransclusion(target, text.NewSegment(segment.Start, segment.Start+m[1]))
+}
+
+type transclusionHTMLRenderer struct{}
+
+func (r *transclusionHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindTransclusion, r.renderTransclusion)
+}
+
+// renderTransclusion writes a reference that was never expanded as it was
+// written.
+func (r *transclusionHTMLRenderer) renderTransclusion(w util.BufWriter, source []byte, n gast.Node, entering bool) (gast.WalkStatus, error) {
+	if entering {
+		_, _ = w.Write(util.EscapeHTML(n.(*ast.Transclusion).Segment.Value(source)))
+	}
+	return gast.WalkSkipChildren, nil
+}
+
+type transclusions struct{}
+
+// Transclusions parses `{{Page Name}}` into Transclusion nodes, for the
+// caller to replace with the named article's markdown before rendering.
+// Navboxes, `{{nav:...}}`, are left alone, as is anything in code.
+var Transclusions = &transclusions{}
+
+func (e *transclusions) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(&transclusionParser{}, 150),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&transclusionHTMLRenderer{}, 500),
+	))
+}
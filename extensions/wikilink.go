@@ -28,6 +28,15 @@ type WikiLinkResolver interface {
 	Resolve(dest []byte) (actual []byte, classes [][]byte)
 }
 
+// DescribingResolver is a WikiLinkResolver that can also describe where a
+// link leads, such as the article a redirect sends readers on to. The
+// description becomes the link's title. The parser calls ResolveDescribed in
+// place of Resolve when a resolver has it.
+type DescribingResolver interface {
+	WikiLinkResolver
+	ResolveDescribed(dest []byte) (actual []byte, classes [][]byte, description []byte)
+}
+
 type identityResolver struct{}
 
 func (r *identityResolver) Resolve(dest []byte) (actual []byte, classes [][]byte) {
@@ -128,7 +137,13 @@ func (p *wikiLinkerParser) Parse(parent gast.Node, block text.Reader, pc parser.
 		return nil
 	}
 
-	actualDest, classes := p.Resolve(originalDest)
+	var actualDest, description []byte
+	var classes [][]byte
+	if describing, ok := p.WikiLinkResolver.(DescribingResolver); ok {
+		actualDest, classes, description = describing.ResolveDescribed(originalDest)
+	} else {
+		actualDest, classes = p.Resolve(originalDest)
+	}
 	if actualDest == nil {
 		return nil
 	}
@@ -138,7 +153,9 @@ func (p *wikiLinkerParser) Parse(parent gast.Node, block text.Reader, pc parser.
 
 	block.Advance(length)
 
-	return ast.NewWikiLink(title, originalDest, actualDest, classes)
+	link := ast.NewWikiLink(title, originalDest, actualDest, classes)
+	link.Description = description
+	return link
 }
 
 type wikiLinker struct {
@@ -191,7 +208,9 @@ func (r *wikiLinkerHTMLRenderer) renderWikiLinker(w util.BufWriter, source []byt
 		}
 		_ = w.WriteByte('"')
 
-		if node.Link.Title != nil {
+		if node.Description != nil {
+			node.SetAttributeString("title", node.Description)
+		} else if node.Link.Title != nil {
 			node.SetAttributeString("title", node.OriginalDest)
 		}
 
@@ -0,0 +1,119 @@
+package extensions
+
+import (
+	"bytes"
+
+	gast "github.com/yuin/goldmark/ast"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+)
+
+// DeadLinkClass is applied to WikiLinks whose destination does not exist.
+const DeadLinkClass = "pw-deadlink"
+
+// DeadAnchorClass is applied to WikiLinks to a section that their existing
+// destination doesn't have.
+const DeadAnchorClass = "pw-deadanchor"
+
+// RedirectLinkClass is applied to WikiLinks whose destination is a redirect.
+const RedirectLinkClass = "pw-redirectlink"
+
+type deadLinkResolver struct {
+	underscoreResolver
+	exists    func(url string) bool
+	hasAnchor func(url, anchor string) bool
+	redirect  func(url string) (target string, ok bool)
+}
+
+func (r *deadLinkResolver) Resolve(original []byte) ([]byte, [][]byte) {
+	dest, classes, _ := r.ResolveDescribed(original)
+	return dest, classes
+}
+
+func (r *deadLinkResolver) ResolveDescribed(original []byte) ([]byte, [][]byte, []byte) {
+	dest, classes := r.underscoreResolver.Resolve(original)
+	page, anchor, hasAnchor := bytes.Cut(dest, []byte{'#'})
+	if len(page) == 0 {
+		return dest, classes, nil
+	}
+	url := string(bytes.TrimPrefix(page, []byte("/wiki/")))
+	if !r.exists(url) {
+		return dest, append(classes, []byte(DeadLinkClass)), nil
+	}
+
+	// Sections of a redirect are looked for in the article it leads to.
+	var description []byte
+	if r.redirect != nil {
+		if target, ok := r.redirect(url); ok {
+			classes = append(classes, []byte(RedirectLinkClass))
+			targetPage, _, _ := bytes.Cut([]byte(target), []byte{'#'})
+			description = append([]byte("Redirects to "), bytes.ReplaceAll(targetPage, []byte{'_'}, []byte{' '})...)
+			url = string(targetPage)
+		}
+	}
+	if hasAnchor && r.hasAnchor != nil && !r.hasAnchor(url, string(anchor)) {
+		classes = append(classes, []byte(DeadAnchorClass))
+	}
+	return dest, classes, description
+}
+
+// WithDeadLinkResolver resolves WikiLinks like WithUnderscoreResolver and
+// adds DeadLinkClass to links for which exists returns false. exists is
+// passed the article URL without any fragment, e.g.
+// `Disambiguation_(Disambiguation)`. Links within the page are never dead.
+func WithDeadLinkResolver(exists func(url string) bool) WikiLinkerOption {
+	return WithCustomResolver(&deadLinkResolver{exists: exists})
+}
+
+// WithDeadAnchorResolver is WithDeadLinkResolver that also adds
+// DeadAnchorClass to links to a section for which hasAnchor returns false.
+// hasAnchor is passed the article URL and the section's id, e.g. `Glossary`
+// and `render-queue`. Links within the page are left to MarkDeadSelfAnchors.
+func WithDeadAnchorResolver(exists func(url string) bool, hasAnchor func(url, anchor string) bool) WikiLinkerOption {
+	return WithCustomResolver(&deadLinkResolver{exists: exists, hasAnchor: hasAnchor})
+}
+
+// WithRedirectAwareResolver is WithDeadAnchorResolver that also recognises
+// links to redirects, for which redirect returns the URL of the article they
+// lead to, perhaps with a fragment. Such links get RedirectLinkClass and a
+// title naming that article, and their sections are checked in it.
+// hasAnchor may be nil to leave sections unchecked.
+func WithRedirectAwareResolver(exists func(url string) bool, hasAnchor func(url, anchor string) bool, redirect func(url string) (string, bool)) WikiLinkerOption {
+	return WithCustomResolver(&deadLinkResolver{exists: exists, hasAnchor: hasAnchor, redirect: redirect})
+}
+
+// Anchors returns the ids goldmark gave the headings and definition terms in
+// doc, in order.
+func Anchors(doc gast.Node) []string {
+	anchors := []string{}
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if !entering {
+			return gast.WalkContinue, nil
+		}
+		if id, ok := n.AttributeString("id"); ok {
+			if id, ok := id.([]byte); ok {
+				anchors = append(anchors, string(id))
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+	return anchors
+}
+
+// MarkDeadSelfAnchors adds DeadAnchorClass to WikiLinks within doc, such as
+// `[[#Section]]`, to sections doc doesn't have.
+func MarkDeadSelfAnchors(doc gast.Node) {
+	ids := make(map[string]bool)
+	for _, id := range Anchors(doc) {
+		ids[id] = true
+	}
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if link, ok := n.(*ast.WikiLink); ok && entering {
+			dest := link.Link.Destination
+			if len(dest) > 0 && dest[0] == '#' && !ids[string(dest[1:])] {
+				link.Classes = append(link.Classes, []byte(DeadAnchorClass))
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+}
@@ -3,6 +3,8 @@ package extensions
 import (
 	"bytes"
 	"regexp"
+
+	"github.com/yuin/goldmark/util"
 )
 
 var underscoreRegexp = regexp.MustCompile(`\s+`)
@@ -10,13 +12,51 @@ var underscoreRegexp = regexp.MustCompile(`\s+`)
 type underscoreResolver struct{}
 
 func (r *underscoreResolver) Resolve(original []byte) ([]byte, [][]byte) {
-	return append([]byte("/wiki/"), underscoreRegexp.ReplaceAll(bytes.Trim(original, " \t"), []byte{'_'})...), nil
+	page, fragment, hasFragment := bytes.Cut(original, []byte{'#'})
+	page = []byte(NormalizeURL(string(page)))
+
+	var dest []byte
+	if len(page) > 0 || !hasFragment {
+		dest = append([]byte("/wiki/"), page...)
+	}
+	if hasFragment {
+		dest = append(append(dest, '#'), anchorSlug(fragment)...)
+	}
+	return dest, nil
+}
+
+// anchorSlug converts a WikiLink fragment to the id goldmark generates for a
+// heading or definition term with that text, less any suffix added to make
+// it unique.
+func anchorSlug(fragment []byte) []byte {
+	fragment = util.TrimRightSpace(util.TrimLeftSpace(fragment))
+	slug := []byte{}
+	for i := 0; i < len(fragment); {
+		c := fragment[i]
+		l := util.UTF8Len(c)
+		i += int(l)
+		if l != 1 {
+			continue
+		}
+		if util.IsAlphaNumeric(c) {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			slug = append(slug, c)
+		} else if util.IsSpace(c) || c == '-' || c == '_' {
+			slug = append(slug, '-')
+		}
+	}
+	return slug
 }
 
-// WithUnderscoreResolver replaces all whitespace in WikiLinks with
-// underscores. Contiguous spaces are merged into a single underscore.
+// WithUnderscoreResolver links WikiLinks to the article URL NormalizeURL
+// makes of them, which replaces all whitespace with underscores, merging
+// contiguous spaces into a single underscore. A fragment after '#' is
+// converted to the matching heading or definition term id.
 //
 // e.g.: `[[ Disambiguation (Disambiguation) ]]` becomes `Disambiguation_(Disambiguation)`
+// and `[[Glossary#Render queue]]` becomes `Glossary#render-queue`
 func WithUnderscoreResolver() WikiLinkerOption {
 	return WithCustomResolver(&underscoreResolver{})
 }
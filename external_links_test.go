@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestExternalLinks(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.MarkExternalLinks = true
+		c.ExternalLinkIcon = true
+	})
+
+	md := "[Elsewhere](https://example.com/page) [Here](http://localhost/wiki/Home) [Relative](/wiki/Home) [[Missing]] <https://example.org/>\n"
+	article := mustPostArticle(t, a, "Links", md, 0)
+
+	for _, want := range []string{
+		`<a href="https://example.com/page" rel="nofollow noopener" target="_blank" class="pw-external">Elsewhere</a>`,
+		`<a href="http://localhost/wiki/Home" rel="nofollow">Here</a>`,
+		`<a href="/wiki/Home" rel="nofollow">Relative</a>`,
+		`<a href="https://example.org/" rel="nofollow noopener" target="_blank" class="pw-external">https://example.org/</a>`,
+	} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s:\n%s", want, article.HTML)
+		}
+	}
+	if !strings.Contains(article.HTML, `class="pw-deadlink"`) || strings.Count(article.HTML, `target="_blank"`) != 2 {
+		t.Errorf("expected WikiLinks to be left alone:\n%s", article.HTML)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Links", md, 0)
+	if strings.Contains(article.HTML, "noopener") || strings.Contains(article.HTML, "pw-external") {
+		t.Errorf("expected external links to be left alone without MarkExternalLinks:\n%s", article.HTML)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// feedEntries is how many of an article's latest revisions its feed lists.
+const feedEntries = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Summary string      `xml:"summary,omitempty"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// articleFeedHandler serves an Atom feed of an article's latest revisions,
+// each with its diff against the revision before it.
+func (a *app) articleFeedHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+
+	if !a.visible(url, req) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrGenericNotFound)
+		return
+	}
+
+	history, err := a.GetRevisionHistory(url)
+	if err != nil {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	}
+	if len(history) > feedEntries {
+		history = history[:feedEntries]
+	}
+
+	// The history doesn't carry each revision's Markdown, so it is loaded
+	// as needed, once per revision.
+	markdown := map[int]string{0: ""}
+	source := func(id int) (string, error) {
+		if md, ok := markdown[id]; ok {
+			return md, nil
+		}
+		revision, err := a.GetArticleByRevisionID(url, id)
+		if err != nil {
+			return "", err
+		}
+		markdown[id] = revision.Markdown
+		return revision.Markdown, nil
+	}
+
+	feed := atomFeed{
+		Xmlns:   atomNamespace,
+		ID:      absoluteURL(req, "/wiki/"+url+"/history"),
+		Title:   "History of " + url,
+		Updated: history[0].Created.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: absoluteURL(req, "/wiki/"+url+"/history")},
+			{Href: absoluteURL(req, "/wiki/"+url) + "?feed", Rel: "self"},
+		},
+	}
+	for _, revision := range history {
+		new, err := source(revision.ID)
+		if err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+		original, err := source(revision.PreviousID)
+		if err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+
+		article := &wiki.Article{URL: url, Revision: revision}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      absoluteURL(req, "/wiki/"+url+"/r/"+fmt.Sprint(revision.ID)),
+			Title:   revision.Title,
+			Updated: revision.Created.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: revision.Creator.ScreenName},
+			Link:    atomLink{Href: absoluteURL(req, article.DiffURL(""))},
+			Summary: revision.Comment,
+			Content: atomContent{Type: "html", Body: "<pre>" + renderDiff(original, new) + "</pre>"},
+		})
+	}
+
+	rw.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = rw.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(rw)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestArticleFeed(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	mustPostArticle(t, a, "Cats", "Cats are great.", 1)
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Cats?feed", nil, nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("expected an Atom feed, got %d (%s):\n%s", rr.Code, rr.Header().Get("Content-Type"), rr.Body)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected an entry per revision, got %d", len(feed.Entries))
+	}
+
+	edit := feed.Entries[0]
+	if !strings.HasSuffix(edit.Link.Href, "/wiki/Cats/diff/1/2") {
+		t.Errorf("expected the edit to link to its diff, got %q", edit.Link.Href)
+	}
+	if !strings.Contains(edit.Content.Body, "<del") || !strings.Contains(edit.Content.Body, "<ins") {
+		t.Errorf("expected the edit's diff as its content, got %q", edit.Content.Body)
+	}
+	if creation := feed.Entries[1]; !strings.Contains(creation.Content.Body, "Cats are nice.") || strings.Contains(creation.Content.Body, "<del") {
+		t.Errorf("expected the creation's diff to add the whole article, got %q", creation.Content.Body)
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Dogs?feed", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing article, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// flashCookie carries a message to the page the user is redirected to.
+const flashCookie = "periwiki-flash"
+
+// flashKey is for context.Context.
+const flashKey wiki.ContextKey = "periwiki.flash"
+
+// FlashMiddleware moves the flash message, if any, into the request context
+// and clears it, so it is shown exactly once.
+func (a *app) FlashMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cookie, err := req.Cookie(flashCookie); err == nil {
+			if message, err := url.QueryUnescape(cookie.Value); err == nil {
+				req = req.WithContext(context.WithValue(req.Context(), flashKey, message))
+			}
+			http.SetCookie(rw, &http.Cookie{Name: flashCookie, Path: "/", MaxAge: -1})
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// setFlash shows message on the next page the user sees.
+func setFlash(rw http.ResponseWriter, req *http.Request, message string) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     flashCookie,
+		Value:    url.QueryEscape(message),
+		Path:     "/",
+		Secure:   isHTTPS(req),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// flashMessage returns the flash message carried by ctx, if any.
+func flashMessage(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	message, _ := ctx.Value(flashKey).(string)
+	return message
+}
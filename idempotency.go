@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyTTL is how long a completed POST is remembered by its key.
+const idempotencyKeyTTL = 10 * time.Minute
+
+// idempotencyKeys remembers recently handled POSTs by the Idempotency-Key the
+// client sent with them, so that a retried or double-submitted request can be
+// answered with the original result instead of being applied twice.
+type idempotencyKeys struct {
+	mu    sync.Mutex
+	posts map[string]*idempotentPost
+}
+
+type idempotentPost struct {
+	done chan struct{}
+	// location is where the request redirected to, or empty if it failed.
+	location string
+	expires  time.Time
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{posts: make(map[string]*idempotentPost)}
+}
+
+// idempotencyKey returns the key sent with req in the Idempotency-Key header
+// or the idempotency_key form field, if any.
+func idempotencyKey(req *http.Request) string {
+	if key := req.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return req.PostFormValue("idempotency_key")
+}
+
+// newIdempotencyKey returns a random key for a form to submit with.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// begin claims key. If it was already claimed, begin waits for that request
+// to finish and returns where it redirected to, with ok set. Otherwise the
+// caller must call finish once it has handled the request.
+func (k *idempotencyKeys) begin(key string) (location string, ok bool) {
+	for {
+		k.mu.Lock()
+		now := time.Now()
+		for other, post := range k.posts {
+			if !post.expires.IsZero() && now.After(post.expires) {
+				delete(k.posts, other)
+			}
+		}
+
+		post, claimed := k.posts[key]
+		if !claimed {
+			k.posts[key] = &idempotentPost{done: make(chan struct{})}
+			k.mu.Unlock()
+			return "", false
+		}
+		k.mu.Unlock()
+
+		<-post.done
+		if post.location != "" {
+			return post.location, true
+		}
+		// The earlier request failed and released the key; try to claim it.
+	}
+}
+
+// finish records the outcome of the request that claimed key. A request that
+// failed releases the key, so it may be retried.
+func (k *idempotencyKeys) finish(key string, location string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	post := k.posts[key]
+	post.location = location
+	if location == "" {
+		delete(k.posts, key)
+	} else {
+		post.expires = time.Now().Add(idempotencyKeyTTL)
+	}
+	close(post.done)
+}
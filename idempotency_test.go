@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestIdempotentArticlePost(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	edit := func(key string) *http.Request {
+		form := url.Values{"title": {"Cats"}, "body": {"Cats are very nice."}, "action": {"submit"}}
+		req := newRequest(http.MethodPost, "/wiki/Cats/r/1", form, nil)
+		req.Header.Set("Idempotency-Key", key)
+		return req
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := serve(a, edit("retry-me"))
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/wiki/Cats" {
+			t.Fatalf("submission %d: expected a redirect to the article, got %d %q", i+1, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	history, err := a.GetRevisionHistory("Cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected one new revision, got %d revisions", len(history))
+	}
+
+	// A different key is a different request, so it gets the usual conflict.
+	if rr := serve(a, edit("another")); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second edit of the same base, got %d", rr.Code)
+	}
+}
+
+func TestIdempotencyKeyReleasedOnFailure(t *testing.T) {
+	a := newTestApp(t)
+
+	form := url.Values{"title": {"Cats"}, "body": {"Cats."}, "action": {"submit"}, "idempotency_key": {"k"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Talk:Cats/r/0", form, nil)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a talk page without a subject, got %d", rr.Code)
+	}
+
+	mustPostArticle(t, a, "Cats", "Cats.", 0)
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Talk:Cats/r/0", form, nil))
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected the retry to be processed after a failure, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// maxImportSize limits the size of an uploaded import archive.
+const maxImportSize = 64 << 20 // 64 MiB
+
+// maxDumpSize limits the size of an uploaded MediaWiki dump. Dumps are read as
+// they arrive, so they may be larger than archives.
+const maxDumpSize = 256 << 20 // 256 MiB
+
+// maxArticleSize limits the markdown of each imported article, as it is
+// decompressed or converted. It matches the limit net/http puts on the edit
+// form.
+const maxArticleSize = 10 << 20 // 10 MiB
+
+var (
+	errArticleTooLarge      = errors.New("article is larger than 10 MiB")
+	errNotMarkdown          = errors.New("not a markdown file")
+	errNoDump               = errors.New("no MediaWiki dump was uploaded")
+	errUnsupportedNamespace = errors.New("only main and talk pages can be imported")
+)
+
+// importResult reports what became of one file in an import archive.
+type importResult struct {
+	File    string
+	URL     string
+	Outcome string
+	Err     error
+}
+
+func (a *app) importHandler(rw http.ResponseWriter, req *http.Request) {
+	err := a.RenderTemplate(rw, "manage_import.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Import"},
+		"Context": req.Context(),
+	})
+	check(err)
+}
+
+// importPostHandler creates or updates an article for each *.md file in the
+// uploaded ZIP, named after the file, and reports the outcome for each.
+func (a *app) importPostHandler(rw http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(rw, req.Body, maxImportSize)
+	file, header, err := req.FormFile("archive")
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+
+	type pendingImport struct {
+		file   *zip.File
+		result *importResult
+	}
+	var results []*importResult
+	var pending []pendingImport
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		result := &importResult{File: f.Name}
+		results = append(results, result)
+		if !strings.HasSuffix(f.Name, ".md") {
+			result.Outcome, result.Err = wiki.ImportSkipped, errNotMarkdown
+			continue
+		}
+		result.URL = wiki.NormalizeURL(strings.TrimSuffix(path.Base(f.Name), ".md"))
+		pending = append(pending, pendingImport{f, result})
+	}
+
+	// Talk pages go last so that their subjects in the same archive exist.
+	sort.SliceStable(pending, func(i, j int) bool {
+		return !wiki.IsTalkPage(pending[i].result.URL) && wiki.IsTalkPage(pending[j].result.URL)
+	})
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	for _, p := range pending {
+		markdown, err := readZipFile(p.file)
+		if err != nil {
+			p.result.Outcome, p.result.Err = wiki.ImportError, err
+			continue
+		}
+		p.result.Outcome, p.result.Err = a.ImportArticle(p.result.URL, "", markdown, user)
+	}
+
+	a.renderImportResults(rw, req, results, results)
+}
+
+// importMediaWikiPostHandler creates or updates an article for each main and
+// talk page in an uploaded MediaWiki XML dump, converting its wikitext to
+// markdown. The upload is read as it arrives rather than buffered, and only
+// the pages that weren't imported are listed afterwards.
+func (a *app) importMediaWikiPostHandler(rw http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(rw, req.Body, maxDumpSize)
+	reader, err := req.MultipartReader()
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+	var dump *multipart.Part
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			a.errorHandler(http.StatusBadRequest, rw, req, errNoDump)
+			return
+		} else if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, err)
+			return
+		}
+		if part.FormName() == "dump" {
+			dump = part
+			break
+		}
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	var results []*importResult
+	var talk []func()
+	importPage := func(page *wiki.MediaWikiPage, result *importResult) {
+		if len(page.Text) > maxArticleSize {
+			result.Outcome, result.Err = wiki.ImportError, errArticleTooLarge
+			return
+		}
+		result.Outcome, result.Err = a.ImportArticle(result.URL, page.Title, wiki.MediaWikiToMarkdown(page.Text), user)
+	}
+	err = wiki.ReadMediaWikiDump(dump, func(page *wiki.MediaWikiPage) error {
+		result := &importResult{File: page.Title, URL: page.URL()}
+		results = append(results, result)
+		switch page.Namespace {
+		case wiki.MediaWikiMainNamespace:
+			importPage(page, result)
+		case wiki.MediaWikiTalkNamespace:
+			// Talk pages wait until every subject in the dump exists.
+			talk = append(talk, func() { importPage(page, result) })
+		default:
+			result.URL = ""
+			result.Outcome, result.Err = wiki.ImportSkipped, errUnsupportedNamespace
+		}
+		return nil
+	})
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+	for _, importTalkPage := range talk {
+		importTalkPage()
+	}
+
+	var notImported []*importResult
+	for _, result := range results {
+		if result.Outcome == wiki.ImportSkipped || result.Outcome == wiki.ImportError {
+			notImported = append(notImported, result)
+		}
+	}
+	a.renderImportResults(rw, req, results, notImported)
+}
+
+// renderImportResults shows how many of results had each outcome and lists
+// those in listed.
+func (a *app) renderImportResults(rw http.ResponseWriter, req *http.Request, results, listed []*importResult) {
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Outcome]++
+	}
+	err := a.RenderTemplate(rw, "manage_import.html", "index.html", map[string]interface{}{
+		"Article":  map[string]string{"Title": "Import"},
+		"Context":  req.Context(),
+		"Imported": true,
+		"Counts":   counts,
+		"Outcomes": []string{wiki.ImportCreated, wiki.ImportUpdated, wiki.ImportSkipped, wiki.ImportError},
+		"Results":  listed,
+	})
+	check(err)
+}
+
+// readZipFile returns the content of f, or errArticleTooLarge once it
+// decompresses to more than maxArticleSize, whatever its header claims.
+func readZipFile(f *zip.File) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, maxArticleSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxArticleSize {
+		return "", errArticleTooLarge
+	}
+	return string(content), nil
+}
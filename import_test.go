@@ -0,0 +1,151 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestImport(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustPostArticle(t, a, "Unchanged", "Same.", 0)
+	mustPostArticle(t, a, "Changed", "Old.", 0)
+
+	var zipped bytes.Buffer
+	archive := zip.NewWriter(&zipped)
+	for _, file := range []struct{ name, content string }{
+		{"Talk:New.md", "Discussion."},
+		{"articles/New.md", "---\ndisplay_title: A New Article\n---\nFresh."},
+		{"Unchanged.md", "Same."},
+		{"Changed.md", "New."},
+		{"Talk:Missing.md", "Nobody's here."},
+		{"notes.txt", "Not an article."},
+	} {
+		w, _ := archive.Create(file.name)
+		w.Write([]byte(file.content))
+	}
+	archive.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("archive", "import.zip")
+	part.Write(zipped.Bytes())
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(login(t, a, "admin"))
+	rr := serve(a, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	page := rr.Body.String()
+	for file, outcome := range map[string]string{
+		"Talk:New.md":     wiki.ImportCreated,
+		"articles/New.md": wiki.ImportCreated,
+		"Unchanged.md":    wiki.ImportSkipped,
+		"Changed.md":      wiki.ImportUpdated,
+		"Talk:Missing.md": wiki.ImportSkipped,
+		"notes.txt":       wiki.ImportSkipped,
+	} {
+		if !strings.Contains(page, `<tr class="pw-import-`+outcome+`">
+                    <td>`+file+`</td>`) {
+			t.Errorf("expected %s to be %s, got:\n%s", file, outcome, page)
+		}
+	}
+
+	article, err := a.GetArticle("New")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "A New Article" {
+		t.Errorf("expected the title from the frontmatter, got %q", article.Title)
+	}
+	if article, err := a.GetArticle("Changed"); err != nil || article.Markdown != "New." {
+		t.Errorf("expected Changed to be updated, got %+v (%v)", article, err)
+	}
+	if _, err := a.GetArticle("Talk:Missing"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected Talk:Missing not to be created, got %v", err)
+	}
+}
+
+func TestImportRejectsOversizedFiles(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+
+	// Compresses to a few KiB, well within the upload limit.
+	var zipped bytes.Buffer
+	archive := zip.NewWriter(&zipped)
+	w, _ := archive.Create("Bomb.md")
+	w.Write(bytes.Repeat([]byte("a"), maxArticleSize+1))
+	archive.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("archive", "import.zip")
+	part.Write(zipped.Bytes())
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(login(t, a, "admin"))
+	rr := serve(a, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), errArticleTooLarge.Error()) {
+		t.Errorf("expected the oversized file to be refused, got %d:\n%s", rr.Code, rr.Body)
+	}
+	if _, err := a.GetArticle("Bomb"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected Bomb not to be created, got %v", err)
+	}
+}
+
+func TestImportMediaWiki(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+
+	// The talk page comes first, before its subject exists.
+	dump := `<mediawiki>
+  <page><title>Talk:Domestic cat</title><ns>1</ns><revision><text>Discuss.</text></revision></page>
+  <page><title>Domestic cat</title><ns>0</ns><revision><text>== Diet ==
+'''Cats''' eat [[Mouse|mice]].</text></revision></page>
+  <page><title>Template:Stub</title><ns>10</ns><revision><text>Short.</text></revision></page>
+</mediawiki>`
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("dump", "pages-articles.xml")
+	part.Write([]byte(dump))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/import/mediawiki", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(login(t, a, "admin"))
+	rr := serve(a, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	page := rr.Body.String()
+	if !strings.Contains(page, "2 created, 0 updated, 1 skipped, 0 error.") {
+		t.Errorf("expected a summary of the import, got:\n%s", page)
+	}
+	if !strings.Contains(page, "Template:Stub") || strings.Contains(page, `href="/wiki/Domestic_cat"`) {
+		t.Errorf("expected only the skipped page to be listed, got:\n%s", page)
+	}
+
+	article, err := a.GetArticle("Domestic_cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "Domestic cat" || article.Markdown != "## Diet\n**Cats** eat [[Mouse|mice]]." {
+		t.Errorf("expected the page to be converted, got %q: %q", article.Title, article.Markdown)
+	}
+	if _, err := a.GetArticle("Talk:Domestic_cat"); err != nil {
+		t.Errorf("expected the talk page to be imported after its subject, got %v", err)
+	}
+}
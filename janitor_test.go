@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// insertSession adds a session expiring at expires, as the session store
+// would.
+func insertSession(t *testing.T, a *app, expires time.Time) {
+	t.Helper()
+	execSQL(t, a, `INSERT INTO sessions (session_data, created_on, modified_on, expires_on) VALUES ('', ?, ?, ?)`,
+		time.Now(), time.Now(), expires)
+}
+
+func countSessions(t *testing.T, a *app) int {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", a.DatabaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var n int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	a := newTestApp(t)
+	insertSession(t, a, time.Now().Add(-time.Hour))
+	insertSession(t, a, time.Now().Add(-time.Minute).In(time.FixedZone("UTC+5", 5*60*60)))
+	insertSession(t, a, time.Now().Add(time.Hour))
+	insertSession(t, a, time.Now().Add(time.Minute).In(time.FixedZone("UTC-5", -5*60*60)))
+
+	n, err := a.DeleteExpiredSessions(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 expired sessions to be deleted, got %d", n)
+	}
+	if remaining := countSessions(t, a); remaining != 2 {
+		t.Errorf("expected the 2 active sessions to remain, got %d", remaining)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.CleanupInterval = 5 * time.Millisecond
+	})
+	insertSession(t, a, time.Now().Add(-time.Hour))
+	insertSession(t, a, time.Now().Add(time.Hour))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countSessions(t, a) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the janitor to delete the expired session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		a.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the janitor to stop on shutdown")
+	}
+
+	// Nothing is swept once the janitor has stopped.
+	insertSession(t, a, time.Now().Add(-time.Hour))
+	time.Sleep(20 * time.Millisecond)
+	if n := countSessions(t, a); n != 2 {
+		t.Errorf("expected no sweeps after shutdown, got %d sessions", n)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielledeleo/periwiki/renderqueue"
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+const maxAssetSize = 1 << 20 // 1 MiB
+
+var errAdminOnly = errors.New("only administrators can manage the wiki")
+var errEditorOnly = errors.New("only editors and administrators can delete or move articles")
+
+// adminOnly wraps handler so that it is only reachable by admins.
+func (a *app) adminOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !req.Context().Value(wiki.UserKey).(*wiki.User).IsAdmin() {
+			a.errorHandler(http.StatusForbidden, rw, req, errAdminOnly)
+			return
+		}
+		handler(rw, req)
+	}
+}
+
+// editorOnly wraps handler so that it is only reachable by users who may
+// manage articles.
+func (a *app) editorOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !req.Context().Value(wiki.UserKey).(*wiki.User).CanManageArticles() {
+			a.errorHandler(http.StatusForbidden, rw, req, errEditorOnly)
+			return
+		}
+		handler(rw, req)
+	}
+}
+
+func (a *app) manageSettingsHandler(rw http.ResponseWriter, req *http.Request) {
+	err := a.RenderTemplate(rw, "manage_settings.html", "index.html", map[string]interface{}{
+		"Article":  map[string]string{"Title": "Settings"},
+		"Context":  req.Context(),
+		"Settings": a.Settings(),
+		"Themes":   listThemes(),
+		// Without a render queue there are no workers to resize.
+		"BackgroundRendering": a.BackgroundRendering(),
+	})
+	check(err)
+}
+
+func (a *app) manageSettingsPostHandler(rw http.ResponseWriter, req *http.Request) {
+	if err := req.ParseMultipartForm(2 * maxAssetSize); err != nil && err != http.ErrNotMultipart {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+
+	settings := a.Settings()
+	settings.SiteName = strings.TrimSpace(req.PostFormValue("site_name"))
+	settings.SiteTagline = strings.TrimSpace(req.PostFormValue("site_tagline"))
+	settings.SiteNotice = strings.TrimSpace(req.PostFormValue("site_notice"))
+
+	if settings.SiteName == "" {
+		a.errorHandler(http.StatusBadRequest, rw, req, errors.New("site name cannot be empty"))
+		return
+	}
+
+	// These are left alone by forms that don't include them.
+	if _, ok := req.PostForm["content_license_name"]; ok {
+		settings.ContentLicenseName = strings.TrimSpace(req.PostFormValue("content_license_name"))
+	}
+	if _, ok := req.PostForm["content_license_url"]; ok {
+		licenseURL := strings.TrimSpace(req.PostFormValue("content_license_url"))
+		if !wiki.ValidLicenseURL(licenseURL) {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the license URL must be an http or https URL"))
+			return
+		}
+		settings.ContentLicenseURL = licenseURL
+	}
+	if _, ok := req.PostForm["active_theme"]; ok {
+		theme := req.PostFormValue("active_theme")
+		if !themeExists(theme) {
+			a.errorHandler(http.StatusBadRequest, rw, req, wiki.ErrBadTheme)
+			return
+		}
+		settings.ActiveTheme = theme
+	}
+	if _, ok := req.PostForm["login_rate_limit"]; ok {
+		limit, err := strconv.Atoi(strings.TrimSpace(req.PostFormValue("login_rate_limit")))
+		if err != nil || limit < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the login rate limit must be a whole number, or 0 for no limit"))
+			return
+		}
+		settings.LoginRateLimit = limit
+	}
+	if _, ok := req.PostForm["login_rate_window"]; ok {
+		window, err := time.ParseDuration(strings.TrimSpace(req.PostFormValue("login_rate_window")))
+		if err != nil || window <= 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the login rate window must be a duration such as 15m"))
+			return
+		}
+		settings.LoginRateWindow = window
+	}
+	if _, ok := req.PostForm["remember_me_expiry"]; ok {
+		expiry, err := time.ParseDuration(strings.TrimSpace(req.PostFormValue("remember_me_expiry")))
+		if err != nil || expiry < a.CookieExpiryDuration() {
+			a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("remember me must last at least as long as a normal login (%v)", a.CookieExpiryDuration()))
+			return
+		}
+		settings.RememberMeExpiry = expiry
+	}
+	if _, ok := req.PostForm["diff_cache_size"]; ok {
+		size, err := strconv.Atoi(strings.TrimSpace(req.PostFormValue("diff_cache_size")))
+		if err != nil || size < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the diff cache size must be a whole number, or 0 for no cache"))
+			return
+		}
+		settings.DiffCacheSize = size
+	}
+	if _, ok := req.PostForm["render_workers"]; ok {
+		workers, err := strconv.Atoi(strings.TrimSpace(req.PostFormValue("render_workers")))
+		if err != nil || workers < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the number of render workers must be a whole number, or 0 to pause background renders"))
+			return
+		}
+		settings.RenderWorkers = workers
+	}
+	if _, ok := req.PostForm["max_render_duration"]; ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(req.PostFormValue("max_render_duration")))
+		if err != nil || duration < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the render time limit must be a duration such as 30s, or 0 for no limit"))
+			return
+		}
+		settings.MaxRenderDuration = duration
+	}
+	if _, ok := req.PostForm["background_render_max_wait"]; ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(req.PostFormValue("background_render_max_wait")))
+		if err != nil || duration < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the background render wait must be a duration such as 1m, or 0 to never promote them"))
+			return
+		}
+		settings.BackgroundRenderMaxWait = duration
+	}
+	if _, ok := req.PostForm["content_security_policy"]; ok {
+		// Headers are one line, so the policy may be written over several.
+		settings.ContentSecurityPolicy = strings.Join(strings.Fields(req.PostFormValue("content_security_policy")), " ")
+	}
+	if _, ok := req.PostForm["referrer_policy"]; ok {
+		policy := strings.TrimSpace(req.PostFormValue("referrer_policy"))
+		valid := policy == ""
+		for _, known := range wiki.ReferrerPolicies {
+			valid = valid || policy == known
+		}
+		if !valid {
+			a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("the referrer policy must be one of %s, or empty", strings.Join(wiki.ReferrerPolicies, ", ")))
+			return
+		}
+		settings.ReferrerPolicy = policy
+	}
+	if _, ok := req.PostForm["require_email_verification"]; ok {
+		require, err := strconv.ParseBool(req.PostFormValue("require_email_verification"))
+		if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, errors.New("email verification must be true or false"))
+			return
+		}
+		settings.RequireEmailVerification = require
+	}
+
+	for field, url := range map[string]*string{"favicon": &settings.FaviconURL, "logo": &settings.LogoURL} {
+		uploaded, err := a.saveUploadedAsset(req, field)
+		if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, err)
+			return
+		}
+		if uploaded != "" {
+			*url = uploaded
+		}
+	}
+
+	if err := a.UpdateSettings(settings); err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	http.Redirect(rw, req, "/manage/settings", http.StatusSeeOther)
+}
+
+// queueRefresh is how often, in seconds, the render queue page reloads.
+const queueRefresh = 5
+
+func (a *app) manageQueueHandler(rw http.ResponseWriter, req *http.Request) {
+	snapshot, ok := a.RenderQueueSnapshot()
+	err := a.RenderTemplate(rw, "manage_queue.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Render queue"},
+		"Context": req.Context(),
+		"Refresh": queueRefresh,
+		"Enabled": ok,
+		"Queue":   snapshot,
+		"Tiers":   []renderqueue.Tier{renderqueue.TierInteractive, renderqueue.TierBackground},
+	})
+	check(err)
+}
+
+// manageToolsHandler shows the render queue's throughput and how many
+// revisions are still waiting to be rendered.
+func (a *app) manageToolsHandler(rw http.ResponseWriter, req *http.Request) {
+	backlog, err := a.CountRenderPending()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	stats, ok := a.RenderQueueStats()
+	stats.AverageLatency = stats.AverageLatency.Round(time.Microsecond)
+	err = a.RenderTemplate(rw, "manage_tools.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Tools"},
+		"Context": req.Context(),
+		"Enabled": ok,
+		"Stats":   stats,
+		"Tiers":   []renderqueue.Tier{renderqueue.TierInteractive, renderqueue.TierBackground},
+		"Backlog": backlog,
+	})
+	check(err)
+}
+
+// saveUploadedAsset stores the image uploaded in the form field name, if any,
+// and returns the URL it is served from.
+func (a *app) saveUploadedAsset(req *http.Request, name string) (string, error) {
+	file, _, err := req.FormFile(name)
+	if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAssetSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxAssetSize {
+		return "", errors.New(name + " is too large")
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", errors.New(name + " must be an image")
+	}
+
+	if err := a.SaveAsset(&wiki.Asset{Name: name, ContentType: contentType, Data: data}); err != nil {
+		return "", err
+	}
+
+	// Version the URL so browsers pick up a replaced image.
+	sum := sha256.Sum256(data)
+	return "/asset/" + name + "?v=" + hex.EncodeToString(sum[:4]), nil
+}
+
+func (a *app) assetHandler(rw http.ResponseWriter, req *http.Request) {
+	asset, err := a.GetAsset(mux.Vars(req)["name"])
+	if err == wiki.ErrGenericNotFound {
+		http.NotFound(rw, req)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", asset.ContentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(rw, req, asset.Name, startTime, bytes.NewReader(asset.Data))
+}
+
+func (a *app) manageUsersHandler(rw http.ResponseWriter, req *http.Request) {
+	users, err := a.GetUsers()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "manage_users.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Users"},
+		"Context": req.Context(),
+		"Users":   users,
+		"Roles":   wiki.Roles,
+	})
+	check(err)
+}
+
+// manageUserRoleHandler gives the user named by the screenname form value
+// the role form value.
+func (a *app) manageUserRoleHandler(rw http.ResponseWriter, req *http.Request) {
+	admin := req.Context().Value(wiki.UserKey).(*wiki.User)
+	screenname := req.PostFormValue("screenname")
+	role := wiki.Role(req.PostFormValue("role"))
+
+	err := a.SetUserRole(screenname, role, admin)
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err == wiki.ErrBadRole || err == wiki.ErrOwnRole {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	setFlash(rw, req, screenname+" is now "+string(role)+".")
+	http.Redirect(rw, req, "/manage/users", http.StatusSeeOther)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielledeleo/periwiki/metrics"
+	"github.com/gorilla/mux"
+)
+
+// httpMetrics counts and times requests by route.
+type httpMetrics struct {
+	requests  *metrics.Counter
+	durations *metrics.Histogram
+}
+
+func newHTTPMetrics(registry *metrics.Registry) *httpMetrics {
+	return &httpMetrics{
+		requests: registry.NewCounter("periwiki_http_requests_total",
+			"HTTP requests served, by method, route and status.", "method", "route", "status"),
+		durations: registry.NewHistogram("periwiki_http_request_duration_seconds",
+			"How long HTTP requests took, by route.", metrics.DefBuckets, "route"),
+	}
+}
+
+// statusRecorder remembers the status a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// MetricsMiddleware records each request's route, status and duration.
+// Routes are the router's path templates, such as /wiki/{article}, so that
+// every article doesn't get series of its own.
+func (a *app) MetricsMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(req); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw}
+		handler.ServeHTTP(recorder, req)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		a.httpMetrics.requests.Inc(req.Method, route, strconv.Itoa(recorder.status))
+		a.httpMetrics.durations.Observe(time.Since(start).Seconds(), route)
+	})
+}
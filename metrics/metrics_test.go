@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounter("requests_total", "Requests.", "route", "status")
+	histogram := r.NewHistogram("duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("queue_length", "Queued.", "tier", func() map[string]float64 {
+		return map[string]float64{"interactive": 2, "background": 0}
+	})
+
+	counter.Inc("/wiki/{article}", "200")
+	counter.Inc("/wiki/{article}", "200")
+	counter.Inc(`/a"b`, "404")
+	histogram.Observe(0.05, "/")
+	histogram.Observe(0.5, "/")
+	histogram.Observe(5, "/")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b",status="404"} 1
+requests_total{route="/wiki/{article}",status="200"} 2
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/",le="0.1"} 1
+duration_seconds_bucket{route="/",le="1"} 2
+duration_seconds_bucket{route="/",le="+Inf"} 3
+duration_seconds_sum{route="/"} 5.55
+duration_seconds_count{route="/"} 3
+# HELP queue_length Queued.
+# TYPE queue_length gauge
+queue_length{tier="background"} 0
+queue_length{tier="interactive"} 2
+`
+	if got := rr.Body.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var counter *Counter
+	var histogram *Histogram
+	counter.Inc("x")
+	histogram.Observe(1, "x")
+}
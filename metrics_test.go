@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.EnableMetrics = true
+	})
+	mustPostArticle(t, a, "Foo", "Hello", 0)
+
+	serve(a, newRequest(http.MethodGet, "/wiki/Foo", nil, nil))
+	serve(a, newRequest(http.MethodGet, "/wiki/Nonexistent", nil, nil))
+
+	rr := serve(a, newRequest(http.MethodGet, "/metrics", nil, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from /metrics, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`periwiki_http_requests_total{method="GET",route="/wiki/{article}",status="200"} 1`,
+		`periwiki_http_requests_total{method="GET",route="/wiki/{article}",status="404"} 1`,
+		`periwiki_http_request_duration_seconds_count{route="/wiki/{article}"} 2`,
+		`periwiki_render_queue_length{tier="interactive"} 0`,
+		`periwiki_render_queue_length{tier="background"} 0`,
+		`periwiki_article_html_cache_total{result="hit"}`,
+		`# TYPE periwiki_render_duration_seconds histogram`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to include %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	a := newTestApp(t)
+	if rr := serve(a, newRequest(http.MethodGet, "/metrics", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected /metrics to be missing unless enabled, got %d", rr.Code)
+	}
+}
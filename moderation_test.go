@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestModerateNewArticles(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ModerateNewArticles = true
+	})
+
+	admin := mustRegister(t, a, "Admin")
+	bob := mustRegister(t, a, "Bob")
+	if !admin.IsAdmin() || bob.IsAdmin() {
+		t.Fatalf("expected only the first user to be an admin, got %q and %q", admin.Role, bob.Role)
+	}
+	adminCookie := login(t, a, "Admin")
+	bobCookie := login(t, a, "Bob")
+
+	draft := wiki.NewArticle("Draft", "Draft", "Written by Bob.")
+	draft.Creator = bob
+	if err := a.PostArticle(draft); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cookie := range map[string]*http.Cookie{"anonymous": nil, "creator": bobCookie} {
+		if rr := serve(a, newRequest(http.MethodGet, "/wiki/Draft", nil, cookie)); rr.Code != http.StatusNotFound {
+			t.Errorf("expected pending article to be hidden from %s, got %d", name, rr.Code)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:PendingReview", nil, bobCookie)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected non-admins to be refused the review queue, got %d", rr.Code)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:PendingReview", nil, adminCookie))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `value="Draft"`) {
+		t.Fatalf("expected Draft in the review queue, got %d", rr.Code)
+	}
+
+	rr = serve(a, newRequest(http.MethodPost, "/wiki/Special:PendingReview",
+		url.Values{"url": {"Draft"}, "action": {"approve"}}, adminCookie))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected approval to redirect, got %d", rr.Code)
+	}
+
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Draft", nil, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Written by Bob.") {
+		t.Errorf("expected approved article to be public, got %d", rr.Code)
+	}
+
+	trusted := wiki.NewArticle("Trusted", "Trusted", "Written by an admin.")
+	trusted.Creator = admin
+	if err := a.PostArticle(trusted); err != nil {
+		t.Fatal(err)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Trusted", nil, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected admin-created article to bypass moderation, got %d", rr.Code)
+	}
+}
+
+func TestRejectPendingArticle(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ModerateNewArticles = true
+	})
+
+	mustPostArticle(t, a, "Spam", "Buy now!", 0)
+
+	if err := a.RejectArticle("Spam", "advertising"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.GetArticle("Spam"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected rejected article to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+var errMoveTitleRequired = errors.New("enter a new title for the article")
+
+func (a *app) moveHandler(rw http.ResponseWriter, req *http.Request) {
+	article, ok := a.moveSource(rw, req)
+	if !ok {
+		return
+	}
+	a.renderMoveForm(rw, req, article, article.Title, nil)
+}
+
+// movePostHandler moves an article to the URL of the title submitted with
+// it, renaming it if the title changed.
+func (a *app) movePostHandler(rw http.ResponseWriter, req *http.Request) {
+	article, ok := a.moveSource(rw, req)
+	if !ok {
+		return
+	}
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+
+	title := strings.TrimSpace(req.PostFormValue("title"))
+	if title == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		a.renderMoveForm(rw, req, article, title, errMoveTitleRequired)
+		return
+	}
+	newURL := a.ResolveNamespace(wiki.NormalizeURL(title))
+
+	switch err := a.MoveArticle(article.URL, newURL, user); err {
+	case nil:
+	case wiki.ErrTargetExists, wiki.ErrArticleDeleted, wiki.ErrTalkSubjectNotFound:
+		rw.WriteHeader(http.StatusConflict)
+		a.renderMoveForm(rw, req, article, title, err)
+		return
+	case wiki.ErrArticleProtected:
+		a.errorHandler(http.StatusForbidden, rw, req, err)
+		return
+	default:
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	if title != article.Title {
+		renamed := wiki.NewArticle(newURL, title, article.Markdown)
+		renamed.Creator = user
+		renamed.PreviousID = article.ID
+		renamed.Comment = "Moved from [[" + article.URL + "]]"
+		if err := a.PostArticle(renamed); err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+	}
+
+	http.Redirect(rw, req, "/wiki/"+newURL, http.StatusSeeOther)
+}
+
+// moveSource loads the article a move request names, responding with an
+// error if it doesn't exist or the user may not move it. Only editors and
+// admins may move articles.
+func (a *app) moveSource(rw http.ResponseWriter, req *http.Request) (*wiki.Article, bool) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+		return nil, false
+	}
+	if !user.CanManageArticles() {
+		a.errorHandler(http.StatusForbidden, rw, req, errEditorOnly)
+		return nil, false
+	}
+
+	article, err := a.GetArticle(mux.Vars(req)["article"])
+	if err == nil && !canView(article, user) {
+		err = wiki.ErrGenericNotFound
+	}
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return nil, false
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return nil, false
+	}
+
+	if allowed, reason := a.EditPolicy().Evaluate(article, user); !allowed {
+		a.denyEdit(reason, rw, req)
+		return nil, false
+	}
+	return article, true
+}
+
+func (a *app) renderMoveForm(rw http.ResponseWriter, req *http.Request, article *wiki.Article, title string, err error) {
+	other := map[string]interface{}{"Title": title}
+	if err != nil {
+		other["Error"] = err.Error()
+	}
+
+	check(a.RenderTemplate(rw, "article_move.html", "index.html", map[string]interface{}{
+		"Article": article,
+		"Context": req.Context(),
+		"Other":   other,
+	}))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestMoveArticle(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+	user, err := a.GetUserByScreenName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPostArticle(t, a, "Old_Name", "First.", 0)
+	mustPostArticle(t, a, "Old_Name", "Second.", 1)
+	mustPostArticle(t, a, "Linker", "See [[Old_Name]].", 0)
+	mustPostArticle(t, a, "Taken", "Already here.", 0)
+
+	if err := a.MoveArticle("Old_Name", "Taken", user); err != wiki.ErrTargetExists {
+		t.Errorf("expected moving onto an existing article to fail with ErrTargetExists, got %v", err)
+	}
+	if err := a.MoveArticle("Old_Name", "Talk:Nowhere", user); err != wiki.ErrTalkSubjectNotFound {
+		t.Errorf("expected moving to an orphaned talk page to fail with ErrTalkSubjectNotFound, got %v", err)
+	}
+
+	if err := a.MoveArticle("Old_Name", "New_Name", user); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := a.GetArticle("New_Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Markdown != "Second." {
+		t.Errorf("expected the moved article's content, got %q", moved.Markdown)
+	}
+	history, err := a.GetRevisionHistory("New_Name")
+	if err != nil || len(history) != 2 {
+		t.Errorf("expected both revisions to move, got %d (%v)", len(history), err)
+	}
+
+	backlinks, err := a.GetBacklinks("New_Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var linkers []string
+	for _, backlink := range backlinks {
+		linkers = append(linkers, backlink.URL)
+	}
+	if strings.Join(linkers, ",") != "Linker,Old_Name" {
+		t.Errorf("expected Linker and the redirect to link to New_Name, got %v", linkers)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Old_Name", nil, nil))
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/wiki/New_Name?redirected_from=Old_Name" {
+		t.Errorf("expected Old_Name to redirect to New_Name, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Old_Name?redirect=no", nil, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected redirect=no to show the redirect page, got %d", rr.Code)
+	}
+}
+
+func TestMoveHandler(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Cats", "Meow.", 0)
+	mustPostArticle(t, a, "Dogs", "Woof.", 0)
+	cookie := login(t, a, "alice")
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?move", url.Values{"title": {"Felines"}}, nil)); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("expected anonymous users to be sent to log in, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats?move", nil, cookie)).Body.String()
+	if !strings.Contains(page, `action="/wiki/Cats?move"`) || !strings.Contains(page, `value="Cats"`) {
+		t.Errorf("expected the move form, got:\n%s", page)
+	}
+
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?move", url.Values{"title": {"Dogs"}}, cookie))
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), wiki.ErrTargetExists.Error()) {
+		t.Errorf("expected a conflict moving onto Dogs, got %d:\n%s", rr.Code, rr.Body)
+	}
+
+	rr = serve(a, newRequest(http.MethodPost, "/wiki/Cats?move", url.Values{"title": {"Domestic cats"}}, cookie))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/wiki/Domestic_cats" {
+		t.Fatalf("expected a redirect to the moved article, got %d to %q:\n%s", rr.Code, rr.Header().Get("Location"), rr.Body)
+	}
+	moved, err := a.GetArticle("Domestic_cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Title != "Domestic cats" || moved.Markdown != "Meow." {
+		t.Errorf("expected the article to be retitled with its content intact, got %q: %q", moved.Title, moved.Markdown)
+	}
+}
+
+func TestRedirectedFrom(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Target", "Body.", 0)
+	mustPostArticle(t, a, "Alias", wiki.RedirectMarkdown("Target"), 0)
+	mustPostArticle(t, a, "Section_Alias", wiki.RedirectMarkdown("Target#History"), 0)
+	mustPostArticle(t, a, "Ping", wiki.RedirectMarkdown("Pong"), 0)
+	mustPostArticle(t, a, "Pong", wiki.RedirectMarkdown("Ping"), 0)
+	mustPostArticle(t, a, "Self", wiki.RedirectMarkdown("Self"), 0)
+
+	for from, want := range map[string]string{
+		"Alias":         "/wiki/Target?redirected_from=Alias",
+		"Section_Alias": "/wiki/Target?redirected_from=Section_Alias#History",
+		"Ping":          "/wiki/Pong?redirected_from=Ping",
+	} {
+		rr := serve(a, newRequest(http.MethodGet, "/wiki/"+from, nil, nil))
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != want {
+			t.Errorf("expected %s to redirect to %q, got %d to %q", from, want, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	notice := `(Redirected from <a href="/wiki/Alias?redirect=no">Alias</a>)`
+	if body := serve(a, newRequest(http.MethodGet, "/wiki/Target?redirected_from=Alias", nil, nil)).Body.String(); !strings.Contains(body, notice) {
+		t.Errorf("expected a redirected from notice, got:\n%s", body)
+	}
+	if body := serve(a, newRequest(http.MethodGet, "/wiki/Target?redirected_from=Ping", nil, nil)).Body.String(); strings.Contains(body, "Redirected from") {
+		t.Error("expected no notice for a page that doesn't redirect here")
+	}
+
+	// A loop stops at its second page, and a page redirecting to itself is
+	// shown rather than followed.
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Pong?redirected_from=Ping", nil, nil)); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Redirected from") {
+		t.Errorf("expected the loop to stop at Pong, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Self", nil, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected a self-redirect to be shown, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func newNavboxApp(t *testing.T) *app {
+	return newTestApp(t, func(c *wiki.Config) {
+		c.Navboxes = true
+		c.BacklinkBatchWindow = 10 * time.Millisecond
+	})
+}
+
+// waitForHTML polls the article at url until its HTML satisfies ok.
+func waitForHTML(t *testing.T, a *app, url string, ok func(html string) bool) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		article, err := a.GetArticle(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok(article.HTML) {
+			return article.HTML
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not re-rendered as expected:\n%s", url, article.HTML)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNavboxEmbedsRenderedHTML(t *testing.T) {
+	a := newNavboxApp(t)
+
+	mustPostArticle(t, a, "Planets_Navbox", "**Planets:** [[Mercury]] · [[Venus]]", 0)
+	mustPostArticle(t, a, "Venus", "Venus is hot.\n\n{{nav:Planets Navbox}}\n", 0)
+
+	article, err := a.GetArticle("Venus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(article.HTML, `<div class="pw-navbox">`) ||
+		!strings.Contains(article.HTML, "<strong>Planets:</strong>") ||
+		!strings.Contains(article.HTML, `href="/wiki/Mercury"`) {
+		t.Errorf("expected the navbox's rendered HTML to be embedded:\n%s", article.HTML)
+	}
+	if strings.Contains(article.HTML, "**Planets:**") || strings.Contains(article.HTML, "{{nav:") {
+		t.Errorf("expected no raw markdown in the embedding article:\n%s", article.HTML)
+	}
+
+	mustPostArticle(t, a, "Mercury", "{{nav:Moons_Navbox}}", 0)
+	article, err = a.GetArticle("Mercury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(article.HTML, `class="pw-deadlink"`) || !strings.Contains(article.HTML, "Moons Navbox") {
+		t.Errorf("expected a missing navbox to show as a dead link:\n%s", article.HTML)
+	}
+}
+
+func TestNavboxEditRerendersEmbedders(t *testing.T) {
+	a := newNavboxApp(t)
+
+	navbox := mustPostArticle(t, a, "Planets_Navbox", "Inner planets", 0)
+	mustPostArticle(t, a, "Venus", "{{nav:Planets_Navbox}}", 0)
+	mustPostArticle(t, a, "Mars", "{{nav:Planets_Navbox}}", 0)
+	mustPostArticle(t, a, "Moons_Navbox", "No navbox here", 0)
+
+	mustPostArticle(t, a, "Planets_Navbox", "Inner *and* outer planets", navbox.ID)
+
+	for _, url := range []string{"Venus", "Mars"} {
+		waitForHTML(t, a, url, func(html string) bool {
+			return strings.Contains(html, "Inner <em>and</em> outer planets")
+		})
+	}
+
+	// Articles embedding a navbox that doesn't exist yet pick it up once it
+	// is created.
+	mustPostArticle(t, a, "Earth", "{{nav:Earth_Navbox}}", 0)
+	mustPostArticle(t, a, "Earth_Navbox", "Home", 0)
+	waitForHTML(t, a, "Earth", func(html string) bool {
+		return strings.Contains(html, "<p>Home</p>") && !strings.Contains(html, "pw-deadlink")
+	})
+}
+
+func TestNavboxRecursion(t *testing.T) {
+	a := newNavboxApp(t)
+
+	mustPostArticle(t, a, "Ping", "Ping content\n\n{{nav:Pong}}", 0)
+	mustPostArticle(t, a, "Pong", "Pong content\n\n{{nav:Ping}}", 0)
+
+	html := waitForHTML(t, a, "Ping", func(html string) bool {
+		return strings.Contains(html, "Pong content")
+	})
+	if strings.Count(html, "Ping content") != 1 || strings.Count(html, "pw-navbox") != 1 {
+		t.Errorf("expected navboxes embedded in a navbox to be dropped:\n%s", html)
+	}
+
+	pong, err := a.GetArticle("Pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(pong.HTML, "Pong content") != 1 || strings.Count(pong.HTML, "pw-navbox") != 1 {
+		t.Errorf("expected navboxes embedded in a navbox to be dropped:\n%s", pong.HTML)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// Media types an article can be served as.
+const (
+	mediaTypeHTML     = "text/html"
+	mediaTypeMarkdown = "text/markdown"
+	mediaTypeJSON     = "application/json"
+)
+
+// negotiate returns the offer the Accept header accept prefers, or the first
+// offer when accept is empty or prefers none of them. Wildcards such as
+// text/* and */* are honoured, with more specific ranges taking precedence.
+func negotiate(accept string, offers ...string) string {
+	best, bestQ := offers[0], 0.0
+	if strings.TrimSpace(accept) == "" {
+		return best
+	}
+
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, part := range strings.Split(accept, ",") {
+			mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			s := rangeSpecificity(mediaRange, offer)
+			if s <= specificity {
+				continue
+			}
+			specificity = s
+			q = 1
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// rangeSpecificity reports how specifically mediaRange matches mediaType: 2
+// for an exact match, 1 for type/* and 0 for */*, or -1 if it doesn't.
+func rangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	case mediaRange == "*/*":
+		return 0
+	}
+	return -1
+}
+
+// articleJSON is the JSON representation of an article.
+type articleJSON struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title"`
+	Revision int       `json:"revision"`
+	Created  time.Time `json:"created"`
+	Comment  string    `json:"comment"`
+	Markdown string    `json:"markdown"`
+	HTML     string    `json:"html"`
+
+	Contributors []*wiki.Contributor `json:"contributors"`
+}
+
+// writeArticleAs writes article as mediaType, which must be Markdown or JSON.
+// A nil article is written as not found. JSON includes the contributors.
+func writeArticleAs(rw http.ResponseWriter, mediaType string, article *wiki.Article, contributors []*wiki.Contributor) {
+	rw.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+
+	if mediaType == mediaTypeMarkdown {
+		if article == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(wiki.ErrGenericNotFound.Error() + "\n"))
+			return
+		}
+		_, _ = rw.Write([]byte(article.Markdown))
+		return
+	}
+
+	encoder := json.NewEncoder(rw)
+	if article == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		check(encoder.Encode(map[string]string{"error": wiki.ErrGenericNotFound.Error()}))
+		return
+	}
+	check(encoder.Encode(articleJSON{
+		URL:      article.URL,
+		Title:    article.Title,
+		Revision: article.ID,
+		Created:  article.Created,
+		Comment:  article.Comment,
+		Markdown: article.Markdown,
+		HTML:     article.HTML,
+
+		Contributors: contributors,
+	}))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mediaTypeHTML, mediaTypeMarkdown, mediaTypeJSON}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", mediaTypeHTML},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", mediaTypeHTML},
+		{"*/*", mediaTypeHTML},
+		{"text/markdown", mediaTypeMarkdown},
+		{"application/json", mediaTypeJSON},
+		{"text/html;q=0.5, application/json", mediaTypeJSON},
+		{"text/*, text/html;q=0.1", mediaTypeMarkdown},
+		{"application/*", mediaTypeJSON},
+		{"image/png", mediaTypeHTML},
+		{"not a media type", mediaTypeHTML},
+	}
+
+	for _, test := range tests {
+		if got := negotiate(test.accept, offers...); got != test.want {
+			t.Errorf("negotiate(%q) = %s, want %s", test.accept, got, test.want)
+		}
+	}
+}
+
+func TestArticleContentNegotiation(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.ContentNegotiation = true })
+	mustPostArticle(t, a, "Foo", "Foo is *emphatic*.", 0)
+
+	get := func(path, accept string) *http.Response {
+		req := newRequest(http.MethodGet, path, nil, nil)
+		req.Header.Set("Accept", accept)
+		return serve(a, req).Result()
+	}
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	resp := get("/wiki/Foo", "text/html")
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+	if b := body(resp); !strings.Contains(b, "<em>emphatic</em>") {
+		t.Errorf("expected the rendered page, got:\n%s", b)
+	}
+	if vary := strings.Join(resp.Header.Values("Vary"), ", "); !strings.Contains(vary, "Accept") {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+
+	resp = get("/wiki/Foo", "text/markdown")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/markdown; charset=utf-8" {
+		t.Errorf("expected text/markdown, got %q", ct)
+	}
+	if b := body(resp); b != "Foo is *emphatic*." {
+		t.Errorf("expected the raw markdown, got %q", b)
+	}
+	if vary := strings.Join(resp.Header.Values("Vary"), ", "); !strings.Contains(vary, "Accept") {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+
+	resp = get("/wiki/Foo", "application/json")
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var article articleJSON
+	if err := json.NewDecoder(resp.Body).Decode(&article); err != nil {
+		t.Fatal(err)
+	}
+	if article.URL != "Foo" || article.Revision != 1 || article.Markdown != "Foo is *emphatic*." || !strings.Contains(article.HTML, "<em>emphatic</em>") {
+		t.Errorf("unexpected JSON representation: %+v", article)
+	}
+
+	for _, accept := range []string{"text/markdown", "application/json"} {
+		if resp := get("/wiki/Missing", accept); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404 for a missing article, got %d", accept, resp.StatusCode)
+		}
+	}
+}
+
+func TestArticleContentNegotiationDisabled(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Foo", "Foo.", 0)
+
+	req := newRequest(http.MethodGet, "/wiki/Foo", nil, nil)
+	req.Header.Set("Accept", "application/json")
+	if ct := serve(a, req).Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected HTML when negotiation is off, got %q", ct)
+	}
+}
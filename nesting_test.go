@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/render"
+	"github.com/danielledeleo/periwiki/renderqueue"
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestMaxNestingDepth(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.MaxNestingDepth = 8
+	})
+
+	var nestedList strings.Builder
+	for i := 0; i < 2000; i++ {
+		nestedList.WriteString(strings.Repeat("  ", i) + "- item\n")
+	}
+
+	for name, body := range map[string]string{
+		"blockquotes": strings.Repeat(">", 10000) + " deep",
+		"lists":       nestedList.String(),
+		"mixed":       strings.Repeat("> - ", 20) + "deep",
+	} {
+		article := wiki.NewArticle("Deep", "Deep", body)
+		article.Creator = wiki.AnonymousUser()
+
+		start := time.Now()
+		err := a.PostArticle(article)
+		elapsed := time.Since(start)
+		if !errors.Is(err, render.ErrNestingTooDeep) {
+			t.Errorf("%s: expected ErrNestingTooDeep, got %v", name, err)
+		}
+		// Well within a second, where rendering it would take much longer.
+		if elapsed > time.Second {
+			t.Errorf("%s: took %v to reject", name, elapsed)
+		}
+	}
+
+	article := mustPostArticle(t, a, "Shallow", strings.Repeat("> ", 4)+"- a\n"+strings.Repeat("> ", 4)+"  - b\n", 0)
+	if strings.Count(article.HTML, "<blockquote>") != 4 || strings.Count(article.HTML, "<ul>") != 2 {
+		t.Errorf("expected nesting within the limit to render:\n%s", article.HTML)
+	}
+}
+
+func TestMaxNestingDepthInBackground(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 100
+		c.MaxNestingDepth = 4
+	})
+
+	body := strings.Repeat("> ", 5) + strings.Repeat("deep ", 50)
+	if a.RenderTier(body) != renderqueue.TierBackground {
+		t.Fatal("expected the article to be rendered in the background")
+	}
+
+	article := wiki.NewArticle("Deep", "Deep", body)
+	article.Creator = wiki.AnonymousUser()
+	if err := a.PostArticle(article); !errors.Is(err, render.ErrNestingTooDeep) {
+		t.Errorf("expected ErrNestingTooDeep before saving, got %v", err)
+	}
+	if _, err := a.GetArticle("Deep"); err != wiki.ErrGenericNotFound {
+		t.Errorf("expected the article not to be saved, got %v", err)
+	}
+
+	blank := wiki.NewArticle("Blank", "Blank", strings.Repeat(" \n", 100))
+	blank.Creator = wiki.AnonymousUser()
+	if err := a.PostArticle(blank); err != wiki.ErrEmptyArticle {
+		t.Errorf("expected ErrEmptyArticle before saving, got %v", err)
+	}
+}
+
+func TestFailedBackgroundRenderIsMarked(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.Transclusion = true
+		c.BacklinkBatchWindow = 10 * time.Millisecond
+		c.MaxNestingDepth = 4
+	})
+
+	mustPostArticle(t, a, "Quote", "Quoted.", 0)
+	mustPostArticle(t, a, "Page", "> > > {{Quote}}\n", 0)
+
+	// Fine on its own, but too deep where Page transcludes it.
+	mustPostArticle(t, a, "Quote", "> > Quoted.", 1)
+	waitForHTML(t, a, "Page", func(html string) bool {
+		return html == wiki.RenderFailedHTML
+	})
+}
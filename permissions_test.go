@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestProtectedArticleOffersViewSource(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ProtectedArticles = []string{"Policy"}
+	})
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+
+	policy := wiki.NewArticle("Policy", "Policy", "Be nice.")
+	policy.Creator, _ = a.GetUserByScreenName("admin")
+	if err := a.PostArticle(policy); err != nil {
+		t.Fatal(err)
+	}
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	bob := login(t, a, "bob")
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Policy", nil, bob)).Body.String()
+	if !strings.Contains(page, `<a href="/wiki/Policy/r/1/source">View source</a>`) {
+		t.Errorf("expected a protected page to offer View source, got:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, bob)).Body.String()
+	if !strings.Contains(page, `<a href="/wiki/Cats/r/1/edit">Edit</a>`) {
+		t.Errorf("expected an ordinary page to offer Edit, got:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Policy", nil, login(t, a, "admin"))).Body.String()
+	if !strings.Contains(page, `<a href="/wiki/Policy/r/1/edit">Edit</a>`) {
+		t.Error("expected an admin to be offered Edit on a protected page")
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Policy/r/1/source", nil, bob))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Be nice.") {
+		t.Errorf("expected the source to be shown read-only, got %d", rr.Code)
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Policy/r/1/edit", nil, bob)); rr.Code != http.StatusFound {
+		t.Errorf("expected the edit form to redirect to the source, got %d", rr.Code)
+	}
+}
+
+func TestProtectedArticleRejectsEdits(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.ProtectedArticles = []string{"Policy"}
+	})
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+
+	form := url.Values{"title": {"Policy"}, "body": {"Be mean."}, "action": {"submit"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Policy/r/0", form, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin edit, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Policy/r/0", form, login(t, a, "admin"))); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected an admin edit to succeed, got %d", rr.Code)
+	}
+
+	article := wiki.NewArticle("Special:Cats", "Cats", "Not an article.")
+	article.Creator, _ = a.GetUserByScreenName("admin")
+	if err := a.PostArticle(article); err != wiki.ErrArticleProtected {
+		t.Errorf("expected Special pages to be uneditable, got %v", err)
+	}
+}
+
+func TestSetProtection(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	admin, bob := login(t, a, "admin"), login(t, a, "bob")
+	mustPostArticle(t, a, "Main", "Welcome.", 0)
+
+	protect := func(level string, cookie *http.Cookie) int {
+		return serve(a, newRequest(http.MethodPost, "/wiki/Main?protect", url.Values{"level": {level}}, cookie)).Code
+	}
+	edit := func(cookie *http.Cookie) int {
+		article, err := a.GetArticle("Main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		form := url.Values{"title": {"Main"}, "body": {article.Markdown + " Again."}, "action": {"submit"}}
+		return serve(a, newRequest(http.MethodPost, "/wiki/Main/r/"+strconv.Itoa(article.ID), form, cookie)).Code
+	}
+
+	if code := protect("admin", bob); code != http.StatusForbidden {
+		t.Errorf("expected only admins to protect pages, got %d", code)
+	}
+	if code := protect("everyone", admin); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown level to be refused, got %d", code)
+	}
+	if code := protect("admin", admin); code != http.StatusSeeOther {
+		t.Fatalf("expected protecting to succeed, got %d", code)
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Main", nil, bob)).Body.String()
+	if !strings.Contains(page, `class="pw-protected" title="Only administrators can edit this article."`) {
+		t.Errorf("expected a lock on the protected article:\n%s", page)
+	}
+	if code := edit(bob); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a user editing an admin-protected article, got %d", code)
+	}
+	if code := edit(admin); code != http.StatusSeeOther {
+		t.Errorf("expected an admin edit to succeed, got %d", code)
+	}
+
+	if code := protect("autoconfirmed", admin); code != http.StatusSeeOther {
+		t.Fatalf("expected protecting to succeed, got %d", code)
+	}
+	if code := edit(nil); code != http.StatusForbidden {
+		t.Errorf("expected 403 for an anonymous edit even though anonymous editing is allowed, got %d", code)
+	}
+	if code := edit(bob); code != http.StatusSeeOther {
+		t.Errorf("expected a registered user's edit to succeed, got %d", code)
+	}
+
+	if code := protect("none", admin); code != http.StatusSeeOther {
+		t.Fatalf("expected unprotecting to succeed, got %d", code)
+	}
+	if code := edit(nil); code != http.StatusSeeOther {
+		t.Errorf("expected anonymous edits again once unprotected, got %d", code)
+	}
+	if page := serve(a, newRequest(http.MethodGet, "/wiki/Main", nil, nil)).Body.String(); strings.Contains(page, "pw-protected") {
+		t.Errorf("expected no lock on an unprotected article:\n%s", page)
+	}
+
+	if code := serve(a, newRequest(http.MethodPost, "/wiki/Nowhere?protect", url.Values{"level": {"admin"}}, admin)).Code; code != http.StatusNotFound {
+		t.Errorf("expected 404 protecting a missing article, got %d", code)
+	}
+}
+
+func TestEditorRole(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "eve")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	mustPostArticle(t, a, "Dogs", "Dogs are nice.", 0)
+	admin := login(t, a, "admin")
+
+	setRole := func(cookie *http.Cookie, screenname, role string) int {
+		form := url.Values{"screenname": {screenname}, "role": {role}}
+		return serve(a, newRequest(http.MethodPost, "/manage/users", form, cookie)).Code
+	}
+	if code := setRole(admin, "eve", "editor"); code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after setting a role, got %d", code)
+	}
+	if code := setRole(admin, "eve", "overlord"); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown role to be rejected, got %d", code)
+	}
+	if code := setRole(admin, "admin", "user"); code != http.StatusBadRequest {
+		t.Errorf("expected admins not to be able to demote themselves, got %d", code)
+	}
+	if code := setRole(admin, "nobody", "editor"); code != http.StatusNotFound {
+		t.Errorf("expected an unknown user to be 404, got %d", code)
+	}
+
+	eve, bob := login(t, a, "eve"), login(t, a, "bob")
+	if code := setRole(eve, "bob", "editor"); code != http.StatusForbidden {
+		t.Errorf("expected editors not to manage users, got %d", code)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/manage/settings", nil, eve)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected editors not to manage settings, got %d", rr.Code)
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, bob)).Body.String()
+	if strings.Contains(page, "?move") || strings.Contains(page, "?delete") {
+		t.Error("expected users not to be offered move or delete")
+	}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, eve)).Body.String()
+	if !strings.Contains(page, "?move") || !strings.Contains(page, "?delete") || strings.Contains(page, "?protect") {
+		t.Error("expected editors to be offered move and delete, but not protect")
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?delete", nil, bob)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected users not to delete articles, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Cats?move", nil, bob)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected users not to move articles, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?protect", url.Values{"level": {"admin"}}, eve)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected editors not to protect articles, got %d", rr.Code)
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Dogs?move", url.Values{"title": {"Hounds"}}, eve)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected editors to move articles, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?delete", nil, eve)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected editors to delete articles, got %d", rr.Code)
+	}
+
+	mustPostArticle(t, a, "Locked", "Admins only.", 0)
+	if err := a.SetProtection("Locked", wiki.ProtectionAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Locked?delete", nil, eve)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected editors not to delete articles they can't edit, got %d", rr.Code)
+	}
+	if _, err := a.GetArticle("Locked"); err != nil {
+		t.Errorf("expected the protected article to remain, got %v", err)
+	}
+}
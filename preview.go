@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/render"
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// maxPreviewSize limits the body of a preview request.
+const maxPreviewSize = 4 << 20
+
+// previewRequest is the body of a POST to /api/v1/preview. Article, if set,
+// is the URL of the article being edited, so its protection applies.
+type previewRequest struct {
+	Markdown string `json:"markdown"`
+	Article  string `json:"article"`
+}
+
+// previewAPIHandler renders Markdown for editors that preview as the user
+// types, without the round trip through the edit form. It is allowed to
+// whoever could preview the article in the editor.
+func (a *app) previewAPIHandler(rw http.ResponseWriter, req *http.Request) {
+	var preview previewRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxPreviewSize))
+	if err := decoder.Decode(&preview); err != nil {
+		writeJSONError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	article := wiki.NewArticle(preview.Article, "", preview.Markdown)
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if allowed, reason := a.EditPolicy().Evaluate(article, user); !allowed && reason != EditDenialPreviewOnly {
+		switch reason {
+		case EditDenialLoginRequired:
+			// There is no login page to redirect a script to.
+			writeJSONError(rw, http.StatusUnauthorized, errors.New("log in to preview"))
+		case EditDenialProtected:
+			writeJSONError(rw, reason.Status(), wiki.ErrArticleProtected)
+		case EditDenialReadOnly:
+			writeJSONError(rw, reason.Status(), errReadOnly)
+		case EditDenialUnverified:
+			writeJSONError(rw, reason.Status(), errVerifyToEdit)
+		}
+		return
+	}
+
+	html, err := a.PreviewMarkdown(preview.Markdown)
+	if errors.Is(err, render.ErrNestingTooDeep) {
+		writeJSONError(rw, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		writeJSONError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", mediaTypeJSON+"; charset=utf-8")
+	check(json.NewEncoder(rw).Encode(map[string]string{"html": html}))
+}
+
+// writeJSONError responds with status and err as {"error": ...}.
+func writeJSONError(rw http.ResponseWriter, status int, err error) {
+	rw.Header().Set("Content-Type", mediaTypeJSON+"; charset=utf-8")
+	rw.WriteHeader(status)
+	check(json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()}))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func newPreviewRequest(body string, cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/preview", strings.NewReader(body))
+	req.Header.Set("Content-Type", mediaTypeJSON)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestPreviewAPI(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Exists", "Body.", 0)
+
+	rr := serve(a, newPreviewRequest(`{"markdown": "*Hi* [[Exists]] [[Missing]] <script>alert(1)</script>"}`, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var preview struct {
+		HTML string `json:"html"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&preview); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(preview.HTML, "<em>Hi</em>") {
+		t.Errorf("expected the Markdown to be rendered, got %q", preview.HTML)
+	}
+	if strings.Contains(preview.HTML, "<script>") {
+		t.Errorf("expected the preview to be sanitized, got %q", preview.HTML)
+	}
+	if n := strings.Count(preview.HTML, "pw-deadlink"); n != 1 {
+		t.Errorf("expected only the missing article to be a dead link, got %d in %q", n, preview.HTML)
+	}
+
+	if rr := serve(a, newPreviewRequest(`{"markdown":`, nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected malformed JSON to be rejected, got %d", rr.Code)
+	}
+	if rr := serveWithoutCSRF(a, newPreviewRequest(`{"markdown": "Hi"}`, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected a preview without a CSRF token to be refused, got %d", rr.Code)
+	}
+}
+
+func TestPreviewAPINestingTooDeep(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.MaxNestingDepth = 8
+	})
+
+	body, _ := json.Marshal(map[string]string{"markdown": strings.Repeat(">", 100) + " deep"})
+	rr := serve(a, newPreviewRequest(string(body), nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), "nested too deeply") {
+		t.Errorf("expected the error to say why, got %s", rr.Body)
+	}
+}
+
+func TestPreviewAPIEditPolicy(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.RequireLoginToEdit = true })
+	if rr := serve(a, newPreviewRequest(`{"markdown": "Hi"}`, nil)); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected anonymous previews to need a login, got %d", rr.Code)
+	}
+
+	a = newTestApp(t, func(c *wiki.Config) {
+		c.RequireLoginToEdit = true
+		c.AllowAnonymousPreview = true
+	})
+	if rr := serve(a, newPreviewRequest(`{"markdown": "Hi"}`, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected anonymous previews to be allowed, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// httpsKey is for context.Context. It is set on requests a trusted proxy
+// received over HTTPS.
+const httpsKey wiki.ContextKey = "periwiki.https"
+
+// clientIPKey is for context.Context. It holds the client address a trusted
+// proxy forwarded a request for.
+const clientIPKey wiki.ContextKey = "periwiki.clientip"
+
+// parseTrustedProxies parses TrustedProxies, each an IP address or CIDR
+// range. Invalid entries are skipped; Config.Validate reports them.
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if _, ipnet, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, ipnet)
+		} else if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return nets
+}
+
+// ProxyMiddleware honours X-Forwarded-Proto and X-Forwarded-For on requests
+// from the TrustedProxies, so that the wiki knows it is served over HTTPS when
+// TLS is terminated in front of it, and which client each request came from.
+// The headers are ignored from anyone else, who could otherwise claim any
+// scheme or address they liked.
+func (a *app) ProxyMiddleware(handler http.Handler) http.Handler {
+	trusted := parseTrustedProxies(a.TrustedProxies)
+	isTrusted := func(ip net.IP) bool {
+		for _, ipnet := range trusted {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if len(trusted) == 0 {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil && isTrusted(ip) {
+			ctx := req.Context()
+			if strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+				ctx = context.WithValue(ctx, httpsKey, true)
+			}
+			if client := forwardedFor(req.Header.Values("X-Forwarded-For"), isTrusted); client != "" {
+				ctx = context.WithValue(ctx, clientIPKey, client)
+			}
+			req = req.WithContext(ctx)
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// forwardedFor returns the client named by X-Forwarded-For headers, or "" if
+// they name none. Each proxy appends the address it received the request
+// from, so the client is the last address that isn't one of the trusted
+// proxies; anything before it was sent by the client and can't be believed.
+func forwardedFor(headers []string, isTrusted func(net.IP) bool) string {
+	var hops []string
+	for _, header := range headers {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !isTrusted(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// isHTTPS reports whether req reached the wiki, or a trusted proxy in front
+// of it, over HTTPS.
+func isHTTPS(req *http.Request) bool {
+	https, _ := req.Context().Value(httpsKey).(bool)
+	return req.TLS != nil || https
+}
+
+// clientIP returns the address req was sent from, without its port. Behind a
+// trusted proxy, that is the client the proxy forwarded it for.
+func clientIP(req *http.Request) string {
+	if client, ok := req.Context().Value(clientIPKey).(string); ok {
+		return client
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
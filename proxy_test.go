@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// loginVia logs in as screenname with the given X-Forwarded-Proto from
+// remoteAddr and returns the session cookie.
+func loginVia(t *testing.T, a *app, screenname, remoteAddr, proto string) *http.Cookie {
+	t.Helper()
+
+	req := newRequest(http.MethodPost, "/user/login", url.Values{"screenname": {screenname}, "password": {testPassword}}, nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-Proto", proto)
+
+	for _, cookie := range serve(a, req).Result().Cookies() {
+		if cookie.Name == "periwiki-login" {
+			return cookie
+		}
+	}
+	t.Fatalf("login as %q failed", screenname)
+	return nil
+}
+
+func TestTrustedProxyHTTPS(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+	})
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Proxied", "Behind a proxy.", 0)
+
+	if cookie := loginVia(t, a, "alice", "192.0.2.1:1234", "https"); !cookie.Secure {
+		t.Error("expected the session cookie to be Secure behind a trusted proxy")
+	}
+	if cookie := loginVia(t, a, "alice", "10.1.2.3:1234", "https"); !cookie.Secure {
+		t.Error("expected the session cookie to be Secure behind a trusted proxy range")
+	}
+
+	req := newRequest(http.MethodGet, "/sitemap.xml", nil, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if body := serve(a, req).Body.String(); !strings.Contains(body, "<loc>https://example.com/wiki/Proxied</loc>") {
+		t.Errorf("expected https absolute URLs behind a trusted proxy:\n%s", body)
+	}
+}
+
+func TestUntrustedForwardedProto(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.TrustedProxies = []string{"192.0.2.1"}
+	})
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Proxied", "Behind a proxy.", 0)
+
+	if cookie := loginVia(t, a, "alice", "198.51.100.7:1234", "https"); cookie.Secure {
+		t.Error("expected X-Forwarded-Proto from an untrusted address to be ignored")
+	}
+
+	req := newRequest(http.MethodGet, "/sitemap.xml", nil, nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if body := serve(a, req).Body.String(); !strings.Contains(body, "<loc>http://example.com/wiki/Proxied</loc>") {
+		t.Errorf("expected http absolute URLs from an untrusted address:\n%s", body)
+	}
+}
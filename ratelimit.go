@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errTooManyAttempts = errors.New("too many attempts; try again later")
+
+// rateLimitedPaths are the forms whose POSTs RateLimitMiddleware limits.
+var rateLimitedPaths = map[string]bool{
+	"/user/login":    true,
+	"/user/register": true,
+	"/user/verify":   true,
+}
+
+// rateLimiter counts attempts by key over a sliding window: an attempt is
+// allowed if fewer than the limit were made in the window before it.
+type rateLimiter struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	// swept is when attempts was last cleared of keys with no attempts left
+	// in the window.
+	swept time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{attempts: make(map[string][]time.Time)}
+}
+
+// allow records an attempt by key at now unless limit attempts were already
+// made within window, in which case it returns how long until the oldest of
+// them expires.
+func (l *rateLimiter) allow(key string, limit int, window time.Duration, now time.Time) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Add(-window)
+	if now.Sub(l.swept) > window {
+		for other, times := range l.attempts {
+			if len(times) == 0 || !times[len(times)-1].After(start) {
+				delete(l.attempts, other)
+			}
+		}
+		l.swept = now
+	}
+
+	times := l.attempts[key]
+	for len(times) > 0 && !times[0].After(start) {
+		times = times[1:]
+	}
+	if len(times) >= limit {
+		l.attempts[key] = times
+		return false, times[len(times)-limit].Sub(start)
+	}
+	l.attempts[key] = append(times, now)
+	return true, 0
+}
+
+// reset forgets the attempts made by key.
+func (l *rateLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// RateLimitMiddleware limits how often one address may try to log in or
+// register, to slow down password guessing. Once the limit in the site
+// settings is reached, further attempts are refused with 429 Too Many
+// Requests until enough of them fall out of the window.
+func (a *app) RateLimitMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		settings := a.Settings()
+		if req.Method != http.MethodPost || !rateLimitedPaths[req.URL.Path] || settings.LoginRateLimit <= 0 {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+
+		ok, retryAfter := a.loginAttempts.allow(clientIP(req), settings.LoginRateLimit, settings.LoginRateWindow, time.Now())
+		if !ok {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			a.errorHandler(http.StatusTooManyRequests, rw, req, errTooManyAttempts)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestLoginRateLimit(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LoginRateLimit = 3
+		c.LoginRateWindow = time.Hour
+	})
+	mustRegister(t, a, "admin")
+
+	attempt := func(password, remoteAddr string) int {
+		form := url.Values{"screenname": {"admin"}, "password": {password}}
+		req := newRequest(http.MethodPost, "/user/login", form, nil)
+		req.RemoteAddr = remoteAddr
+		rr := serve(a, req)
+		if rr.Code == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header with 429")
+		}
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := attempt("wrong password", "192.0.2.1:1234"); code != http.StatusOK {
+			t.Fatalf("attempt %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := attempt("wrong password", "192.0.2.1:5678"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the fourth attempt to be refused, got %d", code)
+	}
+	if code := attempt("wrong password", "198.51.100.7:1234"); code != http.StatusOK {
+		t.Errorf("expected another address to be unaffected, got %d", code)
+	}
+
+	// A successful login clears the address's attempts.
+	attempt("wrong password", "198.51.100.7:1234")
+	attempt(testPassword, "198.51.100.7:1234")
+	for i := 0; i < 3; i++ {
+		if code := attempt("wrong password", "198.51.100.7:1234"); code != http.StatusOK {
+			t.Fatalf("attempt %d after logging in: expected 200, got %d", i+1, code)
+		}
+	}
+
+	// Registration shares the limit, and GETs aren't counted.
+	if rr := serve(a, newRequest(http.MethodGet, "/user/register", nil, nil)); rr.Code != http.StatusOK {
+		t.Errorf("expected the registration form to be served, got %d", rr.Code)
+	}
+	req := newRequest(http.MethodPost, "/user/register", url.Values{"screenname": {"mallory"}}, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if rr := serve(a, req); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected registration to be refused too, got %d", rr.Code)
+	}
+}
+
+func TestLoginRateLimitBehindProxy(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LoginRateLimit = 2
+		c.LoginRateWindow = time.Hour
+		c.TrustedProxies = []string{"10.0.0.0/8"}
+	})
+	mustRegister(t, a, "admin")
+
+	attempt := func(remoteAddr, forwardedFor string) int {
+		form := url.Values{"screenname": {"admin"}, "password": {"wrong password"}}
+		req := newRequest(http.MethodPost, "/user/login", form, nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serve(a, req).Code
+	}
+
+	// Clients behind the proxy are limited apart, even through a chain of
+	// proxies and with addresses of their own choosing in front.
+	for i := 0; i < 2; i++ {
+		attempt("10.0.0.1:1234", "192.0.2.1")
+	}
+	if code := attempt("10.0.0.1:1234", "203.0.113.9, 192.0.2.1, 10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the forwarded client to be limited, got %d", code)
+	}
+	if code := attempt("10.0.0.1:1234", "198.51.100.7"); code != http.StatusOK {
+		t.Errorf("expected another client of the proxy to be unaffected, got %d", code)
+	}
+
+	// Anyone else's X-Forwarded-For is ignored.
+	for i := 0; i < 2; i++ {
+		attempt("203.0.113.5:1234", "198.51.100.1")
+	}
+	if code := attempt("203.0.113.5:1234", "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("expected an untrusted X-Forwarded-For to be ignored, got %d", code)
+	}
+}
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	l := newRateLimiter()
+	start := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("ip", 2, time.Minute, start.Add(time.Duration(i)*10*time.Second)); !ok {
+			t.Fatalf("attempt %d: expected to be allowed", i+1)
+		}
+	}
+	ok, retryAfter := l.allow("ip", 2, time.Minute, start.Add(30*time.Second))
+	if ok || retryAfter != 30*time.Second {
+		t.Errorf("expected to wait 30s for the first attempt to expire, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+	if ok, _ := l.allow("ip", 2, time.Minute, start.Add(61*time.Second)); !ok {
+		t.Error("expected an attempt to be allowed once the first fell out of the window")
+	}
+}
+
+func TestManageLoginRateLimit(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+
+	form := url.Values{"site_name": {"periwiki"}, "login_rate_limit": {"5"}, "login_rate_window": {"10m"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+	}
+
+	reloaded := newApp(a.Config)
+	t.Cleanup(reloaded.Close)
+	if s := reloaded.Settings(); s.LoginRateLimit != 5 || s.LoginRateWindow != 10*time.Minute {
+		t.Errorf("expected the rate limit to persist, got %d per %v", s.LoginRateLimit, s.LoginRateWindow)
+	}
+
+	form.Set("login_rate_window", "soon")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad window to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecentChanges(t *testing.T) {
+	a := newTestApp(t)
+	alice := mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Cats", "Cats.", 0)
+	time.Sleep(5 * time.Millisecond)
+	postAs(t, a, alice, "Cats", "Cats are great.", 1)
+	time.Sleep(5 * time.Millisecond)
+	mustPostArticle(t, a, "Dogs", "Dogs.", 0)
+
+	revisions, err := a.GetRecentChanges(10, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rev := range revisions {
+		got = append(got, rev.URL)
+	}
+	if strings.Join(got, ",") != "Dogs,Cats,Cats" {
+		t.Fatalf("expected the revisions newest first, got %v", got)
+	}
+	if edit := revisions[1]; edit.ScreenName != "alice" || edit.Delta() != 10 || edit.DiffURL("") != "/wiki/Cats/diff/1/2" {
+		t.Errorf("expected alice's edit adding 10 bytes, got %+v (delta %d)", edit, edit.Delta())
+	}
+	if creation := revisions[0]; creation.Delta() != 5 {
+		t.Errorf("expected a new article's delta to be its size, got %d", creation.Delta())
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Special:RecentChanges?limit=2", nil, nil)).Body.String()
+	if !strings.Contains(page, `href="/wiki/Cats/diff/1/2"`) || !strings.Contains(page, "(+10)") || strings.Contains(page, `>Cats.<`) {
+		t.Errorf("expected Dogs and alice's edit of Cats, got:\n%s", page)
+	}
+	start := strings.Index(page, "before=")
+	if start < 0 {
+		t.Fatalf("expected a link to older changes, got:\n%s", page)
+	}
+	next := strings.ReplaceAll(page[start:start+strings.Index(page[start:], `"`)], "&amp;", "&")
+
+	older := serve(a, newRequest(http.MethodGet, "/wiki/Special:RecentChanges?limit=2&"+next, nil, nil)).Body.String()
+	if strings.Count(older, `<a href="/wiki/Cats">`) != 1 || strings.Contains(older, "Dogs") || strings.Contains(older, "Older changes") {
+		t.Errorf("expected only the creation of Cats on the second page, got:\n%s", older)
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:RecentChanges?limit=0", nil, nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad limit to be rejected, got %d", rr.Code)
+	}
+}
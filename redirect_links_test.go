@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedirectLinks(t *testing.T) {
+	a := newAnchorApp(t)
+
+	mustPostArticle(t, a, "Target", "## Setup\n\nText.\n", 0)
+	mustPostArticle(t, a, "Old_name", "---\nredirect: \"Target\"\n---\nMoved.\n", 0)
+	article := mustPostArticle(t, a, "Linker", "[[Old name]] [[Old name#Setup]] [[Old name#Usage]] [[Other]]\n", 0)
+
+	for _, want := range []string{
+		`<a href="/wiki/Old_name" title="Redirects to Target" class="pw-redirectlink" rel="nofollow">`,
+		`<a href="/wiki/Old_name#setup" title="Redirects to Target" class="pw-redirectlink" rel="nofollow">`,
+		`<a href="/wiki/Old_name#usage" title="Redirects to Target" class="pw-redirectlink pw-deadanchor" rel="nofollow">`,
+		`<a href="/wiki/Other" title="Other" class="pw-deadlink" rel="nofollow">`,
+	} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s:\n%s", want, article.HTML)
+		}
+	}
+
+	// Turning an article into a redirect re-renders the articles that link
+	// to it.
+	other := mustPostArticle(t, a, "Other", "Text.\n", 0)
+	waitForHTML(t, a, "Linker", func(html string) bool {
+		return !strings.Contains(html, "pw-deadlink")
+	})
+	mustPostArticle(t, a, "Other", "---\nredirect: \"Target\"\n---\nMoved.\n", other.ID)
+	waitForHTML(t, a, "Linker", func(html string) bool {
+		return strings.Contains(html, `<a href="/wiki/Other" title="Redirects to Target" class="pw-redirectlink"`)
+	})
+}
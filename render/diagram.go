@@ -0,0 +1,84 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// diagramTimeout is how long dot may take to draw one diagram.
+	diagramTimeout = 10 * time.Second
+	// diagramCacheSize is how many drawn diagrams are kept, so re-rendering
+	// an article doesn't run dot again for diagrams that haven't changed.
+	diagramCacheSize = 256
+)
+
+// dot draws Graphviz diagrams as SVG with the dot binary at binPath,
+// remembering the most recent.
+type dot struct {
+	binPath string
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte][]byte
+	order [][sha256.Size]byte
+}
+
+func newDot(binPath string) *dot {
+	return &dot{binPath: binPath, cache: make(map[[sha256.Size]byte][]byte)}
+}
+
+// Draw implements extensions.DrawFunc. Errors include what dot wrote to
+// stderr, so authors can see what's wrong with their diagram.
+func (d *dot) Draw(language string, source []byte) ([]byte, error) {
+	key := sha256.Sum256(append([]byte(language+"\n"), source...))
+	d.mu.Lock()
+	svg, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok {
+		return svg, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagramTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, d.binPath, "-Tsvg")
+	cmd.Stdin = bytes.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("dot took longer than %s", diagramTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("dot: %s", msg)
+		}
+		return nil, fmt.Errorf("dot: %w", err)
+	}
+
+	// Drop the XML declaration and doctype preceding the svg element.
+	svg = stdout.Bytes()
+	start := bytes.Index(svg, []byte("<svg"))
+	if start < 0 {
+		return nil, fmt.Errorf("dot: no SVG in output")
+	}
+	svg = svg[start:]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.cache[key]; !ok {
+		if len(d.order) == diagramCacheSize {
+			delete(d.cache, d.order[0])
+			d.order = d.order[1:]
+		}
+		d.cache[key] = svg
+		d.order = append(d.order, key)
+	}
+	return svg, nil
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ExternalLinkClass is the class WithExternalLinkPolicy gives links that leave
+// the wiki when the policy asks for an icon.
+const ExternalLinkClass = "pw-external"
+
+// ExternalLinkPolicy says how links to other sites are rendered. Links
+// without a host, such as WikiLinks, and links to one of Hosts are left
+// alone.
+type ExternalLinkPolicy struct {
+	// Hosts are the wiki's own hosts, with or without a port.
+	Hosts []string
+	// Icon gives external links ExternalLinkClass, for a stylesheet to mark
+	// them with an icon.
+	Icon bool
+}
+
+// WithExternalLinkPolicy opens links to other sites in a new tab, with
+// rel="nofollow noopener", as policy describes.
+func WithExternalLinkPolicy(policy ExternalLinkPolicy) Option {
+	return func(r *HTMLRenderer) {
+		r.externalLinks = &policy
+	}
+}
+
+// external reports whether href leaves the wiki.
+func (policy *ExternalLinkPolicy) external(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, host := range policy.Hosts {
+		if strings.EqualFold(u.Host, host) || strings.EqualFold(u.Hostname(), hostname(host)) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply marks the external links in document, reporting whether there were
+// any.
+func (policy *ExternalLinkPolicy) apply(document *goquery.Document) bool {
+	changed := false
+	document.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+		if href, _ := link.Attr("href"); !policy.external(href) {
+			return
+		}
+		link.SetAttr("rel", "nofollow noopener")
+		link.SetAttr("target", "_blank")
+		if policy.Icon {
+			link.AddClass(ExternalLinkClass)
+		}
+		changed = true
+	})
+	return changed
+}
+
+// hostname strips the port, if any, from host.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"strings"
+)
+
+// sourceNestingDepth estimates how deeply md nests blockquotes and lists by
+// scanning its lines, without parsing it. Parsing deeply nested lists takes
+// time quadratic in their depth, so input must be refused before goldmark
+// sees it. The estimate counts every '>' and list marker opening a line, and
+// list items indented under one another; fenced code is skipped.
+func sourceNestingDepth(md string) int {
+	var (
+		deepest int
+		markers []int // columns of the list markers enclosing the current line
+		fence   string
+	)
+
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+
+		depth, col, first := 0, 0, -1
+		for i := 0; i < len(line); {
+			switch c := line[i]; {
+			case c == ' ':
+				col++
+				i++
+			case c == '\t':
+				col += 4 - col%4
+				i++
+			case c == '>':
+				depth++
+				col++
+				i++
+			default:
+				n := listMarkerLen(line[i:])
+				if n == 0 {
+					i = len(line)
+					break
+				}
+				if first < 0 && depth == 0 {
+					first = col
+				} else {
+					depth++
+				}
+				col += n
+				i += n
+			}
+		}
+
+		if first >= 0 {
+			for len(markers) > 0 && markers[len(markers)-1] >= first {
+				markers = markers[:len(markers)-1]
+			}
+			markers = append(markers, first)
+		}
+		if depth += len(markers); depth > deepest {
+			deepest = depth
+		}
+	}
+
+	return deepest
+}
+
+// listMarkerLen returns the length of the bullet or ordered list marker,
+// including the space after it, that s starts with, or 0 if it starts with
+// none.
+func listMarkerLen(s string) int {
+	n := 0
+	if len(s) > 0 && (s[0] == '-' || s[0] == '*' || s[0] == '+') {
+		n = 1
+	} else {
+		for n < len(s) && n < 9 && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		if n == 0 || n >= len(s) || (s[n] != '.' && s[n] != ')') {
+			return 0
+		}
+		n++
+	}
+
+	if n == len(s) {
+		return n
+	}
+	if s[n] == ' ' || s[n] == '\t' {
+		return n + 1
+	}
+	return 0
+}
@@ -2,46 +2,449 @@ package render
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
 
 	"html/template"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/pkg/errors"
 
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 
 	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
 
 	"github.com/danielledeleo/periwiki/extensions"
+	"github.com/danielledeleo/periwiki/extensions/ast"
 )
 
+// Version is bumped whenever a change to the rendering pipeline alters the
+// HTML produced for the same markdown. Changes to the sanitizer policy applied
+// to its output are tracked by WithSanitizerPolicy instead.
+const Version = 7
+
+// fingerprintModules are the dependencies whose upgrades may change the HTML
+// produced for the same markdown.
+var fingerprintModules = []string{
+	"github.com/yuin/goldmark",
+	"github.com/alecthomas/chroma/v2",
+	"github.com/PuerkitoBio/goquery",
+	"github.com/microcosm-cc/bluemonday",
+	"golang.org/x/net",
+}
+
 type HTMLRenderer struct {
-	md goldmark.Markdown
-}
-
-func NewHTMLRenderer() *HTMLRenderer {
-	r := &HTMLRenderer{
-		md: goldmark.New(
-			goldmark.WithParserOptions(
-				parser.WithAutoHeadingID(),
-			),
-			goldmark.WithExtensions(
-				extensions.NewWikiLinker(
-					extensions.WithUnderscoreResolver(),
-				),
-			),
-		),
+	md                goldmark.Markdown
+	exists            func(url string) bool
+	hasAnchor         func(url, anchor string) bool
+	redirect          func(url string) (string, bool)
+	definitionAnchors bool
+	taskLists         bool
+	embed             func(url string) (string, bool)
+	maxNestingDepth   int
+	highlightStyle    string
+	math              bool
+	toc               bool
+	dot               *dot
+	fetch             func(url string) (string, bool)
+	externalLinks     *ExternalLinkPolicy
+	fingerprint       string
+	policyVersion     int
+}
+
+// ErrNestingTooDeep is returned by Render for markdown whose blockquotes and
+// lists are nested more deeply than WithMaxNestingDepth allows.
+var ErrNestingTooDeep = errors.New("blockquotes and lists are nested too deeply")
+
+// Option configures an HTMLRenderer.
+type Option func(*HTMLRenderer)
+
+// WithSanitizerPolicy records the version of the sanitizer policy applied to
+// r's output in its fingerprint, so HTML sanitized under another version of
+// the policy counts as outdated.
+func WithSanitizerPolicy(version int) Option {
+	return func(r *HTMLRenderer) {
+		r.policyVersion = version
+	}
+}
+
+// WithExistenceChecker marks WikiLinks to articles for which exists returns
+// false as dead links.
+func WithExistenceChecker(exists func(url string) bool) Option {
+	return func(r *HTMLRenderer) {
+		r.exists = exists
+	}
+}
+
+// WithAnchorChecker marks WikiLinks to sections of existing articles for
+// which hasAnchor returns false, and those to sections missing from the page
+// itself, as dead anchors. It takes effect along with WithExistenceChecker.
+func WithAnchorChecker(hasAnchor func(url, anchor string) bool) Option {
+	return func(r *HTMLRenderer) {
+		r.hasAnchor = hasAnchor
 	}
+}
+
+// WithRedirectChecker marks WikiLinks to redirects, for which redirect
+// returns the URL they lead to, and titles them with it. Their sections are
+// checked in that article. It takes effect along with WithExistenceChecker.
+func WithRedirectChecker(redirect func(url string) (string, bool)) Option {
+	return func(r *HTMLRenderer) {
+		r.redirect = redirect
+	}
+}
+
+// WithDefinitionAnchors enables definition lists and gives their terms ids
+// that WikiLinks can target, e.g. `[[Glossary#Term]]`.
+func WithDefinitionAnchors() Option {
+	return func(r *HTMLRenderer) {
+		r.definitionAnchors = true
+	}
+}
+
+// WithTaskLists renders GitHub-style task list items, `- [ ]` and `- [x]`,
+// with disabled checkboxes.
+func WithTaskLists() Option {
+	return func(r *HTMLRenderer) {
+		r.taskLists = true
+	}
+}
+
+// WithNavboxes enables `{{nav:Some_Navbox}}`, which embeds the HTML embed
+// returns for the named article. Navboxes within the embedded HTML are
+// dropped, so articles embedding each other can't recurse. If embed returns
+// false, a dead link to the article is shown instead.
+func WithNavboxes(embed func(url string) (html string, ok bool)) Option {
+	return func(r *HTMLRenderer) {
+		r.embed = embed
+	}
+}
+
+// WithSyntaxHighlighting highlights fenced code blocks that name their
+// language, using CSS classes for the chroma style of the given name. See
+// HighlightCSS.
+func WithSyntaxHighlighting(style string) Option {
+	return func(r *HTMLRenderer) {
+		r.highlightStyle = style
+	}
+}
+
+// WithMathRendering recognises TeX between dollar signs, `$...$` inline and
+// `$$...$$` for display, and marks it up for KaTeX or MathJax to typeset.
+func WithMathRendering(enabled bool) Option {
+	return func(r *HTMLRenderer) {
+		r.math = enabled
+	}
+}
+
+// WithTableOfContents lists an article's section headings, nested by level,
+// in place of a `[[TOC]]` paragraph or else before the first heading.
+func WithTableOfContents(enabled bool) Option {
+	return func(r *HTMLRenderer) {
+		r.toc = enabled
+	}
+}
+
+// WithDiagrams draws fenced code blocks in Graphviz's language, "```graphviz"
+// or "```dot", as inline SVG with the dot binary at binPath.
+func WithDiagrams(enabled bool, binPath string) Option {
+	return func(r *HTMLRenderer) {
+		r.dot = nil
+		if enabled {
+			r.dot = newDot(binPath)
+		}
+	}
+}
+
+// WithMaxNestingDepth makes Render refuse markdown with blockquotes and lists
+// nested more than depth deep, which could otherwise take pathologically long
+// to render. Navboxes don't count, as they are never nested.
+func WithMaxNestingDepth(depth int) Option {
+	return func(r *HTMLRenderer) {
+		r.maxNestingDepth = depth
+	}
+}
+
+func NewHTMLRenderer(opts ...Option) *HTMLRenderer {
+	r := &HTMLRenderer{}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	resolver := extensions.WithUnderscoreResolver()
+	if r.exists != nil && r.redirect != nil {
+		resolver = extensions.WithRedirectAwareResolver(r.exists, r.hasAnchor, r.redirect)
+	} else if r.exists != nil && r.hasAnchor != nil {
+		resolver = extensions.WithDeadAnchorResolver(r.exists, r.hasAnchor)
+	} else if r.exists != nil {
+		resolver = extensions.WithDeadLinkResolver(r.exists)
+	}
+
+	exts := []goldmark.Extender{extensions.NewWikiLinker(resolver), extensions.CategoryLinks}
+	if r.definitionAnchors {
+		exts = append(exts, extensions.DefinitionAnchors)
+	}
+	if r.taskLists {
+		exts = append(exts, extension.TaskList)
+	}
+	if r.embed != nil {
+		exts = append(exts, extensions.Navboxes)
+	}
+	if r.highlightStyle != "" {
+		exts = append(exts, extensions.NewSyntaxHighlighter(styles.Get(r.highlightStyle)))
+	}
+	if r.math {
+		exts = append(exts, extensions.Math)
+	}
+	if r.dot != nil {
+		exts = append(exts, extensions.NewDiagrammer(r.dot.Draw))
+	}
+	if r.fetch != nil {
+		exts = append(exts, extensions.Transclusions)
+	}
+
+	r.md = goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+		goldmark.WithExtensions(exts...),
+	)
+	r.fingerprint = r.computeFingerprint()
 
 	return r
 }
 
+// Fingerprint identifies the output of r: its options, Version, the version of
+// the sanitizer policy, the versions of the libraries it renders with and its
+// table of contents template. HTML
+// rendered under a different fingerprint may differ from what r would
+// produce now.
+func (r *HTMLRenderer) Fingerprint() string {
+	return r.fingerprint
+}
+
+func (r *HTMLRenderer) computeFingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version=%d\npolicy=%d\ndefinitionAnchors=%t\ntaskLists=%t\nnavboxes=%t\nhighlightStyle=%s\nmath=%t\ntoc=%t\ndiagrams=%t\ntransclusion=%t\n",
+		Version, r.policyVersion, r.definitionAnchors, r.taskLists, r.embed != nil, r.highlightStyle, r.math, r.toc, r.dot != nil, r.fetch != nil)
+	if r.exists != nil && r.hasAnchor != nil {
+		fmt.Fprintf(h, "anchors=true\n")
+	}
+	if r.exists != nil && r.redirect != nil {
+		fmt.Fprintf(h, "redirects=true\n")
+	}
+	if r.externalLinks != nil {
+		fmt.Fprintf(h, "externalLinks=%s icon=%t\n", strings.Join(r.externalLinks.Hosts, ","), r.externalLinks.Icon)
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			for _, path := range fingerprintModules {
+				if dep.Path == path {
+					fmt.Fprintf(h, "%s@%s\n", dep.Path, dep.Version)
+				}
+			}
+		}
+	}
+
+	if toc, err := os.ReadFile("templates/helpers/toc.html"); err == nil {
+		h.Write(toc)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// HighlightCSS returns the stylesheet for highlighted code, or "" if syntax
+// highlighting is off.
+func (r *HTMLRenderer) HighlightCSS() (string, error) {
+	if r.highlightStyle == "" {
+		return "", nil
+	}
+	var css bytes.Buffer
+	if err := extensions.HighlightFormatter.WriteCSS(&css, styles.Get(r.highlightStyle)); err != nil {
+		return "", err
+	}
+	return css.String(), nil
+}
+
+// Links returns the distinct article URLs that md, the markdown of the
+// article at url with its transclusions expanded, links to with WikiLinks, in
+// order of first appearance.
+func (r *HTMLRenderer) Links(url, md string) []string {
+	md, _ = r.expand(url, md)
+	source := []byte(md)
+	doc := r.md.Parser().Parse(text.NewReader(source))
+
+	links := []string{}
+	seen := make(map[string]bool)
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if r.toc && isTOCPlaceholder(n) {
+			return gast.WalkSkipChildren, nil
+		}
+		if link, ok := n.(*ast.WikiLink); ok && entering {
+			url, _, _ := strings.Cut(string(link.Link.Destination), "#")
+			url = strings.TrimPrefix(url, "/wiki/")
+			if url != "" && !seen[url] {
+				seen[url] = true
+				links = append(links, url)
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+
+	return links
+}
+
+// Anchors returns the ids of the headings and definition terms in md, the
+// markdown of the article at url with its transclusions expanded, that
+// WikiLinks to its sections can name, in order.
+func (r *HTMLRenderer) Anchors(url, md string) []string {
+	md, _ = r.expand(url, md)
+	doc := r.md.Parser().Parse(text.NewReader([]byte(md)))
+	return extensions.Anchors(doc)
+}
+
+// Categories returns the names of the categories md, the markdown of the
+// article at url with its transclusions expanded, puts its article in with
+// `[[Category:Name]]` WikiLinks, in order of first appearance.
+func (r *HTMLRenderer) Categories(url, md string) []string {
+	md, _ = r.expand(url, md)
+	pc := parser.NewContext()
+	r.md.Parser().Parse(text.NewReader([]byte(md)), parser.WithContext(pc))
+	return extensions.Categories(pc)
+}
+
+// Embeds returns the distinct article URLs that md, the markdown of the
+// article at url, transcludes, directly or
+// through the articles it transcludes, and then those it embeds as navboxes,
+// in order of first appearance.
+func (r *HTMLRenderer) Embeds(url, md string) []string {
+	md, transcluded := r.expand(url, md)
+	source := []byte(md)
+	doc := r.md.Parser().Parse(text.NewReader(source))
+
+	embeds := []string{}
+	seen := make(map[string]bool)
+	for _, url := range transcluded {
+		seen[url] = true
+		embeds = append(embeds, url)
+	}
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if navbox, ok := n.(*ast.Navbox); ok && entering {
+			url := string(navbox.Target)
+			if !seen[url] {
+				seen[url] = true
+				embeds = append(embeds, url)
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+
+	return embeds
+}
+
+// fillNavboxes replaces the content of each navbox placeholder in document
+// with the embedded article's HTML, less any navboxes of its own.
+func (r *HTMLRenderer) fillNavboxes(document *goquery.Document) {
+	document.Find("div." + extensions.NavboxClass).Each(func(_ int, navbox *goquery.Selection) {
+		url, _ := navbox.Attr("data-navbox")
+		navbox.RemoveAttr("data-navbox")
+
+		embedded, ok := r.embed(url)
+		if !ok {
+			link := `<a href="/wiki/` + template.HTMLEscapeString(url) + `" class="` + extensions.DeadLinkClass + `">` +
+				template.HTMLEscapeString(strings.ReplaceAll(url, "_", " ")) + `</a>`
+			navbox.SetHtml(link)
+			return
+		}
+
+		navbox.SetHtml(embedded)
+		navbox.Find("div." + extensions.NavboxClass).Remove()
+		navbox.Find("#toc").Remove()
+	})
+}
+
+func (r *HTMLRenderer) nestingError() error {
+	return fmt.Errorf("%w: the limit is %d levels", ErrNestingTooDeep, r.maxNestingDepth)
+}
+
+// CheckNesting returns ErrNestingTooDeep if md, the markdown of the article at
+// url with its transclusions expanded, nests blockquotes and lists more deeply
+// than WithMaxNestingDepth allows. It doesn't parse md, so it is cheap enough
+// to run before saving markdown that will be rendered later.
+func (r *HTMLRenderer) CheckNesting(url, md string) error {
+	if r.maxNestingDepth <= 0 {
+		return nil
+	}
+	md, _ = r.expand(url, md)
+	if sourceNestingDepth(md) > r.maxNestingDepth {
+		return r.nestingError()
+	}
+	return nil
+}
+
+// checkNesting returns ErrNestingTooDeep if doc nests blockquotes and lists
+// more than maxNestingDepth deep.
+func (r *HTMLRenderer) checkNesting(doc gast.Node) error {
+	if r.maxNestingDepth <= 0 {
+		return nil
+	}
+
+	depth := 0
+	return gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		switch n.Kind() {
+		case gast.KindBlockquote, gast.KindList:
+		default:
+			return gast.WalkContinue, nil
+		}
+
+		if !entering {
+			depth--
+			return gast.WalkContinue, nil
+		}
+		depth++
+		if depth > r.maxNestingDepth {
+			return gast.WalkStop, r.nestingError()
+		}
+		return gast.WalkContinue, nil
+	})
+}
+
+// Render renders md, which isn't any article's markdown, as HTML.
 func (r *HTMLRenderer) Render(md string) (string, error) {
+	return r.RenderArticle("", md)
+}
+
+// RenderArticle renders md, the markdown of the article at url, as HTML. The
+// url keeps the article from transcluding itself.
+func (r *HTMLRenderer) RenderArticle(url, md string) (string, error) {
 	buf := &bytes.Buffer{}
 
-	if err := r.md.Convert([]byte(md), buf); err != nil {
+	md, _ = r.expand(url, md)
+	if r.maxNestingDepth > 0 && sourceNestingDepth(md) > r.maxNestingDepth {
+		return "", r.nestingError()
+	}
+	source := []byte(md)
+	doc := r.md.Parser().Parse(text.NewReader(source))
+	if err := r.checkNesting(doc); err != nil {
+		return "", err
+	}
+	if r.exists != nil && r.hasAnchor != nil {
+		extensions.MarkDeadSelfAnchors(doc)
+	}
+	if r.toc {
+		markTOCPlaceholders(doc)
+	}
+	if err := r.md.Renderer().Render(buf, source, doc); err != nil {
 		return "", errors.Wrap(err, "failed to Convert")
 	}
 	rawhtml := buf.Bytes()
@@ -54,43 +457,28 @@ func (r *HTMLRenderer) Render(md string) (string, error) {
 
 	document := goquery.NewDocumentFromNode(root)
 
-	headers := document.Find("h2")
-	if headers.Length() == 0 {
+	changed := false
+	if r.embed != nil && document.Find("div."+extensions.NavboxClass).Length() > 0 {
+		r.fillNavboxes(document)
+		changed = true
+	}
+	if r.toc {
+		inserted, err := insertTOC(document)
 		if err != nil {
 			return "", err
 		}
-		return string(rawhtml), nil
-	}
-
-	tmpl, err := template.ParseFiles("templates/helpers/toc.html")
-	if err != nil {
-		return "", err
+		changed = changed || inserted
 	}
-
-	outbuf := &bytes.Buffer{}
-	err = tmpl.Execute(outbuf, map[string]interface{}{"Headers": headers.Nodes})
-	if err != nil {
-		return "", err
-	}
-
-	fakeBody := &html.Node{
-		Type:     html.ElementNode,
-		Data:     "body",
-		DataAtom: atom.Body,
+	if r.externalLinks != nil && r.externalLinks.apply(document) {
+		changed = true
 	}
-
-	newnode, err := html.ParseFragment(outbuf, fakeBody)
-	if err != nil {
-		return "", err
+	if !changed {
+		return string(rawhtml), nil
 	}
 
-	root.InsertBefore(newnode[0], headers.Nodes[0])
-
-	outbuf.Reset()
-	err = html.Render(outbuf, root)
-	if err != nil {
+	outbuf := &bytes.Buffer{}
+	if err := html.Render(outbuf, root); err != nil {
 		return "", err
 	}
-
 	return outbuf.String(), nil
 }
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	gast "github.com/yuin/goldmark/ast"
+
+	"github.com/danielledeleo/periwiki/extensions"
+	"github.com/danielledeleo/periwiki/extensions/ast"
+)
+
+// tocPlaceholderAttr marks the paragraph holding a `[[TOC]]` placeholder.
+// Markdown can't set attributes, so only the renderer can add it.
+const tocPlaceholderAttr = "data-pw-toc"
+
+// headingSelector matches the headings listed in a table of contents. The
+// article's title is its only h1.
+const headingSelector = "h2, h3, h4, h5, h6"
+
+// Heading is a section heading of a rendered article, with the headings of
+// its subsections.
+type Heading struct {
+	Level    int
+	ID       string
+	Text     string
+	Children []*Heading
+}
+
+// Headings returns the tree of section headings in the rendered HTML, less
+// those within navboxes.
+func Headings(rendered string) []*Heading {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(rendered))
+	if err != nil {
+		return nil
+	}
+	return headingTree(document)
+}
+
+// sectionHeadings selects document's headings that have ids and aren't
+// within navboxes.
+func sectionHeadings(document *goquery.Document) *goquery.Selection {
+	return document.Find(headingSelector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		_, ok := s.Attr("id")
+		return ok && s.Closest("div."+extensions.NavboxClass).Length() == 0
+	})
+}
+
+func headingTree(document *goquery.Document) []*Heading {
+	var roots []*Heading
+	var open []*Heading
+	sectionHeadings(document).Each(func(_ int, s *goquery.Selection) {
+		id, _ := s.Attr("id")
+		heading := &Heading{
+			Level: int(s.Nodes[0].Data[1] - '0'),
+			ID:    id,
+			Text:  strings.TrimSpace(s.Text()),
+		}
+
+		for len(open) > 0 && open[len(open)-1].Level >= heading.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			roots = append(roots, heading)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, heading)
+		}
+		open = append(open, heading)
+	})
+	return roots
+}
+
+// isTOCPlaceholder reports whether n is a paragraph of nothing but `[[TOC]]`.
+// The WikiLink parser leaves empty text nodes before links, which are
+// ignored.
+func isTOCPlaceholder(n gast.Node) bool {
+	if n.Kind() != gast.KindParagraph {
+		return false
+	}
+	var link *ast.WikiLink
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if text, ok := child.(*gast.Text); ok && text.Segment.IsEmpty() {
+			continue
+		}
+		wikiLink, ok := child.(*ast.WikiLink)
+		if !ok || link != nil {
+			return false
+		}
+		link = wikiLink
+	}
+	return link != nil && string(link.OriginalDest) == "TOC" && string(link.Link.Title) == "TOC"
+}
+
+// markTOCPlaceholders marks the `[[TOC]]` paragraphs in doc so that
+// insertTOC can find them once rendered.
+func markTOCPlaceholders(doc gast.Node) {
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if entering && isTOCPlaceholder(n) {
+			n.SetAttributeString(tocPlaceholderAttr, []byte("true"))
+			return gast.WalkSkipChildren, nil
+		}
+		return gast.WalkContinue, nil
+	})
+}
+
+// insertTOC puts a table of contents of document's headings in place of the
+// first `[[TOC]]` placeholder, or else before the first heading. Other
+// placeholders, and any when there are no headings, are removed. It reports
+// whether document changed.
+func insertTOC(document *goquery.Document) (bool, error) {
+	placeholders := document.Find("p[" + tocPlaceholderAttr + "]")
+	headings := headingTree(document)
+	if len(headings) == 0 {
+		placeholders.Remove()
+		return placeholders.Length() > 0, nil
+	}
+
+	tmpl, err := template.ParseFiles("templates/helpers/toc.html")
+	if err != nil {
+		return false, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, map[string]interface{}{"Headings": headings}); err != nil {
+		return false, err
+	}
+	body := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	}
+	toc, err := html.ParseFragment(buf, body)
+	if err != nil {
+		return false, err
+	}
+
+	if placeholders.Length() > 0 {
+		placeholders.First().ReplaceWithNodes(toc...)
+		placeholders.Remove()
+	} else {
+		sectionHeadings(document).First().BeforeNodes(toc...)
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package render
+
+import (
+	"bytes"
+
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/danielledeleo/periwiki/extensions/ast"
+)
+
+// MaxTransclusionDepth is how deeply articles may transclude articles that
+// transclude others in turn.
+const MaxTransclusionDepth = 5
+
+// WithTransclusion replaces each `{{Page Name}}` with the markdown fetch
+// returns for the named article before rendering, or with a WikiLink to the
+// article if fetch returns false. References that would transclude an
+// article within itself, or nest deeper than MaxTransclusionDepth, are shown
+// as written. The markdown is only ever parsed as markdown.
+func WithTransclusion(fetch func(url string) (markdown string, ok bool)) Option {
+	return func(r *HTMLRenderer) {
+		r.fetch = fetch
+	}
+}
+
+// expand returns md, the markdown of the article at url, with its
+// transclusions expanded, and the distinct URLs of the articles it tried to
+// transclude, in order of first appearance. The url may be "" for markdown
+// that isn't an article's.
+func (r *HTMLRenderer) expand(url, md string) (string, []string) {
+	if r.fetch == nil {
+		return md, nil
+	}
+
+	transcluded := []string{}
+	seen := make(map[string]bool)
+	note := func(url string) {
+		if !seen[url] {
+			seen[url] = true
+			transcluded = append(transcluded, url)
+		}
+	}
+	expanded := r.expandFrom([]byte(md), map[string]bool{url: true}, 0, note)
+	return string(expanded), transcluded
+}
+
+// expandFrom expands the transclusions in source, which is depth articles
+// deep in those listed in within.
+func (r *HTMLRenderer) expandFrom(source []byte, within map[string]bool, depth int, note func(url string)) []byte {
+	doc := r.md.Parser().Parse(text.NewReader(source))
+
+	var refs []*ast.Transclusion
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if ref, ok := n.(*ast.Transclusion); ok && entering {
+			refs = append(refs, ref)
+		}
+		return gast.WalkContinue, nil
+	})
+	if len(refs) == 0 {
+		return source
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, ref := range refs {
+		out.Write(source[last:ref.Segment.Start])
+		last = ref.Segment.Stop
+
+		url := string(ref.Target)
+		if within[url] || depth >= MaxTransclusionDepth {
+			written := ref.Segment.Value(source)
+			written = bytes.ReplaceAll(written, []byte("{"), []byte(`\{`))
+			written = bytes.ReplaceAll(written, []byte("}"), []byte(`\}`))
+			out.Write(written)
+			continue
+		}
+
+		note(url)
+		md, ok := r.fetch(url)
+		if !ok {
+			out.WriteString("[[" + url + "]]")
+			continue
+		}
+		within[url] = true
+		out.Write(r.expandFrom([]byte(md), within, depth+1, note))
+		delete(within, url)
+	}
+	out.Write(source[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/render"
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// execSQL runs query against a's database directly.
+func execSQL(t *testing.T, a *app, query string, args ...interface{}) {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", a.DatabaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderFingerprint(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RerenderOutdatedHTML = true
+	})
+	posted := mustPostArticle(t, a, "Fingerprinted", "Some *emphasis*.", 0)
+	if posted.RenderFingerprint == "" {
+		t.Fatal("expected the revision to record its render fingerprint")
+	}
+
+	const cached = "<p>cached</p>"
+	execSQL(t, a, `UPDATE Revision SET html = ?`, cached)
+
+	article, err := a.GetArticle("Fingerprinted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.HTML != cached {
+		t.Errorf("expected a matching fingerprint to reuse the cached HTML, got %q", article.HTML)
+	}
+
+	execSQL(t, a, `UPDATE Revision SET render_fingerprint = 'outdated'`)
+
+	for _, get := range []func() (*wiki.Article, error){
+		func() (*wiki.Article, error) { return a.GetArticleByRevisionID("Fingerprinted", posted.ID) },
+		func() (*wiki.Article, error) { return a.GetArticle("Fingerprinted") },
+	} {
+		article, err = get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(article.HTML, "<em>emphasis</em>") {
+			t.Errorf("expected an outdated fingerprint to force a re-render, got %q", article.HTML)
+		}
+		if article.RenderFingerprint != posted.RenderFingerprint {
+			t.Errorf("expected the current fingerprint to be stored, got %q", article.RenderFingerprint)
+		}
+	}
+}
+
+func TestRenderFingerprintDisabled(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Fingerprinted", "Some *emphasis*.", 0)
+
+	execSQL(t, a, `UPDATE Revision SET html = '<p>cached</p>', render_fingerprint = 'outdated'`)
+
+	article, err := a.GetArticle("Fingerprinted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.HTML != "<p>cached</p>" {
+		t.Errorf("expected the stored HTML to be served as is, got %q", article.HTML)
+	}
+}
+
+func TestRenderFingerprintCoversSanitizerPolicy(t *testing.T) {
+	before := render.NewHTMLRenderer(render.WithSanitizerPolicy(1)).Fingerprint()
+	after := render.NewHTMLRenderer(render.WithSanitizerPolicy(2)).Fingerprint()
+	if before == after {
+		t.Error("expected a new sanitizer policy to change the fingerprint")
+	}
+}
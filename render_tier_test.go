@@ -0,0 +1,291 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/renderqueue"
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestLargeArticlesRenderInBackground(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1024
+	})
+
+	small := "A short article."
+	large := strings.Repeat("A very long paragraph. ", 100)
+
+	if tier := a.RenderTier(small); tier != renderqueue.TierInteractive {
+		t.Errorf("expected a small edit to render interactively, got %v", tier)
+	}
+	if tier := a.RenderTier(large); tier != renderqueue.TierBackground {
+		t.Errorf("expected a large edit to render in the background, got %v", tier)
+	}
+
+	mustPostArticle(t, a, "Small", small, 0)
+	if article, _ := a.GetArticle("Small"); article.RenderPending() {
+		t.Error("expected a small article to be rendered before it was saved")
+	}
+
+	mustPostArticle(t, a, "Large", large, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		article, err := a.GetArticle("Large")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !article.RenderPending() {
+			if !strings.Contains(article.HTML, "A very long paragraph.") {
+				t.Errorf("expected the background render to be stored, got %q", article.HTML)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRenderPendingPagePolls(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+	})
+	// With the workers stopped the background render never lands.
+	a.Close()
+	mustPostArticle(t, a, "Large", "Body.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Large", nil, nil)).Body.String()
+	if !strings.Contains(page, `<meta http-equiv="refresh"`) || !strings.Contains(page, "still being rendered") {
+		t.Errorf("expected a polling placeholder, got:\n%s", page)
+	}
+}
+
+func TestRenderTierWithoutWorkers(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RenderWorkers = 0
+		c.LargeArticleRenderBytes = 10
+	})
+
+	if tier := a.RenderTier("Longer than ten bytes."); tier != renderqueue.TierInteractive {
+		t.Errorf("expected renders to stay interactive without workers, got %v", tier)
+	}
+}
+
+func TestManageQueuePage(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+	})
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+
+	mustPostArticle(t, a, "Large", "Body.", 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if s, _ := a.RenderQueueSnapshot(); len(s.Recent) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/manage/queue", nil, login(t, a, "admin"))).Body.String()
+	for _, want := range []string{
+		`<meta http-equiv="refresh"`,
+		"<td>interactive</td><td>0</td>",
+		"<td>background</td><td>0</td>",
+		`<table class="pw-queue-recent">`,
+		`<a href="/wiki/Large">Large</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the queue page to contain %q:\n%s", want, page)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/manage/queue", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+	}
+}
+
+func TestManageToolsPage(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+	})
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	admin := login(t, a, "admin")
+
+	mustPostArticle(t, a, "Rendered", "Body.", 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if s, _ := a.RenderQueueStats(); s.Processed > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// With the workers stopped this render stays queued.
+	a.Close()
+	mustPostArticle(t, a, "Stuck", "Body.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/manage/tools", nil, admin)).Body.String()
+	for _, want := range []string{
+		"Revisions waiting to be rendered: 1",
+		"<th>Pending (background)</th><td>0</td>",
+		"<th>Processed</th><td>1</td>",
+		"<th>Errors</th><td>0</td>",
+		"<th>Average render time</th>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the tools page to contain %q:\n%s", want, page)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/manage/tools", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+	}
+}
+
+func TestRenderWorkersSetting(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+	})
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+
+	form := url.Values{"site_name": {"periwiki"}, "render_workers": {"-1"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a negative number of workers to be rejected, got %d", rr.Code)
+	}
+	form.Set("render_workers", "0")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+	}
+	if snapshot, _ := a.RenderQueueSnapshot(); snapshot.Workers != 0 {
+		t.Errorf("expected the workers to be stopped, got %d", snapshot.Workers)
+	}
+
+	mustPostArticle(t, a, "Paused", "Body.", 0)
+	if article, _ := a.GetArticle("Paused"); !article.RenderPending() {
+		t.Errorf("expected the render to wait for a worker, got %q", article.HTML)
+	}
+
+	form.Set("render_workers", "3")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+	}
+	if snapshot, _ := a.RenderQueueSnapshot(); snapshot.Workers != 3 {
+		t.Errorf("expected 3 workers, got %d", snapshot.Workers)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		article, err := a.GetArticle("Paused")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !article.RenderPending() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the waiting render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResubmitQueuedRenders(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+	})
+	// With the workers stopped, as when the server stops before the queue
+	// drains, these are left waiting to be rendered.
+	a.Close()
+	first := mustPostArticle(t, a, "Stuck", "Old body.", 0).ID
+	mustPostArticle(t, a, "Stuck", "New *body*.", first)
+	mustPostArticle(t, a, "Also_Stuck", "Body.", 0)
+
+	b := newApp(a.Config)
+	t.Cleanup(b.Close)
+	n, err := b.ResubmitQueuedRenders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected all 3 revisions to be resubmitted, got %d", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		article, err := b.GetArticle("Stuck")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !article.RenderPending() {
+			if !strings.Contains(article.HTML, "<em>body</em>") {
+				t.Errorf("expected the head revision to be rendered, got %q", article.HTML)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the resubmitted render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for {
+		old, err := b.GetArticleByRevisionID("Stuck", first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !old.RenderPending() {
+			if !strings.Contains(old.HTML, "Old body.") {
+				t.Errorf("expected the old revision to be rendered, got %q", old.HTML)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the old revision was never rendered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRenderTimeLimit(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.LargeArticleRenderBytes = 1
+		// Long past by the time any render could finish.
+		c.MaxRenderDuration = time.Nanosecond
+	})
+	mustPostArticle(t, a, "Slow", strings.Repeat("A very long paragraph. ", 100), 0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		article, err := a.GetArticle("Slow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !article.RenderPending() {
+			if !article.RenderFailed() {
+				t.Errorf("expected the render to be stopped, got %q", article.HTML)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the background render never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Slow", nil, nil)).Body.String()
+	if !strings.Contains(page, "couldn't be rendered") {
+		t.Errorf("expected the failure placeholder, got:\n%s", page)
+	}
+}
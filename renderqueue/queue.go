@@ -0,0 +1,523 @@
+// Package renderqueue schedules article renders on a fixed pool of workers.
+// Pending jobs are ordered by tier and then by submission time, and
+// submitting a revision that is already waiting merges the two jobs.
+package renderqueue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier is the urgency of a render. Lower tiers are rendered first.
+type Tier int
+
+const (
+	// TierInteractive is for renders a user is waiting on.
+	TierInteractive Tier = iota
+	// TierBackground is for housekeeping, e.g. refreshing backlinks.
+	TierBackground
+)
+
+func (t Tier) String() string {
+	switch t {
+	case TierInteractive:
+		return "interactive"
+	case TierBackground:
+		return "background"
+	}
+	return fmt.Sprintf("Tier(%d)", int(t))
+}
+
+var ErrQueueClosed = errors.New("render queue is closed")
+
+// RenderFunc renders the revision of the article at articleURL with the given
+// ID, or its head revision if revisionID is 0. It should give up once ctx is
+// done, which happens when the render takes longer than the queue's timeout.
+type RenderFunc func(ctx context.Context, articleURL string, revisionID int) error
+
+// Result is delivered to everyone waiting on a job once it has run.
+type Result struct {
+	ArticleURL string
+	RevisionID int
+	Err        error
+}
+
+// Job is a pending render of a single revision. A RevisionID of 0 stands for
+// whichever revision is the article's head when the job runs.
+type Job struct {
+	ArticleURL  string
+	RevisionID  int
+	Tier        Tier
+	SubmittedAt time.Time
+
+	seq     uint64
+	index   int
+	waiters []chan Result
+}
+
+// recentJobs is how many finished jobs a Snapshot lists.
+const recentJobs = 20
+
+// JobInfo describes a job in a Snapshot.
+type JobInfo struct {
+	ArticleURL  string
+	RevisionID  int
+	Tier        Tier
+	SubmittedAt time.Time
+	// StartedAt and FinishedAt are zero until the job starts and finishes.
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Err        error
+}
+
+// Snapshot is a copy of a queue's state at one moment.
+type Snapshot struct {
+	// Pending counts the jobs waiting at each tier.
+	Pending map[Tier]int
+	// Waiting lists the pending jobs in the order they will run.
+	Waiting []JobInfo
+	// InFlight lists the jobs being rendered, longest running first.
+	InFlight []JobInfo
+	// Recent lists the last jobs to finish, most recent first.
+	Recent []JobInfo
+	// Workers is how many workers the queue has been sized to.
+	Workers int
+
+	Completed, Failed, Panicked int
+}
+
+// latencyWeight is how much each finished render moves the average latency.
+const latencyWeight = 0.1
+
+// QueueStats summarises a queue's throughput.
+type QueueStats struct {
+	// Pending counts the jobs waiting at each tier.
+	Pending map[Tier]int
+	// Processed counts the jobs that have run, and Errors those of them that
+	// failed or panicked.
+	Processed, Errors int
+	// AverageLatency is a moving average of how long renders take, weighted
+	// towards the most recent.
+	AverageLatency time.Duration
+}
+
+// Queue is a priority queue of render jobs drained by a pool of workers.
+type Queue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    jobHeap
+	pending map[jobKey]*Job
+	seq     uint64
+	closed  bool
+
+	// workers is how many workers there should be, and live how many there
+	// are. Workers beyond the first workers exit once they are idle.
+	workers, live int
+
+	running                     map[uint64]JobInfo
+	recent                      []JobInfo
+	completed, failed, panicked int
+	latency                     time.Duration
+
+	render  RenderFunc
+	observe func(JobInfo)
+	timeout func() time.Duration
+	maxWait func() time.Duration
+	wg      sync.WaitGroup
+}
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithObserver has observe called with each job once it has run, e.g. to
+// record how long renders take.
+func WithObserver(observe func(JobInfo)) Option {
+	return func(q *Queue) {
+		q.observe = observe
+	}
+}
+
+// WithTimeout gives each render the deadline returned by timeout when it
+// starts, so it may change while the queue runs. Zero means no deadline.
+func WithTimeout(timeout func() time.Duration) Option {
+	return func(q *Queue) {
+		q.timeout = timeout
+	}
+}
+
+// WithMaxWait promotes background jobs to TierInteractive once they have
+// waited longer than maxWait returns, so a steady stream of interactive jobs
+// can't starve them. Zero means they are never promoted.
+func WithMaxWait(maxWait func() time.Duration) Option {
+	return func(q *Queue) {
+		q.maxWait = maxWait
+	}
+}
+
+// New starts a queue with the given number of workers calling render.
+func New(workers int, render RenderFunc, opts ...Option) *Queue {
+	q := &Queue{
+		pending: make(map[jobKey]*Job),
+		running: make(map[uint64]JobInfo),
+		render:  render,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	q.Resize(workers)
+
+	return q
+}
+
+// Resize grows or shrinks the pool to n workers. Removed workers finish the
+// render they are on first. With no workers, jobs wait in the queue until it
+// is resized again.
+func (q *Queue) Resize(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.workers = n
+	for q.live < q.workers {
+		q.live++
+		q.wg.Add(1)
+		go q.work()
+	}
+	// Wake idle workers so any beyond the new size exit.
+	q.cond.Broadcast()
+}
+
+// jobKey identifies the revision a job renders.
+type jobKey struct {
+	url        string
+	revisionID int
+}
+
+// Submit queues a render of the head revision of articleURL at tier and
+// returns a channel that receives its result. If a render of the same
+// article's head is already waiting it is reused, and promoted if tier is more
+// urgent.
+func (q *Queue) Submit(articleURL string, tier Tier) <-chan Result {
+	return q.SubmitRevision(articleURL, 0, tier)
+}
+
+// SubmitRevision is like Submit, but renders the revision of articleURL with
+// the given ID, e.g. one saved while an earlier revision was still waiting.
+func (q *Queue) SubmitRevision(articleURL string, revisionID int, tier Tier) <-chan Result {
+	wait := make(chan Result, 1)
+	key := jobKey{articleURL, revisionID}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		wait <- Result{ArticleURL: articleURL, RevisionID: revisionID, Err: ErrQueueClosed}
+		return wait
+	}
+
+	if job, ok := q.pending[key]; ok {
+		job.waiters = append(job.waiters, wait)
+		if tier < job.Tier {
+			job.Tier = tier
+			heap.Fix(&q.jobs, job.index)
+		}
+		return wait
+	}
+
+	q.seq++
+	job := &Job{
+		ArticleURL:  articleURL,
+		RevisionID:  revisionID,
+		Tier:        tier,
+		SubmittedAt: time.Now(),
+		seq:         q.seq,
+		waiters:     []chan Result{wait},
+	}
+	heap.Push(&q.jobs, job)
+	q.pending[key] = job
+	q.cond.Signal()
+
+	return wait
+}
+
+// Cancel stops wait, a channel returned by Submit or SubmitRevision for
+// articleURL, from receiving a result, e.g. because the client waiting on it
+// has gone. If it was the job's last waiter and the job hasn't started, the
+// job is dropped. Cancel reports whether wait was still waiting on a pending
+// job.
+func (q *Queue) Cancel(articleURL string, wait <-chan Result) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, job := range q.pending {
+		if key.url != articleURL {
+			continue
+		}
+		for i, w := range job.waiters {
+			if w != wait {
+				continue
+			}
+			job.waiters = append(job.waiters[:i], job.waiters[i+1:]...)
+			if len(job.waiters) == 0 {
+				heap.Remove(&q.jobs, job.index)
+				delete(q.pending, key)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of jobs waiting at tier.
+func (q *Queue) Len(tier Tier) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, job := range q.jobs {
+		if job.Tier == tier {
+			n++
+		}
+	}
+	return n
+}
+
+// Snapshot returns a copy of the queue's pending, running and recently
+// finished jobs.
+func (q *Queue) Snapshot() Snapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := Snapshot{
+		Pending:   make(map[Tier]int),
+		Completed: q.completed,
+		Failed:    q.failed,
+		Panicked:  q.panicked,
+		Workers:   q.workers,
+	}
+
+	waiting := make(jobHeap, len(q.jobs))
+	copy(waiting, q.jobs)
+	sort.Slice(waiting, func(i, j int) bool { return waiting.Less(i, j) })
+	for _, job := range waiting {
+		s.Pending[job.Tier]++
+		s.Waiting = append(s.Waiting, job.info())
+	}
+
+	for _, info := range q.running {
+		s.InFlight = append(s.InFlight, info)
+	}
+	sort.Slice(s.InFlight, func(i, j int) bool { return s.InFlight[i].StartedAt.Before(s.InFlight[j].StartedAt) })
+
+	for i := len(q.recent) - 1; i >= 0; i-- {
+		s.Recent = append(s.Recent, q.recent[i])
+	}
+
+	return s
+}
+
+// Stats returns counts of the queue's pending and processed jobs and its
+// average render latency.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := QueueStats{
+		Pending:        make(map[Tier]int),
+		Processed:      q.completed + q.failed + q.panicked,
+		Errors:         q.failed + q.panicked,
+		AverageLatency: q.latency,
+	}
+	for _, job := range q.jobs {
+		s.Pending[job.Tier]++
+	}
+	return s
+}
+
+func (job *Job) key() jobKey {
+	return jobKey{job.ArticleURL, job.RevisionID}
+}
+
+func (job *Job) info() JobInfo {
+	return JobInfo{ArticleURL: job.ArticleURL, RevisionID: job.RevisionID, Tier: job.Tier, SubmittedAt: job.SubmittedAt}
+}
+
+// finish records the outcome of a job that has run. q.mu must be held.
+func (q *Queue) finish(job *Job, info JobInfo, panicked bool) {
+	delete(q.running, job.seq)
+
+	switch {
+	case panicked:
+		q.panicked++
+	case info.Err != nil:
+		q.failed++
+	default:
+		q.completed++
+	}
+
+	latency := info.FinishedAt.Sub(info.StartedAt)
+	if q.completed+q.failed+q.panicked == 1 {
+		q.latency = latency
+	} else {
+		q.latency += time.Duration(latencyWeight * float64(latency-q.latency))
+	}
+
+	q.recent = append(q.recent, info)
+	if len(q.recent) > recentJobs {
+		q.recent = q.recent[len(q.recent)-recentJobs:]
+	}
+}
+
+// Close stops accepting jobs and waits for the workers to drain the queue.
+// Jobs left because the queue has no workers fail with ErrQueueClosed.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.jobs) > 0 {
+		job := heap.Pop(&q.jobs).(*Job)
+		delete(q.pending, job.key())
+		for _, wait := range job.waiters {
+			wait <- Result{ArticleURL: job.ArticleURL, RevisionID: job.RevisionID, Err: ErrQueueClosed}
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 && !q.closed && q.live <= q.workers {
+			q.cond.Wait()
+		}
+		if len(q.jobs) == 0 || q.live > q.workers {
+			q.live--
+			q.mu.Unlock()
+			return
+		}
+		q.promote(time.Now())
+		job := heap.Pop(&q.jobs).(*Job)
+		delete(q.pending, job.key())
+		info := job.info()
+		info.StartedAt = time.Now()
+		q.running[job.seq] = info
+		q.mu.Unlock()
+
+		panicked, err := q.run(job)
+		result := Result{ArticleURL: job.ArticleURL, RevisionID: job.RevisionID, Err: err}
+		if result.Err != nil {
+			log.Printf("render of %s failed: %v", job.ArticleURL, result.Err)
+		}
+
+		info.FinishedAt, info.Err = time.Now(), err
+		q.mu.Lock()
+		q.finish(job, info, panicked)
+		q.mu.Unlock()
+
+		if q.observe != nil {
+			q.observe(info)
+		}
+
+		for _, wait := range job.waiters {
+			wait <- result
+		}
+	}
+}
+
+// promote moves the background jobs that have waited longer than the queue's
+// max wait up to TierInteractive. q.mu must be held.
+func (q *Queue) promote(now time.Time) {
+	if q.maxWait == nil {
+		return
+	}
+	maxWait := q.maxWait()
+	if maxWait <= 0 {
+		return
+	}
+
+	var overdue []*Job
+	for _, job := range q.jobs {
+		if job.Tier == TierBackground && now.Sub(job.SubmittedAt) > maxWait {
+			overdue = append(overdue, job)
+		}
+	}
+	for _, job := range overdue {
+		job.Tier = TierInteractive
+		heap.Fix(&q.jobs, job.index)
+	}
+}
+
+// run calls the render func, turning a panic into an error so one bad article
+// cannot take down a worker.
+func (q *Queue) run(job *Job) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, err = true, fmt.Errorf("render panicked: %v", r)
+		}
+	}()
+
+	ctx := context.Background()
+	if q.timeout != nil {
+		if timeout := q.timeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	return false, q.render(ctx, job.ArticleURL, job.RevisionID)
+}
+
+// jobHeap implements heap.Interface, ordering by tier and then submission.
+type jobHeap []*Job
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].Tier != h[j].Tier {
+		return h[i].Tier < h[j].Tier
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+	*h = old[:n-1]
+	return job
+}
@@ -0,0 +1,359 @@
+package renderqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockedQueue returns a single-worker queue whose worker is stuck rendering
+// "blocker" until the returned func is called.
+func blockedQueue(t *testing.T, render RenderFunc, opts ...Option) (*Queue, func()) {
+	t.Helper()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q := New(1, func(ctx context.Context, url string, revision int) error {
+		if url == "blocker" {
+			close(started)
+			<-release
+			return nil
+		}
+		return render(ctx, url, revision)
+	}, opts...)
+	q.Submit("blocker", TierInteractive)
+	<-started
+
+	return q, func() { close(release) }
+}
+
+func TestQueue_TierOrdering(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, url)
+		return nil
+	})
+
+	q.Submit("background-1", TierBackground)
+	q.Submit("interactive-1", TierInteractive)
+	q.Submit("background-2", TierBackground)
+	q.Submit("interactive-2", TierInteractive)
+
+	if n := q.Len(TierBackground); n != 2 {
+		t.Errorf("expected 2 background jobs, got %d", n)
+	}
+
+	release()
+	q.Close()
+
+	want := []string{"interactive-1", "interactive-2", "background-1", "background-2"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected render order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestQueue_SameArticleDeduplication(t *testing.T) {
+	renders := 0
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		renders++
+		return errors.New("boom")
+	})
+
+	first := q.Submit("Foo", TierBackground)
+	second := q.Submit("Foo", TierInteractive)
+
+	if n := q.Len(TierInteractive); n != 1 {
+		t.Errorf("expected the merged job to be promoted, got %d interactive jobs", n)
+	}
+
+	release()
+	q.Close()
+
+	for _, wait := range []<-chan Result{first, second} {
+		if result := <-wait; result.Err == nil || result.ArticleURL != "Foo" {
+			t.Errorf("expected both waiters to get the shared result, got %+v", result)
+		}
+	}
+	if renders != 1 {
+		t.Errorf("expected one render, got %d", renders)
+	}
+}
+
+func TestQueue_RevisionsAreSeparateJobs(t *testing.T) {
+	var mu sync.Mutex
+	var rendered []int
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rendered = append(rendered, revision)
+		return nil
+	})
+
+	q.SubmitRevision("Foo", 1, TierBackground)
+	q.SubmitRevision("Foo", 2, TierBackground)
+	q.SubmitRevision("Foo", 1, TierBackground)
+
+	if n := q.Len(TierBackground); n != 2 {
+		t.Errorf("expected one job per revision, got %d", n)
+	}
+
+	release()
+	q.Close()
+
+	if len(rendered) != 2 || rendered[0] != 1 || rendered[1] != 2 {
+		t.Errorf("expected revisions 1 and 2 to be rendered once each, got %v", rendered)
+	}
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	var rendered []string
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		rendered = append(rendered, url)
+		return nil
+	})
+
+	first := q.Submit("Foo", TierBackground)
+	second := q.Submit("Foo", TierBackground)
+	abandoned := q.Submit("Bar", TierBackground)
+
+	if !q.Cancel("Foo", first) {
+		t.Error("expected a pending waiter to be cancelled")
+	}
+	if q.Cancel("Foo", first) {
+		t.Error("expected a waiter to be cancelled only once")
+	}
+	if q.Cancel("Bar", second) {
+		t.Error("expected a waiter on another article not to be cancelled")
+	}
+	if n := q.Len(TierBackground); n != 2 {
+		t.Errorf("expected a job with waiters left to stay queued, got %d jobs", n)
+	}
+
+	if !q.Cancel("Bar", abandoned) {
+		t.Error("expected a pending waiter to be cancelled")
+	}
+	if n := q.Len(TierBackground); n != 1 {
+		t.Errorf("expected a job without waiters to be dropped, got %d jobs", n)
+	}
+
+	release()
+	q.Close()
+
+	if result := <-second; result.Err != nil || result.ArticleURL != "Foo" {
+		t.Errorf("expected the remaining waiter to get its result, got %+v", result)
+	}
+	select {
+	case result := <-first:
+		t.Errorf("expected a cancelled waiter to get no result, got %+v", result)
+	default:
+	}
+	if !reflect.DeepEqual(rendered, []string{"Foo"}) {
+		t.Errorf("expected only Foo to be rendered, got %v", rendered)
+	}
+}
+
+func TestQueue_MaxWait(t *testing.T) {
+	var order []string
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		order = append(order, url)
+		return nil
+	}, WithMaxWait(func() time.Duration { return 10 * time.Millisecond }))
+
+	q.Submit("Overdue", TierBackground)
+	time.Sleep(20 * time.Millisecond)
+	q.Submit("Edit", TierInteractive)
+
+	release()
+	q.Close()
+
+	want := []string{"Overdue", "Edit"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected an overdue background job to run first, got %v", order)
+	}
+}
+
+func TestQueue_Resize(t *testing.T) {
+	q := New(1, func(ctx context.Context, url string, revision int) error { return nil })
+
+	q.Resize(0)
+	first := q.Submit("Foo", TierInteractive)
+	second := q.Submit("Bar", TierBackground)
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case result := <-first:
+		t.Fatalf("expected no renders without workers, got %+v", result)
+	default:
+	}
+	if s := q.Snapshot(); s.Workers != 0 || len(s.Waiting) != 2 {
+		t.Errorf("expected 2 jobs waiting on 0 workers, got %d on %d", len(s.Waiting), s.Workers)
+	}
+
+	q.Resize(3)
+	for _, wait := range []<-chan Result{first, second} {
+		if result := <-wait; result.Err != nil {
+			t.Errorf("expected the waiting jobs to render once resized, got %+v", result)
+		}
+	}
+	if s := q.Snapshot(); s.Workers != 3 {
+		t.Errorf("expected 3 workers, got %d", s.Workers)
+	}
+
+	q.Resize(0)
+	left := q.Submit("Baz", TierInteractive)
+	q.Close()
+	if result := <-left; !errors.Is(result.Err, ErrQueueClosed) {
+		t.Errorf("expected a job left without workers to fail on close, got %+v", result)
+	}
+}
+
+func TestQueue_PanicBecomesError(t *testing.T) {
+	q := New(1, func(ctx context.Context, url string, revision int) error {
+		panic("bad article")
+	})
+	defer q.Close()
+
+	if result := <-q.Submit("Foo", TierInteractive); result.Err == nil {
+		t.Error("expected a panicking render to report an error")
+	}
+}
+
+func TestQueue_Snapshot(t *testing.T) {
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		if url == "Broken" {
+			return errors.New("boom")
+		}
+		if url == "Panics" {
+			panic("bad article")
+		}
+		return nil
+	})
+
+	q.Submit("Background_1", TierBackground)
+	q.Submit("Interactive_1", TierInteractive)
+	q.Submit("Background_2", TierBackground)
+	q.Submit("Interactive_2", TierInteractive)
+	q.Submit("Background_3", TierBackground)
+
+	s := q.Snapshot()
+	if s.Pending[TierInteractive] != 2 || s.Pending[TierBackground] != 3 {
+		t.Errorf("expected 2 interactive and 3 background jobs pending, got %v", s.Pending)
+	}
+	var waiting []string
+	for _, job := range s.Waiting {
+		waiting = append(waiting, job.ArticleURL)
+	}
+	want := []string{"Interactive_1", "Interactive_2", "Background_1", "Background_2", "Background_3"}
+	if strings.Join(waiting, " ") != strings.Join(want, " ") {
+		t.Errorf("expected jobs to be listed in the order they will run, got %v", waiting)
+	}
+	if len(s.InFlight) != 1 || s.InFlight[0].ArticleURL != "blocker" || s.InFlight[0].StartedAt.IsZero() {
+		t.Errorf("expected the blocker to be in flight, got %+v", s.InFlight)
+	}
+
+	broken := q.Submit("Broken", TierBackground)
+	panics := q.Submit("Panics", TierBackground)
+	release()
+	<-broken
+	<-panics
+
+	s = q.Snapshot()
+	if len(s.Waiting) != 0 || len(s.InFlight) != 0 {
+		t.Errorf("expected the queue to be drained, got %+v", s)
+	}
+	if s.Completed != 6 || s.Failed != 1 || s.Panicked != 1 {
+		t.Errorf("expected 6 completed, 1 failed and 1 panicked, got %d, %d and %d", s.Completed, s.Failed, s.Panicked)
+	}
+	if len(s.Recent) != 8 || s.Recent[0].ArticleURL != "Panics" || s.Recent[0].Err == nil {
+		t.Errorf("expected the most recent job first, got %+v", s.Recent)
+	}
+	q.Close()
+}
+
+func TestObserver(t *testing.T) {
+	observed := make(chan JobInfo, 1)
+	q := New(1, func(ctx context.Context, url string, revision int) error { return errors.New("boom") }, WithObserver(func(info JobInfo) {
+		observed <- info
+	}))
+	defer q.Close()
+
+	<-q.Submit("Cats", TierBackground)
+	info := <-observed
+	if info.ArticleURL != "Cats" || info.Tier != TierBackground || info.Err == nil {
+		t.Errorf("expected the failed background render of Cats, got %+v", info)
+	}
+	if info.StartedAt.IsZero() || info.FinishedAt.Before(info.StartedAt) {
+		t.Errorf("expected start and finish times, got %v and %v", info.StartedAt, info.FinishedAt)
+	}
+}
+
+func TestQueue_Stats(t *testing.T) {
+	q, release := blockedQueue(t, func(ctx context.Context, url string, revision int) error {
+		if url == "Broken" {
+			return errors.New("boom")
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	q.Submit("Interactive_1", TierInteractive)
+	q.Submit("Background_1", TierBackground)
+	q.Submit("Background_2", TierBackground)
+
+	s := q.Stats()
+	if s.Pending[TierInteractive] != 1 || s.Pending[TierBackground] != 2 {
+		t.Errorf("expected 1 interactive and 2 background jobs pending, got %v", s.Pending)
+	}
+	if s.Processed != 0 || s.AverageLatency != 0 {
+		t.Errorf("expected nothing processed yet, got %+v", s)
+	}
+
+	broken := q.Submit("Broken", TierBackground)
+	release()
+	<-broken
+	q.Close()
+
+	s = q.Stats()
+	if s.Pending[TierInteractive] != 0 || s.Pending[TierBackground] != 0 {
+		t.Errorf("expected the queue to be drained, got %v", s.Pending)
+	}
+	if s.Processed != 5 || s.Errors != 1 {
+		t.Errorf("expected 5 processed and 1 error, got %d and %d", s.Processed, s.Errors)
+	}
+	if s.AverageLatency <= 0 {
+		t.Errorf("expected a positive average latency, got %v", s.AverageLatency)
+	}
+}
+
+func TestQueue_Timeout(t *testing.T) {
+	timeout := 20 * time.Millisecond
+	q := New(1, func(ctx context.Context, url string, revision int) error {
+		if url == "slow" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return errors.New("expected no deadline")
+		}
+		return nil
+	}, WithTimeout(func() time.Duration { return timeout }))
+	defer q.Close()
+
+	if r := <-q.Submit("slow", TierInteractive); !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Errorf("expected the render to time out, got %v", r.Err)
+	}
+
+	timeout = 0
+	if r := <-q.Submit("fast", TierInteractive); r.Err != nil {
+		t.Errorf("expected no deadline once the timeout is 0, got %v", r.Err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestRerenderAll(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		a := newTestApp(t, func(c *wiki.Config) {
+			c.RenderWorkers = workers
+			c.RerenderOutdatedHTML = false
+		})
+		mustRegister(t, a, "admin")
+		mustRegister(t, a, "bob")
+		admin := login(t, a, "admin")
+
+		for _, url := range []string{"Apples", "Bananas", "Cherries"} {
+			mustPostArticle(t, a, url, "About *"+url+"*.", 0)
+		}
+		execSQL(t, a, `UPDATE Revision SET html = '<p>outdated</p>', render_fingerprint = 'outdated'`)
+
+		page := serve(a, newRequest(http.MethodGet, "/wiki/Special:RerenderAll", nil, admin)).Body.String()
+		if !strings.Contains(page, "Re-render all articles</button>") {
+			t.Errorf("expected a button to start a re-render, got:\n%s", page)
+		}
+
+		rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:RerenderAll", nil, admin))
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("expected 303 after starting a re-render, got %d", rr.Code)
+		}
+
+		job := a.CurrentRerender()
+		deadline := time.Now().Add(5 * time.Second)
+		for !job.Progress().Done() {
+			if time.Now().After(deadline) {
+				t.Fatal("re-render never finished")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if p := job.Progress(); p.Completed != 3 || p.Total != 3 || p.Failed != 0 {
+			t.Errorf("with %d workers: expected 3 of 3 articles rendered, got %+v", workers, p)
+		}
+
+		for _, url := range []string{"Apples", "Bananas", "Cherries"} {
+			article, err := a.GetArticle(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(article.HTML, "<em>"+url+"</em>") {
+				t.Errorf("with %d workers: expected %s to be rendered again, got %q", workers, url, article.HTML)
+			}
+		}
+
+		page = serve(a, newRequest(http.MethodGet, "/wiki/Special:RerenderAll", nil, admin)).Body.String()
+		if !strings.Contains(page, "3 of 3 articles rendered") || strings.Contains(page, `http-equiv="refresh"`) {
+			t.Errorf("expected the finished job's progress without polling, got:\n%s", page)
+		}
+
+		if rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:RerenderAll", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+		}
+	}
+}
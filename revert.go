@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+var errRevertCommentRequired = errors.New("a revert needs a comment explaining it")
+
+// revertHandler asks the user to confirm reverting an article to an earlier
+// revision, with a suggested comment they may edit.
+func (a *app) revertHandler(rw http.ResponseWriter, req *http.Request) {
+	target, ok := a.revertTarget(rw, req)
+	if !ok {
+		return
+	}
+	a.renderRevertConfirmation(rw, req, target, wiki.RevertSummary(target.ID), nil)
+}
+
+// revertPostHandler reverts an article to an earlier revision. Unless
+// ConfirmRevertComments is turned off, a revert that wasn't confirmed with a
+// comment is sent back to the confirmation step rather than saved.
+func (a *app) revertPostHandler(rw http.ResponseWriter, req *http.Request) {
+	target, ok := a.revertTarget(rw, req)
+	if !ok {
+		return
+	}
+
+	comment := strings.TrimSpace(req.PostFormValue("comment"))
+	if a.ConfirmRevertComments {
+		if req.PostFormValue("confirm") == "" {
+			if comment == "" {
+				comment = wiki.RevertSummary(target.ID)
+			}
+			a.renderRevertConfirmation(rw, req, target, comment, nil)
+			return
+		}
+		if comment == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			a.renderRevertConfirmation(rw, req, target, wiki.RevertSummary(target.ID), errRevertCommentRequired)
+			return
+		}
+	} else if comment == "" {
+		comment = wiki.RevertSummary(target.ID)
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	_, err := a.RevertArticle(target.URL, target.ID, user, comment)
+	switch err {
+	case nil:
+		http.Redirect(rw, req, "/wiki/"+target.URL, http.StatusSeeOther)
+	case wiki.ErrRevisionAlreadyExists:
+		a.errorHandler(http.StatusConflict, rw, req, err)
+	case wiki.ErrArticleProtected:
+		a.errorHandler(http.StatusForbidden, rw, req, err)
+	default:
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+	}
+}
+
+// revertTarget loads the revision a revert request names, responding with an
+// error if it doesn't exist or the user may not edit the article.
+func (a *app) revertTarget(rw http.ResponseWriter, req *http.Request) (*wiki.Article, bool) {
+	vars := mux.Vars(req)
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+
+	id, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return nil, false
+	}
+
+	target, err := a.GetArticleByRevisionID(vars["article"], id)
+	if err == nil && !canView(target, user) {
+		err = wiki.ErrRevisionNotFound
+	}
+	if err == wiki.ErrRevisionNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return nil, false
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return nil, false
+	}
+
+	if allowed, reason := a.EditPolicy().Evaluate(target, user); !allowed {
+		a.denyEdit(reason, rw, req)
+		return nil, false
+	}
+	return target, true
+}
+
+func (a *app) renderRevertConfirmation(rw http.ResponseWriter, req *http.Request, target *wiki.Article, comment string, err error) {
+	head, headErr := a.GetArticle(target.URL)
+	if headErr != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, headErr)
+		return
+	}
+
+	other := map[string]interface{}{
+		"Comment":    comment,
+		"DiffString": renderContextDiff(head.Markdown, target.Markdown, 3, fmt.Sprintf("/wiki/%s/diff/%d/%d", target.URL, head.ID, target.ID), false),
+	}
+	if err != nil {
+		other["Error"] = err.Error()
+	}
+
+	check(a.RenderTemplate(rw, "revert.html", "index.html", map[string]interface{}{
+		"Article": target,
+		"Context": req.Context(),
+		"Other":   other,
+	}))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestRevertRequiresConfirmation(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.ConfirmRevertComments = true })
+	first := mustPostArticle(t, a, "Cats", "Cats are nice.\n", 0)
+	mustPostArticle(t, a, "Cats", "Cats are vandalised.\n", first.ID)
+
+	// Without the confirmation step the revert is halted.
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1/revert", url.Values{}, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `value="Reverted to revision 1"`) {
+		t.Errorf("expected the confirmation step with a suggested comment, got %d:\n%s", rr.Code, rr.Body)
+	}
+	if head, _ := a.GetArticle("Cats"); head.ID != 2 {
+		t.Errorf("expected nothing to be saved before confirmation, got revision %d", head.ID)
+	}
+
+	// Clearing the comment isn't allowed.
+	rr = serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1/revert", url.Values{"confirm": {"1"}, "comment": {" "}}, nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), errRevertCommentRequired.Error()) {
+		t.Errorf("expected an empty comment to be refused, got %d", rr.Code)
+	}
+
+	form := url.Values{"confirm": {"1"}, "comment": {"Reverted vandalism"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1/revert", form, nil)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the confirmed revert to be saved, got %d", rr.Code)
+	}
+
+	head, err := a.GetArticle("Cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.ID != 3 || head.Markdown != first.Markdown || head.Comment != "Reverted vandalism" {
+		t.Errorf("expected revision 3 to restore revision 1 with the given comment, got %d %q %q", head.ID, head.Markdown, head.Comment)
+	}
+}
+
+func TestRevertWithoutConfirmation(t *testing.T) {
+	a := newTestApp(t)
+	first := mustPostArticle(t, a, "Cats", "Cats are nice.\n", 0)
+	mustPostArticle(t, a, "Cats", "Cats are vandalised.\n", first.ID)
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1/revert", url.Values{}, nil)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the revert to be saved straight away, got %d", rr.Code)
+	}
+
+	head, err := a.GetArticle("Cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Markdown != first.Markdown || head.Comment != wiki.RevertSummary(1) {
+		t.Errorf("expected revision 1 to be restored with the suggested comment, got %q %q", head.Markdown, head.Comment)
+	}
+
+	// Reverting to the current content changes nothing.
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/3/revert", url.Values{}, nil)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected reverting to the current revision to fail, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestStaleContent(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.StaleContentAge = 90 * 24 * time.Hour
+	})
+	fresh := time.Now().AddDate(0, 0, -7).Format(wiki.ReviewedDateLayout)
+
+	mustPostArticle(t, a, "Old_Guide", "---\nreviewed: 2020-03-01\n---\nOutdated steps.", 0)
+	mustPostArticle(t, a, "New_Guide", "---\nreviewed: "+fresh+"\n---\nCurrent steps.", 0)
+	mustPostArticle(t, a, "Unreviewed", "Never checked.", 0)
+
+	article, err := a.GetArticle("Old_Guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(article.HTML, "reviewed:") {
+		t.Errorf("expected frontmatter to be left out of the HTML, got %q", article.HTML)
+	}
+	if !article.ReviewedAt.Valid || article.ReviewedAt.Time.Year() != 2020 {
+		t.Errorf("expected the reviewed date to be stored, got %v", article.ReviewedAt)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:StaleContent", nil, nil))
+	page := rr.Body.String()
+	for _, url := range []string{"Old_Guide", "Unreviewed"} {
+		if !strings.Contains(page, `href="/wiki/`+url+`"`) {
+			t.Errorf("expected %s to be listed as stale", url)
+		}
+	}
+	if strings.Contains(page, `href="/wiki/New_Guide"`) {
+		t.Error("expected a recently reviewed article not to be listed")
+	}
+}
+
+func TestMarkReviewed(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.StaleContentAge = 90 * 24 * time.Hour
+	})
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Guide", "Steps.", 0)
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Guide?markreviewed", nil, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Guide?markreviewed", nil, login(t, a, "admin"))); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", rr.Code)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Guide", nil, nil))
+	if !strings.Contains(rr.Body.String(), "Last reviewed") {
+		t.Error("expected the article to show when it was reviewed")
+	}
+
+	stale, err := a.GetStaleArticles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expected no stale articles after review, got %v", stale)
+	}
+}
+
+func TestBadReviewedDate(t *testing.T) {
+	a := newTestApp(t)
+
+	article := wiki.NewArticle("Guide", "Guide", "---\nreviewed: soon\n---\nSteps.")
+	article.Creator = wiki.AnonymousUser()
+	if err := a.PostArticle(article); err != wiki.ErrBadReviewedDate {
+		t.Errorf("expected ErrBadReviewedDate, got %v", err)
+	}
+}
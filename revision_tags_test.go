@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestRevisionTags(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Tagged", "First.", 0)
+	mustPostArticle(t, a, "Tagged", "Second.", 1)
+	admin := login(t, a, "admin")
+
+	tag := func(cookie *http.Cookie, id, tag string) int {
+		return serve(a, newRequest(http.MethodPost, "/wiki/Tagged/r/"+id+"?tag", url.Values{"tag": {tag}}, cookie)).Code
+	}
+	if code := tag(login(t, a, "bob"), "1", "stable"); code != http.StatusForbidden {
+		t.Errorf("expected a user who isn't an editor to be forbidden, got %d", code)
+	}
+	if code := tag(admin, "1", "12"); code != http.StatusBadRequest {
+		t.Errorf("expected a tag that looks like an ID to be refused, got %d", code)
+	}
+	if code := tag(admin, "9", "stable"); code != http.StatusNotFound {
+		t.Errorf("expected tagging a missing revision to 404, got %d", code)
+	}
+	if code := tag(admin, "1", "Stable"); code != http.StatusSeeOther {
+		t.Fatalf("expected tagging to redirect to the history, got %d", code)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Tagged?revision=stable", nil, nil))
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/wiki/Tagged/r/1" {
+		t.Fatalf("expected a redirect to revision 1, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Tagged/r/stable", nil, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "First.") {
+		t.Errorf("expected the tagged revision, got %d:\n%s", rr.Code, rr.Body)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Tagged?revision=reviewed", nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown tag to 404, got %d", rr.Code)
+	}
+
+	// Tagging another revision moves the tag.
+	if err := a.TagRevision("Tagged", 2, "stable", mustUser(t, a, "admin")); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := a.ResolveRevision("Tagged", "stable"); err != nil || id != 2 {
+		t.Errorf("expected stable to name revision 2, got %d, %v", id, err)
+	}
+
+	history := serve(a, newRequest(http.MethodGet, "/wiki/Tagged/history", nil, nil)).Body.String()
+	if !strings.Contains(history, `<a class="pw-revision-tag" href="/wiki/Tagged?revision=stable">stable</a>`) {
+		t.Errorf("expected the tag in the history:\n%s", history)
+	}
+	if strings.Contains(history, "pw-tag-revision") {
+		t.Errorf("expected no tagging form for anonymous users:\n%s", history)
+	}
+}
+
+func mustUser(t *testing.T, a *app, screenname string) *wiki.User {
+	t.Helper()
+	user, err := a.GetUserByScreenName(screenname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// cspNonceKey is for context.Context.
+const cspNonceKey wiki.ContextKey = "periwiki.csp-nonce"
+
+// inlineStyles are the style attributes written by periwiki's templates and
+// diffs, which the default Content-Security-Policy allows by hash.
+var inlineStyles = func() []string {
+	styles := []string{
+		diffInsertStyle,
+		diffDeleteStyle,
+		"max-width: 16px;",
+		"max-width: 6em;",
+		"max-width: 12em; width: auto;",
+	}
+	for size := 1; size <= wiki.MaxEditorTabSize; size++ {
+		styles = append(styles, fmt.Sprintf("tab-size: %d;", size))
+	}
+	return styles
+}()
+
+// cspStyleHashes is the source list that the CSPStyleHashes placeholder
+// becomes.
+var cspStyleHashes = func() string {
+	sources := []string{"'unsafe-hashes'"}
+	for _, style := range inlineStyles {
+		sum := sha256.Sum256([]byte(style))
+		sources = append(sources, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+	return strings.Join(sources, " ")
+}()
+
+// SecurityHeadersMiddleware sends the configured Content-Security-Policy and
+// Referrer-Policy, along with X-Content-Type-Options and X-Frame-Options. Each
+// request gets a fresh nonce for its inline scripts, which templates read as
+// .CSPNonce.
+func (a *app) SecurityHeadersMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+		req = req.WithContext(context.WithValue(req.Context(), cspNonceKey, nonce))
+
+		settings := a.Settings()
+		header := rw.Header()
+		if settings.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", expandCSP(settings.ContentSecurityPolicy, nonce))
+		}
+		if settings.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", settings.ReferrerPolicy)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// expandCSP fills in the placeholders in policy.
+func expandCSP(policy, nonce string) string {
+	return strings.NewReplacer(
+		wiki.CSPNonce, "'nonce-"+nonce+"'",
+		wiki.CSPStyleHashes, cspStyleHashes,
+	).Replace(policy)
+}
+
+// cspNonce returns the nonce carried by ctx, if any.
+func cspNonce(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	nonce, _ := ctx.Value(cspNonceKey).(string)
+	return nonce
+}
+
+// withCSPDirective returns policy with directive in place of any directive of
+// the same name.
+func withCSPDirective(policy, directive string) string {
+	name := strings.Fields(directive)[0]
+	directives := []string{}
+	for _, d := range strings.Split(policy, ";") {
+		d = strings.TrimSpace(d)
+		if fields := strings.Fields(d); len(fields) > 0 && !strings.EqualFold(fields[0], name) {
+			directives = append(directives, d)
+		}
+	}
+	return strings.Join(append(directives, directive), "; ")
+}
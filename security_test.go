@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// cspDirective returns the directive called name in policy, or "" if it has
+// none.
+func cspDirective(policy, name string) string {
+	for _, directive := range strings.Split(policy, ";") {
+		directive = strings.TrimSpace(directive)
+		if strings.HasPrefix(directive, name+" ") || directive == name {
+			return directive
+		}
+	}
+	return ""
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil))
+	for header, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "SAMEORIGIN",
+		"Referrer-Policy":        wiki.DefaultReferrerPolicy,
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("expected %s %q, got %q", header, want, got)
+		}
+	}
+	csp := rr.Header().Get("Content-Security-Policy")
+	if !strings.Contains(cspDirective(csp, "script-src"), "'nonce-") {
+		t.Errorf("expected a script nonce, got %q", csp)
+	}
+	if strings.Contains(csp, wiki.CSPNonce) || strings.Contains(csp, wiki.CSPStyleHashes) {
+		t.Errorf("expected the placeholders to be filled in, got %q", csp)
+	}
+
+	other := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Header().Get("Content-Security-Policy")
+	if cspDirective(csp, "script-src") == cspDirective(other, "script-src") {
+		t.Error("expected a new nonce for each request")
+	}
+}
+
+// TestSecurityHeadersInlineCode checks that the default policy allows every
+// inline script and style attribute periwiki writes.
+func TestSecurityHeadersInlineCode(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.EditorTabSize = 4
+	})
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+	first := mustPostArticle(t, a, "Cats", "Cats are nice.", 0).ID
+	second := mustPostArticle(t, a, "Cats", "Cats are very nice.", first).ID
+
+	scriptRegexp := regexp.MustCompile(`<script( nonce="([^"]*)")?>`)
+	styleRegexp := regexp.MustCompile(`style="([^"]*)"`)
+	handlerRegexp := regexp.MustCompile(`\son[a-z]+=`)
+	scripts, styles := 0, 0
+	for _, target := range []string{
+		"/wiki/Cats",
+		"/wiki/Cats/r/" + strconv.Itoa(second) + "/edit",
+		"/wiki/Cats/diff/" + strconv.Itoa(first) + "/" + strconv.Itoa(second),
+		"/manage/settings",
+	} {
+		rr := serve(a, newRequest(http.MethodGet, target, nil, admin))
+		csp := rr.Header().Get("Content-Security-Policy")
+		page := rr.Body.String()
+
+		if handler := handlerRegexp.FindString(page); handler != "" {
+			t.Errorf("%s: expected no inline event handlers, found %q", target, handler)
+		}
+		for _, script := range scriptRegexp.FindAllStringSubmatch(page, -1) {
+			scripts++
+			if script[2] == "" || !strings.Contains(cspDirective(csp, "script-src"), "'nonce-"+script[2]+"'") {
+				t.Errorf("%s: expected %s to carry the policy's nonce", target, script[0])
+			}
+		}
+		for _, style := range styleRegexp.FindAllStringSubmatch(page, -1) {
+			styles++
+			found := false
+			for _, allowed := range inlineStyles {
+				found = found || allowed == style[1]
+			}
+			if !found {
+				t.Errorf("%s: expected the style %q to be allowed by hash", target, style[1])
+			}
+		}
+	}
+	if scripts == 0 || styles == 0 {
+		t.Errorf("expected inline scripts and styles to check, found %d and %d", scripts, styles)
+	}
+}
+
+func TestSecurityHeadersSettings(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+
+	form := url.Values{
+		"site_name":               {"periwiki"},
+		"content_security_policy": {"default-src 'self';\n  script-src 'self' {nonce}"},
+		"referrer_policy":         {"no-referrer"},
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+	rr := serve(a, newRequest(http.MethodGet, "/", nil, nil))
+	if csp := rr.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'self'; script-src 'self' 'nonce-") {
+		t.Errorf("expected the configured policy, got %q", csp)
+	}
+	if got := rr.Header().Get("Referrer-Policy"); got != "no-referrer" {
+		t.Errorf("expected the configured Referrer-Policy, got %q", got)
+	}
+
+	form.Set("referrer_policy", "everyone")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown Referrer-Policy, got %d", rr.Code)
+	}
+
+	form.Set("referrer_policy", "")
+	form.Set("content_security_policy", "")
+	serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin))
+	rr = serve(a, newRequest(http.MethodGet, "/", nil, nil))
+	if csp, ok := rr.Header()["Content-Security-Policy"]; ok {
+		t.Errorf("expected no policy once it is cleared, got %q", csp)
+	}
+	if _, ok := rr.Header()["Referrer-Policy"]; ok {
+		t.Error("expected no Referrer-Policy once it is cleared")
+	}
+}
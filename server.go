@@ -1,52 +1,181 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/danielledeleo/periwiki/templater"
 	"github.com/danielledeleo/periwiki/wiki"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-
-	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/gorilla/sessions"
 )
 
 type app struct {
 	*templater.Templater
 	*wiki.WikiModel
+	idempotency *idempotencyKeys
+	// loginAttempts counts login and registration attempts by address.
+	loginAttempts *rateLimiter
+	diffs         *diffCache
+	// csrf holds the sessions carrying CSRF tokens.
+	csrf sessions.Store
+	// httpMetrics is nil unless EnableMetrics is set.
+	httpMetrics *httpMetrics
+}
+
+// startTime is used as the modification time of content without its own.
+var startTime = time.Now()
+
+// RenderTemplate renders a page, making the site settings available to
+// templates as .Site, the site code pages' URLs as .SiteCSS and .SiteJS, the
+// site notice, unless dismissed, as .SiteNotice, the flash message as .Flash,
+// the stylesheet for highlighted code as .HighlightCSS, the user's theme and
+// its stylesheet as .Theme and .ThemeCSS and, for article pages, what the
+// user may do with the article's source as .EditAction.
+func (a *app) RenderTemplate(w io.Writer, name string, base string, data map[string]interface{}) error {
+	settings := a.Settings()
+	data["Site"] = settings
+	ctx, _ := data["Context"].(context.Context)
+	data["SiteNotice"] = a.siteNoticeHTML(ctx, settings)
+	data["Flash"] = flashMessage(ctx)
+	data["CSPNonce"] = cspNonce(ctx)
+	data["SiteCSS"] = a.siteCodeURL("common.css")
+	data["SiteJS"] = a.siteCodeURL("common.js")
+	data["Theme"] = a.theme(ctx)
+	data["ThemeCSS"] = themeStylesheet(data["Theme"].(string))
+	if a.SyntaxHighlighting != "" {
+		data["HighlightCSS"] = "/highlight.css?style=" + a.SyntaxHighlighting
+	}
+	if article, ok := data["Article"].(*wiki.Article); ok && data["Context"] != nil {
+		user := data["Context"].(context.Context).Value(wiki.UserKey).(*wiki.User)
+		data["EditAction"] = a.EditPolicy().EditAction(article, user)
+	}
+	return a.Templater.RenderTemplate(w, name, base, data)
 }
 
 func main() {
 	app := Setup()
 
+	router := newRouter(app)
+
+	logger := handlers.LoggingHandler(os.Stdout, router)
+
+	server := &http.Server{Addr: app.Config.Host, Handler: logger}
+
+	// On SIGINT or SIGTERM, stop accepting requests, let those in flight
+	// finish, then stop the background workers.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	log.Println("Listening on", "http://"+app.Config.Host)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	<-shutdown
+	app.Close()
+}
+
+// newRouter registers every route served by a.
+func newRouter(a *app) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
-	router.Use(app.SessionMiddleware)
+	if a.httpMetrics != nil {
+		router.Use(a.MetricsMiddleware)
+	}
+	router.Use(a.SecurityHeadersMiddleware)
+	router.Use(a.ProxyMiddleware)
+	router.Use(a.SessionMiddleware)
+	router.Use(a.RateLimitMiddleware)
+	router.Use(a.SiteNoticeMiddleware)
+	router.Use(a.FlashMiddleware)
+	router.Use(a.CSRFMiddleware)
+	if a.NormalizeArticleURLs {
+		router.Use(a.ArticleURLMiddleware)
+	}
 
 	fs := http.FileServer(http.Dir("./static"))
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
-	router.HandleFunc("/", app.homeHandler).Methods("GET")
-
-	router.HandleFunc("/wiki/{article}", app.articleHandler).Methods("GET")
-	router.HandleFunc("/wiki/{article}/history", app.articleHistoryHandler).Methods("GET")
-	router.HandleFunc("/wiki/{article}/r/{revision}", app.revisionHandler).Methods("GET")
-	router.HandleFunc("/wiki/{article}/r/{revision}", app.revisionPostHandler).Methods("POST")
-	router.HandleFunc("/wiki/{article}/r/{revision}/edit", app.revisionEditHandler).Methods("GET")
-	router.HandleFunc("/wiki/{article}/diff/{original}/{new}", app.diffHandler).Methods("GET")
-
-	router.HandleFunc("/user/register", app.registerHandler).Methods("GET")
-	router.HandleFunc("/user/register", app.registerPostHandler).Methods("POST")
-	router.HandleFunc("/user/login", app.loginHander).Methods("GET")
-	router.HandleFunc("/user/login", app.loginPostHander).Methods("POST")
-	router.HandleFunc("/user/logout", app.logoutPostHander).Methods("POST")
+	router.HandleFunc("/", a.homeHandler).Methods("GET")
+
+	router.HandleFunc("/wiki/Special:Category/{category}", a.categoryHandler).Methods("GET")
+	router.HandleFunc("/wiki/Special:{page}", a.specialPageHandler).Methods("GET", "POST")
+	router.HandleFunc("/wiki/{article}", a.diffSinceHandler).Methods("GET").Queries("diff", "")
+	router.HandleFunc("/wiki/{article}", a.moveHandler).Methods("GET").Queries("move", "")
+	router.HandleFunc("/wiki/{article}", a.articleFeedHandler).Methods("GET").Queries("feed", "")
+	router.HandleFunc("/wiki/{article}", a.revisionQueryHandler).Methods("GET").Queries("revision", "")
+	router.HandleFunc("/wiki/{article}", a.articleHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}", a.adminOnly(a.markReviewedHandler)).Methods("POST").Queries("markreviewed", "")
+	router.HandleFunc("/wiki/{article}", a.editorOnly(a.deleteArticleHandler)).Methods("POST").Queries("delete", "")
+	router.HandleFunc("/wiki/{article}", a.adminOnly(a.protectHandler)).Methods("POST").Queries("protect", "")
+	router.HandleFunc("/wiki/{article}", a.adminOnly(a.shareHandler)).Methods("POST").Queries("share", "")
+	router.HandleFunc("/wiki/{article}", a.movePostHandler).Methods("POST").Queries("move", "")
+	router.HandleFunc("/wiki/{article}", a.watchHandler).Methods("POST").Queries("watch", "")
+	router.HandleFunc("/wiki/{article}", a.watchHandler).Methods("POST").Queries("unwatch", "")
+	router.HandleFunc("/wiki/{article}/history", a.articleHistoryHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}/r/{revision}", a.revisionHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}/r/{revision}", a.editorOnly(a.tagRevisionHandler)).Methods("POST").Queries("tag", "")
+	router.HandleFunc("/wiki/{article}/r/{revision}", a.revisionPostHandler).Methods("POST")
+	router.HandleFunc("/wiki/{article}/r/{revision}/edit", a.revisionEditHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}/r/{revision}/source", a.revisionSourceHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}/r/{revision}/revert", a.revertHandler).Methods("GET")
+	router.HandleFunc("/wiki/{article}/r/{revision}/revert", a.revertPostHandler).Methods("POST")
+	router.HandleFunc("/wiki/{article}/diff/{original}/{new}", a.diffHandler).Methods("GET")
+
+	router.HandleFunc("/user/register", a.registerHandler).Methods("GET")
+	router.HandleFunc("/user/register", a.registerPostHandler).Methods("POST")
+	router.HandleFunc("/user/login", a.loginHander).Methods("GET")
+	router.HandleFunc("/user/login", a.loginPostHander).Methods("POST")
+	router.HandleFunc("/user/logout", a.logoutPostHander).Methods("POST")
+	router.HandleFunc("/user/verify", a.resendVerificationHandler).Methods("POST")
+	router.HandleFunc("/user/verify/{token}", a.verifyEmailHandler).Methods("GET")
+	router.HandleFunc("/user/settings", a.userSettingsHandler).Methods("GET")
+	router.HandleFunc("/user/settings", a.userSettingsPostHandler).Methods("POST")
+	router.Handle("/user/preferences", http.RedirectHandler("/user/settings", http.StatusMovedPermanently)).Methods("GET")
+	router.HandleFunc("/notice/dismiss", a.dismissNoticeHandler).Methods("POST")
+
+	router.HandleFunc("/api/v1/preview", a.previewAPIHandler).Methods("POST")
+	router.HandleFunc("/embed/{article}", a.embedHandler).Methods("GET")
+	router.HandleFunc("/sitemap.xml", a.sitemapHandler).Methods("GET")
+	router.HandleFunc("/highlight.css", a.highlightCSSHandler).Methods("GET")
+	router.HandleFunc("/asset/{name}", a.assetHandler).Methods("GET")
+	router.HandleFunc("/site/{file}", a.siteCodeHandler).Methods("GET")
+	if registry := a.Metrics(); registry != nil {
+		router.Handle("/metrics", registry).Methods("GET")
+	}
+	router.HandleFunc("/manage/settings", a.adminOnly(a.manageSettingsHandler)).Methods("GET")
+	router.HandleFunc("/manage/settings", a.adminOnly(a.manageSettingsPostHandler)).Methods("POST")
+	router.HandleFunc("/manage/queue", a.adminOnly(a.manageQueueHandler)).Methods("GET")
+	router.HandleFunc("/manage/import", a.adminOnly(a.importHandler)).Methods("GET")
+	router.HandleFunc("/manage/import", a.adminOnly(a.importPostHandler)).Methods("POST")
+	router.HandleFunc("/manage/import/mediawiki", a.adminOnly(a.importMediaWikiPostHandler)).Methods("POST")
+	router.HandleFunc("/manage/export", a.adminOnly(a.exportHandler)).Methods("GET")
+	router.HandleFunc("/manage/tools", a.adminOnly(a.manageToolsHandler)).Methods("GET")
+	router.HandleFunc("/manage/users", a.adminOnly(a.manageUsersHandler)).Methods("GET")
+	router.HandleFunc("/manage/users", a.adminOnly(a.manageUserRoleHandler)).Methods("POST")
 
 	manageRouter := mux.NewRouter().PathPrefix("/manage").Subrouter()
 	manageRouter.HandleFunc("/{page}", func(rw http.ResponseWriter, req *http.Request) {
@@ -55,14 +184,7 @@ func main() {
 	})
 	router.Handle("/manage/{page}", manageRouter)
 
-	logger := handlers.LoggingHandler(os.Stdout, router)
-
-	log.Println("Listening on", "http://"+app.Config.Host)
-	err := http.ListenAndServe(app.Config.Host, logger)
-
-	if err != nil {
-		log.Fatal(err)
-	}
+	return router
 }
 
 func (a *app) registerHandler(rw http.ResponseWriter, req *http.Request) {
@@ -90,7 +212,11 @@ func (a *app) registerPostHandler(rw http.ResponseWriter, req *http.Request) {
 
 	// fill form with previously submitted values and display registration errors
 	err := a.PostUser(user)
-	if err != nil {
+	if err == nil && a.RequiresVerification(user) && a.MailsVerification() {
+		render["calloutMessage"] = "Successfully registered! Follow the link sent to your email address to verify it, then log in."
+	} else if err == nil && a.RequiresVerification(user) {
+		render["calloutMessage"] = "Successfully registered! Ask an administrator for the link that verifies your email address, then log in."
+	} else if err != nil {
 		render["calloutMessage"] = err.Error()
 		render["calloutClasses"] = "pw-error"
 		render["formClasses"] = ""
@@ -122,36 +248,56 @@ func (a *app) loginPostHander(rw http.ResponseWriter, req *http.Request) {
 
 	err := a.CheckUserPassword(user)
 
-	render := map[string]interface{}{
-		"Title":          "Login",
-		"calloutClasses": "pw-success",
-		"calloutMessage": "Successfully logged in!",
-		"formClasses":    "hidden",
-		"Context":        req.Context(),
-	}
-
 	if err != nil {
-		render["calloutMessage"] = err.Error()
-		render["calloutClasses"] = "pw-error"
-		render["formClasses"] = ""
-		render["screennameValue"] = user.ScreenName
-		err = a.RenderTemplate(rw, "login.html", "index.html", map[string]interface{}{"Article": render})
+		err = a.RenderTemplate(rw, "login.html", "index.html", map[string]interface{}{
+			"Article":            map[string]string{"Title": "Login"},
+			"calloutClasses":     "pw-error",
+			"formClasses":        "",
+			"calloutMessage":     err.Error(),
+			"screennameValue":    user.ScreenName,
+			"resendVerification": err == wiki.ErrEmailNotVerified,
+			"Context":            req.Context(),
+		})
 		check(err)
 		return
 	}
 
+	// A cookie that can't be decoded, say because the cookie secret changed,
+	// still comes with a new session to log in with.
 	session, err := a.GetCookie(req, "periwiki-login")
-	if err != nil {
+	if session == nil {
 		a.errorHandler(http.StatusInternalServerError, rw, req, err)
 		return
 	}
-	session.Options.MaxAge = a.CookieExpiry
+	check(err)
+
+	// Without "remember me" the cookie lasts until the browser is closed,
+	// though the session itself still expires after CookieExpiry.
+	expiry := a.CookieExpiryDuration()
+	session.Options.MaxAge = 0
+	if req.PostFormValue("remember") != "" {
+		if remember := a.Settings().RememberMeExpiry; remember > expiry {
+			expiry = remember
+		}
+		session.Options.MaxAge = int(expiry / time.Second)
+	}
+	session.Options.Secure = isHTTPS(req)
+	session.Values["expires_on"] = time.Now().Add(expiry)
 	session.Values["username"] = user.ScreenName
 	err = session.Save(req, rw)
 	if err != nil {
 		a.errorHandler(http.StatusInternalServerError, rw, req, err)
 		return
 	}
+	a.loginAttempts.reset(clientIP(req))
+
+	err = a.RecordSession(&wiki.SessionInfo{
+		ID:        session.ID,
+		IPAddress: clientIP(req),
+		UserAgent: req.UserAgent(),
+		Created:   time.Now(),
+	}, user)
+	check(err)
 
 	if referrer == "" {
 		referrer = "/"
@@ -201,10 +347,44 @@ func (a *app) articleHandler(rw http.ResponseWriter, req *http.Request) {
 	}
 	user := req.Context().Value(wiki.UserKey).(*wiki.User)
 
-	found := article != nil
+	found := article != nil && canView(article, user)
+	// A share link shows a pending article to anyone holding it. Anything
+	// wrong with the token just leaves the article hidden.
+	shared := !found && article != nil && req.Method == http.MethodGet && a.ValidShareToken(article.URL, req.URL.Query().Get("share"))
+	found = found || shared
+	redirectedFrom := req.URL.Query().Get("redirected_from")
+	if found && req.Method == http.MethodGet {
+		// Redirects are followed one hop at most, so loops end at the second
+		// page.
+		target := article.RedirectTarget()
+		if target != "" && req.URL.Query().Get("redirect") != "no" && redirectedFrom == "" && target != article.URL {
+			http.Redirect(rw, req, redirectLocation(article.URL, target), http.StatusFound)
+			return
+		}
+		if shared {
+			rw.Header().Set("Cache-Control", "no-store")
+		} else {
+			rw.Header().Set("Cache-Control", cacheControl(article, user))
+		}
+	}
+
+	if a.ContentNegotiation && req.Method == http.MethodGet {
+		rw.Header().Add("Vary", "Accept")
+		if mediaType := negotiate(req.Header.Get("Accept"), mediaTypeHTML, mediaTypeMarkdown, mediaTypeJSON); mediaType != mediaTypeHTML {
+			var contributors []*wiki.Contributor
+			if !found {
+				article = nil
+			} else if contributors, err = a.GetContributors(article.URL); err != nil {
+				a.errorHandler(http.StatusInternalServerError, rw, req, err)
+				return
+			}
+			writeArticleAs(rw, mediaType, article, contributors)
+			return
+		}
+	}
 
 	if !found {
-		article = wiki.NewArticle(vars["article"], cases.Title(language.AmericanEnglish).String(vars["article"]), "")
+		article = wiki.NewArticle(vars["article"], a.DisplayTitle(vars["article"]), "")
 		article.Hash = "new"
 		check(err)
 	}
@@ -223,47 +403,338 @@ func (a *app) articleHandler(rw http.ResponseWriter, req *http.Request) {
 	render["Context"] = req.Context()
 
 	if !found {
+		suggestions, err := a.SuggestSimilarTitles(article.URL, a.NotFoundSuggestions)
+		check(err)
+		render["Suggestions"] = suggestions
+		render["Deleted"], err = a.ArticleDeleted(article.URL)
+		check(err)
+
 		rw.WriteHeader(http.StatusNotFound)
 		err = a.RenderTemplate(rw, "article_notfound.html", "index.html", render)
 		check(err)
 		return
 	}
 
+	if found && article.RenderPending() {
+		render["Refresh"] = 2 // seconds, until the background render lands
+	}
+	if redirectedFrom != "" && a.redirectsTo(redirectedFrom, article.URL) {
+		render["RedirectedFrom"] = redirectedFrom
+	}
+
+	contributors, err := a.GetContributors(article.URL)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	render["Attribution"], err = a.Attribute(article, contributors)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	render["Protection"] = a.GetProtection(article.URL)
+	render["ProtectionLevels"] = wiki.ProtectionLevels
+
+	render["Watching"], err = a.IsWatching(user.ID, article.URL)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	render["Categories"], err = a.GetArticleCategories(article.URL)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	if a.ShowReadingTime {
+		if words := wiki.CountWords(article.Markdown); words > 0 {
+			render["WordCount"] = words
+			render["ReadingMinutes"] = wiki.ReadingMinutes(words, a.ReadingWordsPerMinute)
+		}
+	}
+
+	if a.ShowWantedLinksOnArticles {
+		render["WantedLinks"], err = a.GetOutboundDeadlinks(article.URL)
+		if err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+	}
+
+	if _, ok := req.URL.Query()["print"]; ok {
+		render["PermanentURL"] = absoluteURL(req, "/wiki/"+article.URL)
+		err = a.RenderTemplate(rw, "article.html", "print.html", render)
+		check(err)
+		return
+	}
+
 	err = a.RenderTemplate(rw, "article.html", "index.html", render)
 	check(err)
 }
 
+// redirectLocation is where the redirect page at from sends readers to reach
+// target, which may name a section. The target is told where they came from.
+func redirectLocation(from, target string) string {
+	target, section, _ := strings.Cut(target, "#")
+	location := "/wiki/" + target + "?redirected_from=" + url.QueryEscape(from)
+	if section != "" {
+		location += "#" + section
+	}
+	return location
+}
+
+// redirectsTo reports whether the article at from is a redirect to the
+// article at target, so a "redirected from" notice can't be made up.
+func (a *app) redirectsTo(from, target string) bool {
+	article, err := a.GetArticle(from)
+	if err != nil {
+		return false
+	}
+	redirect, _, _ := strings.Cut(article.RedirectTarget(), "#")
+	return redirect == target
+}
+
+func (a *app) markReviewedHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+
+	err := a.MarkArticleReviewed(url)
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	http.Redirect(rw, req, "/wiki/"+url, http.StatusSeeOther)
+}
+
+// watchHandler adds the article to the user's watchlist, or with ?unwatch
+// removes it.
+func (a *app) watchHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var err error
+	if _, ok := req.URL.Query()["unwatch"]; ok {
+		err = a.Unwatch(user.ID, url)
+	} else {
+		err = a.Watch(user.ID, url)
+	}
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	http.Redirect(rw, req, "/wiki/"+url, http.StatusSeeOther)
+}
+
+// deleteArticleHandler moves the article to the recycle bin.
+func (a *app) deleteArticleHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+
+	// Deleting a page takes the same rights as editing it.
+	article, err := a.GetArticle(url)
+	if err == nil && !canView(article, user) {
+		err = wiki.ErrGenericNotFound
+	}
+	if err == nil {
+		if allowed, reason := a.EditPolicy().Evaluate(article, user); !allowed {
+			a.denyEdit(reason, rw, req)
+			return
+		}
+		err = a.SoftDeleteArticle(url)
+	}
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	setFlash(rw, req, "Deleted "+url+". It can be restored from the recycle bin.")
+	http.Redirect(rw, req, "/", http.StatusSeeOther)
+}
+
+// protectHandler sets the article's protection level to the level form value.
+func (a *app) protectHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+	level := wiki.ProtectionLevel(req.PostFormValue("level"))
+
+	err := a.SetProtection(url, level)
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err == wiki.ErrBadProtectionLevel {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	setFlash(rw, req, level.Description())
+	http.Redirect(rw, req, "/wiki/"+url, http.StatusSeeOther)
+}
+
+// shareHandler makes a link that shows the article to anyone holding it for
+// the duration in the ttl form value, and flashes it.
+func (a *app) shareHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+	ttl, err := time.ParseDuration(strings.TrimSpace(req.PostFormValue("ttl")))
+	if err != nil || ttl <= 0 {
+		a.errorHandler(http.StatusBadRequest, rw, req, errors.New("the link must last for a duration such as 24h"))
+		return
+	}
+
+	article, err := a.GetArticle(url)
+	if err == wiki.ErrGenericNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	link := absoluteURL(req, "/wiki/"+article.URL) + "?share=" + a.GenerateShareToken(article.URL, ttl)
+	setFlash(rw, req, fmt.Sprintf("Anyone with this link can view %s until %s: %s", article.Title, time.Now().Add(ttl).Format("January 2, 2006 at 3:04 pm"), link))
+	http.Redirect(rw, req, "/wiki/"+article.URL, http.StatusSeeOther)
+}
+
+// cacheControl returns the Cache-Control header for article as seen by user,
+// from its frontmatter.
+func cacheControl(article *wiki.Article, user *wiki.User) string {
+	frontmatter, _ := wiki.ParseFrontmatter(article.Markdown)
+	return cacheControlFor(frontmatter.CacheControl(), user)
+}
+
+// cacheControlFor returns header as it applies to a page shown to user. Pages
+// shown to a logged-in user carry their name, so only their browser may cache
+// them.
+func cacheControlFor(header string, user *wiki.User) string {
+	if !user.IsAnonymous() {
+		header = strings.Replace(header, "public", "private", 1)
+	}
+	return header
+}
+
+// absoluteURL returns path as an absolute URL on the host req was made to.
+func absoluteURL(req *http.Request, path string) string {
+	scheme := "http"
+	if isHTTPS(req) {
+		scheme = "https"
+	}
+	return (&url.URL{Scheme: scheme, Host: req.Host, Path: path}).String()
+}
+
 func (a *app) articleHistoryHandler(rw http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	url := vars["article"]
 
-	revisions, err := a.GetRevisionHistory(url)
+	if !a.visible(url, req) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrGenericNotFound)
+		return
+	}
+
+	history, err := a.GetRevisionHistory(url)
 	if err != nil {
 		a.errorHandler(http.StatusNotFound, rw, req, err)
 		return
 	}
 
+	revisions := make([]*wiki.Article, len(history))
+	for i, revision := range history {
+		revisions[i] = &wiki.Article{URL: url, Revision: revision}
+	}
+	tags, err := a.GetRevisionTags(url)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
 	err = a.RenderTemplate(rw, "article_history.html", "index.html", map[string]interface{}{
 		"Article": map[string]interface{}{
 			"URL":   url,
 			"Title": "History of " + url},
 		"Context":   req.Context(),
-		"Revisions": revisions})
+		"Revisions": revisions,
+		"Tags":      tags})
 	check(err)
 }
 
-func (a *app) revisionHandler(rw http.ResponseWriter, req *http.Request) {
+// revisionQueryHandler redirects /wiki/{article}?revision=ref to the revision
+// that ref, an ID or a tag, addresses. Tags move, so the redirect is
+// temporary.
+func (a *app) revisionQueryHandler(rw http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["article"]
+	revisionID, err := a.ResolveRevision(url, req.URL.Query().Get("revision"))
+	if err == wiki.ErrRevisionNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	http.Redirect(rw, req, fmt.Sprintf("/wiki/%s/r/%d", url, revisionID), http.StatusFound)
+}
+
+// tagRevisionHandler tags the revision with the tag form value.
+func (a *app) tagRevisionHandler(rw http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	revisionID, err := strconv.Atoi(vars["revision"])
 	if err != nil {
 		a.errorHandler(http.StatusBadRequest, rw, req, err)
 		return
 	}
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	tag := req.PostFormValue("tag")
+
+	err = a.TagRevision(vars["article"], revisionID, tag, user)
+	if err == wiki.ErrRevisionNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err == wiki.ErrBadRevisionTag {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	} else if err == wiki.ErrTagNotAllowed {
+		a.errorHandler(http.StatusForbidden, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	setFlash(rw, req, fmt.Sprintf("Tagged revision %d as %s.", revisionID, strings.ToLower(strings.TrimSpace(tag))))
+	http.Redirect(rw, req, "/wiki/"+vars["article"]+"/history", http.StatusSeeOther)
+}
+
+func (a *app) revisionHandler(rw http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	revisionID, err := a.ResolveRevision(vars["article"], vars["revision"])
+	if err == wiki.ErrRevisionNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
 	article, err := a.GetArticleByRevisionID(vars["article"], revisionID)
 	if err != nil {
 		a.errorHandler(http.StatusNotFound, rw, req, err)
 		return
 	}
+	if !canView(article, req.Context().Value(wiki.UserKey).(*wiki.User)) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrRevisionNotFound)
+		return
+	}
 	err = a.RenderTemplate(rw, "article.html", "index.html", map[string]interface{}{
 		"Article": article,
 		"Context": req.Context(),
@@ -279,16 +750,35 @@ func (a *app) revisionEditHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 	article, err := a.GetArticleByRevisionID(vars["article"], revisionID)
+	if err == nil && !canView(article, req.Context().Value(wiki.UserKey).(*wiki.User)) {
+		err = wiki.ErrRevisionNotFound
+	}
 	if err == wiki.ErrRevisionNotFound {
-		article = wiki.NewArticle(vars["article"], cases.Title(language.AmericanEnglish).String(vars["article"]), "")
+		article = wiki.NewArticle(vars["article"], a.DisplayTitle(vars["article"]), "")
 		article.Hash = "new"
 	} else if err != nil {
 		a.errorHandler(http.StatusInternalServerError, rw, req, err)
 		return
 	}
 
+	allowed, reason := a.EditPolicy().Evaluate(article, req.Context().Value(wiki.UserKey).(*wiki.User))
+	if reason == EditDenialLoginRequired {
+		a.denyEdit(reason, rw, req)
+		return
+	} else if !allowed && reason != EditDenialPreviewOnly {
+		http.Redirect(rw, req, fmt.Sprintf("/wiki/%s/r/%d/source", article.URL, article.ID), http.StatusFound)
+		return
+	}
+
 	other := make(map[string]interface{})
 	other["Preview"] = false
+	other["PreviewOnly"] = reason == EditDenialPreviewOnly
+	other["IdempotencyKey"] = newIdempotencyKey()
+	editor := a.editorPreferences(req)
+	other["Editor"] = editor
+	if editor.ShowToolbar {
+		other["Toolbar"] = a.Toolbar()
+	}
 
 	err = a.RenderTemplate(rw, "article_edit.html", "index.html", map[string]interface{}{
 		"Article": article,
@@ -297,6 +787,34 @@ func (a *app) revisionEditHandler(rw http.ResponseWriter, req *http.Request) {
 	check(err)
 }
 
+// revisionSourceHandler shows a revision's Markdown read-only, for users who
+// may not edit it.
+func (a *app) revisionSourceHandler(rw http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	revisionID, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	}
+	article, err := a.GetArticleByRevisionID(vars["article"], revisionID)
+	if err == nil && !canView(article, req.Context().Value(wiki.UserKey).(*wiki.User)) {
+		err = wiki.ErrRevisionNotFound
+	}
+	if err == wiki.ErrRevisionNotFound {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "article_source.html", "index.html", map[string]interface{}{
+		"Article": article,
+		"Context": req.Context(),
+	})
+	check(err)
+}
+
 func (a *app) revisionPostHandler(rw http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	article := &wiki.Article{}
@@ -307,8 +825,16 @@ func (a *app) revisionPostHandler(rw http.ResponseWriter, req *http.Request) {
 	article.Title = req.PostFormValue("title")
 	article.Markdown = req.PostFormValue("body")
 	article.Comment = req.PostFormValue("comment")
+	article.Blanking = req.PostFormValue("blank") != ""
 
 	article.Creator = req.Context().Value(wiki.UserKey).(*wiki.User)
+	preview := req.PostFormValue("action") == "preview"
+	// Previews aren't saved, so they are allowed to users who may only preview.
+	if allowed, reason := a.EditPolicy().Evaluate(article, article.Creator); !allowed && !(preview && reason == EditDenialPreviewOnly) {
+		a.denyEdit(reason, rw, req)
+		return
+	}
+
 	previousID, err := strconv.Atoi(vars["revision"])
 	if err != nil {
 		a.errorHandler(http.StatusBadRequest, rw, req, err)
@@ -316,12 +842,29 @@ func (a *app) revisionPostHandler(rw http.ResponseWriter, req *http.Request) {
 	}
 	article.PreviousID = previousID
 
-	if req.PostFormValue("action") == "preview" {
+	if preview {
 		article.ID = previousID
 		a.articlePreviewHandler(article, rw, req)
 		return
 	}
-	a.articlePostHandler(article, rw, req)
+
+	key := idempotencyKey(req)
+	if key == "" {
+		a.articlePostHandler(article, rw, req)
+		return
+	}
+
+	key = fmt.Sprintf("%s\x00%d\x00%s", article.URL, article.Creator.ID, key)
+	if location, ok := a.idempotency.begin(key); ok {
+		http.Redirect(rw, req, location, http.StatusSeeOther)
+		return
+	}
+
+	location := ""
+	defer func() { a.idempotency.finish(key, location) }()
+	if a.articlePostHandler(article, rw, req) {
+		location = "/wiki/" + article.URL
+	}
 }
 
 func (a *app) articlePreviewHandler(article *wiki.Article, rw http.ResponseWriter, req *http.Request) {
@@ -334,6 +877,27 @@ func (a *app) articlePreviewHandler(article *wiki.Article, rw http.ResponseWrite
 
 	other := make(map[string]interface{})
 	other["Preview"] = true
+	editor := a.editorPreferences(req)
+	other["Editor"] = editor
+	if editor.ShowToolbar {
+		other["Toolbar"] = a.Toolbar()
+	}
+	_, reason := a.EditPolicy().Evaluate(article, article.Creator)
+	other["PreviewOnly"] = reason == EditDenialPreviewOnly
+	other["IdempotencyKey"] = idempotencyKey(req)
+	if other["IdempotencyKey"] == "" {
+		other["IdempotencyKey"] = newIdempotencyKey()
+	}
+
+	// Warn, without preventing the save, when a new article repeats one that
+	// already exists and would be better linked or redirected to.
+	if article.PreviousID == 0 && a.DuplicateContentThreshold > 0 {
+		duplicates, err := a.FindSimilarContent(article.Markdown, a.DuplicateContentThreshold)
+		if err != nil {
+			log.Println(err)
+		}
+		other["Duplicates"] = duplicates
+	}
 
 	err = a.RenderTemplate(rw, "article_edit.html", "index.html",
 		map[string]interface{}{
@@ -342,17 +906,51 @@ func (a *app) articlePreviewHandler(article *wiki.Article, rw http.ResponseWrite
 			"Other":   other})
 	check(err)
 }
-func (a *app) articlePostHandler(article *wiki.Article, rw http.ResponseWriter, req *http.Request) {
+
+// articlePostHandler saves article and redirects to it, reporting whether it
+// was saved.
+func (a *app) articlePostHandler(article *wiki.Article, rw http.ResponseWriter, req *http.Request) bool {
 	err := a.PostArticle(article)
+	if err == wiki.ErrRevisionAlreadyExists && a.MergeEditConflicts && article.PreviousID > 0 {
+		err = a.postMergedArticle(article)
+	}
 	if err != nil {
-		if err == wiki.ErrRevisionAlreadyExists {
+		if err == wiki.ErrRevisionAlreadyExists || err == wiki.ErrArticleDeleted {
 			a.errorHandler(http.StatusConflict, rw, req, err)
-			return
+			return false
+		}
+		if err == wiki.ErrMergeConflict {
+			a.errorHandler(http.StatusConflict, rw, req, wiki.ErrRevisionAlreadyExists, err)
+			return false
+		}
+		if err == wiki.ErrArticleProtected {
+			a.errorHandler(http.StatusForbidden, rw, req, err)
+			return false
 		}
 		a.errorHandler(http.StatusBadRequest, rw, req, err)
-		return
+		return false
 	}
 	http.Redirect(rw, req, "/wiki/"+article.URL, http.StatusSeeOther) // To prevent "browser must resend..."
+	return true
+}
+
+// postMergedArticle retries a conflicting edit as a three-way merge against the
+// current head of the article.
+func (a *app) postMergedArticle(article *wiki.Article) error {
+	merged, err := a.TryMerge(article.URL, article.PreviousID, article.Markdown)
+	if err != nil {
+		return err
+	}
+
+	article.Markdown = merged.Markdown
+	article.PreviousID = merged.ID
+	if article.Comment == "" {
+		article.Comment = "merged edit"
+	} else {
+		article.Comment += " (merged edit)"
+	}
+
+	return a.PostArticle(article)
 }
 
 func check(err error) {
@@ -391,46 +989,157 @@ func (a *app) diffHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
 	orginal, err := a.GetArticleByRevisionID(vars["article"], originalID)
 	if err != nil {
 		a.errorHandler(http.StatusNotFound, rw, req, err)
 		return
 	}
+	if !canView(orginal, user) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrRevisionNotFound)
+		return
+	}
 
 	new, err := a.GetArticleByRevisionID(vars["article"], newID)
 	if err != nil {
 		a.errorHandler(http.StatusNotFound, rw, req, err)
 		return
 	}
+	if !canView(new, user) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrRevisionNotFound)
+		return
+	}
+
+	a.renderDiffPage(rw, req, orginal, new)
+}
+
+// renderSplitDiffPage renders the lines of original and new side by side.
+func (a *app) renderSplitDiffPage(rw http.ResponseWriter, req *http.Request, orginal, new *wiki.Article) {
+	err := a.RenderTemplate(rw, "diff_split.html", "index.html", map[string]interface{}{
+		"Article":   orginal,
+		"Context":   req.Context(),
+		"Rows":      splitDiff(orginal.Markdown, new.Markdown),
+		"InlineURL": diffURL(req, "view", "inline"),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
 
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(orginal.Markdown, new.Markdown, false)
-
-	var buff bytes.Buffer
-	for _, diff := range diffs {
-		// text := strings.Replace(html.EscapeString(diff.Text), "\n", "&para;<br>", -1)
-		switch diff.Type {
-		case diffmatchpatch.DiffInsert:
-			_, _ = buff.WriteString("<ins style=\"background:#e6ffe6;\">")
-			_, _ = buff.WriteString(diff.Text)
-			_, _ = buff.WriteString("</ins>")
-		case diffmatchpatch.DiffDelete:
-			_, _ = buff.WriteString("<del style=\"background:#ffe6e6;\">")
-			_, _ = buff.WriteString(diff.Text)
-			_, _ = buff.WriteString("</del>")
-		case diffmatchpatch.DiffEqual:
-			_, _ = buff.WriteString("<span>")
-			_, _ = buff.WriteString(diff.Text)
-			_, _ = buff.WriteString("</span>")
+// diffURL returns the URL of the diff req is for, with the query parameter
+// key set to value. The rest of the query, e.g. ?since=, is kept.
+func diffURL(req *http.Request, key, value string) string {
+	query := req.URL.Query()
+	query.Set(key, value)
+	return req.URL.Path + "?" + query.Encode()
+}
+
+// diffSinceHandler diffs the current revision of an article against the one
+// that was current at the end of the ?since= date. An article created after
+// that date is diffed against nothing.
+func (a *app) diffSinceHandler(rw http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	since, err := time.Parse(wiki.ReviewedDateLayout, req.URL.Query().Get("since"))
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", req.URL.Query().Get("since")))
+		return
+	}
+
+	new, err := a.GetArticle(vars["article"])
+	if err != nil {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	}
+	if !canView(new, req.Context().Value(wiki.UserKey).(*wiki.User)) {
+		a.errorHandler(http.StatusNotFound, rw, req, wiki.ErrRevisionNotFound)
+		return
+	}
+
+	original, err := a.GetArticleAsOf(vars["article"], since.AddDate(0, 0, 1))
+	if err == wiki.ErrRevisionNotFound {
+		empty := *new.Revision
+		// Clearing the hash keeps its diff apart from new's own in a.diffs.
+		empty.Markdown, empty.Hash = "", ""
+		original = &wiki.Article{URL: new.URL, Revision: &empty}
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	a.renderDiffPage(rw, req, original, new)
+}
+
+// renderDiffPage renders the diff from original to new, honouring ?context=,
+// ?mode=word and ?view=split, or else the user's diff mode. Rendered inline
+// diffs are kept in a.diffs.
+func (a *app) renderDiffPage(rw http.ResponseWriter, req *http.Request, orginal, new *wiki.Article) {
+	view, mode := req.URL.Query().Get("view"), req.URL.Query().Get("mode")
+	if view == "" && mode == "" {
+		switch a.displayPreferences(req.Context()).DiffMode {
+		case wiki.DiffModeSplit:
+			view = "split"
+		case wiki.DiffModeWord:
+			mode = "word"
+		}
+	}
+
+	switch view {
+	case "", "inline":
+	case "split":
+		a.renderSplitDiffPage(rw, req, orginal, new)
+		return
+	default:
+		a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("invalid diff view %q, expected inline or split", view))
+		return
+	}
+
+	if mode == "" {
+		mode = "char"
+	}
+	if mode != "char" && mode != "word" {
+		a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("invalid diff mode %q, expected char or word", mode))
+		return
+	}
+
+	c := req.URL.Query().Get("context")
+	context := -1
+	if c != "" {
+		var err error
+		context, err = strconv.Atoi(c)
+		if err != nil || context < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, fmt.Errorf("invalid context %q", c))
+			return
 		}
 	}
-	pretty := buff.String()
 
-	err = a.RenderTemplate(rw, "diff.html", "index.html", map[string]interface{}{
+	key := diffCacheKey(req.URL.Path, mode, c, orginal, new)
+	pretty, ok := a.diffs.get(key)
+	if !ok {
+		switch {
+		case context < 0 && mode == "word":
+			pretty = renderWordDiff(orginal.Markdown, new.Markdown)
+		case context < 0:
+			pretty = renderDiff(orginal.Markdown, new.Markdown)
+		default:
+			pretty = renderContextDiff(orginal.Markdown, new.Markdown, context, req.URL.Path, mode == "word")
+		}
+		a.diffs.put(key, pretty, a.Settings().DiffCacheSize)
+	}
+
+	modeURLs := map[string]string{
+		"char":  diffURL(req, "mode", "char"),
+		"word":  diffURL(req, "mode", "word"),
+		"split": diffURL(req, "view", "split"),
+	}
+
+	err := a.RenderTemplate(rw, "diff.html", "index.html", map[string]interface{}{
 		"Article": orginal,
 		"Context": req.Context(),
 		"Other": map[string]interface{}{
 			"DiffString": pretty,
+			"Mode":       mode,
+			"ModeURLs":   modeURLs,
 		}})
 
 	if err != nil {
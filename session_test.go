@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/securecookie"
+)
+
+// loginCookie logs in as screenname with form's extra fields and returns the
+// login cookie.
+func loginCookie(t *testing.T, a *app, screenname string, form url.Values, cookie *http.Cookie) *http.Cookie {
+	t.Helper()
+
+	form.Set("screenname", screenname)
+	form.Set("password", testPassword)
+	rr := serve(a, newRequest(http.MethodPost, "/user/login", form, cookie))
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "periwiki-login" {
+			return cookie
+		}
+	}
+	t.Fatalf("login as %q failed with %d", screenname, rr.Code)
+	return nil
+}
+
+func TestRememberMe(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RememberMeExpiry = 30 * 24 * time.Hour
+	})
+	mustRegister(t, a, "admin")
+
+	session := loginCookie(t, a, "admin", url.Values{}, nil)
+	if session.MaxAge != 0 || !session.Expires.IsZero() {
+		t.Errorf("expected a session cookie without remember me, got MaxAge %d", session.MaxAge)
+	}
+	if !userFor(a, session).IsAdmin() {
+		t.Error("expected a session cookie to keep the user logged in")
+	}
+
+	remembered := loginCookie(t, a, "admin", url.Values{"remember": {"on"}}, nil)
+	if remembered.MaxAge != 30*24*60*60 {
+		t.Errorf("expected remember me to last 30 days, got MaxAge %d", remembered.MaxAge)
+	}
+	if !userFor(a, remembered).IsAdmin() {
+		t.Error("expected a remembered session to keep the user logged in")
+	}
+}
+
+func TestManageRememberMeExpiry(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	admin := login(t, a, "admin")
+
+	form := url.Values{"site_name": {"periwiki"}, "remember_me_expiry": {"1h"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected an expiry shorter than cookie_expiry to be rejected, got %d", rr.Code)
+	}
+
+	form.Set("remember_me_expiry", "2160h")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+	}
+	if expiry := a.Settings().RememberMeExpiry; expiry != 90*24*time.Hour {
+		t.Errorf("expected remember me to last 90 days, got %v", expiry)
+	}
+	if cookie := loginCookie(t, a, "admin", url.Values{"remember": {"on"}}, nil); cookie.MaxAge != 90*24*60*60 {
+		t.Errorf("expected the new expiry to apply to logins, got MaxAge %d", cookie.MaxAge)
+	}
+}
+
+func TestCookieSecretChange(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	stale := login(t, a, "admin")
+
+	conf := *a.Config
+	conf.CookieSecret = securecookie.GenerateRandomKey(64)
+	b := newApp(&conf)
+	t.Cleanup(b.Close)
+
+	if user := userFor(b, stale); !user.IsAnonymous() {
+		t.Errorf("expected a cookie signed with the old secret to be anonymous, got %q", user.ScreenName)
+	}
+	if cookie := loginCookie(t, b, "admin", url.Values{}, stale); !userFor(b, cookie).IsAdmin() {
+		t.Error("expected logging in over a stale cookie to work")
+	}
+}
+
+// userFor returns the user a request with cookie is made as.
+func userFor(a *app, cookie *http.Cookie) *wiki.User {
+	var user *wiki.User
+	handler := a.SessionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		user = req.Context().Value(wiki.UserKey).(*wiki.User)
+	}))
+	handler.ServeHTTP(nil, newRequest(http.MethodGet, "/", nil, cookie))
+	return user
+}
+
+func TestActiveSessions(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+
+	logInFrom := func(screenname, userAgent string) *http.Cookie {
+		t.Helper()
+		form := url.Values{"screenname": {screenname}, "password": {testPassword}}
+		req := newRequest(http.MethodPost, "/user/login", form, nil)
+		req.Header.Set("User-Agent", userAgent)
+		for _, cookie := range serve(a, req).Result().Cookies() {
+			if cookie.Name == "periwiki-login" {
+				return cookie
+			}
+		}
+		t.Fatalf("login as %q failed", screenname)
+		return nil
+	}
+	laptop := logInFrom("admin", "Laptop <b>browser</b>")
+	phone := logInFrom("admin", "Phone browser")
+	bobs := logInFrom("bob", "Bob's browser")
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Special:Sessions", nil, laptop)).Body.String()
+	if !strings.Contains(page, "Laptop &lt;b&gt;browser&lt;/b&gt;") || !strings.Contains(page, "Phone browser") {
+		t.Errorf("expected both of admin's sessions to be listed with escaped user agents, got:\n%s", page)
+	}
+	if strings.Contains(page, "Bob") {
+		t.Error("expected another user's sessions not to be listed")
+	}
+	if strings.Count(page, "This session") != 1 {
+		t.Error("expected the current session to be marked")
+	}
+
+	sessions, err := a.GetUserSessions(userFor(a, phone))
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d (%v)", len(sessions), err)
+	}
+	phoneID := sessions[0].ID
+	bobsID := mustSessions(t, a, bobs)[0].ID
+
+	// Other users' sessions can't be revoked.
+	form := url.Values{"action": {"revoke"}, "session": {bobsID}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:Sessions", form, laptop)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected revoking another user's session to fail with 404, got %d", rr.Code)
+	}
+	if userFor(a, bobs).IsAnonymous() {
+		t.Error("expected bob to still be logged in")
+	}
+
+	form.Set("session", phoneID)
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:Sessions", form, laptop))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/wiki/Special:Sessions" {
+		t.Errorf("expected revoking another session to return to the list, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if !userFor(a, phone).IsAnonymous() {
+		t.Error("expected the revoked session to be logged out")
+	}
+	if userFor(a, laptop).IsAnonymous() {
+		t.Error("expected the current session to stay logged in")
+	}
+
+	form = url.Values{"action": {"revoke_all"}}
+	rr = serve(a, newRequest(http.MethodPost, "/wiki/Special:Sessions", form, laptop))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Errorf("expected logging out everywhere to redirect to /, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if !userFor(a, laptop).IsAnonymous() {
+		t.Error("expected every session to be logged out")
+	}
+	if userFor(a, bobs).IsAnonymous() {
+		t.Error("expected bob to still be logged in")
+	}
+
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:Sessions", nil, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected anonymous users to be refused, got %d", rr.Code)
+	}
+}
+
+func TestRevokeCurrentSession(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	cookie := login(t, a, "admin")
+
+	sessions := mustSessions(t, a, cookie)
+	form := url.Values{"action": {"revoke"}, "session": {sessions[0].ID}}
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Special:Sessions", form, cookie))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Errorf("expected revoking the current session to redirect to /, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if !userFor(a, cookie).IsAnonymous() {
+		t.Error("expected the session to be logged out")
+	}
+}
+
+// mustSessions returns the sessions of the user logged in with cookie.
+func mustSessions(t *testing.T, a *app, cookie *http.Cookie) []*wiki.SessionInfo {
+	t.Helper()
+
+	sessions, err := a.GetUserSessions(userFor(a, cookie))
+	if err != nil || len(sessions) == 0 {
+		t.Fatalf("expected sessions, got %d (%v)", len(sessions), err)
+	}
+	return sessions
+}
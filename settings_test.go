@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// pngHeader is enough of a PNG for content sniffing to recognise it.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestManageSettings(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("site_name", "Catipedia")
+	form.WriteField("site_tagline", "All about cats")
+	part, _ := form.CreateFormFile("favicon", "favicon.png")
+	part.Write(pngHeader)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/settings", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(login(t, a, "admin"))
+	if rr := serve(a, req); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil))
+	page := rr.Body.String()
+	if !strings.Contains(page, "<title>Cats — Catipedia</title>") {
+		t.Errorf("expected the site name in the title, got:\n%s", page)
+	}
+	if !strings.Contains(page, "All about cats") {
+		t.Error("expected the tagline in the header")
+	}
+	if !strings.Contains(page, `<link rel="icon" href="/asset/favicon?v=`) {
+		t.Error("expected the uploaded favicon to be linked")
+	}
+
+	rr = serve(a, newRequest(http.MethodGet, "/asset/favicon", nil, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected the favicon to be served as image/png, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	// Settings survive a reload from the database.
+	reloaded := newApp(a.Config)
+	t.Cleanup(reloaded.Close)
+	if name := reloaded.Settings().SiteName; name != "Catipedia" {
+		t.Errorf("expected the site name to persist, got %q", name)
+	}
+}
+
+func TestSiteBrandingIsEscaped(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	admin := login(t, a, "admin")
+
+	form := url.Values{"site_name": {`Cats"><script>alert(1)</script>`}, "site_tagline": {"<svg/onload=alert(2)>"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, path := range []string{"/wiki/Cats", "/embed/Cats", "/wiki/Cats?print", "/manage/settings"} {
+		page := serve(a, newRequest(http.MethodGet, path, nil, admin)).Body.String()
+		if strings.Contains(page, "<script>alert") || strings.Contains(page, "<svg") {
+			t.Errorf("%s: expected the site name and tagline to be escaped, got:\n%s", path, page)
+		}
+		if !strings.Contains(page, "Cats&#34;&gt;&lt;script&gt;") {
+			t.Errorf("%s: expected the escaped site name, got:\n%s", path, page)
+		}
+	}
+}
+
+func TestSavedLicenseURLIsChecked(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.ContentLicenseName = "CC0" })
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	// As saved before license URLs were limited to http and https.
+	settings := a.Settings()
+	settings.ContentLicenseURL = "javascript:alert(1)"
+	if err := a.UpdateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newApp(a.Config)
+	t.Cleanup(reloaded.Close)
+	if u := reloaded.Settings().ContentLicenseURL; u != "" {
+		t.Errorf("expected the saved javascript: URL to be dropped, got %q", u)
+	}
+	if page := serve(reloaded, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String(); strings.Contains(page, "javascript:") {
+		t.Errorf("expected no javascript: link in the footer, got:\n%s", page)
+	}
+}
+
+func TestManageSettingsRequiresAdmin(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+
+	form := url.Values{"site_name": {"Bobipedia"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/manage/settings", nil, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an anonymous user, got %d", rr.Code)
+	}
+	if name := a.Settings().SiteName; name != "periwiki" {
+		t.Errorf("expected the site name to be unchanged, got %q", name)
+	}
+}
+
+func TestManageSettingsRejectsNonImages(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("site_name", "periwiki")
+	part, _ := form.CreateFormFile("logo", "logo.svg")
+	part.Write([]byte("<html><script>alert(1)</script></html>"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/settings", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(login(t, a, "admin"))
+	if rr := serve(a, req); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-image upload, got %d", rr.Code)
+	}
+}
+
+func TestContentLicense(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.ContentLicenseName = "CC BY-SA 4.0" })
+	mustRegister(t, a, "admin")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, `<small class="pw-license">Content is available under CC BY-SA 4.0.</small>`) {
+		t.Errorf("expected the configured license in the footer, got:\n%s", page)
+	}
+
+	admin := login(t, a, "admin")
+	form := url.Values{"site_name": {"periwiki"}}
+	for _, bad := range []string{"javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,hi", "/license", "//example.com/license"} {
+		form.Set("content_license_url", bad)
+		if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for the license URL %q, got %d", bad, rr.Code)
+		}
+	}
+
+	form.Set("content_license_name", "CC0")
+	form.Set("content_license_url", "https://creativecommons.org/publicdomain/zero/1.0/")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, `<a href="https://creativecommons.org/publicdomain/zero/1.0/" rel="license">CC0</a>`) {
+		t.Errorf("expected the license to link to its URL, got:\n%s", page)
+	}
+
+	form.Set("content_license_name", `CC0"><script>alert(1)</script>`)
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+	for _, path := range []string{"/wiki/Cats", "/manage/settings"} {
+		if page := serve(a, newRequest(http.MethodGet, path, nil, admin)).Body.String(); strings.Contains(page, "<script>alert") {
+			t.Errorf("%s: expected the license name to be escaped, got:\n%s", path, page)
+		}
+	}
+
+	form.Set("content_license_name", "")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+	if page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String(); strings.Contains(page, "pw-license") {
+		t.Errorf("expected no license without a name, got:\n%s", page)
+	}
+}
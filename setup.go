@@ -5,24 +5,87 @@ import (
 	"regexp"
 
 	"github.com/danielledeleo/periwiki/db"
+	"github.com/danielledeleo/periwiki/extensions"
+	"github.com/danielledeleo/periwiki/render"
 	"github.com/danielledeleo/periwiki/templater"
 	"github.com/danielledeleo/periwiki/wiki"
 	"github.com/microcosm-cc/bluemonday"
 )
 
 func Setup() *app {
-	modelConf := SetupConfig()
+	conf := SetupConfig()
+	if err := conf.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	a := newApp(conf)
+	if n, err := a.IndexAnchors(); err != nil {
+		log.Println(err)
+	} else if n > 0 {
+		log.Printf("indexed the headings of %d articles", n)
+	}
+	if n, err := a.ResubmitQueuedRenders(); err != nil {
+		log.Println(err)
+	} else if n > 0 {
+		log.Printf("resubmitted %d revisions left waiting to be rendered", n)
+	}
+	return a
+}
 
+// sanitizerPolicyVersion is bumped whenever the policy newApp sanitizes
+// rendered HTML with changes, so HTML stored under the old policy is rendered
+// again.
+const sanitizerPolicyVersion = 1
+
+// newApp wires the templates, database and model together for the given config.
+func newApp(modelConf *wiki.Config) *app {
 	bm := bluemonday.UGCPolicy()
 
 	bm.AllowAttrs("class").Matching(regexp.MustCompile("^sourceCode(| [a-zA-Z0-9]+)(| lineNumbers)$")).
 		OnElements("pre", "code")
-	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^infobox$`)).OnElements("div")
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^(infobox|pw-navbox)$`)).OnElements("div")
 	bm.AllowAttrs("data-line-number", "class").Matching(regexp.MustCompile("^[0-9]+$")).OnElements("a")
 	bm.AllowAttrs("style").OnElements("ins", "del")
-	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote-ref$`)).OnElements("a")
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^(footnote-ref|pw-deadlink|pw-deadanchor|pw-redirectlink|pw-redirectlink pw-deadanchor)$`)).OnElements("a")
+	// External links, as marked by render.WithExternalLinkPolicy.
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^` + render.ExternalLinkClass + `$`)).OnElements("a")
+	bm.AllowAttrs("rel").Matching(regexp.MustCompile(`^nofollow noopener$`)).OnElements("a")
+	bm.AllowAttrs("target").Matching(regexp.MustCompile(`^_blank$`)).OnElements("a")
 	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^footnotes$`)).OnElements("section")
 	bm.AllowAttrs("style").Matching(regexp.MustCompile(`^text-align:\s+(left|right|center);$`)).OnElements("td", "th")
+	// Highlighted code, whose classes all carry the same prefix.
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^` + extensions.HighlightClassPrefix + `chroma$`)).OnElements("pre")
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^` + extensions.HighlightClassPrefix + `[a-z0-9]+$`)).OnElements("span")
+	// Math is left as escaped TeX for a script to typeset, so no MathML
+	// elements need be allowed.
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^math (inline|display)$`)).OnElements("span", "div")
+	// Diagrams drawn by Graphviz: only the shapes and text it draws, with
+	// attribute values limited to numbers, names and colours. Elements that
+	// script, link, animate or embed are still stripped, as are event
+	// handlers.
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^` + extensions.DiagramClass + `$`)).OnElements("div")
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^` + extensions.DiagramErrorClass + `$`)).OnElements("pre")
+	svgNumber := regexp.MustCompile(`^-?[0-9.]+$`)
+	svgNumbers := regexp.MustCompile(`^[0-9.,\s-]+$`)
+	svgShapes := []string{"polygon", "polyline", "path", "ellipse", "circle", "rect", "line"}
+	bm.AllowElements(append([]string{"svg", "g", "text", "tspan"}, svgShapes...)...)
+	bm.AllowAttrs("width", "height").Matching(regexp.MustCompile(`^[0-9.]+(pt|px)?$`)).OnElements("svg")
+	bm.AllowAttrs("viewbox").Matching(svgNumbers).OnElements("svg")
+	bm.AllowAttrs("class").Matching(regexp.MustCompile(`^(graph|node|edge|cluster)$`)).OnElements("g")
+	bm.AllowAttrs("transform").Matching(regexp.MustCompile(`^((scale|rotate|translate)\([0-9.\s-]+\)\s*)+$`)).OnElements("g")
+	bm.AllowAttrs("fill", "stroke").Matching(regexp.MustCompile(`^([a-z]+|#[0-9a-fA-F]{3,8})$`)).OnElements(append([]string{"g", "text"}, svgShapes...)...)
+	bm.AllowAttrs("stroke-width", "fill-opacity", "stroke-opacity").Matching(svgNumber).OnElements(append([]string{"g", "text"}, svgShapes...)...)
+	bm.AllowAttrs("stroke-dasharray").Matching(svgNumbers).OnElements(svgShapes...)
+	bm.AllowAttrs("points").Matching(svgNumbers).OnElements("polygon", "polyline")
+	bm.AllowAttrs("d").Matching(regexp.MustCompile(`^[MmLlHhVvCcSsQqTtAaZz0-9.,\s-]+$`)).OnElements("path")
+	bm.AllowAttrs("cx", "cy", "r", "rx", "ry", "x", "y", "x1", "y1", "x2", "y2").Matching(svgNumber).OnElements(append([]string{"text", "tspan"}, svgShapes...)...)
+	bm.AllowAttrs("text-anchor").Matching(regexp.MustCompile(`^(start|middle|end)$`)).OnElements("text", "tspan")
+	bm.AllowAttrs("font-family").Matching(regexp.MustCompile(`^[a-zA-Z0-9 ,-]+$`)).OnElements("text", "tspan")
+	bm.AllowAttrs("font-size").Matching(svgNumber).OnElements("text", "tspan")
+	bm.AllowAttrs("font-weight", "font-style").Matching(regexp.MustCompile(`^(normal|bold|italic)$`)).OnElements("text", "tspan")
+	bm.SkipElementsContent("title")
+	// Task list checkboxes, which are always disabled.
+	bm.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	bm.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
 
 	t := templater.New()
 
@@ -32,6 +95,10 @@ func Setup() *app {
 
 	database, err := db.Init(modelConf)
 	check(err)
-	model := wiki.New(database, modelConf, bm)
-	return &app{t, model}
+	model := wiki.New(database, modelConf, bm, sanitizerPolicyVersion)
+	a := &app{Templater: t, WikiModel: model, idempotency: newIdempotencyKeys(), loginAttempts: newRateLimiter(), diffs: newDiffCache(), csrf: newCSRFStore(modelConf.CookieSecret)}
+	if registry := model.Metrics(); registry != nil {
+		a.httpMetrics = newHTTPMetrics(registry)
+	}
+	return a
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/securecookie"
+)
+
+// newTestApp returns an app backed by a fresh database in a temporary
+// directory. Options may adjust the config before the app is built.
+func newTestApp(t *testing.T, options ...func(*wiki.Config)) *app {
+	t.Helper()
+
+	conf := &wiki.Config{
+		CookieSecret:          securecookie.GenerateRandomKey(64),
+		CookieExpiry:          86400,
+		DatabaseFile:          filepath.Join(t.TempDir(), "periwiki.db"),
+		MinimumPasswordLength: 8,
+		Host:                  "localhost:8080",
+		NormalizeArticleURLs:  true,
+		RenderWorkers:         2,
+		BacklinkBatchWindow:   10 * time.Millisecond,
+		DiffCacheSize:         256,
+	}
+
+	for _, option := range options {
+		option(conf)
+	}
+
+	a := newApp(conf)
+	t.Cleanup(a.Close)
+	return a
+}
+
+// mustPostArticle creates or updates an article as the anonymous user and
+// fails the test on error.
+func mustPostArticle(t *testing.T, a *app, url, markdown string, previousID int) *wiki.Article {
+	t.Helper()
+
+	article := wiki.NewArticle(url, url, markdown)
+	article.Creator = wiki.AnonymousUser()
+	article.PreviousID = previousID
+
+	if err := a.PostArticle(article); err != nil {
+		t.Fatalf("PostArticle(%q): %v", url, err)
+	}
+
+	return article
+}
+
+// serve runs req through the app's router and returns the recorded response.
+// serve sends req to a. Requests that change state and don't carry a CSRF
+// token are sent with one, as a browser would from the form it submits.
+func serve(a *app, req *http.Request) *httptest.ResponseRecorder {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if req.Header.Get(csrfHeader) == "" {
+			replayCSRFToken(a, req)
+		}
+	}
+	return serveWithoutCSRF(a, req)
+}
+
+// serveWithoutCSRF sends req to a as it is.
+func serveWithoutCSRF(a *app, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	newRouter(a).ServeHTTP(rr, req)
+	return rr
+}
+
+var csrfFieldRegexp = regexp.MustCompile(`name="` + wiki.CSRFField + `" value="([^"]+)"`)
+
+// replayCSRFToken loads a form with req's cookies and adds the CSRF token
+// it carries, and the cookie it may set, to req.
+func replayCSRFToken(a *app, req *http.Request) {
+	page := httptest.NewRequest(http.MethodGet, "/user/login", nil)
+	for _, cookie := range req.Cookies() {
+		page.AddCookie(cookie)
+	}
+	rr := serveWithoutCSRF(a, page)
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == csrfSession {
+			req.AddCookie(cookie)
+		}
+	}
+	if match := csrfFieldRegexp.FindStringSubmatch(rr.Body.String()); match != nil {
+		req.Header.Set(csrfHeader, match[1])
+	}
+}
+
+const testPassword = "correct horse battery staple"
+
+// mustRegister registers a user with testPassword and returns it as loaded
+// from the database. The first user registered on an app is its admin.
+func mustRegister(t *testing.T, a *app, screenname string) *wiki.User {
+	t.Helper()
+
+	user := &wiki.User{ScreenName: screenname, Email: screenname + "@example.com", RawPassword: testPassword}
+	if err := a.PostUser(user); err != nil {
+		t.Fatalf("PostUser(%q): %v", screenname, err)
+	}
+
+	user, err := a.GetUserByScreenName(screenname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login logs in as screenname and returns the resulting session cookie.
+func login(t *testing.T, a *app, screenname string) *http.Cookie {
+	t.Helper()
+
+	form := url.Values{"screenname": {screenname}, "password": {testPassword}}
+	req := httptest.NewRequest(http.MethodPost, "/user/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := serve(a, req)
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "periwiki-login" {
+			return cookie
+		}
+	}
+
+	t.Fatalf("login as %q failed with %d", screenname, rr.Code)
+	return nil
+}
+
+// newRequest builds a request, sending cookie when it is non-nil and
+// form-encoding a non-nil form as the body.
+func newRequest(method, target string, form url.Values, cookie *http.Cookie) *http.Request {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
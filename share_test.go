@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestShareLinks(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.ModerateNewArticles = true })
+	mustRegister(t, a, "admin")
+	bob := mustRegister(t, a, "bob")
+	admin := login(t, a, "admin")
+	for _, name := range []string{"Draft", "Other"} {
+		article := wiki.NewArticle(name, name, "Written by bob.")
+		article.Creator = bob
+		if err := a.PostArticle(article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Draft?share", url.Values{"ttl": {"1h"}}, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected only admins to share articles, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Draft?share", url.Values{"ttl": {"0"}}, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a link that never lasts, got %d", rr.Code)
+	}
+
+	rr := serve(a, newRequest(http.MethodPost, "/wiki/Draft?share", url.Values{"ttl": {"1h"}}, admin))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after sharing, got %d", rr.Code)
+	}
+	var flash string
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == flashCookie {
+			flash, _ = url.QueryUnescape(cookie.Value)
+		}
+	}
+	match := regexp.MustCompile(`/wiki/Draft\?share=(\S+)$`).FindStringSubmatch(flash)
+	if match == nil {
+		t.Fatalf("expected the link in the flash message, got %q", flash)
+	}
+	token := match[1]
+
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Draft?share="+token, nil, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Written by bob.") {
+		t.Errorf("expected the share link to show the pending article, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected shared views not to be cached, got %q", got)
+	}
+
+	for name, target := range map[string]string{
+		"another article": "/wiki/Other?share=" + token,
+		"tampered":        "/wiki/Draft?share=" + strings.Replace(token, ".", "0.", 1),
+		"expired":         "/wiki/Draft?share=" + a.GenerateShareToken("Draft", -time.Minute),
+		"malformed":       "/wiki/Draft?share=nonsense",
+	} {
+		if rr := serve(a, newRequest(http.MethodGet, target, nil, nil)); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected the article to stay hidden, got %d", name, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+// siteCode describes a site code page and where it is served from.
+type siteCode struct {
+	articleURL  string
+	contentType string
+}
+
+var siteCodeFiles = map[string]siteCode{
+	"common.css": {wiki.CommonCSSURL, "text/css; charset=utf-8"},
+	"common.js":  {wiki.CommonJSURL, "text/javascript; charset=utf-8"},
+}
+
+// siteCodeEnabled reports whether the site code page at url is in use.
+func (a *app) siteCodeEnabled(url string) bool {
+	return url != wiki.CommonJSURL || a.AllowCustomJS
+}
+
+// siteCodeURL returns the URL file is served from, versioned by revision so
+// it can be cached, or "" if its page doesn't exist or is disabled.
+func (a *app) siteCodeURL(file string) string {
+	code := siteCodeFiles[file]
+	if !a.siteCodeEnabled(code.articleURL) {
+		return ""
+	}
+
+	article, err := a.GetArticle(code.articleURL)
+	if err != nil {
+		if err != wiki.ErrGenericNotFound {
+			check(err)
+		}
+		return ""
+	}
+	return fmt.Sprintf("/site/%s?v=%d", file, article.ID)
+}
+
+// siteCodeHandler serves the current content of a site code page.
+func (a *app) siteCodeHandler(rw http.ResponseWriter, req *http.Request) {
+	code, ok := siteCodeFiles[mux.Vars(req)["file"]]
+	if !ok || !a.siteCodeEnabled(code.articleURL) {
+		http.NotFound(rw, req)
+		return
+	}
+
+	article, err := a.GetArticle(code.articleURL)
+	if err == wiki.ErrGenericNotFound {
+		http.NotFound(rw, req)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", code.contentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.Header().Set("ETag", `"`+article.Hash+`"`)
+	if req.URL.Query().Get("v") == fmt.Sprint(article.ID) {
+		// Versioned URLs change with every edit.
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		rw.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(rw, req, "", article.Created, strings.NewReader(article.Markdown))
+}
+
+// highlightCSSHandler serves the stylesheet for highlighted code. Its URL
+// names the style, so it may be cached for as long as that doesn't change.
+func (a *app) highlightCSSHandler(rw http.ResponseWriter, req *http.Request) {
+	css, err := a.HighlightCSS()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	if css == "" {
+		http.NotFound(rw, req)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/css; charset=utf-8")
+	if req.URL.Query().Get("style") == a.SyntaxHighlighting {
+		rw.Header().Set("Cache-Control", "public, max-age=86400")
+	} else {
+		rw.Header().Set("Cache-Control", "no-cache")
+	}
+	_, _ = rw.Write([]byte(css))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestCommonCSS(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "bob")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	admin := login(t, a, "admin")
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if strings.Contains(page, "/site/common.css") {
+		t.Error("expected no custom stylesheet before Periwiki:Common.css exists")
+	}
+
+	form := url.Values{"title": {"Common.css"}, "body": {"body { color: red; }"}, "action": {"submit"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Periwiki:Common.css/r/0", form, login(t, a, "bob"))); rr.Code != http.StatusForbidden {
+		t.Errorf("expected only admins to edit site CSS, got %d", rr.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Periwiki:Common.css/r/0", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the admin's edit to be saved, got %d", rr.Code)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, `<link rel="stylesheet" type="text/css" href="/site/common.css?v=1" />`) {
+		t.Errorf("expected the custom stylesheet to be linked, got:\n%s", page)
+	}
+
+	form.Set("body", "body { color: blue; }")
+	serve(a, newRequest(http.MethodPost, "/wiki/Periwiki:Common.css/r/1", form, admin))
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, "/site/common.css?v=2") {
+		t.Error("expected the link to change with the edit")
+	}
+
+	rr := serve(a, newRequest(http.MethodGet, "/site/common.css?v=2", nil, nil))
+	if rr.Header().Get("Content-Type") != "text/css; charset=utf-8" || rr.Body.String() != "body { color: blue; }" {
+		t.Errorf("expected the raw CSS as text/css, got %q: %q", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	css, err := a.GetArticle(wiki.CommonCSSURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if css.HTML != "<pre><code>body { color: blue; }</code></pre>" {
+		t.Errorf("expected CSS to be shown as code, not rendered, got %q", css.HTML)
+	}
+}
+
+func TestCommonJSRequiresOptIn(t *testing.T) {
+	for _, allowed := range []bool{false, true} {
+		a := newTestApp(t, func(c *wiki.Config) {
+			c.AllowCustomJS = allowed
+		})
+		admin := mustRegister(t, a, "admin")
+
+		js := wiki.NewArticle(wiki.CommonJSURL, "Common.js", "console.log('hi');")
+		js.Creator = admin
+		if err := a.PostArticle(js); err != nil {
+			t.Fatal(err)
+		}
+
+		page := serve(a, newRequest(http.MethodGet, "/wiki/Periwiki:Common.js", nil, nil)).Body.String()
+		if injected := strings.Contains(page, `<script src="/site/common.js?v=1"></script>`); injected != allowed {
+			t.Errorf("AllowCustomJS=%v: expected the script to be injected: %v", allowed, allowed)
+		}
+
+		code := serve(a, newRequest(http.MethodGet, "/site/common.js", nil, nil)).Code
+		if want := map[bool]int{false: http.StatusNotFound, true: http.StatusOK}[allowed]; code != want {
+			t.Errorf("AllowCustomJS=%v: expected %d serving the script, got %d", allowed, want, code)
+		}
+	}
+}
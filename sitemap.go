@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority"`
+}
+
+// sitemapHandler lists the wiki's articles, and any Special pages named in
+// SitemapSpecialPages, for search engines.
+func (a *app) sitemapHandler(rw http.ResponseWriter, req *http.Request) {
+	entries, err := a.GetSitemap()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	urlset := sitemapURLSet{Xmlns: sitemapNamespace}
+	for _, entry := range entries {
+		urlset.URLs = append(urlset.URLs, sitemapURL{
+			Loc:        absoluteURL(req, "/wiki/"+entry.URL),
+			LastMod:    entry.LastModified.UTC().Format(wiki.ReviewedDateLayout),
+			ChangeFreq: entry.ChangeFreq,
+			Priority:   fmt.Sprintf("%.1f", entry.Priority),
+		})
+	}
+
+	pages := a.specialPages()
+	for _, name := range a.SitemapSpecialPages {
+		if _, ok := pages[name]; !ok {
+			continue
+		}
+		url := wiki.SpecialNamespace + name
+		urlset.URLs = append(urlset.URLs, sitemapURL{
+			Loc:      absoluteURL(req, "/wiki/"+url),
+			Priority: fmt.Sprintf("%.1f", a.SitemapPriority(url)),
+		})
+	}
+
+	rw.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = rw.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(rw)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(urlset); err != nil {
+		log.Println(err)
+	}
+}
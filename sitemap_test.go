@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// fetchSitemap returns the sitemap's entries keyed by location.
+func fetchSitemap(t *testing.T, a *app) map[string]sitemapURL {
+	t.Helper()
+
+	rr := serve(a, newRequest(http.MethodGet, "/sitemap.xml", nil, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var urlset sitemapURLSet
+	if err := xml.Unmarshal(rr.Body.Bytes(), &urlset); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := make(map[string]sitemapURL)
+	for _, url := range urlset.URLs {
+		urls[url.Loc] = url
+	}
+	return urls
+}
+
+func TestSitemap(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.SitemapPriorities = map[string]float64{"main_page": 1.0, "Talk:*": 0.1}
+		c.SitemapExcludeNamespaces = []string{"Talk"}
+		c.SitemapSpecialPages = []string{"StaleContent", "NoSuchPage"}
+	})
+
+	mustPostArticle(t, a, "Main_Page", "Welcome.", 0)
+	mustPostArticle(t, a, "Talk:Main_Page", "Hello.", 0)
+	mustPostArticle(t, a, "Hidden", "---\nnoindex: true\n---\nNot for search engines.", 0)
+	busy := mustPostArticle(t, a, "Busy", "Edit 0.", 0)
+	for i := 1; i <= 4; i++ {
+		busy = mustPostArticle(t, a, "Busy", fmt.Sprintf("Edit %d.", i), busy.ID)
+	}
+
+	urls := fetchSitemap(t, a)
+
+	main, ok := urls["http://example.com/wiki/Main_Page"]
+	if !ok {
+		t.Fatalf("expected Main_Page in the sitemap, got %v", urls)
+	}
+	for loc, url := range urls {
+		if priority, _ := strconv.ParseFloat(url.Priority, 64); priority > 1.0 || (priority == 1.0 && url != main) {
+			t.Errorf("expected Main_Page to have the highest priority, but %s has %s", loc, url.Priority)
+		}
+	}
+	if main.Priority != "1.0" || main.ChangeFreq != "monthly" {
+		t.Errorf("expected Main_Page to have priority 1.0 and changefreq monthly, got %+v", main)
+	}
+
+	if busy := urls["http://example.com/wiki/Busy"]; busy.Priority != "0.5" || busy.ChangeFreq != "weekly" {
+		t.Errorf("expected Busy to have the default priority and changefreq weekly, got %+v", busy)
+	}
+
+	for _, excluded := range []string{"Talk:Main_Page", "Hidden", "Special:NoSuchPage"} {
+		if _, ok := urls["http://example.com/wiki/"+excluded]; ok {
+			t.Errorf("expected %s to be excluded from the sitemap", excluded)
+		}
+	}
+	if _, ok := urls["http://example.com/wiki/Special:StaleContent"]; !ok {
+		t.Error("expected Special:StaleContent in the sitemap")
+	}
+}
+
+func TestSitemapTalkPagesIncludedByDefault(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Main_Page", "Welcome.", 0)
+	mustPostArticle(t, a, "Talk:Main_Page", "Hello.", 0)
+
+	if _, ok := fetchSitemap(t, a)["http://example.com/wiki/Talk:Main_Page"]; !ok {
+		t.Error("expected Talk pages in the sitemap unless excluded")
+	}
+}
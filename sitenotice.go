@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// siteNoticeCookie holds the version of the site notice the browser last
+// dismissed.
+const siteNoticeCookie = "periwiki-notice-dismissed"
+
+// dismissedNoticeKey is for context.Context.
+const dismissedNoticeKey wiki.ContextKey = "periwiki.dismissed-notice"
+
+// SiteNoticeMiddleware makes the version of the site notice the user
+// dismissed available to RenderTemplate.
+func (a *app) SiteNoticeMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cookie, err := req.Cookie(siteNoticeCookie); err == nil {
+			req = req.WithContext(context.WithValue(req.Context(), dismissedNoticeKey, cookie.Value))
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// siteNoticeHTML returns the site notice to show on a page rendered within
+// ctx, or an empty string if there is none or it was dismissed.
+func (a *app) siteNoticeHTML(ctx context.Context, settings wiki.Settings) string {
+	if settings.SiteNoticeHTML == "" {
+		return ""
+	}
+	if ctx != nil {
+		if dismissed, _ := ctx.Value(dismissedNoticeKey).(string); dismissed == settings.SiteNoticeVersion {
+			return ""
+		}
+	}
+	return settings.SiteNoticeHTML
+}
+
+// dismissNoticeHandler hides the version of the site notice the user saw
+// for this browser, until the notice is changed.
+func (a *app) dismissNoticeHandler(rw http.ResponseWriter, req *http.Request) {
+	version := req.PostFormValue("version")
+	if version == "" {
+		version = a.Settings().SiteNoticeVersion
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     siteNoticeCookie,
+		Value:    version,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   isHTTPS(req),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	referrer := req.Referer()
+	if referrer == "" {
+		referrer = "/"
+	}
+	http.Redirect(rw, req, referrer, http.StatusSeeOther)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSiteNotice(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	admin := login(t, a, "admin")
+
+	setNotice := func(notice string) {
+		t.Helper()
+		form := url.Values{"site_name": {"periwiki"}, "site_notice": {notice}}
+		if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+			t.Fatalf("expected 303 after saving settings, got %d", rr.Code)
+		}
+	}
+	page := func(cookie *http.Cookie) string {
+		return serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, cookie)).Body.String()
+	}
+
+	if strings.Contains(page(nil), `id="site-notice"`) {
+		t.Error("expected no notice while it is empty")
+	}
+
+	setNotice("Down for **maintenance** <script>alert(1)</script>")
+	if p := page(nil); !strings.Contains(p, "Down for <strong>maintenance</strong>") || strings.Contains(p, "<script>alert") {
+		t.Errorf("expected the notice to be rendered and sanitized, got:\n%s", p)
+	}
+
+	version := a.Settings().SiteNoticeVersion
+	req := newRequest(http.MethodPost, "/notice/dismiss", url.Values{"version": {version}}, nil)
+	req.Header.Set("Referer", "/wiki/Cats")
+	rr := serve(a, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/wiki/Cats" {
+		t.Errorf("expected to be sent back after dismissing, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	var dismissed *http.Cookie
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == siteNoticeCookie {
+			dismissed = cookie
+		}
+	}
+	if dismissed == nil || dismissed.Value != version {
+		t.Fatalf("expected dismissing to set the %s cookie to %q, got %v", siteNoticeCookie, version, dismissed)
+	}
+
+	if strings.Contains(page(dismissed), `id="site-notice"`) {
+		t.Error("expected a dismissed notice to be hidden")
+	}
+
+	setNotice("Back to normal.")
+	if a.Settings().SiteNoticeVersion == version {
+		t.Fatal("expected editing the notice to change its version")
+	}
+	if p := page(dismissed); !strings.Contains(p, "Back to normal.") {
+		t.Errorf("expected an edited notice to be shown again, got:\n%s", p)
+	}
+}
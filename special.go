@@ -0,0 +1,623 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+var errSpecialPageNotFound = errors.New("no such special page")
+
+// specialPages maps the names of Special: pages to their handlers.
+func (a *app) specialPages() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"AllPages":      a.allPagesHandler,
+		"Categories":    a.categoriesHandler,
+		"DeadEndPages":  a.deadEndPagesHandler,
+		"OrphanedPages": a.orphanedPagesHandler,
+		"PendingReview": a.pendingReviewHandler,
+		"RecentChanges": a.recentChangesHandler,
+		"RecycleBin":    a.recycleBinHandler,
+		"RerenderAll":   a.rerenderAllHandler,
+		"Sessions":      a.sessionsHandler,
+		"StaleContent":  a.staleContentHandler,
+		"WantedPages":   a.wantedPagesHandler,
+		"Watchlist":     a.watchlistHandler,
+		"Statistics":    a.statisticsHandler,
+		"WhatLinksHere": a.whatLinksHereHandler,
+	}
+}
+
+func (a *app) specialPageHandler(rw http.ResponseWriter, req *http.Request) {
+	handler, ok := a.specialPages()[mux.Vars(req)["page"]]
+	if !ok {
+		a.errorHandler(http.StatusNotFound, rw, req, errSpecialPageNotFound)
+		return
+	}
+	handler(rw, req)
+}
+
+func (a *app) pendingReviewHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if !user.IsAdmin() {
+		a.errorHandler(http.StatusForbidden, rw, req, errors.New("only administrators can review pending articles"))
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		var err error
+		url := req.PostFormValue("url")
+
+		switch req.PostFormValue("action") {
+		case "approve":
+			err = a.ApproveArticle(url)
+		case "reject":
+			err = a.RejectArticle(url, req.PostFormValue("reason"))
+		default:
+			err = errors.New("unknown review action")
+		}
+
+		if err == wiki.ErrGenericNotFound {
+			a.errorHandler(http.StatusNotFound, rw, req, err)
+			return
+		} else if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, err)
+			return
+		}
+
+		http.Redirect(rw, req, req.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	pending, err := a.GetPendingArticles()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_pending_review.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Pending review"},
+		"Context": req.Context(),
+		"Pending": pending,
+	})
+	check(err)
+}
+
+// recycleBinHandler lists the deleted articles for admins to restore or
+// purge for good.
+func (a *app) recycleBinHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if !user.IsAdmin() {
+		a.errorHandler(http.StatusForbidden, rw, req, errors.New("only administrators can see the recycle bin"))
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		var err error
+		url := req.PostFormValue("url")
+
+		switch req.PostFormValue("action") {
+		case "restore":
+			err = a.RestoreArticle(url)
+		case "purge":
+			err = a.PurgeArticle(url)
+		default:
+			err = errors.New("unknown recycle bin action")
+		}
+
+		if err == wiki.ErrGenericNotFound {
+			a.errorHandler(http.StatusNotFound, rw, req, err)
+			return
+		} else if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, err)
+			return
+		}
+
+		if req.PostFormValue("action") == "restore" {
+			setFlash(rw, req, "Restored "+url+".")
+		} else {
+			setFlash(rw, req, "Purged "+url+".")
+		}
+		http.Redirect(rw, req, req.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	deleted, err := a.GetDeletedArticles()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_recycle_bin.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Recycle bin"},
+		"Context": req.Context(),
+		"Deleted": deleted,
+	})
+	check(err)
+}
+
+func (a *app) staleContentHandler(rw http.ResponseWriter, req *http.Request) {
+	stale, err := a.GetStaleArticles()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_stale_content.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Stale content"},
+		"Context": req.Context(),
+		"Stale":   stale,
+	})
+	check(err)
+}
+
+// orphanedPagesHandler lists the articles nothing links to.
+func (a *app) orphanedPagesHandler(rw http.ResponseWriter, req *http.Request) {
+	orphans, err := a.GetOrphanedArticles()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_link_report.html", "index.html", map[string]interface{}{
+		"Article":     map[string]string{"Title": "Orphaned pages"},
+		"Context":     req.Context(),
+		"Page":        "OrphanedPages",
+		"Description": "These articles aren't linked to or embedded by any other article.",
+		"Empty":       "Every article is linked to from another.",
+		"Articles":    orphans,
+	})
+	check(err)
+}
+
+// deadEndPagesHandler lists the articles that link nowhere.
+func (a *app) deadEndPagesHandler(rw http.ResponseWriter, req *http.Request) {
+	deadEnds, err := a.GetDeadEndArticles()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_link_report.html", "index.html", map[string]interface{}{
+		"Article":     map[string]string{"Title": "Dead-end pages"},
+		"Context":     req.Context(),
+		"Page":        "DeadEndPages",
+		"Description": "These articles don't link to any other article.",
+		"Empty":       "Every article links to another.",
+		"Articles":    deadEnds,
+	})
+	check(err)
+}
+
+// wantedPagesHandler lists the articles that are linked to but don't exist,
+// most wanted first.
+func (a *app) wantedPagesHandler(rw http.ResponseWriter, req *http.Request) {
+	wanted, err := a.GetWantedPages()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_wanted_pages.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Wanted pages"},
+		"Context": req.Context(),
+		"Wanted":  wanted,
+	})
+	check(err)
+}
+
+// statisticsHandler shows counts of the wiki's content and contributors.
+func (a *app) statisticsHandler(rw http.ResponseWriter, req *http.Request) {
+	stats, err := a.GetStatistics()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	rw.Header().Set("Cache-Control", cacheControlFor(wiki.CacheControlDefault, user))
+
+	err = a.RenderTemplate(rw, "special_statistics.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Statistics"},
+		"Context": req.Context(),
+		"Stats":   stats,
+	})
+	check(err)
+}
+
+var errBadOffset = errors.New("offset must be a non-negative number")
+
+// backlink is an article listed by Special:WhatLinksHere.
+type backlink struct {
+	*wiki.ArticleSummary
+	// Redirect is set if the article only redirects to the target.
+	Redirect bool
+}
+
+// whatLinksHereHandler lists the articles linking to the target query
+// parameter, a page at a time. Without a target it asks for one.
+func (a *app) whatLinksHereHandler(rw http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	if target == "" {
+		err := a.RenderTemplate(rw, "special_what_links_here.html", "index.html", map[string]interface{}{
+			"Article": map[string]string{"Title": "What links here"},
+			"Context": req.Context(),
+			"Other":   map[string]interface{}{},
+		})
+		check(err)
+		return
+	}
+
+	offset := 0
+	if s := req.URL.Query().Get("offset"); s != "" {
+		var err error
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errBadOffset)
+			return
+		}
+	}
+
+	limit := a.BacklinksPerPage()
+	backlinks, total, err := a.GetBacklinksPaged(target, limit, offset)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	visible := make([]*backlink, 0, len(backlinks))
+	for _, summary := range backlinks {
+		article, err := a.GetArticle(summary.URL)
+		if err != nil || !canView(article, user) {
+			continue
+		}
+		visible = append(visible, &backlink{summary, article.RedirectTarget() == target})
+	}
+
+	other := map[string]interface{}{"Target": target, "Total": total, "Offset": offset}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		other["HasPrev"], other["Prev"] = true, prev
+	}
+	if offset+len(backlinks) < total {
+		other["HasNext"], other["Next"] = true, offset+len(backlinks)
+	}
+
+	err = a.RenderTemplate(rw, "special_what_links_here.html", "index.html", map[string]interface{}{
+		"Article":   map[string]string{"Title": "What links here"},
+		"Context":   req.Context(),
+		"Backlinks": visible,
+		"Other":     other,
+	})
+	check(err)
+}
+
+// Page sizes for Special:RecentChanges.
+const (
+	defaultRecentChanges = 50
+	maxRecentChanges     = 500
+)
+
+var (
+	errBadLimit  = errors.New("limit must be a positive number")
+	errBadBefore = errors.New("before must be a time like 2006-01-02T15:04:05Z")
+)
+
+// recentChangesHandler lists the latest revisions of every article, newest
+// first, limit at a time. The before parameter pages back through them.
+func (a *app) recentChangesHandler(rw http.ResponseWriter, req *http.Request) {
+	a.changesHandler(rw, req, "Recent changes", "Nothing has changed yet.", a.GetRecentChanges)
+}
+
+// watchlistHandler lists the recent changes to the pages the user watches.
+func (a *app) watchlistHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	a.changesHandler(rw, req, "Watchlist", "Nothing you watch has changed yet.", func(limit int, before time.Time) ([]*wiki.RevisionSummary, error) {
+		return a.GetWatchlistChanges(user.ID, limit, before)
+	})
+}
+
+// changesHandler renders a page of the revisions returned by changes, paged
+// by the limit and before query parameters, or empty if there are none.
+func (a *app) changesHandler(rw http.ResponseWriter, req *http.Request, title, empty string,
+	changes func(limit int, before time.Time) ([]*wiki.RevisionSummary, error)) {
+	limit := defaultRecentChanges
+	if s := req.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errBadLimit)
+			return
+		}
+		if limit > maxRecentChanges {
+			limit = maxRecentChanges
+		}
+	}
+
+	var before time.Time
+	if s := req.URL.Query().Get("before"); s != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, errBadBefore)
+			return
+		}
+	}
+
+	revisions, err := changes(limit, before)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	visible := make([]*wiki.RevisionSummary, 0, len(revisions))
+	for _, rev := range revisions {
+		if !rev.Pending || user.IsTrusted() {
+			visible = append(visible, rev)
+		}
+	}
+
+	other := map[string]interface{}{"Limit": limit, "Path": req.URL.Path, "Empty": empty}
+	if len(revisions) == limit {
+		other["HasNext"], other["Next"] = true, revisions[len(revisions)-1].Created.UTC().Format(time.RFC3339Nano)
+	}
+
+	err = a.RenderTemplate(rw, "special_recent_changes.html", "index.html", map[string]interface{}{
+		"Article":   map[string]string{"Title": title},
+		"Context":   req.Context(),
+		"Revisions": visible,
+		"Other":     other,
+	})
+	check(err)
+}
+
+// canView reports whether user may see article. Articles awaiting moderation
+// are only visible to trusted users.
+func canView(article *wiki.Article, user *wiki.User) bool {
+	return !article.Pending || user.IsTrusted()
+}
+
+// visible reports whether the article at url exists and may be seen by the
+// user making req.
+func (a *app) visible(url string, req *http.Request) bool {
+	article, err := a.GetArticle(url)
+	if err != nil {
+		return false
+	}
+	return canView(article, req.Context().Value(wiki.UserKey).(*wiki.User))
+}
+
+// categoriesHandler lists every category with the number of articles in it.
+func (a *app) categoriesHandler(rw http.ResponseWriter, req *http.Request) {
+	categories, err := a.GetCategories()
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_categories.html", "index.html", map[string]interface{}{
+		"Article":    map[string]string{"Title": "Categories"},
+		"Context":    req.Context(),
+		"Categories": categories,
+	})
+	check(err)
+}
+
+// categoryHandler lists the articles in the category named in the path, at
+// /wiki/Special:Category/{category}.
+func (a *app) categoryHandler(rw http.ResponseWriter, req *http.Request) {
+	category := &wiki.Category{Name: wiki.NormalizeURL(mux.Vars(req)["category"])}
+	members, err := a.GetCategoryMembers(category.Name)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	visible := make([]*wiki.ArticleSummary, 0, len(members))
+	for _, member := range members {
+		article, err := a.GetArticle(member.URL)
+		if err != nil || !canView(article, user) {
+			continue
+		}
+		visible = append(visible, member)
+	}
+	category.Members = len(visible)
+
+	// The layout doesn't escape titles, and this one comes from the URL.
+	err = a.RenderTemplate(rw, "special_category.html", "index.html", map[string]interface{}{
+		"Article":  map[string]string{"Title": "Category: " + html.EscapeString(category.Title())},
+		"Context":  req.Context(),
+		"Category": category,
+		"Members":  visible,
+	})
+	check(err)
+}
+
+var errSessionsLoggedOut = errors.New("log in to see where you are logged in")
+
+// sessionsHandler lists where the user is logged in, and logs them out of
+// the sessions they choose, or of all of them.
+func (a *app) sessionsHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		a.errorHandler(http.StatusForbidden, rw, req, errSessionsLoggedOut)
+		return
+	}
+
+	current, err := a.GetCookie(req, "periwiki-login")
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		revoked := req.PostFormValue("session")
+		switch req.PostFormValue("action") {
+		case "revoke":
+			err = a.RevokeSession(user, revoked)
+		case "revoke_all":
+			err = a.RevokeAllSessions(user)
+			revoked = current.ID
+		default:
+			err = errors.New("unknown session action")
+		}
+
+		if err == wiki.ErrGenericNotFound {
+			a.errorHandler(http.StatusNotFound, rw, req, err)
+			return
+		} else if err != nil {
+			a.errorHandler(http.StatusBadRequest, rw, req, err)
+			return
+		}
+
+		if revoked == current.ID {
+			if err := a.DeleteCookie(req, rw, current); err != nil {
+				a.errorHandler(http.StatusInternalServerError, rw, req, err)
+				return
+			}
+			http.Redirect(rw, req, "/", http.StatusSeeOther)
+			return
+		}
+		setFlash(rw, req, "Logged out of the session.")
+		http.Redirect(rw, req, req.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	sessions, err := a.GetUserSessions(user)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_sessions.html", "index.html", map[string]interface{}{
+		"Article":  map[string]string{"Title": "Sessions"},
+		"Context":  req.Context(),
+		"Sessions": sessions,
+		"Current":  current.ID,
+	})
+	check(err)
+}
+
+// rerenderRefresh is how often, in seconds, Special:RerenderAll reloads while
+// a re-render is running.
+const rerenderRefresh = 2
+
+// rerenderAllHandler starts rendering every article again and shows how far
+// the most recent re-render has got.
+func (a *app) rerenderAllHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if !user.IsAdmin() {
+		a.errorHandler(http.StatusForbidden, rw, req, errAdminOnly)
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		// The job outlives the request, so it isn't given the request's context.
+		_, err := a.RerenderAllAsync(context.Background())
+		if err == wiki.ErrRerenderRunning {
+			setFlash(rw, req, err.Error())
+		} else if err != nil {
+			a.errorHandler(http.StatusInternalServerError, rw, req, err)
+			return
+		}
+		http.Redirect(rw, req, req.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	render := map[string]interface{}{
+		"Article": map[string]string{"Title": "Re-render all articles"},
+		"Context": req.Context(),
+	}
+	if job := a.CurrentRerender(); job != nil {
+		progress := job.Progress()
+		render["Progress"] = progress
+		if !progress.Done() {
+			render["Refresh"] = rerenderRefresh
+		}
+	}
+	err := a.RenderTemplate(rw, "special_rerender_all.html", "index.html", render)
+	check(err)
+}
+
+// Page sizes for Special:AllPages.
+const (
+	defaultAllPages = 200
+	maxAllPages     = 1000
+)
+
+var errBadNamespace = errors.New("unknown namespace")
+
+// allPagesHandler lists articles alphabetically, limit at a time, starting
+// from the from parameter. The namespace parameter lists only the articles in
+// a namespace; without it, Periwiki pages are left out.
+func (a *app) allPagesHandler(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	limit := defaultAllPages
+	if s := query.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			a.errorHandler(http.StatusBadRequest, rw, req, errBadLimit)
+			return
+		}
+		if limit > maxAllPages {
+			limit = maxAllPages
+		}
+	}
+
+	namespace := ""
+	if s := query.Get("namespace"); s != "" {
+		namespace = a.ResolveNamespace(strings.TrimSuffix(s, ":") + ":")
+		known := false
+		for _, ns := range wiki.Namespaces {
+			known = known || ns == namespace
+		}
+		if !known {
+			a.errorHandler(http.StatusBadRequest, rw, req, errBadNamespace)
+			return
+		}
+	}
+
+	// Special pages aren't articles, so there's nothing to list in theirs.
+	var namespaces []string
+	for _, ns := range wiki.Namespaces {
+		if ns != wiki.SpecialNamespace {
+			namespaces = append(namespaces, strings.TrimSuffix(ns, ":"))
+		}
+	}
+
+	pages, next, err := a.GetAllPages(namespace, wiki.NormalizeURL(query.Get("from")), limit)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "special_all_pages.html", "index.html", map[string]interface{}{
+		"Article":    map[string]string{"Title": "All pages"},
+		"Context":    req.Context(),
+		"Pages":      pages,
+		"Namespaces": namespaces,
+		"Other": map[string]interface{}{
+			"Namespace": strings.TrimSuffix(namespace, ":"),
+			"From":      query.Get("from"),
+			"Limit":     limit,
+			"Next":      next,
+		},
+	})
+	check(err)
+}
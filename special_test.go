@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// TestSpecialPageListingsEscapeURLs saves articles whose URLs and link
+// targets carry markup, and checks that the Special pages listing them
+// escape it.
+func TestSpecialPageListingsEscapeURLs(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.StaleContentAge = 90 * 24 * time.Hour
+	})
+	const hostile = `X"><svg/onload=alert(1)>`
+	mustPostArticle(t, a, hostile, "No links here.", 0)
+	mustPostArticle(t, a, "Wanting", `See [[`+hostile+`Y]].`, 0)
+	mustPostArticle(t, a, hostile+"Z", "See [[Wanting]].\n\n[[Category:Hostile]]", 0)
+
+	for _, test := range []struct {
+		page, url string
+	}{
+		{"RecentChanges", hostile},
+		{"AllPages", hostile},
+		{"OrphanedPages", hostile},
+		{"DeadEndPages", hostile},
+		{"StaleContent", hostile},
+		{"WantedPages", hostile + "Y"},
+		{"Category/Hostile", hostile + "Z"},
+		{"WhatLinksHere?target=Wanting", hostile + "Z"},
+		{"WhatLinksHere?target=" + url.QueryEscape(hostile+"Y"), hostile + "Y"},
+	} {
+		body := serve(a, newRequest(http.MethodGet, "/wiki/Special:"+test.page, nil, nil)).Body.String()
+		if strings.Contains(body, "<svg") {
+			t.Errorf("%s: expected %q to be escaped:\n%s", test.page, test.url, body)
+		}
+		if !strings.Contains(body, `href="/wiki/`+url.PathEscape(test.url)+`"`) {
+			t.Errorf("%s: expected a link to %q:\n%s", test.page, test.url, body)
+		}
+	}
+}
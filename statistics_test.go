@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatistics(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "alice")
+	alice, err := a.GetUserByScreenName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := mustPostArticle(t, a, "One", "Links to [[Two]].", 0)
+	mustPostArticle(t, a, "One", "Links to [[Two]] and [[Three]].", first.ID)
+	mustPostArticle(t, a, "Two", "Links to [[One]].", 0)
+	postAs(t, a, alice, "Three", "No links.", 0)
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:Statistics", nil, nil))
+	if got := rr.Header().Get("Cache-Control"); got != "public, no-cache" {
+		t.Errorf("expected Cache-Control public, no-cache, got %q", got)
+	}
+	page := rr.Body.String()
+	for _, want := range []string{
+		"<th>Articles</th><td>3</td>",
+		"<th>Revisions</th><td>4</td>",
+		"<th>Revisions per article</th><td>1.33</td>",
+		"<th>Edits by registered users</th><td>1</td>",
+		"<th>Anonymous edits</th><td>3</td>",
+		"<th>Registered users</th><td>1</td>",
+		"<th>Links between articles</th><td>3</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the statistics to include %q, got:\n%s", want, page)
+		}
+	}
+
+	rr = serve(a, newRequest(http.MethodGet, "/wiki/Special:Statistics", nil, login(t, a, "alice")))
+	if got := rr.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("expected Cache-Control private, no-cache when logged in, got %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestNotFoundSuggestions(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.NotFoundSuggestions = 5
+	})
+	mustPostArticle(t, a, "Main_Page", "Welcome.", 0)
+	mustPostArticle(t, a, "Talk:Main_Page", "Hello.", 0)
+	mustPostArticle(t, a, "Zebras", "Stripes.", 0)
+
+	rr := serve(a, newRequest(http.MethodGet, "/wiki/Mian_Page", nil, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+
+	page := rr.Body.String()
+	if !strings.Contains(page, "Did you mean") || !strings.Contains(page, `<a href="/wiki/Main_Page">`) {
+		t.Errorf("expected Main_Page to be suggested, got:\n%s", page)
+	}
+	for _, unwanted := range []string{`href="/wiki/Talk:Main_Page"`, `href="/wiki/Zebras"`} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("expected %s not to be suggested", unwanted)
+		}
+	}
+}
+
+func TestNotFoundSuggestionsDisabled(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Main_Page", "Welcome.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Mian_Page", nil, nil)).Body.String()
+	if strings.Contains(page, "Did you mean") {
+		t.Error("expected no suggestions when disabled")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/dustin/go-humanize"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -80,6 +81,9 @@ func (t *Templater) Load(baseGlob, mainGlob string) error {
 		"pathEscape":  url.PathEscape,
 		"queryEscape": url.QueryEscape,
 		"statusText":  http.StatusText,
+		"ago":         humanize.Time,
+		"csrfToken":   csrfToken,
+		"csrfField":   csrfField,
 	}
 
 	// Generate our templates map from our layouts/ and includes/ directories
@@ -110,6 +114,22 @@ func (t *Templater) RenderTemplate(w io.Writer, name string, base string, data m
 	return tmpl.ExecuteTemplate(w, base, data)
 }
 
+// csrfToken returns the CSRF token carried by ctx, for forms that can't
+// include csrfField, such as multipart forms, to send in their query string.
+func csrfToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	token, _ := ctx.Value(wiki.CSRFTokenKey).(string)
+	return token
+}
+
+// csrfField returns a hidden input carrying the CSRF token from ctx, which
+// every POST form must include.
+func csrfField(ctx context.Context) string {
+	return fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, wiki.CSRFField, csrfToken(ctx))
+}
+
 func capitalize(s string) string {
 	if s == "" {
 		return ""
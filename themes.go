@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// themesDir holds the themes besides wiki.ThemeLight. Each is a stylesheet,
+// loaded after main.css, named after its file: dark.css is the dark theme.
+const themesDir = "static/themes"
+
+// listThemes returns the names of the themes in themesDir, after
+// wiki.ThemeLight. Themes may be added while the wiki is running.
+func listThemes() []string {
+	themes := []string{wiki.ThemeLight}
+	entries, err := os.ReadDir(themesDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return themes
+	}
+	for _, entry := range entries {
+		theme := strings.TrimSuffix(entry.Name(), ".css")
+		if entry.IsDir() || theme == entry.Name() || theme == wiki.ThemeLight || !wiki.ValidThemeName(theme) {
+			continue
+		}
+		themes = append(themes, theme)
+	}
+	return themes
+}
+
+// themeExists reports whether theme is one of listThemes.
+func themeExists(theme string) bool {
+	for _, t := range listThemes() {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// theme returns the theme of the user in ctx, which may be nil: their own,
+// else the site's ActiveTheme, else wiki.ThemeLight if neither exists.
+func (a *app) theme(ctx context.Context) string {
+	for _, theme := range []string{a.displayPreferences(ctx).Theme, a.Settings().ActiveTheme} {
+		if theme != "" && themeExists(theme) {
+			return theme
+		}
+	}
+	return wiki.ThemeLight
+}
+
+// themeStylesheet returns the URL of theme's stylesheet, or "" for
+// wiki.ThemeLight, which has none of its own.
+func themeStylesheet(theme string) string {
+	if theme == wiki.ThemeLight {
+		return ""
+	}
+	return "/" + themesDir + "/" + theme + ".css"
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestThemes(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.Theme = "dark" })
+	mustRegister(t, a, "admin")
+	mustRegister(t, a, "alice")
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	admin := login(t, a, "admin")
+	alice := login(t, a, "alice")
+
+	const dark = `<link rel="stylesheet" type="text/css" media="screen" href="/static/themes/dark.css" />`
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, `<html data-theme="dark">`) || !strings.Contains(page, dark) {
+		t.Errorf("expected the configured theme, got:\n%s", page)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/manage/settings", nil, admin)).Body.String()
+	if !strings.Contains(page, `<option value="light">Light</option>`) || !strings.Contains(page, `<option value="dark" selected>Dark</option>`) {
+		t.Errorf("expected the themes in static/themes to be offered, got:\n%s", page)
+	}
+
+	form := url.Values{"site_name": {"periwiki"}, "active_theme": {"neon"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a theme that doesn't exist, got %d", rr.Code)
+	}
+	form.Set("active_theme", "light")
+	if rr := serve(a, newRequest(http.MethodPost, "/manage/settings", form, admin)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 after saving settings, got %d: %s", rr.Code, rr.Body)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String(); strings.Contains(page, "/static/themes/") {
+		t.Errorf("expected the light theme to add no stylesheet, got:\n%s", page)
+	}
+
+	// A user's own theme wins over the site's.
+	form = url.Values{"tab_size": {"4"}, "theme": {"dark"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, alice)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected preferences to be saved, got %d", rr.Code)
+	}
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, alice)).Body.String(); !strings.Contains(page, dark) {
+		t.Errorf("expected alice's theme, got:\n%s", page)
+	}
+
+	// One that has since been removed falls back to the site's.
+	execSQL(t, a, `UPDATE UserPreference SET value = 'gone' WHERE pref_label = 'theme'`)
+	if page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, alice)).Body.String(); !strings.Contains(page, `<html data-theme="light">`) {
+		t.Errorf("expected the site's theme in place of a missing one, got:\n%s", page)
+	}
+
+	form.Set("theme", "")
+	if rr := serve(a, newRequest(http.MethodPost, "/user/settings", form, alice)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected preferences to be saved, got %d", rr.Code)
+	}
+	page = serve(a, newRequest(http.MethodGet, "/user/settings", nil, alice)).Body.String()
+	if !strings.Contains(page, `<option value="">Site default</option>`) || strings.Contains(page, `<option value="dark" selected>`) {
+		t.Errorf("expected the site default to be chosen, got:\n%s", page)
+	}
+}
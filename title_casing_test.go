@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestTitleCasing(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.TitleCasing = wiki.TitleCasingTitle })
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/my-cool-page/r/0/edit", nil, nil)).Body.String()
+	if !strings.Contains(page, `value="My Cool Page"`) {
+		t.Errorf("expected the new article to be titled My Cool Page, got:\n%s", page)
+	}
+
+	a.TitleCasing = wiki.TitleCasingFirstLetter
+	page = serve(a, newRequest(http.MethodGet, "/wiki/my-cool-page/r/0/edit", nil, nil)).Body.String()
+	if !strings.Contains(page, `value="My-cool-page"`) {
+		t.Errorf("expected only the first letter to be capitalized, got:\n%s", page)
+	}
+}
+
+func TestTitleCasingKeepsExplicitTitles(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.TitleCasing = wiki.TitleCasingTitle })
+
+	form := url.Values{"title": {"my lower-case title"}, "body": {"Hello."}, "action": {"submit"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/my-cool-page/r/0", form, nil)); rr.Code >= http.StatusBadRequest {
+		t.Fatalf("expected the article to be saved, got %d:\n%s", rr.Code, rr.Body)
+	}
+
+	article, err := a.GetArticle("my-cool-page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "my lower-case title" {
+		t.Errorf("expected the submitted title to be kept, got %q", article.Title)
+	}
+}
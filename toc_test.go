@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestTableOfContents(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.TableOfContents = true
+	})
+
+	md := "Intro\n\n## Setup\n\n### *Install*\n\n#### Linux\n\n## Duplicate\n\n## Duplicate\n\n## <img src=x onerror=alert(1)>\"'\n"
+	article := mustPostArticle(t, a, "Guide", md, 0)
+
+	toc := strings.Index(article.HTML, `<div id="toc">`)
+	if toc < 0 || toc > strings.Index(article.HTML, `<h2 id="setup">`) || toc < strings.Index(article.HTML, "Intro") {
+		t.Fatalf("expected a table of contents before the first heading:\n%s", article.HTML)
+	}
+	for _, want := range []string{`<h2 id="duplicate">`, `<h2 id="duplicate-1">`, `href="#duplicate-1"`, `href="#install"`, `href="#linux"`} {
+		if !strings.Contains(article.HTML, want) {
+			t.Errorf("expected %s:\n%s", want, article.HTML)
+		}
+	}
+	if strings.Contains(article.HTML, "<img") || strings.Contains(article.HTML, "onerror=") {
+		t.Errorf("expected markup in headings to be escaped or stripped:\n%s", article.HTML)
+	}
+
+	headings := article.Headings()
+	if len(headings) != 4 || headings[0].Text != "Setup" || len(headings[0].Children) != 1 {
+		t.Fatalf("expected four top-level headings with Setup's subsection nested, got %+v", headings)
+	}
+	install := headings[0].Children[0]
+	if install.Level != 3 || install.ID != "install" || install.Text != "Install" || len(install.Children) != 1 || install.Children[0].ID != "linux" {
+		t.Errorf("expected Install with Linux beneath it, got %+v", install)
+	}
+
+	article = mustPostArticle(t, a, "Placed", "Intro\n\n[[TOC]]\n\n## One\n\nText\n\n[[TOC]]\n", 0)
+	if strings.Count(article.HTML, `<div id="toc">`) != 1 || strings.Index(article.HTML, `<div id="toc">`) > strings.Index(article.HTML, `<h2 id="one">`) {
+		t.Errorf("expected one table of contents in place of the first placeholder:\n%s", article.HTML)
+	}
+	if strings.Contains(article.HTML, "/wiki/TOC") || len(article.Links) != 0 {
+		t.Errorf("expected placeholders not to link to an article, got links %v:\n%s", article.Links, article.HTML)
+	}
+
+	article = mustPostArticle(t, a, "Flat", "[[TOC]]\n\nNo headings here.", 0)
+	if strings.Contains(article.HTML, "toc") || strings.Contains(article.HTML, "TOC") {
+		t.Errorf("expected the placeholder to vanish without headings:\n%s", article.HTML)
+	}
+
+	plain := newTestApp(t)
+	article = mustPostArticle(t, plain, "Guide", md, 0)
+	if strings.Contains(article.HTML, `id="toc"`) {
+		t.Errorf("expected no table of contents unless configured:\n%s", article.HTML)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestEditToolbar(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.EditorToolbar = wiki.DefaultEditorToolbar })
+	mustPostArticle(t, a, "Cats", "Cats are *great*.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, nil)).Body.String()
+	for _, name := range wiki.DefaultEditorToolbar {
+		if !strings.Contains(page, `value="`+name+`"`) {
+			t.Errorf("expected a %q button on the edit form", name)
+		}
+	}
+	if !strings.Contains(page, `data-before="[["`) {
+		t.Errorf("expected the wikilink button to insert [[, got:\n%s", page)
+	}
+
+	a.EditorToolbar = []string{"bold", "wikilink"}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, nil)).Body.String()
+	if strings.Contains(page, `value="italic"`) {
+		t.Errorf("expected the disabled italic button to be omitted, got:\n%s", page)
+	}
+	if !strings.Contains(page, `value="bold"`) || !strings.Contains(page, `value="wikilink"`) {
+		t.Errorf("expected the enabled buttons to remain, got:\n%s", page)
+	}
+
+	a.EditorToolbar = nil
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats/r/1/edit", nil, nil)).Body.String()
+	if strings.Contains(page, "pw-toolbar") {
+		t.Errorf("expected no toolbar when every button is disabled, got:\n%s", page)
+	}
+}
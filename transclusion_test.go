@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func newTransclusionApp(t *testing.T) *app {
+	return newTestApp(t, func(c *wiki.Config) {
+		c.Transclusion = true
+		c.BacklinkBatchWindow = 10 * time.Millisecond
+	})
+}
+
+func TestTransclusion(t *testing.T) {
+	a := newTransclusionApp(t)
+
+	mustPostArticle(t, a, "Stub_notice", "---\ncache: short\n---\n*This article is a stub.* See [[Help]].", 0)
+	article := mustPostArticle(t, a, "Otter", "Otters swim.\n\n{{Stub notice}}\n\n`{{Stub notice}}`\n\n{{.Secret}}\n", 0)
+
+	if !strings.Contains(article.HTML, "<em>This article is a stub.</em>") || strings.Contains(article.HTML, "cache: short") {
+		t.Errorf("expected the transcluded markdown to be rendered without its frontmatter:\n%s", article.HTML)
+	}
+	if !strings.Contains(article.HTML, "<code>{{Stub notice}}</code>") {
+		t.Errorf("expected code to be left alone:\n%s", article.HTML)
+	}
+	if !strings.Contains(article.HTML, `href="/wiki/.Secret"`) || strings.Contains(article.HTML, "{{.Secret}}") {
+		t.Errorf("expected a missing article to become a link to it:\n%s", article.HTML)
+	}
+	if len(article.Links) != 2 || article.Links[0] != "Help" {
+		t.Errorf("expected links in transcluded markdown to count, got %v", article.Links)
+	}
+
+	mustPostArticle(t, a, "Stub_notice", "*Please expand this article.*", 1)
+	waitForHTML(t, a, "Otter", func(html string) bool {
+		return strings.Contains(html, "<em>Please expand this article.</em>")
+	})
+}
+
+func TestTransclusionCycles(t *testing.T) {
+	a := newTransclusionApp(t)
+
+	mustPostArticle(t, a, "A", "A then {{B}}", 0)
+	article := mustPostArticle(t, a, "B", "B then {{A}}", 0)
+	if !strings.Contains(article.HTML, "B then A then {{B}}") {
+		t.Errorf("expected expansion to stop at the cycle:\n%s", article.HTML)
+	}
+	waitForHTML(t, a, "A", func(html string) bool {
+		return strings.Contains(html, "A then B then {{A}}")
+	})
+
+	article = mustPostArticle(t, a, "C", "{{C}} {{C}}", 0)
+	if !strings.Contains(article.HTML, "<p>{{C}} {{C}}</p>") {
+		t.Errorf("expected an article transcluding itself to be shown as written:\n%s", article.HTML)
+	}
+
+	article = mustPostArticle(t, a, "Twice", "{{B}} and {{B}}", 0)
+	if strings.Count(article.HTML, "B then A then") != 2 {
+		t.Errorf("expected an article transcluded twice to be expanded twice:\n%s", article.HTML)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+// ArticleURLMiddleware permanently redirects GET requests for article routes
+// whose {article} is not in canonical form (see wiki.NormalizeURL), including
+// a namespace written in another case or by an alias, preserving the rest of
+// the path and the query string.
+func (a *app) ArticleURLMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		article, ok := mux.Vars(req)["article"]
+		if !ok || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+
+		canonical := a.ResolveNamespace(wiki.NormalizeURL(article))
+		prefix := "/wiki/" + article
+		if canonical == article || !strings.HasPrefix(req.URL.Path, prefix) {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+
+		location := &url.URL{
+			Path:     "/wiki/" + canonical + strings.TrimPrefix(req.URL.Path, prefix),
+			RawQuery: req.URL.RawQuery,
+		}
+		http.Redirect(rw, req, location.String(), http.StatusMovedPermanently)
+	})
+}
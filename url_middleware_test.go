@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestArticleURLNormalization(t *testing.T) {
+	a := newTestApp(t)
+	mustPostArticle(t, a, "Café", "Coffee and pastries.", 0)
+
+	tests := []struct {
+		name     string
+		path     string
+		location string
+	}{
+		{name: "encoded", path: "/wiki/Caf%C3%A9"},
+		{name: "decoded", path: "/wiki/Café"},
+		{name: "decomposed", path: "/wiki/Cafe%CC%81", location: "/wiki/Caf%C3%A9"},
+		{name: "spaces", path: "/wiki/Caf%C3%A9%20Au%20Lait", location: "/wiki/Caf%C3%A9_Au_Lait"},
+		{name: "padded", path: "/wiki/%20Caf%C3%A9%20", location: "/wiki/Caf%C3%A9"},
+		{name: "subpath and query", path: "/wiki/Cafe%CC%81/history?limit=5", location: "/wiki/Caf%C3%A9/history?limit=5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := serve(a, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if test.location == "" {
+				if rr.Code != http.StatusOK {
+					t.Fatalf("expected 200 for canonical URL, got %d", rr.Code)
+				}
+				return
+			}
+
+			if rr.Code != http.StatusMovedPermanently {
+				t.Fatalf("expected 301, got %d", rr.Code)
+			}
+			if got := rr.Header().Get("Location"); got != test.location {
+				t.Errorf("expected redirect to %q, got %q", test.location, got)
+			}
+		})
+	}
+}
+
+func TestNamespaceAliases(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.NamespaceAliases = map[string]string{"Discussion": "Talk"}
+	})
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	mustPostArticle(t, a, "Talk:Cats", "Are they?", 0)
+
+	for _, path := range []string{"/wiki/talk:Cats", "/wiki/TALK:Cats", "/wiki/Discussion:Cats", "/wiki/discussion:Cats"} {
+		rr := serve(a, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/wiki/Talk:Cats" {
+			t.Errorf("%s: expected a redirect to /wiki/Talk:Cats, got %d %q", path, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	rr := serve(a, httptest.NewRequest(http.MethodGet, "/wiki/special:StaleContent", nil))
+	if rr.Header().Get("Location") != "/wiki/Special:StaleContent" {
+		t.Errorf("expected Special pages to resolve ignoring case, got %q", rr.Header().Get("Location"))
+	}
+
+	// Colons outside a known namespace are just part of the title.
+	if rr := serve(a, httptest.NewRequest(http.MethodGet, "/wiki/Cats:_A_History", nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown prefix to be looked up as an article, got %d", rr.Code)
+	}
+
+	article := wiki.NewArticle("discussion:Cats", "Talk:Cats", "Yes.")
+	article.Creator = wiki.AnonymousUser()
+	article.PreviousID = 1
+	if err := a.PostArticle(article); err != nil {
+		t.Fatal(err)
+	}
+	if article.URL != "Talk:Cats" {
+		t.Errorf("expected edits to be saved under the canonical namespace, got %q", article.URL)
+	}
+}
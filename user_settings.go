@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+// userSettingsHandler shows the logged in user's editor and display
+// preferences.
+func (a *app) userSettingsHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	prefs, err := a.GetEditorPreferences(user)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	display, err := a.GetDisplayPreferences(user)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	notify, err := a.NotifyWatchedEdits(user)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	err = a.RenderTemplate(rw, "user_settings.html", "index.html", map[string]interface{}{
+		"Article": map[string]string{"Title": "Settings"},
+		"Context": req.Context(),
+		"Editor":  prefs,
+		"Display": display,
+		"Themes":  listThemes(),
+		"Notify":  notify,
+	})
+	check(err)
+}
+
+func (a *app) userSettingsPostHandler(rw http.ResponseWriter, req *http.Request) {
+	user := req.Context().Value(wiki.UserKey).(*wiki.User)
+	if user.IsAnonymous() {
+		http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	tabSize, err := strconv.Atoi(req.PostFormValue("tab_size"))
+	if err != nil {
+		a.errorHandler(http.StatusBadRequest, rw, req, wiki.ErrBadTabSize)
+		return
+	}
+
+	err = a.UpdateEditorPreferences(user, wiki.EditorPreferences{
+		PreviewByDefault: req.PostFormValue("preview_by_default") != "",
+		Monospace:        req.PostFormValue("monospace") != "",
+		TabSize:          tabSize,
+		ShowToolbar:      req.PostFormValue("show_toolbar") != "",
+	})
+	if err == wiki.ErrBadTabSize {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	// Display preferences left out of the form are kept as they were.
+	display, err := a.GetDisplayPreferences(user)
+	if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+	if theme, ok := req.PostForm["theme"]; ok {
+		if theme[0] != "" && !themeExists(theme[0]) {
+			a.errorHandler(http.StatusBadRequest, rw, req, wiki.ErrBadTheme)
+			return
+		}
+		display.Theme = theme[0]
+	}
+	if mode, ok := req.PostForm["diff_mode"]; ok {
+		display.DiffMode = mode[0]
+	}
+	err = a.UpdateDisplayPreferences(user, display)
+	if err == wiki.ErrBadTheme || err == wiki.ErrBadDiffMode {
+		a.errorHandler(http.StatusBadRequest, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	if err := a.UpdateNotifyWatchedEdits(user, req.PostFormValue("notify_watched_edits") != ""); err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	http.Redirect(rw, req, "/user/settings", http.StatusSeeOther)
+}
+
+// editorPreferences returns the editor preferences of the user making req,
+// logging any error and falling back to the site defaults.
+func (a *app) editorPreferences(req *http.Request) wiki.EditorPreferences {
+	prefs, err := a.GetEditorPreferences(req.Context().Value(wiki.UserKey).(*wiki.User))
+	check(err)
+	return prefs
+}
+
+// displayPreferences returns the display preferences of the user in ctx,
+// which may be nil, logging any error and falling back to the site defaults.
+func (a *app) displayPreferences(ctx context.Context) wiki.DisplayPreferences {
+	if ctx == nil {
+		return a.DisplayDefaults()
+	}
+	user, ok := ctx.Value(wiki.UserKey).(*wiki.User)
+	if !ok {
+		return a.DisplayDefaults()
+	}
+	prefs, err := a.GetDisplayPreferences(user)
+	check(err)
+	return prefs
+}
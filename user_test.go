@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestCaseInsensitiveUsernames(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.CaseInsensitiveUsernames = true
+	})
+	mustRegister(t, a, "Alice")
+
+	variant := &wiki.User{ScreenName: "alice", Email: "other@example.com", RawPassword: testPassword}
+	if err := a.PostUser(variant); err != wiki.ErrUsernameTaken {
+		t.Fatalf("expected ErrUsernameTaken for a case variant, got %v", err)
+	}
+
+	user := &wiki.User{ScreenName: "ALICE", RawPassword: testPassword}
+	if err := a.CheckUserPassword(user); err != nil {
+		t.Fatalf("expected login to ignore case, got %v", err)
+	}
+	if user.ScreenName != "Alice" {
+		t.Errorf("expected the stored casing to be preserved, got %q", user.ScreenName)
+	}
+
+	if cookie := login(t, a, "aLiCe"); cookie == nil {
+		t.Error("expected a session cookie")
+	}
+}
+
+func TestCaseSensitiveUsernames(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "Alice")
+	mustRegister(t, a, "alice")
+}
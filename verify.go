@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/danielledeleo/periwiki/wiki"
+	"github.com/gorilla/mux"
+)
+
+// verifyEmailHandler marks the email address of the user the link was sent
+// to as verified, and sends them to log in.
+func (a *app) verifyEmailHandler(rw http.ResponseWriter, req *http.Request) {
+	screenname, err := a.VerifyEmail(mux.Vars(req)["token"])
+	if err == wiki.ErrBadVerificationToken {
+		a.errorHandler(http.StatusNotFound, rw, req, err)
+		return
+	} else if err != nil {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	setFlash(rw, req, "Thanks, "+screenname+". Your email address is verified and you can now log in.")
+	http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+}
+
+// resendVerificationHandler sends a new verification link to a user who
+// couldn't log in because their email address isn't verified. The response is
+// the same whether or not the user exists, so it can't be used to find out.
+func (a *app) resendVerificationHandler(rw http.ResponseWriter, req *http.Request) {
+	err := a.ResendVerification(req.PostFormValue("screenname"))
+	if err != nil && err != wiki.ErrUsernameNotFound {
+		a.errorHandler(http.StatusInternalServerError, rw, req, err)
+		return
+	}
+
+	if a.MailsVerification() {
+		setFlash(rw, req, "If that account's email address isn't verified yet, a new verification link has been sent to it.")
+	} else {
+		setFlash(rw, req, "If that account's email address isn't verified yet, a new verification link has been issued. Ask an administrator for it.")
+	}
+	http.Redirect(rw, req, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestEmailVerification(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RequireEmailVerification = true
+		c.RequireLoginToEdit = true
+	})
+	links := map[string]string{}
+	a.SendVerification = func(user *wiki.User, link string) error {
+		links[user.ScreenName] = link
+		return nil
+	}
+
+	if admin := mustRegister(t, a, "admin"); admin.EmailVerified {
+		t.Error("expected the first user to need verifying too")
+	}
+	if _, ok := links["admin"]; !ok {
+		t.Error("expected a verification link to be sent to the first user")
+	}
+	mustRegister(t, a, "bob")
+	first, ok := links["bob"]
+	if !ok {
+		t.Fatal("expected a verification link to be sent to bob")
+	}
+
+	form := url.Values{"screenname": {"bob"}, "password": {testPassword}}
+	rr := serve(a, newRequest(http.MethodPost, "/user/login", form, nil))
+	if page := rr.Body.String(); !strings.Contains(page, wiki.ErrEmailNotVerified.Error()) || !strings.Contains(page, `action="/user/verify"`) {
+		t.Fatalf("expected an unverified user to be refused with a resend form, got:\n%s", page)
+	}
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "periwiki-login" {
+			t.Error("expected no login cookie for an unverified user")
+		}
+	}
+
+	// A session from before verification was required still can't edit.
+	requireVerification := func(require bool) {
+		settings := a.Settings()
+		settings.RequireEmailVerification = require
+		if err := a.UpdateSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requireVerification(false)
+	bob := login(t, a, "bob")
+	requireVerification(true)
+	mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	form = url.Values{"title": {"Cats"}, "body": {"Cats are great."}, "action": {"submit"}}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1", form, bob)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected an unverified user's edit to be refused, got %d", rr.Code)
+	}
+
+	rr = serve(a, newRequest(http.MethodPost, "/user/verify", url.Values{"screenname": {"bob"}}, nil))
+	if rr.Code != http.StatusSeeOther || links["bob"] == first {
+		t.Fatalf("expected a new link to be sent, got %d", rr.Code)
+	}
+	unknown := serve(a, newRequest(http.MethodPost, "/user/verify", url.Values{"screenname": {"nobody"}}, nil))
+	if unknown.Code != rr.Code || unknown.Header().Get("Location") != rr.Header().Get("Location") {
+		t.Errorf("expected an unknown user to get the same response, got %d", unknown.Code)
+	}
+	if rr := serve(a, newRequest(http.MethodGet, first, nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the replaced link to be rejected, got %d", rr.Code)
+	}
+
+	rr = serve(a, newRequest(http.MethodGet, links["bob"], nil, nil))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Fatalf("expected verifying to redirect to the login page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := serve(a, newRequest(http.MethodGet, links["bob"], nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected a used link to be rejected, got %d", rr.Code)
+	}
+
+	bob = login(t, a, "bob")
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats/r/1", form, bob)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected a verified user to be able to edit, got %d", rr.Code)
+	}
+}
+
+func TestEmailVerificationNotRequired(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+	if user := mustRegister(t, a, "bob"); user.EmailVerified {
+		t.Error("expected a new user to be unverified")
+	}
+	// Unverified users can log in while verification isn't required.
+	login(t, a, "bob")
+}
+
+func TestLoginErrorShown(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "admin")
+
+	form := url.Values{"screenname": {`"><script>alert(1)</script>`}, "password": {testPassword}}
+	page := serve(a, newRequest(http.MethodPost, "/user/login", form, nil)).Body.String()
+	if !strings.Contains(page, wiki.ErrUsernameNotFound.Error()) {
+		t.Errorf("expected the login error to be shown, got:\n%s", page)
+	}
+	if strings.Contains(page, "<script>alert") {
+		t.Error("expected the submitted username to be escaped")
+	}
+}
+
+func TestEmailVerificationExpires(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) {
+		c.RequireEmailVerification = true
+	})
+	links := map[string]string{}
+	a.SendVerification = func(user *wiki.User, link string) error {
+		links[user.ScreenName] = link
+		return nil
+	}
+
+	mustRegister(t, a, "bob")
+	execSQL(t, a, `UPDATE User SET verification_expires = ? WHERE screenname = 'bob'`, time.Now().Add(-time.Minute).UTC())
+	if rr := serve(a, newRequest(http.MethodGet, links["bob"], nil, nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected an expired link to be rejected, got %d", rr.Code)
+	}
+
+	serve(a, newRequest(http.MethodPost, "/user/verify", url.Values{"screenname": {"bob"}}, nil))
+	if rr := serve(a, newRequest(http.MethodGet, links["bob"], nil, nil)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected a fresh link to work, got %d", rr.Code)
+	}
+}
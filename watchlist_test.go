@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/wiki"
+)
+
+func TestWatchlist(t *testing.T) {
+	a := newTestApp(t)
+	mustRegister(t, a, "bob")
+	bob := login(t, a, "bob")
+	cats := mustPostArticle(t, a, "Cats", "Cats are nice.", 0)
+	dogs := mustPostArticle(t, a, "Dogs", "Dogs are nice.", 0)
+
+	page := serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, nil)).Body.String()
+	if !strings.Contains(page, `<a href="/user/login">Log in</a> to watch this page.`) {
+		t.Errorf("expected anonymous users to be asked to log in, got:\n%s", page)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?watch", nil, nil)); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("expected anonymous users to be sent to log in, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := serve(a, newRequest(http.MethodGet, "/wiki/Special:Watchlist", nil, nil)); rr.Code != http.StatusSeeOther {
+		t.Errorf("expected the anonymous watchlist to redirect to the login page, got %d", rr.Code)
+	}
+
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, bob)).Body.String()
+	if !strings.Contains(page, `action="/wiki/Cats?watch"`) {
+		t.Errorf("expected a watch button, got:\n%s", page)
+	}
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?watch", nil, bob)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after watching, got %d", rr.Code)
+	}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Cats", nil, bob)).Body.String()
+	if !strings.Contains(page, `action="/wiki/Cats?unwatch"`) {
+		t.Errorf("expected an unwatch button, got:\n%s", page)
+	}
+
+	mustPostArticle(t, a, "Cats", "Cats are very nice.", cats.ID)
+	mustPostArticle(t, a, "Dogs", "Dogs are very nice.", dogs.ID)
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Watchlist", nil, bob)).Body.String()
+	if !strings.Contains(page, `<a href="/wiki/Cats">Cats</a>`) || strings.Contains(page, `<a href="/wiki/Dogs">Dogs</a>`) {
+		t.Errorf("expected only changes to watched pages, got:\n%s", page)
+	}
+
+	if rr := serve(a, newRequest(http.MethodPost, "/wiki/Cats?unwatch", nil, bob)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after unwatching, got %d", rr.Code)
+	}
+	page = serve(a, newRequest(http.MethodGet, "/wiki/Special:Watchlist", nil, bob)).Body.String()
+	if !strings.Contains(page, "Nothing you watch has changed yet.") {
+		t.Errorf("expected an empty watchlist, got:\n%s", page)
+	}
+}
+
+func TestWatchNotifications(t *testing.T) {
+	a := newTestApp(t, func(c *wiki.Config) { c.WatchNotificationDelay = 50 * time.Millisecond })
+	alice := mustRegister(t, a, "alice")
+	bob := mustRegister(t, a, "bob")
+	carol := mustRegister(t, a, "carol")
+
+	type sent struct {
+		to           string
+		notification *wiki.WatchNotification
+	}
+	notifications := make(chan sent, 10)
+	a.SendWatchNotification = func(user *wiki.User, notification *wiki.WatchNotification) error {
+		notifications <- sent{user.ScreenName, notification}
+		return nil
+	}
+
+	edit := func(user *wiki.User, markdown string, previousID int) {
+		t.Helper()
+		article := wiki.NewArticle("Cats", "Cats", markdown)
+		article.Creator = user
+		article.PreviousID = previousID
+		article.Comment = "Edit by " + user.ScreenName
+		if err := a.PostArticle(article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edit(alice, "Cats.", 0)
+	// Let the batch for the creation, which nobody watched, go by.
+	time.Sleep(100 * time.Millisecond)
+	for _, user := range []*wiki.User{bob, carol} {
+		if err := a.Watch(user.ID, "Cats"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.UpdateNotifyWatchedEdits(bob, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Quick successive edits are sent together, and only to watchers who
+	// opted in.
+	edit(alice, "Cats are nice.", 1)
+	edit(alice, "Cats are very nice.", 2)
+	select {
+	case got := <-notifications:
+		n := got.notification
+		if got.to != "bob" || n.Edits != 2 || n.Delta != 14 || n.DiffLink != "/wiki/Cats/diff/1/3" ||
+			!reflect.DeepEqual(n.Editors, []string{"alice"}) || len(n.Comments) != 2 {
+			t.Errorf("unexpected notification to %s: %+v", got.to, n)
+		}
+		if summary := n.Summary(); summary != "Cats was edited 2 times by alice (+14 bytes)" {
+			t.Errorf("unexpected summary %q", summary)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected bob to be notified")
+	}
+
+	// Nobody is told of their own edits.
+	edit(bob, "Cats are very nice indeed.", 3)
+	select {
+	case got := <-notifications:
+		t.Errorf("expected no notification, got one to %s: %+v", got.to, got.notification)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,80 @@
+package wiki
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+)
+
+// anchorsIndexedPref records that IndexAnchors has run, so it runs once.
+const anchorsIndexedPref = "anchors_indexed"
+
+// hasAnchor reports whether the article at url has a section with the id
+// anchor. Lookups that fail count as found, so links aren't marked dead on
+// an error.
+func (model *WikiModel) hasAnchor(url, anchor string) bool {
+	anchors, err := model.db.SelectAnchors(url)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+	i := sort.SearchStrings(anchors, anchor)
+	return i < len(anchors) && anchors[i] == anchor
+}
+
+// anchorsChanged reports whether article, about to be saved, has different
+// sections from those recorded for it.
+func (model *WikiModel) anchorsChanged(article *Article) bool {
+	before, err := model.db.SelectAnchors(article.URL)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	after := append([]string(nil), article.Anchors...)
+	sort.Strings(after)
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexAnchors records the section ids of every article, for wikis upgraded
+// from a version that didn't keep them. It does nothing once it has run,
+// and returns how many articles it indexed.
+func (model *WikiModel) IndexAnchors() (int, error) {
+	if _, err := model.GetPreference(anchorsIndexedPref); err == nil {
+		return 0, nil
+	} else if err != ErrGenericNotFound {
+		return 0, err
+	}
+
+	urls, err := model.db.SelectArticleURLs()
+	if err != nil {
+		return 0, err
+	}
+	for _, url := range urls {
+		if IsSiteCode(url) {
+			continue
+		}
+		article, err := model.db.SelectArticle(url)
+		if err != nil {
+			return 0, err
+		}
+		_, body := ParseFrontmatter(article.Markdown)
+		if err := model.db.UpdateAnchors(url, model.renderer.Anchors(url, body)); err != nil {
+			return 0, err
+		}
+	}
+
+	err = model.UpdatePreference(&Preference{
+		Label:    anchorsIndexedPref,
+		Type:     IntPref,
+		IntValue: sql.NullInt64{Int64: 1, Valid: true},
+	})
+	return len(urls), err
+}
@@ -1,10 +1,64 @@
 package wiki
 
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/danielledeleo/periwiki/render"
+)
+
+// Namespace prefixes recognised in article URLs.
+const (
+	TalkNamespace     = "Talk:"
+	SpecialNamespace  = "Special:"
+	PeriwikiNamespace = "Periwiki:"
+)
+
+// MainPageURL is the wiki's entry point, which needn't be linked to.
+const MainPageURL = "Main_Page"
+
+// Site code pages in the Periwiki namespace are injected into every page.
+const (
+	CommonCSSURL = PeriwikiNamespace + "Common.css"
+	CommonJSURL  = PeriwikiNamespace + "Common.js"
+)
+
 type Article struct {
 	URL string
+	// Pending is set while a moderated article awaits review.
+	Pending bool `db:"pending"`
+	// ReviewedAt is when the article's content was last confirmed to be
+	// current, either in its frontmatter or by an administrator.
+	ReviewedAt sql.NullTime `db:"reviewed_at"`
+	// Links holds the URLs of the articles this one WikiLinks to. It is
+	// filled in by PostArticle and not loaded from the database.
+	Links []string
+	// Embeds holds the URLs of the articles this one embeds as navboxes. Like
+	// Links, it is only filled in by PostArticle.
+	Embeds []string
+	// Categories holds the names of the categories the article is in. Like
+	// Links, it is only filled in by PostArticle.
+	Categories []string
+	// Anchors holds the ids of the article's headings and definition terms,
+	// which WikiLinks to its sections name. Like Links, it is only filled in
+	// by PostArticle.
+	Anchors []string
+	// Shingles holds the hashes of the article's word shingles, indexed for
+	// FindSimilarContent. Like Links, it is only filled in by PostArticle.
+	Shingles []int64
+	// Blanking is set when the editor means to empty an existing page, which
+	// is otherwise refused with ErrEmptyArticle.
+	Blanking bool
 	*Revision
 }
 
+// ArticleSummary identifies an article without loading its content.
+type ArticleSummary struct {
+	URL   string `db:"url"`
+	Title string `db:"title"`
+}
+
 func NewArticle(url, title, markdownBody string) *Article {
 	article := &Article{URL: url, Revision: &Revision{}}
 	article.Title = title
@@ -12,3 +66,67 @@ func NewArticle(url, title, markdownBody string) *Article {
 
 	return article
 }
+
+// RenderFailed reports whether the article's HTML couldn't be rendered in
+// the background.
+func (article *Article) RenderFailed() bool {
+	return article.Revision != nil && article.HTML == RenderFailedHTML
+}
+
+// RenderPending reports whether the article's HTML is still being rendered in
+// the background.
+func (article *Article) RenderPending() bool {
+	return article.Revision != nil && article.HTML == RenderPendingHTML
+}
+
+// DiffURL returns the URL of the diff introduced by the article's revision,
+// prefixed with baseURL, e.g. "https://wiki.example.com" or "" for a relative
+// URL. A revision that created the article has nothing to diff against, so
+// the article itself is linked instead.
+func (article *Article) DiffURL(baseURL string) string {
+	if article.PreviousID == 0 {
+		return baseURL + "/wiki/" + article.URL
+	}
+	return fmt.Sprintf("%s/wiki/%s/diff/%d/%d", baseURL, article.URL, article.PreviousID, article.ID)
+}
+
+// Headings returns the tree of the article's section headings, for templates
+// to build their own table of contents from.
+func (article *Article) Headings() []*render.Heading {
+	if article.Revision == nil || article.RenderPending() {
+		return nil
+	}
+	return render.Headings(article.HTML)
+}
+
+// IsTalkPage reports whether url belongs to the Talk namespace.
+func IsTalkPage(url string) bool {
+	return strings.HasPrefix(url, TalkNamespace)
+}
+
+// IsSpecialPage reports whether url belongs to the Special namespace.
+func IsSpecialPage(url string) bool {
+	return strings.HasPrefix(url, SpecialNamespace)
+}
+
+// IsPeriwikiPage reports whether url belongs to the Periwiki namespace, which
+// holds pages configuring the wiki itself.
+func IsPeriwikiPage(url string) bool {
+	return strings.HasPrefix(url, PeriwikiNamespace)
+}
+
+// IsSiteCode reports whether url is a site CSS or JavaScript page, whose
+// content is code rather than Markdown.
+func IsSiteCode(url string) bool {
+	return url == CommonCSSURL || url == CommonJSURL
+}
+
+// TalkPageURL returns the URL of the talk page for the subject article at url.
+func TalkPageURL(url string) string {
+	return TalkNamespace + url
+}
+
+// SubjectURL returns the URL of the article discussed by the talk page at url.
+func SubjectURL(url string) string {
+	return strings.TrimPrefix(url, TalkNamespace)
+}
@@ -0,0 +1,26 @@
+package wiki
+
+import "testing"
+
+func TestDiffURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		previousID int
+		id         int
+		baseURL    string
+		want       string
+	}{
+		{"edit", 3, 4, "", "/wiki/Go_(language)/diff/3/4"},
+		{"absolute edit", 1, 2, "https://wiki.example.com", "https://wiki.example.com/wiki/Go_(language)/diff/1/2"},
+		{"creation", 0, 1, "", "/wiki/Go_(language)"},
+		{"absolute creation", 0, 1, "https://wiki.example.com", "https://wiki.example.com/wiki/Go_(language)"},
+	}
+
+	for _, test := range tests {
+		article := NewArticle("Go_(language)", "Go (language)", "")
+		article.ID, article.PreviousID = test.id, test.previousID
+		if got := article.DiffURL(test.baseURL); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
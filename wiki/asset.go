@@ -0,0 +1,18 @@
+package wiki
+
+// Asset is a file uploaded by an admin, such as the site favicon or logo.
+type Asset struct {
+	Name        string `db:"name"`
+	ContentType string `db:"content_type"`
+	Data        []byte `db:"data"`
+}
+
+// SaveAsset stores asset, replacing any existing asset of the same name.
+func (model *WikiModel) SaveAsset(asset *Asset) error {
+	return model.db.InsertAsset(asset)
+}
+
+// GetAsset returns the asset called name.
+func (model *WikiModel) GetAsset(name string) (*Asset, error) {
+	return model.db.SelectAsset(name)
+}
@@ -0,0 +1,352 @@
+package wiki
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/danielledeleo/periwiki/render"
+	"github.com/danielledeleo/periwiki/renderqueue"
+)
+
+// GetBacklinks returns every article that WikiLinks to target, ordered by URL.
+// target is normalized, so "Target B" finds links to Target_B.
+func (model *WikiModel) GetBacklinks(target string) ([]*ArticleSummary, error) {
+	return model.db.SelectBacklinks(model.canonicalURL(target))
+}
+
+// GetOutboundDeadlinks returns the URLs, in order, of the articles that the
+// article at url WikiLinks to but which don't exist yet.
+func (model *WikiModel) GetOutboundDeadlinks(url string) ([]string, error) {
+	return model.db.SelectOutboundDeadlinks(url)
+}
+
+// GetBacklinksPaged returns at most limit of the articles that WikiLinks to
+// target, starting at offset, along with the total number of them. Pages are
+// ordered by URL so consecutive pages neither overlap nor skip articles.
+func (model *WikiModel) GetBacklinksPaged(target string, limit, offset int) ([]*ArticleSummary, int, error) {
+	return model.db.SelectBacklinksPaged(model.canonicalURL(target), limit, offset)
+}
+
+// WantedPage is an article that doesn't exist but is linked to.
+type WantedPage struct {
+	URL string `db:"target"`
+	// References counts the articles linking to it.
+	References int `db:"refs"`
+}
+
+// GetWantedPages returns the articles that are linked to but don't exist,
+// most linked to first.
+func (model *WikiModel) GetWantedPages() ([]WantedPage, error) {
+	return model.db.SelectWantedPages()
+}
+
+// GetOrphanedArticles returns the articles no other article links to or
+// embeds. The main page, talk pages and Periwiki pages are reached by other
+// means, so they are left out.
+func (model *WikiModel) GetOrphanedArticles() ([]*ArticleSummary, error) {
+	articles, err := model.db.SelectOrphanedArticles()
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := articles[:0]
+	for _, article := range articles {
+		if article.URL != MainPageURL && !IsTalkPage(article.URL) && !IsPeriwikiPage(article.URL) {
+			orphans = append(orphans, article)
+		}
+	}
+	return orphans, nil
+}
+
+// GetDeadEndArticles returns the articles that link to no other article.
+// Periwiki pages, which configure the wiki, are left out.
+func (model *WikiModel) GetDeadEndArticles() ([]*ArticleSummary, error) {
+	articles, err := model.db.SelectDeadEndArticles()
+	if err != nil {
+		return nil, err
+	}
+
+	deadEnds := articles[:0]
+	for _, article := range articles {
+		if !IsPeriwikiPage(article.URL) {
+			deadEnds = append(deadEnds, article)
+		}
+	}
+	return deadEnds, nil
+}
+
+// BacklinksPerPage is BacklinksPageSize, or 100 when it isn't set.
+func (model *WikiModel) BacklinksPerPage() int {
+	if model.BacklinksPageSize > 0 {
+		return model.BacklinksPageSize
+	}
+	return 100
+}
+
+// InvalidateBacklinkersAsync schedules a background re-render of every
+// article linking to target, e.g. after target is created or deleted so its
+// dead-link styling is refreshed. Calls made within BacklinkBatchWindow of
+// the first are coalesced into a single pass, and an article linking to
+// several of the targets is only queued once.
+func (model *WikiModel) InvalidateBacklinkersAsync(target string) {
+	model.invalidateMu.Lock()
+	defer model.invalidateMu.Unlock()
+
+	model.scheduleInvalidations()
+	model.invalidateTargets[target] = true
+}
+
+// InvalidateEmbeddersAsync schedules a background re-render of every article
+// embedding target as a navbox, e.g. after target is edited. It is batched
+// along with InvalidateBacklinkersAsync.
+func (model *WikiModel) InvalidateEmbeddersAsync(target string) {
+	model.invalidateMu.Lock()
+	defer model.invalidateMu.Unlock()
+
+	model.scheduleInvalidations()
+	model.invalidateEmbedded[target] = true
+}
+
+// scheduleInvalidations starts a new batch of invalidations unless one is
+// already pending. invalidateMu must be held.
+func (model *WikiModel) scheduleInvalidations() {
+	if model.invalidateTargets == nil {
+		model.invalidateTargets = make(map[string]bool)
+		model.invalidateEmbedded = make(map[string]bool)
+		model.invalidateTimer = time.AfterFunc(model.BacklinkBatchWindow, model.FlushBacklinkInvalidations)
+	}
+}
+
+// FlushBacklinkInvalidations queues the re-renders of the pending batch of
+// invalidations now, rather than once BacklinkBatchWindow has passed.
+func (model *WikiModel) FlushBacklinkInvalidations() {
+	model.invalidateMu.Lock()
+	targets, embedded := model.invalidateTargets, model.invalidateEmbedded
+	model.invalidateTargets, model.invalidateEmbedded = nil, nil
+	if model.invalidateTimer != nil {
+		model.invalidateTimer.Stop()
+		model.invalidateTimer = nil
+	}
+	model.invalidateMu.Unlock()
+
+	queued := make(map[string]bool)
+	limit := model.BacklinksPerPage()
+	for target := range targets {
+		for offset := 0; ; offset += limit {
+			backlinks, total, err := model.GetBacklinksPaged(target, limit, offset)
+			if err != nil {
+				log.Println(err)
+				break
+			}
+
+			for _, backlink := range backlinks {
+				model.queueRerender(backlink.URL, queued)
+			}
+
+			if len(backlinks) == 0 || offset+len(backlinks) >= total {
+				break
+			}
+		}
+	}
+
+	for target := range embedded {
+		embedders, err := model.db.SelectEmbedders(target)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, embedder := range embedders {
+			model.queueRerender(embedder.URL, queued)
+		}
+	}
+}
+
+// queueRerender re-renders the article at url in the background unless
+// queued shows it already was.
+func (model *WikiModel) queueRerender(url string, queued map[string]bool) {
+	if queued[url] {
+		return
+	}
+	queued[url] = true
+
+	if model.queue != nil {
+		model.queue.Submit(url, renderqueue.TierBackground)
+	} else if err := model.rerenderArticle(url); err != nil {
+		log.Println(err)
+	}
+}
+
+// rerenderArticle renders the head revision of the article at url again,
+// storing the result if it changed. Articles embedding one whose HTML changed
+// are re-rendered in turn.
+func (model *WikiModel) rerenderArticle(url string) error {
+	article, err := model.db.SelectArticle(url)
+	if err != nil {
+		return err
+	}
+
+	before := article.HTML
+	if err := model.rerenderRevision(article); err != nil {
+		return err
+	}
+	if article.HTML != before {
+		model.InvalidateEmbeddersAsync(url)
+	}
+	return nil
+}
+
+// renderQueued is the render queue's RenderFunc. It renders the revision
+// like rerenderRevision, or the article's head revision if revisionID is 0.
+// If the render fails, or ctx is done first, it saves the revision with
+// RenderFailedHTML and returns, freeing the worker. A render that finishes
+// after that is discarded.
+func (model *WikiModel) renderQueued(ctx context.Context, url string, revisionID int) error {
+	var (
+		article *Article
+		err     error
+	)
+	if revisionID == 0 {
+		article, err = model.db.SelectArticle(url)
+	} else {
+		article, err = model.db.SelectArticleByRevisionID(url, revisionID)
+	}
+	if err != nil {
+		return err
+	}
+
+	type outcome struct {
+		html      string
+		err       error
+		recovered interface{}
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{recovered: r}
+			}
+		}()
+		html, err := model.renderArticle(article.URL, article.Markdown)
+		done <- outcome{html: html, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		// Let the queue recover the panic as though it were its own.
+		if o.recovered != nil {
+			model.markRenderFailed(article)
+			panic(o.recovered)
+		}
+		if o.err != nil {
+			model.markRenderFailed(article)
+			return o.err
+		}
+		before := article.HTML
+		if err := model.storeRender(article, o.html); err != nil {
+			return err
+		}
+		if article.HTML != before {
+			model.InvalidateEmbeddersAsync(url)
+		}
+		return nil
+	case <-ctx.Done():
+		model.markRenderFailed(article)
+		return fmt.Errorf("render of %s stopped after %s: %w", url, model.Settings().MaxRenderDuration, ctx.Err())
+	}
+}
+
+// markRenderFailed saves article's revision with RenderFailedHTML, so
+// readers aren't left waiting on a render that won't come.
+func (model *WikiModel) markRenderFailed(article *Article) {
+	if err := model.db.UpdateRevisionHTML(article.URL, article.ID, RenderFailedHTML, ""); err != nil {
+		log.Println(err)
+	}
+}
+
+// rerenderRevision renders article's revision again, storing the result if it
+// or the renderer's fingerprint changed.
+func (model *WikiModel) rerenderRevision(article *Article) error {
+	html, err := model.renderArticle(article.URL, article.Markdown)
+	if err != nil {
+		return err
+	}
+	return model.storeRender(article, html)
+}
+
+// storeRender stores html as the HTML of article's revision if it or the
+// renderer's fingerprint changed.
+func (model *WikiModel) storeRender(article *Article, html string) error {
+	fingerprint := model.renderer.Fingerprint()
+	if html == article.HTML && fingerprint == article.RenderFingerprint {
+		return nil
+	}
+	article.HTML, article.RenderFingerprint = html, fingerprint
+	return model.db.UpdateRevisionHTML(article.URL, article.ID, html, fingerprint)
+}
+
+// navboxHTML returns the stored HTML of the article at url for embedding as a
+// navbox. Articles awaiting review can't be embedded. The HTML is used as
+// stored rather than rendered again, so embedding can't recurse.
+func (model *WikiModel) navboxHTML(url string) (string, bool) {
+	article, err := model.db.SelectArticle(url)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return "", false
+	}
+	if article.Pending {
+		return "", false
+	}
+	return article.HTML, true
+}
+
+// transcludedMarkdown returns the markdown of the article at url, less its
+// frontmatter, for articles transcluding it. Like navboxes, pending articles
+// are treated as missing, and site code pages aren't markdown.
+func (model *WikiModel) transcludedMarkdown(url string) (string, bool) {
+	if IsSiteCode(url) {
+		return "", false
+	}
+	article, err := model.db.SelectArticle(url)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return "", false
+	}
+	if article.Pending {
+		return "", false
+	}
+	_, body := ParseFrontmatter(article.Markdown)
+	return body, true
+}
+
+// refreshHTML renders article again if RerenderOutdatedHTML is set and its
+// HTML was produced by a renderer with a different fingerprint. Revisions
+// still rendering in the background are left to the render queue, and those
+// saved before MaxNestingDepth refused them keep their old HTML. Those whose
+// background render failed aren't tried again while a reader waits.
+func (model *WikiModel) refreshHTML(article *Article) error {
+	switch {
+	case article.RenderPending(), article.RenderFailed():
+		return nil
+	case article.RenderFingerprint == model.renderer.Fingerprint():
+		model.htmlCache.Inc("hit")
+		return nil
+	case !model.RerenderOutdatedHTML:
+		model.htmlCache.Inc("stale")
+		return nil
+	}
+	model.htmlCache.Inc("miss")
+	err := model.rerenderRevision(article)
+	if errors.Is(err, render.ErrNestingTooDeep) {
+		log.Printf("keeping outdated HTML of %s: %v", article.URL, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package wiki
+
+import "strings"
+
+// Category is a group of articles, which put themselves in it with a
+// `[[Category:Name]]` WikiLink.
+type Category struct {
+	// Name is in the form of an article URL, with underscores for spaces.
+	Name string `db:"name"`
+	// Members is how many articles are in the category.
+	Members int `db:"members"`
+}
+
+// Title returns the category's name for display.
+func (category *Category) Title() string {
+	return strings.ReplaceAll(category.Name, "_", " ")
+}
+
+// GetCategories returns every category with at least one article in it,
+// ordered by name.
+func (model *WikiModel) GetCategories() ([]*Category, error) {
+	return model.db.SelectCategories()
+}
+
+// GetCategoryMembers returns the articles in the named category, ordered by
+// URL.
+func (model *WikiModel) GetCategoryMembers(name string) ([]*ArticleSummary, error) {
+	return model.db.SelectCategoryMembers(name)
+}
+
+// GetArticleCategories returns the categories the article at url is in,
+// ordered by name.
+func (model *WikiModel) GetArticleCategories(url string) ([]*Category, error) {
+	return model.db.SelectArticleCategories(url)
+}
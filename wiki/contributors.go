@@ -0,0 +1,57 @@
+package wiki
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// Contributor is someone who has edited an article, and how many of its
+// revisions they made.
+type Contributor struct {
+	ScreenName string `db:"screenname" json:"screenname"`
+	Edits      int    `db:"edits" json:"edits"`
+}
+
+// DefaultAttributionTemplate credits every contributor to an article.
+const DefaultAttributionTemplate = `Contributors to “{{.Title}}”: ` +
+	`{{range $i, $c := .Contributors}}{{if $i}}, {{end}}{{$c.ScreenName}} ({{$c.Edits}}){{end}}.`
+
+// Attribution is the data AttributionTemplate is executed with.
+type Attribution struct {
+	SiteName     string
+	Title        string
+	URL          string
+	Contributors []*Contributor
+}
+
+// GetContributors returns the distinct authors of the article at url's
+// revisions, most edits first.
+func (model *WikiModel) GetContributors(url string) ([]*Contributor, error) {
+	return model.db.SelectContributors(url)
+}
+
+// parseAttributionTemplate parses text, or DefaultAttributionTemplate if it
+// is empty.
+func parseAttributionTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultAttributionTemplate
+	}
+	return template.New("attribution").Parse(text)
+}
+
+// Attribute renders AttributionTemplate crediting contributors to article.
+func (model *WikiModel) Attribute(article *Article, contributors []*Contributor) (template.HTML, error) {
+	tmpl, err := parseAttributionTemplate(model.AttributionTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, Attribution{
+		SiteName:     model.Settings().SiteName,
+		Title:        article.Title,
+		URL:          article.URL,
+		Contributors: contributors,
+	})
+	return template.HTML(buf.String()), err
+}
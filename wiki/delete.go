@@ -0,0 +1,83 @@
+package wiki
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DeletedArticle is an article in the recycle bin.
+type DeletedArticle struct {
+	URL       string    `db:"url"`
+	Title     string    `db:"title"`
+	DeletedAt time.Time `db:"deleted_at"`
+}
+
+// DeleteArticle deletes the article at url along with its revisions and
+// outgoing links. Articles linking to or embedding it are re-rendered so they
+// show it as missing.
+func (model *WikiModel) DeleteArticle(url string) error {
+	if _, err := model.db.SelectArticle(url); err == sql.ErrNoRows {
+		return ErrGenericNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if err := model.db.DeleteArticle(url); err != nil {
+		return err
+	}
+
+	model.InvalidateBacklinkersAsync(url)
+	model.InvalidateEmbeddersAsync(url)
+	return nil
+}
+
+// SoftDeleteArticle moves the article at url to the recycle bin, where it
+// keeps its revisions until it is restored or purged. Until then it is
+// treated as missing, and articles linking to or embedding it are
+// re-rendered to show it so.
+func (model *WikiModel) SoftDeleteArticle(url string) error {
+	if err := model.db.SoftDeleteArticle(url); err == sql.ErrNoRows {
+		return ErrGenericNotFound
+	} else if err != nil {
+		return err
+	}
+
+	model.InvalidateBacklinkersAsync(url)
+	model.InvalidateEmbeddersAsync(url)
+	return nil
+}
+
+// RestoreArticle takes the article at url out of the recycle bin, and
+// re-renders the articles linking to or embedding it.
+func (model *WikiModel) RestoreArticle(url string) error {
+	if err := model.db.RestoreArticle(url); err == sql.ErrNoRows {
+		return ErrGenericNotFound
+	} else if err != nil {
+		return err
+	}
+
+	model.InvalidateBacklinkersAsync(url)
+	model.InvalidateEmbeddersAsync(url)
+	return nil
+}
+
+// PurgeArticle deletes the article at url from the recycle bin for good.
+func (model *WikiModel) PurgeArticle(url string) error {
+	if deleted, err := model.db.ArticleDeleted(url); err != nil {
+		return err
+	} else if !deleted {
+		return ErrGenericNotFound
+	}
+	return model.db.DeleteArticle(url)
+}
+
+// ArticleDeleted reports whether the article at url is in the recycle bin.
+func (model *WikiModel) ArticleDeleted(url string) (bool, error) {
+	return model.db.ArticleDeleted(url)
+}
+
+// GetDeletedArticles returns the articles in the recycle bin, most recently
+// deleted first.
+func (model *WikiModel) GetDeletedArticles() ([]*DeletedArticle, error) {
+	return model.db.SelectDeletedArticles()
+}
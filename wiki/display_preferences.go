@@ -0,0 +1,101 @@
+package wiki
+
+import (
+	"errors"
+	"regexp"
+)
+
+// DisplayPreferences are a user's choices for how the wiki looks.
+type DisplayPreferences struct {
+	// Theme names the user's theme, or is empty to use the site's
+	// ActiveTheme.
+	Theme string
+	// DiffMode is how diffs are shown when the link to them doesn't say:
+	// DiffModeInline, DiffModeWord or DiffModeSplit.
+	DiffMode string
+}
+
+// ThemeLight is the built-in theme. Other themes are stylesheets, loaded
+// after the built-in one, that the wiki finds by name.
+const ThemeLight = "light"
+
+var themeName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Diff modes a user may choose. DiffModeInline compares characters and
+// DiffModeWord whole words, both in one column; DiffModeSplit puts the
+// lines of the two revisions side by side.
+const (
+	DiffModeInline = "char"
+	DiffModeWord   = "word"
+	DiffModeSplit  = "split"
+)
+
+// Labels of the display preferences saved for each user.
+const (
+	prefTheme    = "theme"
+	prefDiffMode = "diff_mode"
+)
+
+var ErrBadTheme = errors.New("there is no such theme")
+var ErrBadDiffMode = errors.New("diff mode must be char, word or split")
+
+// ValidThemeName reports whether theme could name a theme: lower case
+// letters, digits, dashes and underscores only.
+func ValidThemeName(theme string) bool {
+	return themeName.MatchString(theme)
+}
+
+// ValidDiffMode reports whether mode is one a user may choose.
+func ValidDiffMode(mode string) bool {
+	return mode == DiffModeInline || mode == DiffModeWord || mode == DiffModeSplit
+}
+
+// DisplayDefaults are the display preferences of anonymous users, and of
+// users who haven't chosen their own, as set in the config file.
+func (model *WikiModel) DisplayDefaults() DisplayPreferences {
+	prefs := DisplayPreferences{DiffMode: DiffModeInline}
+	if model.DiffMode != "" {
+		prefs.DiffMode = model.DiffMode
+	}
+	return prefs
+}
+
+// GetDisplayPreferences returns user's display preferences, falling back to
+// the site defaults for any they haven't saved.
+func (model *WikiModel) GetDisplayPreferences(user *User) (DisplayPreferences, error) {
+	prefs := model.DisplayDefaults()
+	if user.IsAnonymous() {
+		return prefs, nil
+	}
+
+	saved, err := model.db.SelectUserPreferences(user.ID)
+	if err != nil {
+		return prefs, err
+	}
+
+	if ValidThemeName(saved[prefTheme]) {
+		prefs.Theme = saved[prefTheme]
+	}
+	if ValidDiffMode(saved[prefDiffMode]) {
+		prefs.DiffMode = saved[prefDiffMode]
+	}
+	return prefs, nil
+}
+
+// UpdateDisplayPreferences saves prefs as user's display preferences.
+func (model *WikiModel) UpdateDisplayPreferences(user *User, prefs DisplayPreferences) error {
+	if user.IsAnonymous() {
+		return ErrAnonymousPreferences
+	}
+	if prefs.Theme != "" && !ValidThemeName(prefs.Theme) {
+		return ErrBadTheme
+	}
+	if !ValidDiffMode(prefs.DiffMode) {
+		return ErrBadDiffMode
+	}
+
+	return model.db.InsertUserPreferences(user.ID, map[string]string{
+		prefTheme:    prefs.Theme,
+		prefDiffMode: prefs.DiffMode,
+	})
+}
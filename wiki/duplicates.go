@@ -0,0 +1,103 @@
+package wiki
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// shingleSize is the number of consecutive words compared as one unit when
+// estimating how similar two texts are.
+const shingleSize = 3
+
+// ContentMatch is an existing article whose content resembles another text.
+type ContentMatch struct {
+	URL, Title string
+	// Similarity is 1 for identical content, down to 0 for nothing shared.
+	Similarity float64
+}
+
+// Percent is Similarity as a whole percentage.
+func (m *ContentMatch) Percent() int {
+	return int(m.Similarity*100 + 0.5)
+}
+
+// ShingleOverlap is an article sharing shingles with a text.
+type ShingleOverlap struct {
+	ArticleSummary
+	// Shingles is how many shingles the article has, and Shared how many of
+	// them the text has too.
+	Shingles int `db:"shingles"`
+	Shared   int `db:"shared"`
+}
+
+// FindSimilarContent returns the articles whose current content is at least
+// threshold similar to markdown, most similar first, by the Jaccard
+// similarity of their word shingles. Only articles sharing a shingle with
+// markdown are looked up, through the index PostArticle keeps. Talk and
+// Special pages are ignored.
+func (model *WikiModel) FindSimilarContent(markdown string, threshold float64) ([]*ContentMatch, error) {
+	if strings.TrimSpace(markdown) == "" {
+		return nil, nil
+	}
+
+	target := ShingleHashes(markdown)
+	overlaps, err := model.db.SelectShingleOverlaps(target)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*ContentMatch{}
+	for _, overlap := range overlaps {
+		if IsTalkPage(overlap.URL) || IsSpecialPage(overlap.URL) {
+			continue
+		}
+
+		similarity := float64(overlap.Shared) / float64(len(target)+overlap.Shingles-overlap.Shared)
+		if similarity >= threshold {
+			matches = append(matches, &ContentMatch{overlap.URL, overlap.Title, similarity})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	return matches, nil
+}
+
+// ShingleHashes returns the distinct hashes of text's shingles, ignoring case
+// and whitespace.
+func ShingleHashes(text string) []int64 {
+	set := make(map[int64]struct{})
+	for shingle := range shingles(text) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(shingle))
+		set[int64(h.Sum64())] = struct{}{}
+	}
+
+	hashes := make([]int64, 0, len(set))
+	for hash := range set {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+// shingles returns the set of runs of shingleSize consecutive words in text,
+// ignoring case and whitespace. Texts shorter than that are a single shingle.
+func shingles(text string) map[string]struct{} {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[string]struct{})
+	if len(words) == 0 {
+		return set
+	}
+	if len(words) < shingleSize {
+		set[strings.Join(words, " ")] = struct{}{}
+		return set
+	}
+
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,90 @@
+package wiki
+
+import (
+	"errors"
+	"strconv"
+)
+
+// EditorPreferences are a user's choices for how the article editor behaves.
+type EditorPreferences struct {
+	// PreviewByDefault shows the rendered article below the editor as soon
+	// as it opens.
+	PreviewByDefault bool
+	// Monospace sets the editor in a fixed-width font.
+	Monospace bool
+	// TabSize is the width of a tab character in the editor, in spaces.
+	TabSize int
+	// ShowToolbar shows the formatting buttons above the editor, if the site
+	// has any. See EditorToolbar.
+	ShowToolbar bool
+}
+
+// Labels of the editor preferences saved for each user.
+const (
+	prefEditorPreviewByDefault = "editor_preview_by_default"
+	prefEditorMonospace        = "editor_monospace"
+	prefEditorTabSize          = "editor_tab_size"
+	prefEditorShowToolbar      = "editor_show_toolbar"
+)
+
+// MaxEditorTabSize is the widest tab a user may choose.
+const MaxEditorTabSize = 16
+
+var ErrBadTabSize = errors.New("tab size must be between 1 and 16")
+var ErrAnonymousPreferences = errors.New("log in to save preferences")
+
+// EditorDefaults are the editor preferences of anonymous users, and of users
+// who haven't chosen their own, as set in the config file.
+func (model *WikiModel) EditorDefaults() EditorPreferences {
+	return EditorPreferences{
+		PreviewByDefault: model.EditorPreviewByDefault,
+		Monospace:        model.EditorMonospace,
+		TabSize:          model.EditorTabSize,
+		ShowToolbar:      true,
+	}
+}
+
+// GetEditorPreferences returns user's editor preferences, falling back to the
+// site defaults for any they haven't saved.
+func (model *WikiModel) GetEditorPreferences(user *User) (EditorPreferences, error) {
+	prefs := model.EditorDefaults()
+	if user.IsAnonymous() {
+		return prefs, nil
+	}
+
+	saved, err := model.db.SelectUserPreferences(user.ID)
+	if err != nil {
+		return prefs, err
+	}
+
+	if b, err := strconv.ParseBool(saved[prefEditorPreviewByDefault]); err == nil {
+		prefs.PreviewByDefault = b
+	}
+	if b, err := strconv.ParseBool(saved[prefEditorMonospace]); err == nil {
+		prefs.Monospace = b
+	}
+	if n, err := strconv.Atoi(saved[prefEditorTabSize]); err == nil {
+		prefs.TabSize = n
+	}
+	if b, err := strconv.ParseBool(saved[prefEditorShowToolbar]); err == nil {
+		prefs.ShowToolbar = b
+	}
+	return prefs, nil
+}
+
+// UpdateEditorPreferences saves prefs as user's editor preferences.
+func (model *WikiModel) UpdateEditorPreferences(user *User, prefs EditorPreferences) error {
+	if user.IsAnonymous() {
+		return ErrAnonymousPreferences
+	}
+	if prefs.TabSize < 1 || prefs.TabSize > MaxEditorTabSize {
+		return ErrBadTabSize
+	}
+
+	return model.db.InsertUserPreferences(user.ID, map[string]string{
+		prefEditorPreviewByDefault: strconv.FormatBool(prefs.PreviewByDefault),
+		prefEditorMonospace:        strconv.FormatBool(prefs.Monospace),
+		prefEditorTabSize:          strconv.Itoa(prefs.TabSize),
+		prefEditorShowToolbar:      strconv.FormatBool(prefs.ShowToolbar),
+	})
+}
@@ -0,0 +1,32 @@
+package wiki
+
+import "time"
+
+// ExportEntry describes an exported article in an export's manifest.
+type ExportEntry struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title"`
+	Revision int       `json:"revision"`
+	Created  time.Time `json:"created"`
+}
+
+// ExportArticles calls fn with the latest revision of every article, including
+// those awaiting moderation, in URL order. Articles are loaded one at a time so
+// that exporting a large wiki doesn't hold it all in memory. It stops at the
+// first error fn returns.
+func (model *WikiModel) ExportArticles(fn func(*Article) error) error {
+	urls, err := model.db.SelectArticleURLs()
+	if err != nil {
+		return err
+	}
+	for _, url := range urls {
+		article, err := model.db.SelectArticle(url)
+		if err != nil {
+			return err
+		}
+		if err := fn(article); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package wiki
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReviewedDateLayout is the layout of the reviewed date in frontmatter.
+const ReviewedDateLayout = "2006-01-02"
+
+// Frontmatter is the optional metadata block at the top of an article's
+// Markdown, written as YAML between two "---" lines.
+type Frontmatter struct {
+	// Reviewed is the date the article's content was last checked, as
+	// YYYY-MM-DD.
+	Reviewed string `yaml:"reviewed"`
+	// NoIndex keeps the article out of the sitemap.
+	NoIndex bool `yaml:"noindex"`
+	// Cache is how long the article may be cached: "none", "short" or
+	// "long". Anything else gets the default, which is to revalidate.
+	Cache string `yaml:"cache"`
+	// Redirect is the URL of the article readers are sent to instead, as
+	// left behind by MoveArticle.
+	Redirect string `yaml:"redirect"`
+	// DisplayTitle is the title given to the article when it is imported.
+	DisplayTitle string `yaml:"display_title"`
+}
+
+// Cache-Control values for the article cache policies.
+const (
+	CacheControlDefault = "public, no-cache"
+	CacheControlNone    = "no-store"
+	CacheControlShort   = "public, max-age=300"
+	CacheControlLong    = "public, max-age=86400"
+)
+
+// ParseFrontmatter splits markdown into its frontmatter and body. Markdown
+// that doesn't open with a YAML mapping between "---" lines has no
+// frontmatter and is returned whole.
+func ParseFrontmatter(markdown string) (*Frontmatter, string) {
+	normalized := strings.ReplaceAll(markdown, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return &Frontmatter{}, markdown
+	}
+
+	block, body, found := strings.Cut(normalized[len("---\n"):], "\n---")
+	if !found || (body != "" && body[0] != '\n') {
+		return &Frontmatter{}, markdown
+	}
+
+	fm := &Frontmatter{}
+	if err := yaml.Unmarshal([]byte(block), fm); err != nil {
+		return &Frontmatter{}, markdown
+	}
+
+	return fm, strings.TrimPrefix(body, "\n")
+}
+
+// CacheControl returns the Cache-Control header for the article, following
+// its cache policy.
+func (fm *Frontmatter) CacheControl() string {
+	switch strings.ToLower(strings.TrimSpace(fm.Cache)) {
+	case "none":
+		return CacheControlNone
+	case "short":
+		return CacheControlShort
+	case "long":
+		return CacheControlLong
+	}
+	return CacheControlDefault
+}
+
+// ReviewedAt returns the reviewed date, if one was given.
+func (fm *Frontmatter) ReviewedAt() (time.Time, bool, error) {
+	if fm.Reviewed == "" {
+		return time.Time{}, false, nil
+	}
+
+	reviewed, err := time.Parse(ReviewedDateLayout, strings.TrimSpace(fm.Reviewed))
+	if err != nil {
+		return time.Time{}, false, ErrBadReviewedDate
+	}
+	return reviewed, true, nil
+}
@@ -0,0 +1,44 @@
+package wiki
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	fm, body := ParseFrontmatter("---\nreviewed: 2024-01-15\n---\n# Install\n\nRun it.")
+	if body != "# Install\n\nRun it." {
+		t.Errorf("expected the frontmatter to be stripped, got %q", body)
+	}
+
+	reviewed, ok, err := fm.ReviewedAt()
+	if err != nil || !ok {
+		t.Fatalf("expected a reviewed date, got %v, %v", ok, err)
+	}
+	if want := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC); !reviewed.Equal(want) {
+		t.Errorf("expected %v, got %v", want, reviewed)
+	}
+}
+
+func TestParseFrontmatterAbsent(t *testing.T) {
+	for _, markdown := range []string{
+		"No frontmatter here.",
+		"---\n\nA document opening with a rule.",
+		"---\nnot: [closed\n---\nBroken YAML is left alone.",
+	} {
+		fm, body := ParseFrontmatter(markdown)
+		if body != markdown {
+			t.Errorf("expected %q to be returned whole, got %q", markdown, body)
+		}
+		if _, ok, _ := fm.ReviewedAt(); ok {
+			t.Errorf("expected no reviewed date in %q", markdown)
+		}
+	}
+}
+
+func TestParseFrontmatterBadDate(t *testing.T) {
+	fm, _ := ParseFrontmatter("---\nreviewed: last tuesday\n---\nBody")
+	if _, _, err := fm.ReviewedAt(); err != ErrBadReviewedDate {
+		t.Errorf("expected ErrBadReviewedDate, got %v", err)
+	}
+}
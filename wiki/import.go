@@ -0,0 +1,49 @@
+package wiki
+
+import "strings"
+
+// Outcomes of importing an article.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportError   = "error"
+)
+
+// ImportArticle saves markdown as the latest revision of the article at url,
+// creating it if it doesn't exist, and reports which it did. Unless title is
+// given, it is taken from the display_title frontmatter, or else kept from
+// the existing article or derived from url. Articles that are unchanged, and
+// talk pages whose subject doesn't exist, are skipped, with the reason as the
+// error.
+func (model *WikiModel) ImportArticle(url, title, markdown string, creator *User) (string, error) {
+	url = model.canonicalURL(url)
+	article := NewArticle(url, model.DisplayTitle(url), markdown)
+	article.Creator = creator
+	article.Comment = "Imported"
+
+	outcome := ImportCreated
+	existing, err := model.GetArticle(url)
+	if err == nil {
+		outcome = ImportUpdated
+		article.PreviousID = existing.ID
+		article.Title = existing.Title
+	} else if err != ErrGenericNotFound {
+		return ImportError, err
+	}
+	if frontmatter, _ := ParseFrontmatter(markdown); strings.TrimSpace(frontmatter.DisplayTitle) != "" {
+		article.Title = strings.TrimSpace(frontmatter.DisplayTitle)
+	}
+	if title != "" {
+		article.Title = title
+	}
+
+	switch err := model.PostArticle(article); err {
+	case nil:
+		return outcome, nil
+	case ErrArticleNotModified, ErrTalkSubjectNotFound:
+		return ImportSkipped, err
+	default:
+		return ImportError, err
+	}
+}
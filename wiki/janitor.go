@@ -0,0 +1,60 @@
+package wiki
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// janitor periodically deletes rows that have outlived their use, such as
+// expired sessions, until it is stopped.
+type janitor struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// startJanitor calls sweep every interval until the janitor is stopped.
+func startJanitor(interval time.Duration, sweep func(now time.Time)) *janitor {
+	j := &janitor{stop: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				sweep(now)
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+
+	return j
+}
+
+// Stop stops the janitor, waiting for a sweep in progress to finish. It is
+// safe to call more than once.
+func (j *janitor) Stop() {
+	j.stopOnce.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// DeleteExpiredSessions deletes the sessions that expired before now,
+// returning how many there were.
+func (model *WikiModel) DeleteExpiredSessions(now time.Time) (int, error) {
+	return model.db.DeleteExpiredSessions(now)
+}
+
+// cleanUp deletes expired rows as of now.
+func (model *WikiModel) cleanUp(now time.Time) {
+	n, err := model.DeleteExpiredSessions(now)
+	if err != nil {
+		log.Println("failed to delete expired sessions:", err)
+	} else if n > 0 {
+		log.Printf("deleted %d expired sessions", n)
+	}
+}
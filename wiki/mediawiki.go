@@ -0,0 +1,113 @@
+package wiki
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// MediaWiki namespace numbers that can be imported.
+const (
+	MediaWikiMainNamespace = 0
+	MediaWikiTalkNamespace = 1
+)
+
+// MediaWikiPage is a page read from a MediaWiki XML dump, with the wikitext
+// of its latest revision.
+type MediaWikiPage struct {
+	Title     string
+	Namespace int
+	Text      string
+}
+
+// URL returns the URL of the article the page becomes.
+func (page *MediaWikiPage) URL() string {
+	return strings.ReplaceAll(strings.TrimSpace(page.Title), " ", "_")
+}
+
+type mediaWikiPageElement struct {
+	Title     string `xml:"title"`
+	Namespace int    `xml:"ns"`
+	Revisions []struct {
+		Text string `xml:"text"`
+	} `xml:"revision"`
+}
+
+// ReadMediaWikiDump calls fn with each page of the MediaWiki XML dump in r,
+// such as pages-articles.xml. Pages are decoded one at a time so that large
+// dumps needn't fit in memory. It stops at the first error fn returns.
+func ReadMediaWikiDump(r io.Reader, fn func(*MediaWikiPage) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+		var element mediaWikiPageElement
+		if err := decoder.DecodeElement(&element, &start); err != nil {
+			return err
+		}
+		page := &MediaWikiPage{Title: element.Title, Namespace: element.Namespace}
+		if n := len(element.Revisions); n > 0 {
+			page.Text = element.Revisions[n-1].Text
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+}
+
+var (
+	mediaWikiRedirectRegexp     = regexp.MustCompile(`(?i)^\s*#REDIRECT\s*\[\[\s*([^\]|#]+?)\s*(?:[|#][^\]]*)?\]\]`)
+	mediaWikiHeadingRegexp      = regexp.MustCompile(`^(={1,6})\s*(.+?)\s*={1,6}\s*$`)
+	mediaWikiListRegexp         = regexp.MustCompile(`^([*#]+)\s*(.*)$`)
+	mediaWikiBoldItalicRegexp   = regexp.MustCompile(`'''''(.+?)'''''`)
+	mediaWikiBoldRegexp         = regexp.MustCompile(`'''(.+?)'''`)
+	mediaWikiItalicRegexp       = regexp.MustCompile(`''(.+?)''`)
+	mediaWikiExternalLinkRegexp = regexp.MustCompile(`\[((?:https?|ftp)://[^\s\]]+)(?:\s+([^\]]+))?\]`)
+)
+
+// MediaWikiToMarkdown converts wikitext to markdown, as far as it can:
+// headings, bold and italic text, lists, external links and redirects are
+// converted, and WikiLinks already share periwiki's syntax. Anything else,
+// such as templates and tables, is left as it is.
+func MediaWikiToMarkdown(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if match := mediaWikiRedirectRegexp.FindStringSubmatch(text); match != nil {
+		return RedirectMarkdown(strings.ReplaceAll(match[1], " ", "_"))
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if match := mediaWikiHeadingRegexp.FindStringSubmatch(line); match != nil {
+			line = strings.Repeat("#", len(match[1])) + " " + match[2]
+		} else if match := mediaWikiListRegexp.FindStringSubmatch(line); match != nil {
+			marker := "-"
+			if strings.HasSuffix(match[1], "#") {
+				marker = "1."
+			}
+			line = strings.Repeat("    ", len(match[1])-1) + marker + " " + match[2]
+		}
+		line = mediaWikiBoldItalicRegexp.ReplaceAllString(line, "***$1***")
+		line = mediaWikiBoldRegexp.ReplaceAllString(line, "**$1**")
+		line = mediaWikiItalicRegexp.ReplaceAllString(line, "*$1*")
+		line = mediaWikiExternalLinkRegexp.ReplaceAllStringFunc(line, func(link string) string {
+			match := mediaWikiExternalLinkRegexp.FindStringSubmatch(link)
+			if match[2] == "" {
+				return "<" + match[1] + ">"
+			}
+			return fmt.Sprintf("[%s](%s)", match[2], match[1])
+		})
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
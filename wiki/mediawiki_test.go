@@ -0,0 +1,60 @@
+package wiki
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMediaWikiToMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		wikitext, want string
+	}{
+		{"== History ==", "## History"},
+		{"===Early years===", "### Early years"},
+		{"'''Cats''' are ''small''.", "**Cats** are *small*."},
+		{"'''''Very''''' important", "***Very*** important"},
+		{"* One\n** Two\n# First", "- One\n    - Two\n1. First"},
+		{"See [[Felis catus|the cat]].", "See [[Felis catus|the cat]]."},
+		{"[https://example.com Example] and [https://example.org]", "[Example](https://example.com) and <https://example.org>"},
+		{"#REDIRECT [[Domestic cat#Behaviour]]", RedirectMarkdown("Domestic_cat")},
+	} {
+		if got := MediaWikiToMarkdown(test.wikitext); got != test.want {
+			t.Errorf("MediaWikiToMarkdown(%q) = %q, want %q", test.wikitext, got, test.want)
+		}
+	}
+}
+
+func TestReadMediaWikiDump(t *testing.T) {
+	dump := `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/">
+  <siteinfo><sitename>Catipedia</sitename></siteinfo>
+  <page>
+    <title>Domestic cat</title>
+    <ns>0</ns>
+    <revision><id>1</id><text>Old.</text></revision>
+    <revision><id>2</id><text>'''Cats''' &amp; kittens.</text></revision>
+  </page>
+  <page>
+    <title>Talk:Domestic cat</title>
+    <ns>1</ns>
+    <revision><id>3</id><text>Discuss.</text></revision>
+  </page>
+</mediawiki>`
+
+	var pages []*MediaWikiPage
+	err := ReadMediaWikiDump(strings.NewReader(dump), func(page *MediaWikiPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if page := pages[0]; page.URL() != "Domestic_cat" || page.Namespace != MediaWikiMainNamespace || page.Text != "'''Cats''' & kittens." {
+		t.Errorf("expected the latest revision of Domestic cat, got %+v", page)
+	}
+	if page := pages[1]; page.URL() != "Talk:Domestic_cat" || page.Namespace != MediaWikiTalkNamespace {
+		t.Errorf("expected the talk page, got %+v", page)
+	}
+}
@@ -0,0 +1,55 @@
+package wiki
+
+import (
+	"errors"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var ErrMergeConflict = errors.New("edit conflicts with changes made since it was started")
+
+// TryMerge performs a three-way merge of submitted against the current head of
+// the article at url, using the revision baseRevID as the common ancestor. The
+// returned article is the head revision with its markdown replaced by the
+// merged result. ErrMergeConflict is returned if both sides touched the same
+// region of the ancestor.
+func (model *WikiModel) TryMerge(url string, baseRevID int, submitted string) (*Article, error) {
+	base, err := model.GetArticleByRevisionID(url, baseRevID)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := model.GetArticle(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if head.ID == base.ID {
+		head.Markdown = submitted
+		return head, nil
+	}
+
+	dmp := diffmatchpatch.New()
+	mine := dmp.PatchMake(base.Markdown, submitted)
+	theirs := dmp.PatchMake(base.Markdown, head.Markdown)
+
+	// PatchApply matches fuzzily, so refuse up front to apply any patch whose
+	// region of the ancestor (context included) was also changed on the head.
+	for _, m := range mine {
+		for _, t := range theirs {
+			if m.Start1 < t.Start1+t.Length1 && t.Start1 < m.Start1+m.Length1 {
+				return nil, ErrMergeConflict
+			}
+		}
+	}
+
+	merged, applied := dmp.PatchApply(mine, head.Markdown)
+	for _, ok := range applied {
+		if !ok {
+			return nil, ErrMergeConflict
+		}
+	}
+
+	head.Markdown = merged
+	return head, nil
+}
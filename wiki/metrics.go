@@ -0,0 +1,44 @@
+package wiki
+
+import (
+	"github.com/danielledeleo/periwiki/metrics"
+	"github.com/danielledeleo/periwiki/renderqueue"
+)
+
+// registerMetrics creates the model's metrics and returns the options that
+// instrument its render queue.
+func (model *WikiModel) registerMetrics() []renderqueue.Option {
+	model.metrics = metrics.NewRegistry()
+	model.htmlCache = model.metrics.NewCounter("periwiki_article_html_cache_total",
+		"Revisions read, by whether their stored HTML was current (hit), rendered again (miss) or outdated but kept (stale).",
+		"result")
+
+	model.metrics.NewGaugeFunc("periwiki_render_queue_length", "Renders waiting in the queue, by tier.", "tier",
+		func() map[string]float64 {
+			lengths := make(map[string]float64)
+			if model.queue == nil {
+				return lengths
+			}
+			for _, tier := range []renderqueue.Tier{renderqueue.TierInteractive, renderqueue.TierBackground} {
+				lengths[tier.String()] = float64(model.queue.Len(tier))
+			}
+			return lengths
+		})
+
+	durations := model.metrics.NewHistogram("periwiki_render_duration_seconds",
+		"How long queued renders took, by tier and result.", metrics.DefBuckets, "tier", "result")
+	observe := func(info renderqueue.JobInfo) {
+		result := "ok"
+		if info.Err != nil {
+			result = "error"
+		}
+		durations.Observe(info.FinishedAt.Sub(info.StartedAt).Seconds(), info.Tier.String(), result)
+	}
+	return []renderqueue.Option{renderqueue.WithObserver(observe)}
+}
+
+// Metrics returns the registry of the wiki's metrics, or nil if EnableMetrics
+// isn't set.
+func (model *WikiModel) Metrics() *metrics.Registry {
+	return model.metrics
+}
@@ -6,11 +6,17 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"log"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/danielledeleo/periwiki/metrics"
 	"github.com/danielledeleo/periwiki/render"
+	"github.com/danielledeleo/periwiki/renderqueue"
 	"github.com/gorilla/sessions"
 
 	"github.com/microcosm-cc/bluemonday"
@@ -22,6 +28,13 @@ type ContextKey string
 // UserKey is for context.Context
 const UserKey ContextKey = "periwiki.user"
 
+// CSRFTokenKey is for context.Context. It carries the token that forms must
+// send back as CSRFField.
+const CSRFTokenKey ContextKey = "periwiki.csrf"
+
+// CSRFField is the name of the form field carrying the CSRF token.
+const CSRFField = "csrf_token"
+
 type WikiModel struct {
 	*Config
 	db        db
@@ -29,6 +42,36 @@ type WikiModel struct {
 	// cache, perhaps
 
 	renderer *render.HTMLRenderer
+	// renderSlots holds a token for each render in progress, if
+	// MaxConcurrentRenders limits them.
+	renderSlots chan struct{}
+	queue       *renderqueue.Queue
+	janitor     *janitor
+
+	invalidateMu       sync.Mutex
+	invalidateTargets  map[string]bool
+	invalidateEmbedded map[string]bool
+	invalidateTimer    *time.Timer
+
+	notifyMu      sync.Mutex
+	notifyBatches map[string]*watchBatch
+
+	settingsMu sync.RWMutex
+	settings   Settings
+
+	rerenderMu  sync.Mutex
+	rerenderJob *RerenderJob
+
+	// SendVerification delivers email verification links. If nil, they are
+	// logged.
+	SendVerification VerificationSender
+	// SendWatchNotification delivers notifications of edits to watched
+	// pages. If nil, they are logged.
+	SendWatchNotification WatchNotificationSender
+
+	// metrics is nil unless EnableMetrics is set, as are the metrics in it.
+	metrics   *metrics.Registry
+	htmlCache *metrics.Counter
 }
 
 type Config struct {
@@ -37,19 +80,310 @@ type Config struct {
 	DatabaseFile          string `yaml:"dbfile"`
 	MinimumPasswordLength int    `yaml:"minimum_password_length"`
 	Host                  string `yaml:"host"`
+	SiteName              string `yaml:"site_name"`
+	AutoCreateTalkPages   bool   `yaml:"auto_create_talk_pages"`
+	MergeEditConflicts    bool   `yaml:"merge_edit_conflicts"`
+	NormalizeArticleURLs  bool   `yaml:"normalize_article_urls"`
+	ModerateNewArticles   bool   `yaml:"moderate_new_articles"`
+	// RenderWorkers is the initial number of background render workers,
+	// until an admin changes it from /manage/settings. With none, background
+	// renders run synchronously and the number can't be changed without a
+	// restart.
+	RenderWorkers int `yaml:"render_workers"`
+	// MaxConcurrentRenders limits how many renders run at once across the
+	// background workers, previews and synchronous renders, bounding the
+	// memory a burst of edits can take. With 0, renders aren't limited.
+	MaxConcurrentRenders int `yaml:"max_concurrent_renders"`
+	// BacklinkBatchWindow is how long backlink invalidations are collected
+	// before the affected articles are queued for re-rendering.
+	BacklinkBatchWindow time.Duration `yaml:"backlink_batch_window"`
+	// WatchNotificationDelay is how long edits to a watched page are
+	// collected before its watchers are notified of them all at once.
+	WatchNotificationDelay time.Duration `yaml:"watch_notification_delay"`
+	// BacklinksPageSize is how many backlinks are listed per page of
+	// Special:WhatLinksHere and fetched per batch when invalidating them.
+	BacklinksPageSize int `yaml:"backlinks_page_size"`
+	// TitleCasing is how the titles of new articles are derived from their
+	// URLs: "first-letter", "sentence-case" or "title-case".
+	TitleCasing string `yaml:"title_casing"`
+	// EditorToolbar names the formatting buttons shown above the editor, in
+	// order. See ToolbarButtons.
+	EditorToolbar []string `yaml:"editor_toolbar"`
+	// ShowWantedLinksOnArticles lists, below each article, the articles it
+	// links to that don't exist yet, to prompt readers to create them.
+	ShowWantedLinksOnArticles bool `yaml:"show_wanted_links_on_articles"`
+	// ShowReadingTime shows, below each article, how many words it has and
+	// roughly how long it takes to read at ReadingWordsPerMinute.
+	ShowReadingTime       bool `yaml:"show_reading_time"`
+	ReadingWordsPerMinute int  `yaml:"reading_words_per_minute"`
+	// TaskLists renders `- [ ]` and `- [x]` list items with disabled
+	// checkboxes.
+	TaskLists bool `yaml:"task_lists"`
+	// SyntaxHighlighting is the chroma style, e.g. "github", that fenced
+	// code blocks naming their language are highlighted with. Leave it empty
+	// to turn highlighting off.
+	SyntaxHighlighting string `yaml:"syntax_highlighting"`
+	// MathRendering marks up TeX between dollar signs, `$x^2$` and
+	// `$$...$$`, for a script such as KaTeX, loaded from the site's
+	// Common.js, to typeset. It's off by default, as prose uses $ for money.
+	MathRendering bool `yaml:"math_rendering"`
+	// TableOfContents lists an article's section headings before the first
+	// of them, or where the article has a `[[TOC]]` paragraph.
+	TableOfContents bool `yaml:"table_of_contents"`
+	// Diagrams draws "```graphviz" and "```dot" code blocks as SVG with the
+	// Graphviz dot binary at DotPath, a path or a name to find in $PATH.
+	Diagrams bool   `yaml:"diagrams"`
+	DotPath  string `yaml:"dot_path"`
+	// MaxNestingDepth is how deeply blockquotes and lists may be nested in
+	// an article before it is refused as too slow to render. Zero allows any
+	// depth.
+	MaxNestingDepth int `yaml:"max_nesting_depth"`
+	// AllowEmptyArticles permits saving revisions that render to nothing.
+	// Otherwise only an existing page may be emptied, and only by blanking it
+	// deliberately, e.g. to request its deletion.
+	AllowEmptyArticles bool `yaml:"allow_empty_articles"`
+	// AttributionTemplate is the html/template rendered as an article's
+	// attribution footer and in its exports. It is executed with an
+	// Attribution.
+	AttributionTemplate string `yaml:"attribution_template"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-Proto and X-Forwarded-For headers are believed, so
+	// that the wiki sets Secure cookies and https URLs when they terminate
+	// TLS in front of it, and rate limits clients rather than the proxy.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Navboxes enables `{{nav:Some_Navbox}}`, which embeds the rendered HTML
+	// of another article. Embedding articles are re-rendered in the
+	// background whenever the navbox changes.
+	Navboxes bool `yaml:"navboxes"`
+	// Transclusion enables `{{Page_Name}}`, which splices in the markdown of
+	// another article. Like navboxes, transcluding articles are re-rendered
+	// whenever the transcluded one changes.
+	Transclusion bool `yaml:"transclusion"`
+	// MarkExternalLinks opens links to other sites in a new tab, with
+	// rel="nofollow noopener". Links to Host are not external.
+	MarkExternalLinks bool `yaml:"mark_external_links"`
+	// ExternalLinkIcon marks those links with an icon as well.
+	ExternalLinkIcon bool `yaml:"external_link_icon"`
+	// LargeArticleRenderBytes is the Markdown size above which an edit is
+	// rendered in the background rather than before the edit is saved, so
+	// huge articles don't hold up everyone else's edits. Zero disables it.
+	LargeArticleRenderBytes int `yaml:"large_article_render_bytes"`
+	// CaseInsensitiveUsernames rejects registering a screenname that differs
+	// from an existing one only by (ASCII) case, and matches logins ignoring case.
+	CaseInsensitiveUsernames bool `yaml:"case_insensitive_usernames"`
+	// StaleContentAge is how long after its last review an article is listed
+	// on Special:StaleContent.
+	StaleContentAge time.Duration `yaml:"stale_content_age"`
+	// ProtectedArticles lists the URLs of articles only administrators may
+	// edit. Everyone else is offered their source read-only.
+	ProtectedArticles []string `yaml:"protected_articles"`
+	// NamespaceAliases maps alternative namespace names to the namespace
+	// they stand for, e.g. "Discussion" to "Talk".
+	NamespaceAliases map[string]string `yaml:"namespace_aliases"`
+	// RequireLoginToEdit refuses edits from anonymous users.
+	RequireLoginToEdit bool `yaml:"require_login_to_edit"`
+	// ReadOnly refuses all edits, e.g. during maintenance.
+	ReadOnly bool `yaml:"read_only"`
+	// AllowAnonymousPreview lets anonymous users open the editor and preview
+	// changes when RequireLoginToEdit stops them saving.
+	AllowAnonymousPreview bool `yaml:"allow_anonymous_preview"`
+	// EmbedAllowedOrigins lists the origins, besides the wiki itself, allowed
+	// to frame articles from /embed/, e.g. "https://intranet.example.com".
+	EmbedAllowedOrigins []string `yaml:"embed_allowed_origins"`
+	// CheckWikiLinkAnchors marks WikiLinks to sections that don't exist, such
+	// as `[[Glossary#No such term]]`, as dead anchors.
+	CheckWikiLinkAnchors bool `yaml:"check_wikilink_anchors"`
+	// DefinitionAnchors enables definition lists and gives their terms ids
+	// that WikiLinks can target, like headings.
+	DefinitionAnchors bool `yaml:"definition_anchors"`
+	// AllowCustomJS injects Periwiki:Common.js into every page. Off by
+	// default, as it lets administrators run scripts in every reader's browser.
+	AllowCustomJS bool `yaml:"allow_custom_js"`
+	// NotFoundSuggestions is how many similarly titled articles a missing
+	// article's page suggests. Zero disables suggestions.
+	NotFoundSuggestions int `yaml:"not_found_suggestions"`
+	// CompressRevisions gzips the markdown and HTML of new revisions.
+	// Existing uncompressed revisions remain readable either way.
+	CompressRevisions bool `yaml:"compress_revisions"`
+	// DuplicateContentThreshold is the similarity, from 0 to 1, above which
+	// a new article's preview warns that it duplicates an existing one.
+	// Zero disables the check.
+	DuplicateContentThreshold float64 `yaml:"duplicate_content_threshold"`
+	// RerenderOutdatedHTML renders a revision again when it is read if its
+	// stored HTML came from a renderer with a different fingerprint, so old
+	// revisions display as the current renderer would show them.
+	RerenderOutdatedHTML bool `yaml:"rerender_outdated_html"`
+	// SitemapPriorities maps article URLs, or patterns like "Talk:*", to
+	// their priority in the sitemap. See SitemapPriority.
+	SitemapPriorities map[string]float64 `yaml:"sitemap_priorities"`
+	// SitemapExcludeNamespaces lists namespaces, e.g. "Talk", whose pages
+	// are left out of the sitemap.
+	SitemapExcludeNamespaces []string `yaml:"sitemap_exclude_namespaces"`
+	// SitemapSpecialPages lists the Special pages, e.g. "StaleContent", to
+	// include in the sitemap.
+	SitemapSpecialPages []string `yaml:"sitemap_special_pages"`
+	// EditorPreviewByDefault, EditorMonospace and EditorTabSize are the
+	// editor preferences of users who haven't chosen their own.
+	EditorPreviewByDefault bool `yaml:"editor_preview_by_default"`
+	EditorMonospace        bool `yaml:"editor_monospace"`
+	EditorTabSize          int  `yaml:"editor_tab_size"`
+	// Theme is the initial ActiveTheme, "light" if unset.
+	Theme string `yaml:"theme"`
+	// DiffMode is the diff mode of users who haven't chosen their own,
+	// "char" if unset. See DisplayPreferences.
+	DiffMode string `yaml:"diff_mode"`
+	// CleanupInterval is how often expired sessions are deleted. Zero
+	// disables the cleanup.
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	// SiteNotice is the initial site notice, until an admin changes it from
+	// /manage/settings.
+	SiteNotice string `yaml:"site_notice"`
+	// ContentLicenseName and ContentLicenseURL are the initial content
+	// license shown in the footer, until an admin changes it from
+	// /manage/settings. No license is shown when the name is empty.
+	ContentLicenseName string `yaml:"content_license_name"`
+	ContentLicenseURL  string `yaml:"content_license_url"`
+	// ConfirmRevertComments makes reverts go through a confirmation step
+	// where the user must keep or edit the suggested comment.
+	ConfirmRevertComments bool `yaml:"confirm_revert_comments"`
+	// ContentNegotiation serves articles as Markdown or JSON to clients
+	// whose Accept header prefers text/markdown or application/json.
+	ContentNegotiation bool `yaml:"content_negotiation"`
+	// LoginRateLimit and LoginRateWindow are the initial limit on login and
+	// registration attempts from one address, until an admin changes them
+	// from /manage/settings. A limit of zero allows any number.
+	LoginRateLimit  int           `yaml:"login_rate_limit"`
+	LoginRateWindow time.Duration `yaml:"login_rate_window"`
+	// RequireEmailVerification is the initial setting of whether new users
+	// must verify their email address, until an admin changes it from
+	// /manage/settings.
+	RequireEmailVerification bool `yaml:"require_email_verification"`
+	// RememberMeExpiry is the initial lifetime of logins made with "remember
+	// me" checked, until an admin changes it from /manage/settings. Other
+	// logins last until the browser is closed, or CookieExpiry at most.
+	RememberMeExpiry time.Duration `yaml:"remember_me_expiry"`
+	// DiffCacheSize is the initial number of rendered diffs kept in memory,
+	// until an admin changes it from /manage/settings. Zero disables the
+	// cache.
+	DiffCacheSize int `yaml:"diff_cache_size"`
+	// MaxRenderDuration is the initial limit on how long a background render
+	// may take, until an admin changes it from /manage/settings. Zero means
+	// no limit.
+	MaxRenderDuration time.Duration `yaml:"max_render_duration"`
+	// BackgroundRenderMaxWait is the initial limit on how long a background
+	// render waits behind interactive ones before it is promoted, until an
+	// admin changes it from /manage/settings. Zero means it is never promoted.
+	BackgroundRenderMaxWait time.Duration `yaml:"background_render_max_wait"`
+	// EnableMetrics serves Prometheus metrics at /metrics.
+	EnableMetrics bool `yaml:"enable_metrics"`
+}
+
+// CookieExpiryDuration returns CookieExpiry, which is in seconds, as a
+// time.Duration.
+func (c *Config) CookieExpiryDuration() time.Duration {
+	return time.Duration(c.CookieExpiry) * time.Second
 }
 
 type db interface {
 	SelectArticle(url string) (*Article, error)
 	SelectArticleByRevisionHash(url string, hash string) (*Article, error)
 	SelectArticleByRevisionID(url string, id int) (*Article, error)
+	SelectRevisionAsOf(url string, t time.Time) (*Article, error)
 	SelectRevision(hash string) (*Revision, error)
 	SelectUserByScreenname(screenname string, withHash bool) (*User, error)
 	SelectRevisionHistory(url string) ([]*Revision, error)
+	// InsertRevisionTag tags revision id of the article at url, moving the
+	// tag from any other revision of it.
+	InsertRevisionTag(url string, id int, tag string, userID int) error
+	// SelectRevisionTags selects the tags of the article at url, newest
+	// revision first.
+	SelectRevisionTags(url string) ([]*RevisionTag, error)
+	SelectTaggedRevisionID(url, tag string) (int, error)
+	SelectRecentRevisions(limit int, before time.Time) ([]*RevisionSummary, error)
+	// SelectWatchedRevisions is SelectRecentRevisions limited to the pages
+	// on the user's watchlist.
+	SelectWatchedRevisions(userID, limit int, before time.Time) ([]*RevisionSummary, error)
+	InsertWatch(userID int, url string) error
+	DeleteWatch(userID int, url string) error
+	SelectWatching(userID int, url string) (bool, error)
+	// SelectWatchers selects the users watching the page at url.
+	SelectWatchers(url string) ([]*User, error)
+	SelectPendingArticles() ([]*Article, error)
+	SelectBacklinks(target string) ([]*ArticleSummary, error)
+	SelectBacklinksPaged(target string, limit, offset int) ([]*ArticleSummary, int, error)
+	SelectEmbedders(target string) ([]*ArticleSummary, error)
+	SelectOutboundDeadlinks(url string) ([]string, error)
+	// SelectOrphanedArticles selects the articles not awaiting moderation
+	// that no other article links to or embeds.
+	SelectOrphanedArticles() ([]*ArticleSummary, error)
+	// SelectDeadEndArticles selects the articles not awaiting moderation that
+	// link to no other article.
+	SelectDeadEndArticles() ([]*ArticleSummary, error)
+	SelectWikiStatistics() (*Stats, error)
+	// SelectWantedPages selects the targets of links to articles that don't
+	// exist, with the number of articles linking to each, most first.
+	SelectWantedPages() ([]WantedPage, error)
+	SelectCategories() ([]*Category, error)
+	SelectCategoryMembers(name string) ([]*ArticleSummary, error)
+	SelectArticleCategories(url string) ([]*Category, error)
+	SelectContributors(url string) ([]*Contributor, error)
+	// SelectArticleSummariesByLength selects at most limit articles not
+	// awaiting moderation whose URLs are between minLength and maxLength
+	// characters long, those closest to length first.
+	SelectArticleSummariesByLength(length, minLength, maxLength, limit int) ([]*ArticleSummary, error)
+	// SelectArticleSummariesFrom selects at most limit articles not awaiting
+	// moderation, ordered by URL, starting at from. Only URLs starting with
+	// prefix are selected, and none starting with exclude unless it is empty.
+	SelectArticleSummariesFrom(from, prefix, exclude string, limit int) ([]*ArticleSummary, error)
+	// SelectShingleOverlaps selects every article not awaiting moderation
+	// that shares any of hashes, with how many it shares.
+	SelectShingleOverlaps(hashes []int64) ([]*ShingleOverlap, error)
+	// SelectArticleURLs selects the URL of every article, including those
+	// awaiting moderation.
+	SelectArticleURLs() ([]string, error)
+	SelectArticleActivity(since time.Time) ([]*ArticleActivity, error)
+	SelectStaleArticles(olderThan time.Duration) ([]*Article, error)
+	UpdateArticleReviewed(url string, reviewed time.Time) error
+	SelectProtection(url string) (ProtectionLevel, error)
+	UpdateProtection(url string, level ProtectionLevel) error
+	ArticleExists(url string) (bool, error)
+	UpdateRevisionHTML(url string, id int, html, fingerprint string) error
+	// UpdateAnchors replaces the section ids recorded for the article at url.
+	UpdateAnchors(url string, anchors []string) error
+	// SelectAnchors selects the section ids recorded for the article at url,
+	// in order.
+	SelectAnchors(url string) ([]string, error)
+	// CountRevisionsWithHTML counts the revisions whose HTML is html.
+	CountRevisionsWithHTML(html string) (int, error)
+	// SelectQueuedRevisions selects the revisions of undeleted articles saved
+	// with RenderPendingHTML, oldest first.
+	SelectQueuedRevisions() ([]*QueuedRevision, error)
 	InsertArticle(article *Article) error
+	ApproveArticle(url string) error
+	DeleteArticle(url string) error
+	SoftDeleteArticle(url string) error
+	RestoreArticle(url string) error
+	ArticleDeleted(url string) (bool, error)
+	SelectDeletedArticles() ([]*DeletedArticle, error)
+	MoveArticle(oldURL, newURL string) error
 	InsertUser(user *User) error
+	UpdateVerificationToken(userID int, token string, expires time.Time) error
+	SelectUsers() ([]*User, error)
+	UpdateUserRole(screenname string, role Role) error
+	// UpdateEmailVerified marks the user with token as verified if it is
+	// still valid at now.
+	UpdateEmailVerified(token string, now time.Time) (screenname string, err error)
 	InsertPreference(pref *Preference) error
 	SelectPreference(key string) (*Preference, error)
+	SelectUserPreferences(userID int) (map[string]string, error)
+	InsertUserPreferences(userID int, prefs map[string]string) error
+	DeleteExpiredSessions(now time.Time) (int, error)
+	InsertLoginSession(info *SessionInfo, userID int) error
+	SelectUserSessions(userID int, now time.Time) ([]*SessionInfo, error)
+	DeleteUserSession(userID int, sessionID string) error
+	DeleteUserSessions(userID int) error
+	InsertAsset(asset *Asset) error
+	SelectAsset(name string) (*Asset, error)
 
 	// For cookie store, delete isn't part of the interface for some reason
 	sessions.Store
@@ -87,15 +421,19 @@ func (article *Article) String() string {
 }
 
 type Revision struct {
-	ID         int    `db:"id"`
-	Title      string `db:"title"`
-	Markdown   string `db:"markdown"`
-	HTML       string `db:"html"`
-	Hash       string `db:"hashval"`
-	Creator    *User
-	Created    time.Time `db:"created"`
-	PreviousID int       `db:"previous_id"`
-	Comment    string    `db:"comment"`
+	ID       int    `db:"id"`
+	Title    string `db:"title"`
+	Markdown string `db:"markdown"`
+	HTML     string `db:"html"`
+	// RenderFingerprint identifies the renderer that produced HTML. It is
+	// empty while the HTML is pending or for revisions saved before
+	// fingerprints were recorded.
+	RenderFingerprint string `db:"render_fingerprint"`
+	Hash              string `db:"hashval"`
+	Creator           *User
+	Created           time.Time `db:"created"`
+	PreviousID        int       `db:"previous_id"`
+	Comment           string    `db:"comment"`
 }
 
 type User struct {
@@ -103,9 +441,46 @@ type User struct {
 	ScreenName   string `db:"screenname"`
 	ID           int    `db:"id"`
 	PasswordHash string `db:"passwordhash"`
-	RawPassword  string
-	IPAddress    string
-	// Role
+	Role         Role   `db:"role"`
+	// EmailVerified is set once the user follows the link sent to Email,
+	// whose token is VerificationToken when the user is registered. The link
+	// works until VerificationExpires.
+	EmailVerified       bool      `db:"email_verified"`
+	VerificationToken   string    `db:"-"`
+	VerificationExpires time.Time `db:"-"`
+	RawPassword         string
+	IPAddress           string
+}
+
+// Role determines what a registered user is allowed to do.
+type Role string
+
+const (
+	RoleUser Role = "user"
+	// RoleEditor may also delete and move articles, but not manage the wiki.
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// IsAnonymous reports whether u is the shared anonymous user.
+func (u *User) IsAnonymous() bool {
+	return u == nil || u.ID == 0
+}
+
+// IsAdmin reports whether u has the admin role.
+func (u *User) IsAdmin() bool {
+	return !u.IsAnonymous() && u.Role == RoleAdmin
+}
+
+// CanManageArticles reports whether u may delete and move articles, which
+// editors and admins may.
+func (u *User) CanManageArticles() bool {
+	return !u.IsAnonymous() && (u.Role == RoleEditor || u.Role == RoleAdmin)
+}
+
+// IsTrusted reports whether u's contributions bypass moderation.
+func (u *User) IsTrusted() bool {
+	return u.CanManageArticles()
 }
 
 func (u *User) SetPasswordHash() error {
@@ -120,13 +495,131 @@ func (u *User) SetPasswordHash() error {
 	return nil
 }
 
-func New(db db, conf *Config, s *bluemonday.Policy) *WikiModel {
-	return &WikiModel{
+// New returns a model storing articles in db and sanitizing rendered HTML
+// with s, whose version is policyVersion.
+func New(db db, conf *Config, s *bluemonday.Policy, policyVersion int) *WikiModel {
+	model := &WikiModel{
 		db:        db,
 		Config:    conf,
 		sanitizer: s,
-		renderer:  render.NewHTMLRenderer(),
 	}
+	renderOpts := []render.Option{
+		render.WithExistenceChecker(model.articleExists),
+		render.WithRedirectChecker(model.ResolveRedirect),
+		render.WithSanitizerPolicy(policyVersion),
+	}
+	if conf.CheckWikiLinkAnchors {
+		renderOpts = append(renderOpts, render.WithAnchorChecker(model.hasAnchor))
+	}
+	if conf.DefinitionAnchors {
+		renderOpts = append(renderOpts, render.WithDefinitionAnchors())
+	}
+	if conf.TaskLists {
+		renderOpts = append(renderOpts, render.WithTaskLists())
+	}
+	if conf.SyntaxHighlighting != "" {
+		renderOpts = append(renderOpts, render.WithSyntaxHighlighting(conf.SyntaxHighlighting))
+	}
+	if conf.MathRendering {
+		renderOpts = append(renderOpts, render.WithMathRendering(true))
+	}
+	if conf.TableOfContents {
+		renderOpts = append(renderOpts, render.WithTableOfContents(true))
+	}
+	if conf.Diagrams {
+		renderOpts = append(renderOpts, render.WithDiagrams(true, conf.DotPath))
+	}
+	if conf.MaxNestingDepth > 0 {
+		renderOpts = append(renderOpts, render.WithMaxNestingDepth(conf.MaxNestingDepth))
+	}
+	if conf.Navboxes {
+		renderOpts = append(renderOpts, render.WithNavboxes(model.navboxHTML))
+	}
+	if conf.Transclusion {
+		renderOpts = append(renderOpts, render.WithTransclusion(model.transcludedMarkdown))
+	}
+	if conf.MarkExternalLinks {
+		renderOpts = append(renderOpts, render.WithExternalLinkPolicy(render.ExternalLinkPolicy{
+			Hosts: []string{conf.Host},
+			Icon:  conf.ExternalLinkIcon,
+		}))
+	}
+	model.renderer = render.NewHTMLRenderer(renderOpts...)
+	if conf.MaxConcurrentRenders > 0 {
+		model.renderSlots = make(chan struct{}, conf.MaxConcurrentRenders)
+	}
+	model.loadSettings()
+	var queueOpts []renderqueue.Option
+	if conf.EnableMetrics {
+		queueOpts = model.registerMetrics()
+	}
+	if conf.RenderWorkers > 0 {
+		queueOpts = append(queueOpts, renderqueue.WithTimeout(func() time.Duration {
+			return model.Settings().MaxRenderDuration
+		}), renderqueue.WithMaxWait(func() time.Duration {
+			return model.Settings().BackgroundRenderMaxWait
+		}))
+		model.queue = renderqueue.New(model.Settings().RenderWorkers, model.renderQueued, queueOpts...)
+	}
+	if conf.CleanupInterval > 0 {
+		model.janitor = startJanitor(conf.CleanupInterval, model.cleanUp)
+	}
+
+	return model
+}
+
+// BackgroundRendering reports whether the wiki has a render queue, i.e.
+// whether it started with any render workers.
+func (model *WikiModel) BackgroundRendering() bool {
+	return model.queue != nil
+}
+
+// RenderQueueSnapshot returns the state of the background render queue, or
+// false if renders run synchronously.
+func (model *WikiModel) RenderQueueSnapshot() (renderqueue.Snapshot, bool) {
+	if model.queue == nil {
+		return renderqueue.Snapshot{}, false
+	}
+	return model.queue.Snapshot(), true
+}
+
+// RenderQueueStats returns the background render queue's counts and average
+// latency, or false if renders run synchronously.
+func (model *WikiModel) RenderQueueStats() (renderqueue.QueueStats, bool) {
+	if model.queue == nil {
+		return renderqueue.QueueStats{}, false
+	}
+	return model.queue.Stats(), true
+}
+
+// CountRenderPending counts the revisions saved with RenderPendingHTML that
+// haven't been rendered yet.
+func (model *WikiModel) CountRenderPending() (int, error) {
+	return model.db.CountRevisionsWithHTML(RenderPendingHTML)
+}
+
+// Close stops the janitor and waits for queued renders to finish.
+func (model *WikiModel) Close() {
+	if model.janitor != nil {
+		model.janitor.Stop()
+	}
+	if model.queue != nil {
+		model.queue.Close()
+	}
+}
+
+// articleExists reports whether WikiLinks to the article at url lead
+// anywhere. Like navboxes, articles awaiting review are treated as missing,
+// so links to them don't give them away.
+func (model *WikiModel) articleExists(url string) bool {
+	article, err := model.db.SelectArticle(url)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return false
+	}
+	return !article.Pending
 }
 
 func (model *WikiModel) GetArticle(url string) (*Article, error) {
@@ -137,7 +630,7 @@ func (model *WikiModel) GetArticle(url string) (*Article, error) {
 		return nil, err
 	}
 
-	return article, err
+	return article, model.refreshHTML(article)
 }
 
 var ErrUsernameTaken = errors.New("username already in use")
@@ -151,6 +644,12 @@ var ErrArticleNotModified = errors.New("article not modified")
 var ErrRevisionNotFound = errors.New("revision not found")
 var ErrRevisionAlreadyExists = errors.New("revision already exists")
 var ErrGenericNotFound = errors.New("not found")
+var ErrTalkSubjectNotFound = errors.New("cannot create a talk page for an article that does not exist")
+var ErrArticleNotPending = errors.New("article is not pending review")
+var ErrArticleDeleted = errors.New("a deleted article is at this URL; restore or purge it from the recycle bin first")
+var ErrArticleProtected = errors.New("article is protected")
+var ErrBadReviewedDate = errors.New("reviewed date must be formatted as YYYY-MM-DD")
+var ErrEmptyArticle = errors.New("article is empty; to request its deletion, blank the page instead")
 
 func (model *WikiModel) UpdatePreference(pref *Preference) error {
 	return model.db.InsertPreference(pref)
@@ -165,8 +664,47 @@ func (model *WikiModel) GetPreference(key string) (*Preference, error) {
 	return pref, err
 }
 
+// RenderPendingHTML stands in for the HTML of a revision that is still being
+// rendered in the background.
+const RenderPendingHTML = `<p class="pw-render-pending">This revision is still being rendered…</p>`
+
+// RenderFailedHTML stands in for the HTML of a revision whose background
+// render failed or took longer than MaxRenderDuration.
+const RenderFailedHTML = `<p class="pw-render-failed">This revision couldn't be rendered.</p>`
+
+// RenderTier returns the tier at which an edit of markdown is rendered. Edits
+// are rendered before they are saved unless they are larger than
+// LargeArticleRenderBytes, in which case they are saved with RenderPendingHTML
+// and rendered by the background workers.
+func (model *WikiModel) RenderTier(markdown string) renderqueue.Tier {
+	if model.queue != nil && model.LargeArticleRenderBytes > 0 && len(markdown) > model.LargeArticleRenderBytes {
+		return renderqueue.TierBackground
+	}
+	return renderqueue.TierInteractive
+}
+
+// HighlightCSS returns the stylesheet for highlighted code blocks, or "" if
+// SyntaxHighlighting is off.
+func (model *WikiModel) HighlightCSS() (string, error) {
+	return model.renderer.HighlightCSS()
+}
+
+// Render converts markdown, less any frontmatter, to sanitized HTML. If
+// MaxConcurrentRenders renders are already running, it waits for one to
+// finish.
 func (model *WikiModel) Render(markdown string) (string, error) {
-	unsafe, err := model.renderer.Render(markdown)
+	return model.render("", markdown)
+}
+
+// render is Render for the markdown of the article at url, which may be "".
+func (model *WikiModel) render(url, markdown string) (string, error) {
+	if model.renderSlots != nil {
+		model.renderSlots <- struct{}{}
+		defer func() { <-model.renderSlots }()
+	}
+
+	_, body := ParseFrontmatter(markdown)
+	unsafe, err := model.renderer.RenderArticle(url, body)
 
 	if err != nil {
 		return "", err
@@ -175,32 +713,150 @@ func (model *WikiModel) Render(markdown string) (string, error) {
 	return model.sanitizer.Sanitize(string(unsafe)), nil
 }
 
+// renderArticle renders the source of the article at url. Site code pages are
+// shown as code rather than rendered as Markdown.
+func (model *WikiModel) renderArticle(url string, source string) (string, error) {
+	if IsSiteCode(url) {
+		return "<pre><code>" + html.EscapeString(source) + "</code></pre>", nil
+	}
+	return model.render(url, source)
+}
+
+// revisionHash is the hashval identifying a revision's content.
+func revisionHash(title, markdown string) string {
+	x := sha512.Sum384([]byte(title + markdown))
+	return base64.URLEncoding.EncodeToString(x[:])
+}
+
 func (model *WikiModel) PostArticle(article *Article) error {
-	x := sha512.Sum384([]byte(article.Title + article.Markdown))
-	article.Hash = base64.URLEncoding.EncodeToString(x[:])
+	article.URL = model.canonicalURL(article.URL)
 
-	sourceRevision, err := model.GetArticleByRevisionID(article.URL, article.PreviousID)
-	if err != ErrRevisionNotFound {
-		if sourceRevision.Hash == article.Hash {
-			return ErrArticleNotModified
+	if model.CanEdit(article, article.Creator) != EditActionEdit {
+		return ErrArticleProtected
+	}
+
+	article.Hash = revisionHash(article.Title, article.Markdown)
+
+	if IsTalkPage(article.URL) {
+		_, err := model.GetArticle(SubjectURL(article.URL))
+		if err == ErrGenericNotFound {
+			return ErrTalkSubjectNotFound
 		} else if err != nil {
 			return err
 		}
 	}
 
+	isNew := false
+	sourceRevision, err := model.GetArticleByRevisionID(article.URL, article.PreviousID)
+	if err == ErrRevisionNotFound {
+		// The URL isn't free until the deleted article is restored or purged.
+		if deleted, err := model.db.ArticleDeleted(article.URL); err != nil {
+			return err
+		} else if deleted {
+			return ErrArticleDeleted
+		}
+		isNew = article.PreviousID == 0
+	} else if err != nil {
+		return err
+	} else if sourceRevision.Hash == article.Hash {
+		return ErrArticleNotModified
+	}
+
 	strip := bluemonday.StrictPolicy()
 
 	article.Title = strip.Sanitize(article.Title)
 	article.Comment = strip.Sanitize(article.Comment)
 
-	html, err := model.Render(article.Markdown)
+	frontmatter, body := ParseFrontmatter(article.Markdown)
+	reviewed, ok, err := frontmatter.ReviewedAt()
 	if err != nil {
 		return err
 	}
+	article.ReviewedAt = sql.NullTime{Time: reviewed, Valid: ok}
+
+	refuseEmpty := !model.AllowEmptyArticles && !(article.Blanking && !isNew)
+	if !IsSiteCode(article.URL) {
+		// Refuse what the render would, even if it happens after saving.
+		if err := model.renderer.CheckNesting(article.URL, body); err != nil {
+			return err
+		}
+		if refuseEmpty && strings.TrimSpace(body) == "" {
+			return ErrEmptyArticle
+		}
+	}
+
+	tier := model.RenderTier(article.Markdown)
+	if tier == renderqueue.TierInteractive {
+		html, err := model.renderArticle(article.URL, article.Markdown)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(html) == "" && refuseEmpty {
+			return ErrEmptyArticle
+		}
+		article.HTML = html
+		article.RenderFingerprint = model.renderer.Fingerprint()
+	} else {
+		article.HTML = RenderPendingHTML
+	}
+
+	if !IsSiteCode(article.URL) {
+		article.Links = model.renderer.Links(article.URL, body)
+		article.Embeds = model.renderer.Embeds(article.URL, body)
+		article.Categories = model.renderer.Categories(article.URL, body)
+		article.Anchors = model.renderer.Anchors(article.URL, body)
+	}
+	article.Shingles = ShingleHashes(article.Markdown)
+	anchorsChanged := !isNew && model.CheckWikiLinkAnchors && model.anchorsChanged(article)
+	redirectChanged := !isNew && model.redirectChanged(article)
+	article.Pending = isNew && model.ModerateNewArticles && !article.Creator.IsTrusted()
+
+	if err := model.db.InsertArticle(article); err != nil {
+		return err
+	}
+
+	if tier == renderqueue.TierBackground {
+		model.queue.SubmitRevision(article.URL, article.ID, tier)
+	}
+	model.InvalidateEmbeddersAsync(article.URL)
+	if !article.Pending {
+		model.notifyWatchersAsync(article)
+	}
+
+	if !isNew {
+		// Links to its sections may have come or gone, or now lead elsewhere.
+		if anchorsChanged || redirectChanged {
+			model.InvalidateBacklinkersAsync(article.URL)
+		}
+		return nil
+	}
 
-	article.HTML = html
+	model.InvalidateBacklinkersAsync(article.URL)
 
-	return model.db.InsertArticle(article)
+	if model.AutoCreateTalkPages && !IsTalkPage(article.URL) && !IsSpecialPage(article.URL) {
+		// The subject is already saved, so failing now would report a save
+		// that happened and make a retry conflict with it.
+		if err := model.createTalkPage(article); err != nil {
+			log.Printf("failed to create the talk page for %s: %v", article.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// createTalkPage seeds an empty talk page for a newly created subject article,
+// attributed to the subject's creator.
+func (model *WikiModel) createTalkPage(subject *Article) error {
+	talk := NewArticle(TalkPageURL(subject.URL), TalkNamespace+subject.Title,
+		fmt.Sprintf("This is the talk page for discussing [[%s|%s]].", subject.URL, subject.Title))
+	talk.Creator = subject.Creator
+	talk.Comment = "Created talk page"
+
+	err := model.PostArticle(talk)
+	if err == ErrRevisionAlreadyExists {
+		return nil
+	}
+	return err
 }
 
 func (model *WikiModel) PreviewMarkdown(markdown string) (string, error) {
@@ -231,7 +887,17 @@ func (model *WikiModel) PostUser(user *User) error {
 		return err
 	}
 
-	return model.db.InsertUser(user)
+	if err := issueVerificationToken(user); err != nil {
+		return err
+	}
+	if err := model.db.InsertUser(user); err != nil {
+		return err
+	}
+
+	if model.RequiresVerification(user) {
+		return model.sendVerification(user)
+	}
+	return nil
 }
 
 func AnonymousUser() *User {
@@ -267,9 +933,17 @@ func (model *WikiModel) CheckUserPassword(u *User) error {
 	err = bcrypt.CompareHashAndPassword([]byte(dbUser.PasswordHash), []byte(u.RawPassword))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		return ErrIncorrectPassword
+	} else if err != nil {
+		return err
 	}
 
-	return err
+	// Use the stored casing, the screenname may have been matched ignoring case.
+	u.ScreenName = dbUser.ScreenName
+	u.ID = dbUser.ID
+	if model.RequiresVerification(dbUser) {
+		return ErrEmailNotVerified
+	}
+	return nil
 }
 
 func (model *WikiModel) GetUserByScreenName(screenname string) (*User, error) {
@@ -285,18 +959,35 @@ func (model *WikiModel) GetArticleByRevisionHash(url string, hash string) (*Arti
 	revision, err := model.db.SelectArticleByRevisionHash(url, hash)
 	if err == sql.ErrNoRows {
 		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
 	}
 
-	return revision, err
+	return revision, model.refreshHTML(revision)
 }
 
 func (model *WikiModel) GetArticleByRevisionID(url string, id int) (*Article, error) {
 	revision, err := model.db.SelectArticleByRevisionID(url, id)
 	if err == sql.ErrNoRows {
 		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return revision, model.refreshHTML(revision)
+}
+
+// GetArticleAsOf returns the article as it was at t: its latest revision
+// created before or at that time.
+func (model *WikiModel) GetArticleAsOf(url string, t time.Time) (*Article, error) {
+	revision, err := model.db.SelectRevisionAsOf(url, t)
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
 	}
 
-	return revision, err
+	return revision, model.refreshHTML(revision)
 }
 
 func (model *WikiModel) GetRevisionHistory(url string) ([]*Revision, error) {
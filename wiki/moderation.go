@@ -0,0 +1,49 @@
+package wiki
+
+import "log"
+
+// GetPendingArticles returns every article awaiting review, oldest first.
+func (model *WikiModel) GetPendingArticles() ([]*Article, error) {
+	return model.db.SelectPendingArticles()
+}
+
+// ApproveArticle publishes a pending article.
+func (model *WikiModel) ApproveArticle(url string) error {
+	article, err := model.GetArticle(url)
+	if err != nil {
+		return err
+	}
+	if !article.Pending {
+		return ErrArticleNotPending
+	}
+
+	if err := model.db.ApproveArticle(url); err != nil {
+		return err
+	}
+
+	// Links to it were dead until now.
+	model.InvalidateBacklinkersAsync(url)
+	model.InvalidateEmbeddersAsync(url)
+	return nil
+}
+
+// RejectArticle deletes a pending article along with its revisions. The
+// reason, if any, is only logged.
+func (model *WikiModel) RejectArticle(url string, reason string) error {
+	article, err := model.GetArticle(url)
+	if err != nil {
+		return err
+	}
+	if !article.Pending {
+		return ErrArticleNotPending
+	}
+
+	log.Printf("rejected pending article %s: %s", url, reason)
+	if err := model.db.DeleteArticle(url); err != nil {
+		return err
+	}
+
+	model.InvalidateBacklinkersAsync(url)
+	model.InvalidateEmbeddersAsync(url)
+	return nil
+}
@@ -0,0 +1,74 @@
+package wiki
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTargetExists is returned when moving an article to a URL that already
+// has one.
+var ErrTargetExists = errors.New("an article already exists at that URL")
+
+// RedirectMarkdown is the source of a page redirecting readers to the
+// article at url.
+func RedirectMarkdown(url string) string {
+	return fmt.Sprintf("---\nredirect: %q\n---\nThis article has moved to [[%s]].\n", url, url)
+}
+
+// RedirectTarget returns the URL the article redirects readers to, if it is
+// a redirect.
+func (article *Article) RedirectTarget() string {
+	fm, _ := ParseFrontmatter(article.Markdown)
+	return fm.Redirect
+}
+
+// MoveArticle moves the article at oldURL, with its history, to newURL on
+// behalf of user, and leaves a redirect to it at oldURL. Links to oldURL are
+// counted as links to newURL from then on.
+func (model *WikiModel) MoveArticle(oldURL, newURL string, user *User) error {
+	newURL = model.canonicalURL(newURL)
+
+	article, err := model.GetArticle(oldURL)
+	if err != nil {
+		return err
+	}
+	if model.CanEdit(article, user) != EditActionEdit || model.CanEdit(NewArticle(newURL, "", ""), user) != EditActionEdit {
+		return ErrArticleProtected
+	}
+
+	exists, err := model.db.ArticleExists(newURL)
+	if err != nil {
+		return err
+	} else if exists {
+		return ErrTargetExists
+	}
+	if deleted, err := model.db.ArticleDeleted(newURL); err != nil {
+		return err
+	} else if deleted {
+		return ErrArticleDeleted
+	}
+
+	if IsTalkPage(newURL) {
+		_, err := model.GetArticle(SubjectURL(newURL))
+		if err == ErrGenericNotFound {
+			return ErrTalkSubjectNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := model.db.MoveArticle(oldURL, newURL); err != nil {
+		return err
+	}
+
+	stub := NewArticle(oldURL, article.Title, RedirectMarkdown(newURL))
+	stub.Creator = user
+	stub.Comment = fmt.Sprintf("Moved to [[%s]]", newURL)
+	if err := model.PostArticle(stub); err != nil {
+		return err
+	}
+
+	model.InvalidateBacklinkersAsync(newURL)
+	model.InvalidateEmbeddersAsync(oldURL)
+	return nil
+}
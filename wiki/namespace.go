@@ -0,0 +1,54 @@
+package wiki
+
+import "strings"
+
+// Namespaces lists the namespace prefixes periwiki recognises.
+var Namespaces = []string{TalkNamespace, SpecialNamespace, PeriwikiNamespace}
+
+// ResolveNamespace returns url with its namespace in canonical form. Known
+// namespaces are matched ignoring case, and the names in NamespaceAliases are
+// replaced by the namespace they stand for. A url outside any namespace, or
+// in one periwiki doesn't know, is returned unchanged.
+func (model *WikiModel) ResolveNamespace(url string) string {
+	name, rest, found := strings.Cut(url, ":")
+	if !found {
+		return url
+	}
+
+	for alias, namespace := range model.NamespaceAliases {
+		if strings.EqualFold(name, strings.TrimSuffix(alias, ":")) {
+			name = strings.TrimSuffix(namespace, ":")
+			break
+		}
+	}
+
+	for _, namespace := range Namespaces {
+		if strings.EqualFold(name+":", namespace) {
+			return namespace + rest
+		}
+	}
+	return url
+}
+
+// GetAllPages returns at most limit articles in URL order, starting with the
+// first at or after from, and the URL the next page starts from, or "" if
+// this is the last. With a namespace only its articles are listed; without
+// one, every article but those in the Periwiki namespace is. from is taken
+// to be in the namespace, e.g. "B" lists Talk:B onwards in the Talk namespace.
+func (model *WikiModel) GetAllPages(namespace, from string, limit int) ([]*ArticleSummary, string, error) {
+	exclude := ""
+	if namespace == "" {
+		exclude = PeriwikiNamespace
+	} else if !strings.HasPrefix(from, namespace) {
+		from = namespace + from
+	}
+
+	pages, err := model.db.SelectArticleSummariesFrom(from, namespace, exclude, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(pages) > limit {
+		return pages[:limit], pages[limit].URL, nil
+	}
+	return pages, "", nil
+}
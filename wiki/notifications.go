@@ -0,0 +1,181 @@
+package wiki
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const prefNotifyWatchedEdits = "notify_watched_edits"
+
+// WatchNotification tells a user about the edits made to a page they watch
+// within WatchNotificationDelay.
+type WatchNotification struct {
+	URL   string
+	Title string
+	// Editors are the screennames of those who made the edits, in the
+	// order of their first edit, and Comments the edit summaries given.
+	Editors  []string
+	Comments []string
+	Edits    int
+	// Delta is the change in size the edits made, in bytes.
+	Delta int
+	// DiffLink is the path of the diff of the edits, such as
+	// /wiki/Cats/diff/3/5, or of the page if it was created.
+	DiffLink string
+}
+
+// Summary describes the edits in a line, e.g. `Cats was edited 2 times by
+// alice, bob (+12 bytes)`.
+func (n *WatchNotification) Summary() string {
+	times := "once"
+	if n.Edits > 1 {
+		times = fmt.Sprintf("%d times", n.Edits)
+	}
+	return fmt.Sprintf("%s was edited %s by %s (%+d bytes)", n.Title, times, strings.Join(n.Editors, ", "), n.Delta)
+}
+
+// WatchNotificationSender delivers notification to user, who has opted in to
+// hearing about edits to the pages they watch.
+type WatchNotificationSender func(user *User, notification *WatchNotification) error
+
+// logWatchNotification is the WatchNotificationSender used when none is
+// set. Periwiki doesn't send mail itself, so notifications are logged.
+func logWatchNotification(user *User, notification *WatchNotification) error {
+	log.Printf("Watchlist notification for %s <%s>: %s: %s", user.ScreenName, user.Email, notification.Summary(), notification.DiffLink)
+	return nil
+}
+
+// watchBatch collects the edits made to a page until its watchers are
+// notified.
+type watchBatch struct {
+	// fromID is the revision before the first edit, and toID the last edit.
+	fromID, toID int
+	editorIDs    map[int]bool
+	editors      []string
+	comments     []string
+	edits        int
+}
+
+// NotifyWatchedEdits reports whether user has opted in to notifications of
+// edits to the pages they watch.
+func (model *WikiModel) NotifyWatchedEdits(user *User) (bool, error) {
+	if user.IsAnonymous() {
+		return false, nil
+	}
+	saved, err := model.db.SelectUserPreferences(user.ID)
+	if err != nil {
+		return false, err
+	}
+	notify, _ := strconv.ParseBool(saved[prefNotifyWatchedEdits])
+	return notify, nil
+}
+
+// UpdateNotifyWatchedEdits opts user in to or out of notifications of edits
+// to the pages they watch.
+func (model *WikiModel) UpdateNotifyWatchedEdits(user *User, notify bool) error {
+	if user.IsAnonymous() {
+		return ErrAnonymousPreferences
+	}
+	return model.db.InsertUserPreferences(user.ID, map[string]string{
+		prefNotifyWatchedEdits: strconv.FormatBool(notify),
+	})
+}
+
+// notifyWatchersAsync schedules notifications of article, just saved, to
+// the users watching it. Edits made within WatchNotificationDelay of the
+// first are sent as one notification.
+func (model *WikiModel) notifyWatchersAsync(article *Article) {
+	model.notifyMu.Lock()
+	defer model.notifyMu.Unlock()
+
+	batch := model.notifyBatches[article.URL]
+	if batch == nil {
+		if model.notifyBatches == nil {
+			model.notifyBatches = make(map[string]*watchBatch)
+		}
+		batch = &watchBatch{fromID: article.PreviousID, editorIDs: make(map[int]bool)}
+		model.notifyBatches[article.URL] = batch
+		url := article.URL
+		time.AfterFunc(model.WatchNotificationDelay, func() { model.flushWatchNotifications(url) })
+	}
+
+	batch.toID = article.ID
+	batch.edits++
+	batch.editorIDs[article.Creator.ID] = true
+	batch.editors = appendUnique(batch.editors, article.Creator.ScreenName)
+	if article.Comment != "" {
+		batch.comments = append(batch.comments, article.Comment)
+	}
+}
+
+// flushWatchNotifications notifies the users watching the page at url of
+// the edits collected for it, but not those who made them all.
+func (model *WikiModel) flushWatchNotifications(url string) {
+	model.notifyMu.Lock()
+	batch := model.notifyBatches[url]
+	delete(model.notifyBatches, url)
+	model.notifyMu.Unlock()
+
+	watchers, err := model.db.SelectWatchers(url)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(watchers) == 0 {
+		return
+	}
+
+	latest, err := model.GetArticleByRevisionID(url, batch.toID)
+	if err != nil {
+		// Deleted since, perhaps.
+		log.Println(err)
+		return
+	}
+	notification := &WatchNotification{
+		URL:      url,
+		Title:    latest.Title,
+		Editors:  batch.editors,
+		Comments: batch.comments,
+		Edits:    batch.edits,
+		Delta:    len(latest.Markdown),
+		DiffLink: "/wiki/" + url,
+	}
+	if batch.fromID > 0 {
+		if before, err := model.GetArticleByRevisionID(url, batch.fromID); err == nil {
+			notification.Delta -= len(before.Markdown)
+		}
+		notification.DiffLink = fmt.Sprintf("/wiki/%s/diff/%d/%d", url, batch.fromID, batch.toID)
+	}
+
+	send := model.SendWatchNotification
+	if send == nil {
+		send = logWatchNotification
+	}
+	for _, watcher := range watchers {
+		if len(batch.editorIDs) == 1 && batch.editorIDs[watcher.ID] {
+			continue
+		}
+		if notify, err := model.NotifyWatchedEdits(watcher); err != nil {
+			log.Println(err)
+			continue
+		} else if !notify {
+			continue
+		}
+		if err := send(watcher, notification); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// appendUnique appends s to list unless it is already there.
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,48 @@
+package wiki
+
+// EditAction is what a user is offered for an article's source: editing it,
+// or only viewing it.
+type EditAction string
+
+const (
+	EditActionEdit       EditAction = "edit"
+	EditActionViewSource EditAction = "source"
+)
+
+// Label is the text of the link to the action.
+func (action EditAction) Label() string {
+	if action == EditActionEdit {
+		return "Edit"
+	}
+	return "View source"
+}
+
+// Path is the action's path relative to a revision, as in
+// /wiki/{article}/r/{revision}/{path}.
+func (action EditAction) Path() string {
+	return string(action)
+}
+
+// IsProtected reports whether the article at url may only be edited by
+// administrators.
+func (model *WikiModel) IsProtected(url string) bool {
+	for _, protected := range model.ProtectedArticles {
+		if protected == url {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEdit returns the action user may take on article's source. Special pages
+// have no editable source, and protected articles can only be edited by users
+// their protection level allows. See GetProtection.
+func (model *WikiModel) CanEdit(article *Article, user *User) EditAction {
+	if IsSpecialPage(article.URL) {
+		return EditActionViewSource
+	}
+	if !model.GetProtection(article.URL).Allows(user) {
+		return EditActionViewSource
+	}
+	return EditActionEdit
+}
@@ -0,0 +1,79 @@
+package wiki
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// ProtectionLevel is who may edit an article.
+type ProtectionLevel string
+
+const (
+	// ProtectionNone lets anyone the wiki lets edit edit the article.
+	ProtectionNone ProtectionLevel = "none"
+	// ProtectionAutoconfirmed lets only registered users edit the article,
+	// even when anonymous editing is allowed.
+	ProtectionAutoconfirmed ProtectionLevel = "autoconfirmed"
+	// ProtectionAdmin lets only administrators edit the article.
+	ProtectionAdmin ProtectionLevel = "admin"
+)
+
+// ProtectionLevels lists the protection levels, least restrictive first.
+var ProtectionLevels = []ProtectionLevel{ProtectionNone, ProtectionAutoconfirmed, ProtectionAdmin}
+
+var ErrBadProtectionLevel = errors.New("protection level must be none, autoconfirmed or admin")
+
+// Allows reports whether user may edit an article protected at level.
+func (level ProtectionLevel) Allows(user *User) bool {
+	switch level {
+	case ProtectionAutoconfirmed:
+		return !user.IsAnonymous()
+	case ProtectionAdmin:
+		return user.IsAdmin()
+	}
+	return true
+}
+
+// Description says who may edit an article protected at level.
+func (level ProtectionLevel) Description() string {
+	switch level {
+	case ProtectionAutoconfirmed:
+		return "Only registered users can edit this article."
+	case ProtectionAdmin:
+		return "Only administrators can edit this article."
+	}
+	return "Anyone can edit this article."
+}
+
+// GetProtection returns the protection level of the article at url.
+// Articles listed in ProtectedArticles, and those in the Periwiki namespace,
+// are always protected at ProtectionAdmin.
+func (model *WikiModel) GetProtection(url string) ProtectionLevel {
+	if model.IsProtected(url) || IsPeriwikiPage(url) {
+		return ProtectionAdmin
+	}
+	level, err := model.db.SelectProtection(url)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return ProtectionNone
+	}
+	return level
+}
+
+// SetProtection changes who may edit the article at url.
+func (model *WikiModel) SetProtection(url string, level ProtectionLevel) error {
+	valid := false
+	for _, l := range ProtectionLevels {
+		valid = valid || l == level
+	}
+	if !valid {
+		return ErrBadProtectionLevel
+	}
+	if _, err := model.GetArticle(url); err != nil {
+		return err
+	}
+	return model.db.UpdateProtection(url, level)
+}
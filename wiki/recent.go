@@ -0,0 +1,38 @@
+package wiki
+
+import "time"
+
+// RevisionSummary describes a revision for lists of changes, without its
+// content.
+type RevisionSummary struct {
+	URL        string    `db:"url"`
+	ID         int       `db:"id"`
+	PreviousID int       `db:"previous_id"`
+	Title      string    `db:"title"`
+	ScreenName string    `db:"screenname"`
+	Comment    string    `db:"comment"`
+	Created    time.Time `db:"created"`
+	Pending    bool      `db:"pending"`
+	// Size and PreviousSize are the lengths in bytes of the revision's
+	// Markdown and of its previous revision's, if any.
+	Size         int `db:"size"`
+	PreviousSize int `db:"previous_size"`
+}
+
+// Delta is the change in size the revision made, in bytes.
+func (rev *RevisionSummary) Delta() int {
+	return rev.Size - rev.PreviousSize
+}
+
+// DiffURL returns the URL of the revision's diff, as Article.DiffURL does.
+func (rev *RevisionSummary) DiffURL(baseURL string) string {
+	article := &Article{URL: rev.URL, Revision: &Revision{ID: rev.ID, PreviousID: rev.PreviousID}}
+	return article.DiffURL(baseURL)
+}
+
+// GetRecentChanges returns up to limit revisions, newest first, created
+// before the given time, or the latest ones if it is zero. Use the Created
+// time of the last one as before to get the next page.
+func (model *WikiModel) GetRecentChanges(limit int, before time.Time) ([]*RevisionSummary, error) {
+	return model.db.SelectRecentRevisions(limit, before)
+}
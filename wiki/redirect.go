@@ -0,0 +1,30 @@
+package wiki
+
+import (
+	"database/sql"
+	"log"
+)
+
+// ResolveRedirect returns the URL that the article at url redirects to,
+// perhaps with a fragment, and whether it is a redirect at all. Only one hop
+// is followed, as readers are only sent on once.
+func (model *WikiModel) ResolveRedirect(url string) (string, bool) {
+	// Read the stored article directly: GetArticle may re-render it, and this
+	// is called while rendering.
+	article, err := model.db.SelectArticle(url)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return "", false
+	}
+	target := article.RedirectTarget()
+	return target, target != ""
+}
+
+// redirectChanged reports whether article, about to be saved, redirects
+// somewhere other than the revision it replaces did.
+func (model *WikiModel) redirectChanged(article *Article) bool {
+	before, _ := model.ResolveRedirect(article.URL)
+	return before != article.RedirectTarget()
+}
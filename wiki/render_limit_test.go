@@ -0,0 +1,58 @@
+package wiki
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danielledeleo/periwiki/render"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+func TestRenderRespectsMaxConcurrentRenders(t *testing.T) {
+	var running, peak int32
+	// Filling in a navbox happens mid-render, so it can see how many renders
+	// overlap.
+	embed := func(url string) (string, bool) {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "<p>" + url + "</p>", true
+	}
+
+	model := &WikiModel{
+		Config:      &Config{MaxConcurrentRenders: 2},
+		sanitizer:   bluemonday.UGCPolicy(),
+		renderer:    render.NewHTMLRenderer(render.WithNavboxes(embed)),
+		renderSlots: make(chan struct{}, 2),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			html, err := model.Render("{{nav:Box}}\n\nSome *text*.")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.Contains(html, "<p>Box</p>") || !strings.Contains(html, "<em>text</em>") {
+				t.Errorf("expected the navbox and text to be rendered, got %q", html)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := atomic.LoadInt32(&peak); peak != 2 {
+		t.Errorf("expected at most 2 renders at once, and for them to overlap; saw %d", peak)
+	}
+}
@@ -0,0 +1,164 @@
+package wiki
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danielledeleo/periwiki/renderqueue"
+)
+
+var ErrRerenderRunning = errors.New("every article is already being rendered again")
+
+// RerenderJob tracks a render of the head revision of every article.
+type RerenderJob struct {
+	Started time.Time
+	Total   int
+
+	mu                sync.Mutex
+	completed, failed int
+	finished          time.Time
+}
+
+// RerenderProgress is how far a RerenderJob has got.
+type RerenderProgress struct {
+	Started, Finished time.Time
+	// Completed counts the articles rendered, including the Failed ones, of
+	// Total.
+	Completed, Failed, Total int
+}
+
+// Done reports whether every article has been rendered, or the job was
+// cancelled.
+func (p RerenderProgress) Done() bool {
+	return !p.Finished.IsZero()
+}
+
+// Progress returns how many of the job's articles have been rendered.
+func (job *RerenderJob) Progress() RerenderProgress {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	return RerenderProgress{
+		Started:   job.Started,
+		Finished:  job.finished,
+		Completed: job.completed,
+		Failed:    job.failed,
+		Total:     job.Total,
+	}
+}
+
+func (job *RerenderJob) record(url string, err error) {
+	if err != nil {
+		log.Printf("re-render of %s failed: %v", url, err)
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.completed++
+	if err != nil {
+		job.failed++
+	}
+}
+
+func (job *RerenderJob) finish() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.finished = time.Now()
+}
+
+// RerenderAllAsync renders the head revision of every article again in the
+// background and returns a job reporting its progress. With render workers
+// the articles are queued at TierBackground, behind renders users are
+// waiting on. Cancelling ctx stops the job waiting for them. Only one job
+// runs at a time: while one is running it is returned with
+// ErrRerenderRunning.
+func (model *WikiModel) RerenderAllAsync(ctx context.Context) (*RerenderJob, error) {
+	model.rerenderMu.Lock()
+	defer model.rerenderMu.Unlock()
+
+	if job := model.rerenderJob; job != nil && !job.Progress().Done() {
+		return job, ErrRerenderRunning
+	}
+
+	urls, err := model.db.SelectArticleURLs()
+	if err != nil {
+		return nil, err
+	}
+	job := &RerenderJob{Started: time.Now(), Total: len(urls)}
+	model.rerenderJob = job
+
+	if model.queue == nil {
+		go func() {
+			defer job.finish()
+			for _, url := range urls {
+				if ctx.Err() != nil {
+					return
+				}
+				job.record(url, model.rerenderArticle(url))
+			}
+		}()
+		return job, nil
+	}
+
+	// An article already waiting in the queue isn't queued twice, but every
+	// submission is answered once the article is rendered, so each one is
+	// counted.
+	results := make([]<-chan renderqueue.Result, len(urls))
+	for i, url := range urls {
+		results[i] = model.queue.Submit(url, renderqueue.TierBackground)
+	}
+	go func() {
+		defer job.finish()
+		for _, result := range results {
+			select {
+			case r := <-result:
+				job.record(r.ArticleURL, r.Err)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return job, nil
+}
+
+// CurrentRerender returns the most recent job started by RerenderAllAsync,
+// or nil if there hasn't been one.
+func (model *WikiModel) CurrentRerender() *RerenderJob {
+	model.rerenderMu.Lock()
+	defer model.rerenderMu.Unlock()
+
+	return model.rerenderJob
+}
+
+// QueuedRevision is a revision that was saved with RenderPendingHTML for the
+// render queue to fill in.
+type QueuedRevision struct {
+	URL string `db:"url"`
+	ID  int    `db:"id"`
+}
+
+// ResubmitQueuedRenders queues the revisions still waiting to be rendered at
+// TierBackground. The queue is kept in memory, so these are left behind when
+// the server stops before it drains. Without render workers they are rendered
+// before it returns. It returns how many were resubmitted.
+func (model *WikiModel) ResubmitQueuedRenders() (int, error) {
+	queued, err := model.db.SelectQueuedRevisions()
+	if err != nil {
+		return 0, err
+	}
+	for _, revision := range queued {
+		if model.queue == nil {
+			if err := model.renderQueued(context.Background(), revision.URL, revision.ID); err != nil {
+				log.Printf("render of %s failed: %v", revision.URL, err)
+			}
+			continue
+		}
+		model.queue.SubmitRevision(revision.URL, revision.ID, renderqueue.TierBackground)
+	}
+	return len(queued), nil
+}
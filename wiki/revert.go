@@ -0,0 +1,30 @@
+package wiki
+
+import "fmt"
+
+// RevertSummary is the comment suggested for reverting an article to
+// revision id.
+func RevertSummary(id int) string {
+	return fmt.Sprintf("Reverted to revision %d", id)
+}
+
+// RevertArticle saves the content of revision id of the article at url as a
+// new revision by user, with the given comment. Reverting to the current
+// revision returns ErrArticleNotModified.
+func (model *WikiModel) RevertArticle(url string, id int, user *User, comment string) (*Article, error) {
+	target, err := model.GetArticleByRevisionID(url, id)
+	if err != nil {
+		return nil, err
+	}
+	head, err := model.GetArticle(url)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := NewArticle(url, target.Title, target.Markdown)
+	reverted.Creator = user
+	reverted.PreviousID = head.ID
+	reverted.Comment = comment
+
+	return reverted, model.PostArticle(reverted)
+}
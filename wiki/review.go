@@ -0,0 +1,29 @@
+package wiki
+
+import "time"
+
+// GetStaleArticles returns the published articles that have not been reviewed
+// within StaleContentAge, least recently reviewed first. Articles that have
+// never been reviewed come first. Talk and Special pages are not reviewed.
+func (model *WikiModel) GetStaleArticles() ([]*Article, error) {
+	articles, err := model.db.SelectStaleArticles(model.StaleContentAge)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make([]*Article, 0, len(articles))
+	for _, article := range articles {
+		if !IsTalkPage(article.URL) && !IsSpecialPage(article.URL) {
+			stale = append(stale, article)
+		}
+	}
+	return stale, nil
+}
+
+// MarkArticleReviewed records that the article at url was reviewed now.
+func (model *WikiModel) MarkArticleReviewed(url string) error {
+	if _, err := model.GetArticle(url); err != nil {
+		return err
+	}
+	return model.db.UpdateArticleReviewed(url, time.Now())
+}
@@ -1 +1,68 @@
 package wiki
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RevisionTag labels a revision of an article as a milestone, such as
+// "stable" or "reviewed".
+type RevisionTag struct {
+	RevisionID int    `db:"revision_id"`
+	Tag        string `db:"tag"`
+}
+
+var ErrBadRevisionTag = errors.New("tags must start with a letter and have only lowercase letters, digits, - and _")
+var ErrTagNotAllowed = errors.New("only editors and admins can tag revisions")
+
+// revisionTagPattern matches tags. They start with a letter so that they
+// can't be mistaken for revision IDs.
+var revisionTagPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// TagRevision tags revision id of the article at url on behalf of user, who
+// must be an editor or admin. A tag names one revision of an article, so
+// tagging another revision moves it.
+func (model *WikiModel) TagRevision(url string, id int, tag string, user *User) error {
+	if !user.CanManageArticles() {
+		return ErrTagNotAllowed
+	}
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !revisionTagPattern.MatchString(tag) {
+		return ErrBadRevisionTag
+	}
+	err := model.db.InsertRevisionTag(url, id, tag, user.ID)
+	if err == sql.ErrNoRows {
+		return ErrRevisionNotFound
+	}
+	return err
+}
+
+// GetRevisionTags returns the tags of the article at url by revision ID.
+func (model *WikiModel) GetRevisionTags(url string) (map[int][]string, error) {
+	tags, err := model.db.SelectRevisionTags(url)
+	if err != nil {
+		return nil, err
+	}
+	byRevision := make(map[int][]string)
+	for _, t := range tags {
+		byRevision[t.RevisionID] = append(byRevision[t.RevisionID], t.Tag)
+	}
+	return byRevision, nil
+}
+
+// ResolveRevision returns the ID of the revision of the article at url that
+// ref addresses, either by its ID or by a tag. It doesn't check that a
+// revision with a numeric ref exists.
+func (model *WikiModel) ResolveRevision(url, ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+	id, err := model.db.SelectTaggedRevisionID(url, strings.ToLower(ref))
+	if err == sql.ErrNoRows {
+		return 0, ErrRevisionNotFound
+	}
+	return id, err
+}
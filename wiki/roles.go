@@ -0,0 +1,45 @@
+package wiki
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Roles lists the roles, least privileged first.
+var Roles = []Role{RoleUser, RoleEditor, RoleAdmin}
+
+var ErrBadRole = errors.New("role must be user, editor or admin")
+var ErrOwnRole = errors.New("you can't change your own role")
+
+// GetUsers returns every registered user, by screenname.
+func (model *WikiModel) GetUsers() ([]*User, error) {
+	return model.db.SelectUsers()
+}
+
+// SetUserRole gives the user with screenname role, on behalf of by. Admins
+// can't change their own role, so that the wiki isn't left without one.
+func (model *WikiModel) SetUserRole(screenname string, role Role, by *User) error {
+	valid := false
+	for _, r := range Roles {
+		valid = valid || r == role
+	}
+	if !valid {
+		return ErrBadRole
+	}
+
+	user, err := model.GetUserByScreenName(screenname)
+	if err == ErrUsernameNotFound {
+		return ErrGenericNotFound
+	} else if err != nil {
+		return err
+	}
+	if user.ID == by.ID {
+		return ErrOwnRole
+	}
+
+	err = model.db.UpdateUserRole(user.ScreenName, role)
+	if err == sql.ErrNoRows {
+		return ErrGenericNotFound
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package wiki
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SessionInfo describes a login: when and from where the user logged in.
+type SessionInfo struct {
+	// ID is the session's ID in the session store.
+	ID        string    `db:"session_id"`
+	IPAddress string    `db:"ip"`
+	UserAgent string    `db:"user_agent"`
+	Created   time.Time `db:"created"`
+	Expires   time.Time `db:"expires_on"`
+}
+
+// RecordSession notes that user logged in to the session info describes.
+func (model *WikiModel) RecordSession(info *SessionInfo, user *User) error {
+	return model.db.InsertLoginSession(info, user.ID)
+}
+
+// GetUserSessions returns the logins of user that haven't expired, most
+// recent first.
+func (model *WikiModel) GetUserSessions(user *User) ([]*SessionInfo, error) {
+	return model.db.SelectUserSessions(user.ID, time.Now())
+}
+
+// RevokeSession logs user out of one of their sessions. It returns
+// ErrGenericNotFound if the session isn't theirs.
+func (model *WikiModel) RevokeSession(user *User, sessionID string) error {
+	err := model.db.DeleteUserSession(user.ID, sessionID)
+	if err == sql.ErrNoRows {
+		return ErrGenericNotFound
+	}
+	return err
+}
+
+// RevokeAllSessions logs user out everywhere.
+func (model *WikiModel) RevokeAllSessions(user *User) error {
+	return model.db.DeleteUserSessions(user.ID)
+}
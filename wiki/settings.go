@@ -0,0 +1,293 @@
+package wiki
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Settings are site options that admins can change at runtime from
+// /manage/settings. Each is persisted as a text Preference.
+type Settings struct {
+	SiteName    string
+	SiteTagline string
+	FaviconURL  string
+	LogoURL     string
+	// SiteNotice is Markdown shown as a banner on every page when set.
+	SiteNotice string
+	// ContentLicenseName is the license articles are available under, shown
+	// in the footer of every page when set, linking to ContentLicenseURL if
+	// that is set too.
+	ContentLicenseName string
+	ContentLicenseURL  string
+	// ActiveTheme names the theme of users who haven't chosen their own.
+	ActiveTheme string
+	// LoginRateLimit is how many times one address may try to log in or
+	// register within LoginRateWindow. Zero allows any number.
+	LoginRateLimit  int
+	LoginRateWindow time.Duration
+	// RequireEmailVerification keeps users who haven't verified their email
+	// address from logging in.
+	RequireEmailVerification bool
+	// RememberMeExpiry is how long a login lasts when "remember me" is
+	// checked. It is never shorter than the config's CookieExpiry.
+	RememberMeExpiry time.Duration
+	// DiffCacheSize is how many rendered diffs are kept in memory. Zero
+	// disables the cache.
+	DiffCacheSize int
+	// MaxRenderDuration is how long a background render may take before the
+	// revision is saved with RenderFailedHTML. Zero means no limit.
+	MaxRenderDuration time.Duration
+	// RenderWorkers is how many workers render in the background, if the
+	// wiki started with any. Zero pauses background renders.
+	RenderWorkers int
+	// BackgroundRenderMaxWait is how long a background render may wait before
+	// it is promoted ahead of newer interactive ones. Zero means never.
+	BackgroundRenderMaxWait time.Duration
+	// ContentSecurityPolicy is sent with every response, with CSPNonce and
+	// CSPStyleHashes filled in. It is not sent when empty.
+	ContentSecurityPolicy string
+	// ReferrerPolicy is sent with every response. It is not sent when empty.
+	ReferrerPolicy string
+
+	// SiteNoticeHTML is SiteNotice rendered, and SiteNoticeVersion
+	// identifies its text so that a dismissed notice reappears once it is
+	// changed. Neither is persisted.
+	SiteNoticeHTML    string
+	SiteNoticeVersion string
+}
+
+// Placeholders in ContentSecurityPolicy. CSPNonce becomes the nonce of the
+// page's inline scripts, and CSPStyleHashes the hashes of periwiki's own
+// inline style attributes.
+const (
+	CSPNonce       = "{nonce}"
+	CSPStyleHashes = "{style-hashes}"
+)
+
+// DefaultContentSecurityPolicy allows scripts and styles from the wiki
+// itself and images from anywhere, since articles may link to them.
+const DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' " + CSPNonce +
+	"; style-src 'self' " + CSPStyleHashes +
+	"; img-src * data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'self'"
+
+// DefaultReferrerPolicy sends other sites only the wiki's origin.
+const DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+
+// ReferrerPolicies lists the values ReferrerPolicy may take.
+var ReferrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// ValidLicenseURL reports whether u may be used as ContentLicenseURL: it
+// must be empty or an http or https URL. Any other scheme, such as
+// javascript:, would run in the footer of every page.
+func ValidLicenseURL(u string) bool {
+	if u == "" {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// originHost matches the host and optional port of an origin: a domain name,
+// an IPv4 address or a bracketed IPv6 address.
+var originHost = regexp.MustCompile(`^([A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*|\[[0-9A-Fa-f:.]+\])(:[0-9]{1,5})?$`)
+
+// ValidOrigin reports whether o is an origin that may be listed in
+// EmbedAllowedOrigins: an http or https scheme and a host, with an optional
+// port and nothing else. Anything more could smuggle directives into the
+// Content-Security-Policy it ends up in.
+func ValidOrigin(o string) bool {
+	parsed, err := url.Parse(o)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") &&
+		originHost.MatchString(parsed.Host) &&
+		o == parsed.Scheme+"://"+parsed.Host
+}
+
+// preferences maps the Preference label of each setting to its field.
+func (s *Settings) preferences() map[string]*string {
+	return map[string]*string{
+		"site_name":    &s.SiteName,
+		"site_tagline": &s.SiteTagline,
+		"favicon_url":  &s.FaviconURL,
+		"logo_url":     &s.LogoURL,
+		"site_notice":  &s.SiteNotice,
+
+		"content_license_name": &s.ContentLicenseName,
+		"content_license_url":  &s.ContentLicenseURL,
+		"active_theme":         &s.ActiveTheme,
+
+		"content_security_policy": &s.ContentSecurityPolicy,
+		"referrer_policy":         &s.ReferrerPolicy,
+	}
+}
+
+// intPreferences returns the Preference label and value of each numeric
+// setting. Durations are stored in nanoseconds and booleans as 0 or 1.
+func (s *Settings) intPreferences() map[string]int64 {
+	var requireVerification int64
+	if s.RequireEmailVerification {
+		requireVerification = 1
+	}
+	return map[string]int64{
+		"login_rate_limit":           int64(s.LoginRateLimit),
+		"login_rate_window":          int64(s.LoginRateWindow),
+		"require_email_verification": requireVerification,
+		"remember_me_expiry":         int64(s.RememberMeExpiry),
+		"diff_cache_size":            int64(s.DiffCacheSize),
+		"max_render_duration":        int64(s.MaxRenderDuration),
+		"render_workers":             int64(s.RenderWorkers),
+		"background_render_max_wait": int64(s.BackgroundRenderMaxWait),
+	}
+}
+
+// loadSettings starts from the config file's values and applies any settings
+// previously saved by an admin.
+func (model *WikiModel) loadSettings() {
+	settings := Settings{
+		SiteName:   model.SiteName,
+		FaviconURL: "/static/favicon.ico",
+		LogoURL:    "/static/logo.svg",
+		SiteNotice: model.Config.SiteNotice,
+
+		ContentLicenseName: model.Config.ContentLicenseName,
+		ContentLicenseURL:  model.Config.ContentLicenseURL,
+		ActiveTheme:        model.Config.Theme,
+
+		LoginRateLimit:  model.Config.LoginRateLimit,
+		LoginRateWindow: model.Config.LoginRateWindow,
+
+		RequireEmailVerification: model.Config.RequireEmailVerification,
+		RememberMeExpiry:         model.Config.RememberMeExpiry,
+
+		DiffCacheSize:           model.Config.DiffCacheSize,
+		MaxRenderDuration:       model.Config.MaxRenderDuration,
+		RenderWorkers:           model.Config.RenderWorkers,
+		BackgroundRenderMaxWait: model.Config.BackgroundRenderMaxWait,
+
+		ContentSecurityPolicy: DefaultContentSecurityPolicy,
+		ReferrerPolicy:        DefaultReferrerPolicy,
+	}
+	if settings.SiteName == "" {
+		settings.SiteName = "periwiki"
+	}
+	if settings.ActiveTheme == "" {
+		settings.ActiveTheme = ThemeLight
+	}
+
+	for label, field := range settings.preferences() {
+		pref, err := model.GetPreference(label)
+		if err == ErrGenericNotFound {
+			continue
+		} else if err != nil {
+			log.Println(err)
+			continue
+		}
+		*field = pref.TextValue.String
+	}
+	ints := settings.intPreferences()
+	for label := range ints {
+		pref, err := model.GetPreference(label)
+		if err == ErrGenericNotFound {
+			continue
+		} else if err != nil {
+			log.Println(err)
+			continue
+		}
+		ints[label] = pref.IntValue.Int64
+	}
+	settings.LoginRateLimit = int(ints["login_rate_limit"])
+	settings.LoginRateWindow = time.Duration(ints["login_rate_window"])
+	settings.RequireEmailVerification = ints["require_email_verification"] != 0
+	settings.RememberMeExpiry = time.Duration(ints["remember_me_expiry"])
+	settings.DiffCacheSize = int(ints["diff_cache_size"])
+	settings.MaxRenderDuration = time.Duration(ints["max_render_duration"])
+	settings.RenderWorkers = int(ints["render_workers"])
+	settings.BackgroundRenderMaxWait = time.Duration(ints["background_render_max_wait"])
+	if !ValidLicenseURL(settings.ContentLicenseURL) {
+		// Saved before license URLs were limited to http and https.
+		log.Printf("ignoring the saved license URL %q: it must be an http or https URL", settings.ContentLicenseURL)
+		settings.ContentLicenseURL = ""
+	}
+	model.renderSiteNotice(&settings)
+
+	model.settingsMu.Lock()
+	model.settings = settings
+	model.settingsMu.Unlock()
+}
+
+// Settings returns a copy of the current site settings.
+func (model *WikiModel) Settings() Settings {
+	model.settingsMu.RLock()
+	defer model.settingsMu.RUnlock()
+
+	return model.settings
+}
+
+// UpdateSettings persists settings and makes them current.
+func (model *WikiModel) UpdateSettings(settings Settings) error {
+	for label, field := range settings.preferences() {
+		err := model.UpdatePreference(&Preference{
+			Label:     label,
+			Type:      TextPref,
+			TextValue: sql.NullString{String: *field, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for label, value := range settings.intPreferences() {
+		err := model.UpdatePreference(&Preference{
+			Label:    label,
+			Type:     IntPref,
+			IntValue: sql.NullInt64{Int64: value, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	model.renderSiteNotice(&settings)
+
+	model.settingsMu.Lock()
+	model.settings = settings
+	model.settingsMu.Unlock()
+
+	if model.queue != nil {
+		model.queue.Resize(settings.RenderWorkers)
+	}
+
+	return nil
+}
+
+// renderSiteNotice fills in the rendered HTML and version of settings' site
+// notice.
+func (model *WikiModel) renderSiteNotice(settings *Settings) {
+	settings.SiteNoticeHTML, settings.SiteNoticeVersion = "", ""
+	if strings.TrimSpace(settings.SiteNotice) == "" {
+		return
+	}
+
+	rendered, err := model.Render(settings.SiteNotice)
+	if err != nil {
+		log.Println(err)
+		rendered = "<p>" + html.EscapeString(settings.SiteNotice) + "</p>"
+	}
+	settings.SiteNoticeHTML = rendered
+
+	sum := sha256.Sum256([]byte(settings.SiteNotice))
+	settings.SiteNoticeVersion = hex.EncodeToString(sum[:])[:12]
+}
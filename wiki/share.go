@@ -0,0 +1,51 @@
+package wiki
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shareKey is the key share tokens are signed with, derived from
+// CookieSecret so that it differs from the session keys.
+func (model *WikiModel) shareKey() []byte {
+	key := sha256.Sum256(append([]byte("periwiki-share:"), model.CookieSecret...))
+	return key[:]
+}
+
+// shareSignature signs url and expiry, a Unix time.
+func (model *WikiModel) shareSignature(url string, expiry int64) []byte {
+	mac := hmac.New(sha256.New, model.shareKey())
+	mac.Write([]byte(url + "\n" + strconv.FormatInt(expiry, 10)))
+	return mac.Sum(nil)
+}
+
+// GenerateShareToken returns a token that lets anyone holding it view the
+// article at url, even while it is pending, until ttl has passed. Tokens
+// aren't stored: they are the expiry and an HMAC of it and url, so they stop
+// working if CookieSecret changes.
+func (model *WikiModel) GenerateShareToken(url string, ttl time.Duration) string {
+	expiry := time.Now().Add(ttl).Unix()
+	return strconv.FormatInt(expiry, 10) + "." + base64.RawURLEncoding.EncodeToString(model.shareSignature(url, expiry))
+}
+
+// ValidShareToken reports whether token was made by GenerateShareToken for
+// url and has yet to expire. Signatures are compared in constant time.
+func (model *WikiModel) ValidShareToken(url, token string) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, model.shareSignature(url, expiry)) {
+		return false
+	}
+	return time.Now().Before(time.Unix(expiry, 0))
+}
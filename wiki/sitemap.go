@@ -0,0 +1,111 @@
+package wiki
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSitemapPriority is the priority of pages no SitemapPriorities rule
+// matches.
+const DefaultSitemapPriority = 0.5
+
+// recentEditWindow is how far back edits count towards an article's
+// changefreq.
+const recentEditWindow = 30 * 24 * time.Hour
+
+// ArticleActivity is an article's current content and how often it changes.
+type ArticleActivity struct {
+	URL          string    `db:"url"`
+	Markdown     string    `db:"markdown"`
+	LastModified time.Time `db:"last_modified"`
+	// RecentEdits counts the revisions made in the window asked for.
+	RecentEdits int `db:"recent_edits"`
+}
+
+// SitemapEntry is a page listed in the sitemap.
+type SitemapEntry struct {
+	URL          string
+	LastModified time.Time
+	ChangeFreq   string
+	Priority     float64
+}
+
+// GetSitemap returns the articles to list in the sitemap. Articles awaiting
+// moderation, in a namespace in SitemapExcludeNamespaces, or with "noindex"
+// in their frontmatter are left out.
+func (model *WikiModel) GetSitemap() ([]*SitemapEntry, error) {
+	articles, err := model.db.SelectArticleActivity(time.Now().Add(-recentEditWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*SitemapEntry{}
+	for _, article := range articles {
+		if model.excludedFromSitemap(article.URL) {
+			continue
+		}
+		if frontmatter, _ := ParseFrontmatter(article.Markdown); frontmatter.NoIndex {
+			continue
+		}
+
+		entries = append(entries, &SitemapEntry{
+			URL:          article.URL,
+			LastModified: article.LastModified,
+			ChangeFreq:   changeFreq(article.RecentEdits),
+			Priority:     model.SitemapPriority(article.URL),
+		})
+	}
+	return entries, nil
+}
+
+func (model *WikiModel) excludedFromSitemap(url string) bool {
+	for _, namespace := range model.SitemapExcludeNamespaces {
+		if strings.HasPrefix(strings.ToLower(url), strings.ToLower(strings.TrimSuffix(namespace, ":"))+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// SitemapPriority returns the priority of url in the sitemap. The keys of
+// SitemapPriorities are URLs or path.Match patterns such as "Talk:*",
+// compared case-insensitively; an exact URL beats a pattern and longer
+// patterns beat shorter ones.
+func (model *WikiModel) SitemapPriority(url string) float64 {
+	patterns := make([]string, 0, len(model.SitemapPriorities))
+	for pattern, priority := range model.SitemapPriorities {
+		if strings.EqualFold(pattern, url) {
+			return priority
+		}
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(url)); matched {
+			return model.SitemapPriorities[pattern]
+		}
+	}
+	return DefaultSitemapPriority
+}
+
+// changeFreq suggests how often an article changes from the number of edits
+// it had in the last recentEditWindow.
+func changeFreq(recentEdits int) string {
+	switch {
+	case recentEdits >= 30:
+		return "daily"
+	case recentEdits >= 4:
+		return "weekly"
+	case recentEdits >= 1:
+		return "monthly"
+	}
+	return "yearly"
+}
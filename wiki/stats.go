@@ -0,0 +1,29 @@
+package wiki
+
+// Stats are counts describing the whole wiki, for Special:Statistics.
+type Stats struct {
+	// Articles counts the articles not awaiting moderation.
+	Articles  int `db:"articles"`
+	Revisions int `db:"revisions"`
+	// Users counts registered users.
+	Users int `db:"users"`
+	// RegisteredEdits and AnonymousEdits count revisions by whether they were
+	// saved by a logged-in user.
+	RegisteredEdits int `db:"registered_edits"`
+	AnonymousEdits  int `db:"anonymous_edits"`
+	// Links counts the WikiLinks between articles.
+	Links int `db:"links"`
+}
+
+// RevisionsPerArticle is the average number of revisions of an article.
+func (s *Stats) RevisionsPerArticle() float64 {
+	if s.Articles == 0 {
+		return 0
+	}
+	return float64(s.Revisions) / float64(s.Articles)
+}
+
+// GetStatistics counts the wiki's articles, revisions, users and links.
+func (model *WikiModel) GetStatistics() (*Stats, error) {
+	return model.db.SelectWikiStatistics()
+}
@@ -0,0 +1,93 @@
+package wiki
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestionCandidates caps how many articles SuggestSimilarTitles
+// compares url against.
+const maxSuggestionCandidates = 1000
+
+// SuggestSimilarTitles returns up to limit existing articles whose URLs are
+// within a few typos of url, closest first. Talk and Special pages are never
+// suggested.
+func (model *WikiModel) SuggestSimilarTitles(url string, limit int) ([]*ArticleSummary, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	target := []rune(strings.ToLower(url))
+	// Allow roughly one typo per four characters.
+	maxDistance := len(target)/4 + 1
+
+	// A URL whose length differs by more than maxDistance can't be close
+	// enough, so only those of similar length are compared.
+	articles, err := model.db.SelectArticleSummariesByLength(len(target),
+		len(target)-maxDistance, len(target)+maxDistance, maxSuggestionCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		*ArticleSummary
+		distance int
+	}
+	candidates := []candidate{}
+	for _, article := range articles {
+		if article.URL == url || IsTalkPage(article.URL) || IsSpecialPage(article.URL) {
+			continue
+		}
+		distance := editDistance(target, []rune(strings.ToLower(article.URL)))
+		if distance <= maxDistance {
+			candidates = append(candidates, candidate{article, distance})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	suggestions := []*ArticleSummary{}
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, candidates[i].ArticleSummary)
+	}
+	return suggestions, nil
+}
+
+// editDistance is the Damerau–Levenshtein (optimal string alignment)
+// distance between a and b, so a swapped pair of letters counts as one typo.
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,46 @@
+package wiki
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// Title casings for TitleCasing.
+const (
+	// TitleCasingFirstLetter capitalizes only the first letter of the URL,
+	// e.g. "New-test-article".
+	TitleCasingFirstLetter = "first-letter"
+	// TitleCasingSentence separates the URL's words and writes them in
+	// sentence case, e.g. "New test article".
+	TitleCasingSentence = "sentence-case"
+	// TitleCasingTitle separates the URL's words and capitalizes each, e.g.
+	// "New Test Article".
+	TitleCasingTitle = "title-case"
+)
+
+var titleWordSeparators = strings.NewReplacer("-", " ", "_", " ")
+
+// DisplayTitle derives a title for a new article from its URL, following
+// TitleCasing. Titles that editors set are never recased.
+func (model *WikiModel) DisplayTitle(url string) string {
+	switch model.TitleCasing {
+	case TitleCasingSentence:
+		return capitalizeFirst(strings.ToLower(titleWordSeparators.Replace(url)))
+	case TitleCasingTitle:
+		return cases.Title(language.AmericanEnglish).String(titleWordSeparators.Replace(url))
+	default:
+		return TitleFromURL(url)
+	}
+}
+
+func capitalizeFirst(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if first == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(first)) + s[size:]
+}
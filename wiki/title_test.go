@@ -0,0 +1,26 @@
+package wiki
+
+import "testing"
+
+func TestDisplayTitle(t *testing.T) {
+	tests := []struct {
+		casing string
+		url    string
+		want   string
+	}{
+		{"", "my-cool-page", "My-cool-page"},
+		{TitleCasingFirstLetter, "new-test-article", "New-test-article"},
+		{TitleCasingFirstLetter, "éclair", "Éclair"},
+		{TitleCasingFirstLetter, "cats_and_dogs", "Cats and dogs"},
+		{TitleCasingSentence, "My_Cool-PAGE", "My cool page"},
+		{TitleCasingTitle, "my-cool-page", "My Cool Page"},
+		{TitleCasingTitle, "my_cool_page", "My Cool Page"},
+	}
+
+	for _, test := range tests {
+		model := &WikiModel{Config: &Config{TitleCasing: test.casing}}
+		if got := model.DisplayTitle(test.url); got != test.want {
+			t.Errorf("%q with %q casing: expected %q, got %q", test.url, test.casing, test.want, got)
+		}
+	}
+}
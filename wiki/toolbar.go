@@ -0,0 +1,54 @@
+package wiki
+
+// ToolbarButton is a formatting button on the edit form. Clicking it wraps
+// the selected text, or Placeholder if nothing is selected, in Before and
+// After.
+type ToolbarButton struct {
+	Name        string
+	Label       string
+	Title       string
+	Before      string
+	After       string
+	Placeholder string
+}
+
+// ToolbarButtons are the buttons EditorToolbar may choose from. They only
+// insert syntax the renderer supports.
+var ToolbarButtons = []ToolbarButton{
+	{Name: "bold", Label: "B", Title: "Bold", Before: "**", After: "**", Placeholder: "bold text"},
+	{Name: "italic", Label: "I", Title: "Italic", Before: "_", After: "_", Placeholder: "italic text"},
+	{Name: "heading", Label: "H", Title: "Section heading", Before: "\n## ", After: "\n", Placeholder: "Heading"},
+	{Name: "link", Label: "Link", Title: "External link", Before: "[", After: "](https://)", Placeholder: "link text"},
+	{Name: "wikilink", Label: "[[ ]]", Title: "Link to an article", Before: "[[", After: "]]", Placeholder: "Article"},
+	{Name: "code", Label: "<>", Title: "Inline code", Before: "`", After: "`", Placeholder: "code"},
+	{Name: "list", Label: "•", Title: "Bulleted list", Before: "\n- ", After: "\n", Placeholder: "item"},
+}
+
+// DefaultEditorToolbar names every button in ToolbarButtons.
+var DefaultEditorToolbar = func() []string {
+	names := make([]string, len(ToolbarButtons))
+	for i, button := range ToolbarButtons {
+		names[i] = button.Name
+	}
+	return names
+}()
+
+func toolbarButton(name string) (ToolbarButton, bool) {
+	for _, button := range ToolbarButtons {
+		if button.Name == name {
+			return button, true
+		}
+	}
+	return ToolbarButton{}, false
+}
+
+// Toolbar returns the buttons named by EditorToolbar, in its order.
+func (model *WikiModel) Toolbar() []ToolbarButton {
+	toolbar := make([]ToolbarButton, 0, len(model.EditorToolbar))
+	for _, name := range model.EditorToolbar {
+		if button, ok := toolbarButton(name); ok {
+			toolbar = append(toolbar, button)
+		}
+	}
+	return toolbar
+}
@@ -0,0 +1,37 @@
+package wiki
+
+import (
+	"strings"
+
+	"github.com/danielledeleo/periwiki/extensions"
+)
+
+// NormalizeURL returns the canonical form of the article URL raw. Two URLs
+// with the same canonical form name the same article:
+//
+//   - surrounding whitespace is trimmed;
+//   - each run of whitespace becomes a single underscore, so "Cats and dogs"
+//     and "Cats_and_dogs" are the same;
+//   - the result is in Unicode NFC, so "café" written with a precomposed é
+//     and with e and a combining acute accent are the same.
+//
+// Case is kept: "cats" and "Cats" are different articles. WikiLinks, and so
+// the links recorded for backlinks, follow the same rules, as do the /wiki/
+// routes, which redirect to the canonical URL. Namespaces are canonicalized
+// separately by ResolveNamespace.
+func NormalizeURL(raw string) string {
+	return extensions.NormalizeURL(raw)
+}
+
+// TitleFromURL returns the plain title for the article URL url: its
+// underscores become spaces and its first letter is capitalized, e.g.
+// "café_au_lait" becomes "Café au lait". See DisplayTitle for the title new
+// articles are given.
+func TitleFromURL(url string) string {
+	return capitalizeFirst(strings.ReplaceAll(url, "_", " "))
+}
+
+// canonicalURL returns NormalizeURL of url with its namespace resolved.
+func (model *WikiModel) canonicalURL(url string) string {
+	return model.ResolveNamespace(NormalizeURL(url))
+}
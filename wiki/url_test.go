@@ -0,0 +1,67 @@
+package wiki
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"Cats", "Cats"},
+		{"cats", "cats"},
+		{"Target B", "Target_B"},
+		{"Target_B", "Target_B"},
+		{" \tList of  Canadian\t provinces \n", "List_of_Canadian_provinces"},
+		{"Disambiguation (Disambiguation)", "Disambiguation_(Disambiguation)"},
+		{"Talk:Cats and dogs", "Talk:Cats_and_dogs"},
+		// é precomposed, and as e with a combining acute accent.
+		{"Caf\u00e9", "Caf\u00e9"},
+		{"Cafe\u0301", "Caf\u00e9"},
+		{"cafe\u0301 au lait", "caf\u00e9_au_lait"},
+		// Combining marks are put in canonical order before composing: a
+		// with a circumflex then a dot below is ậ, as is a with the dot
+		// first.
+		{"a\u0302\u0323", "\u1ead"},
+		{"a\u0323\u0302", "\u1ead"},
+		// Marks with no precomposed form are kept.
+		{"x\u0301", "x\u0301"},
+		{"Ωmega", "Ωmega"},
+		{"日本語 ページ", "日本語_ページ"},
+		{"", ""},
+		{"   ", ""},
+	}
+
+	for _, test := range tests {
+		got := NormalizeURL(test.raw)
+		if got != test.want {
+			t.Errorf("NormalizeURL(%q): expected %q, got %q", test.raw, test.want, got)
+		}
+		if again := NormalizeURL(got); again != got {
+			t.Errorf("NormalizeURL(%q) isn't stable: %q became %q", test.raw, got, again)
+		}
+	}
+}
+
+func TestTitleFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"cats", "Cats"},
+		{"Target_B", "Target B"},
+		{"caf\u00e9_au_lait", "Caf\u00e9 au lait"},
+		{"\u00e9clair", "\u00c9clair"},
+		{"new-test-article", "New-test-article"},
+		{"日本語_ページ", "日本語 ページ"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := TitleFromURL(test.url); got != test.want {
+			t.Errorf("TitleFromURL(%q): expected %q, got %q", test.url, test.want, got)
+		}
+		if url := NormalizeURL(TitleFromURL(test.url)); url != capitalizeFirst(test.url) {
+			t.Errorf("expected the title of %q to lead back to it, got %q", test.url, url)
+		}
+	}
+}
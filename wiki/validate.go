@@ -0,0 +1,179 @@
+package wiki
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// ConfigError lists every problem found by Config.Validate.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks c for values the wiki can't run with. Rather than stopping
+// at the first, it returns a *ConfigError describing all of them, so they can
+// be fixed in one go. Problems are named by their config.yaml keys.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.CookieSecret) == 0 {
+		problem("cookie_secret is empty; delete .cookiesecret.yaml to have a new one generated")
+	}
+	if c.CookieExpiry <= 0 {
+		problem("cookie_expiry must be a positive number of seconds, not %d", c.CookieExpiry)
+	}
+	if c.RememberMeExpiry < c.CookieExpiryDuration() {
+		problem("remember_me_expiry must be at least cookie_expiry (%v), not %v", c.CookieExpiryDuration(), c.RememberMeExpiry)
+	}
+	if c.MinimumPasswordLength < 1 {
+		problem("min_password_length must be at least 1, not %d", c.MinimumPasswordLength)
+	}
+	if c.RenderWorkers < 0 {
+		problem("render_workers must not be negative; use 0 to render synchronously")
+	}
+	if c.MaxConcurrentRenders < 0 {
+		problem("max_concurrent_renders must not be negative; use 0 for no limit")
+	}
+	if c.BacklinkBatchWindow < 0 {
+		problem("backlink_batch_window must not be negative")
+	}
+	if c.WatchNotificationDelay < 0 {
+		problem("watch_notification_delay must not be negative")
+	}
+	if c.LargeArticleRenderBytes < 0 {
+		problem("large_article_render_bytes must not be negative; use 0 to disable it")
+	}
+	if c.MaxNestingDepth < 0 {
+		problem("max_nesting_depth must not be negative; use 0 for no limit")
+	}
+	if !ValidLicenseURL(c.ContentLicenseURL) {
+		problem("content_license_url must be an http or https URL, not %q", c.ContentLicenseURL)
+	}
+	if c.CleanupInterval < 0 {
+		problem("cleanup_interval must not be negative; use 0 to disable cleanup")
+	}
+	if c.LoginRateLimit < 0 {
+		problem("login_rate_limit must not be negative; use 0 for no limit")
+	} else if c.LoginRateLimit > 0 && c.LoginRateWindow <= 0 {
+		problem("login_rate_window must be positive when login_rate_limit is set")
+	}
+	if c.DiffCacheSize < 0 {
+		problem("diff_cache_size must not be negative; use 0 to disable the cache")
+	}
+	if c.ShowReadingTime && c.ReadingWordsPerMinute <= 0 {
+		problem("reading_words_per_minute must be positive to show reading times")
+	}
+	if c.MaxRenderDuration < 0 {
+		problem("max_render_duration must not be negative; use 0 for no limit")
+	}
+	if c.BackgroundRenderMaxWait < 0 {
+		problem("background_render_max_wait must not be negative; use 0 to never promote background renders")
+	}
+	if c.EditorTabSize < 1 || c.EditorTabSize > MaxEditorTabSize {
+		problem("editor_tab_size must be between 1 and %d, not %d", MaxEditorTabSize, c.EditorTabSize)
+	}
+	if c.DuplicateContentThreshold < 0 || c.DuplicateContentThreshold > 1 {
+		problem("duplicate_content_threshold must be between 0 and 1, not %g", c.DuplicateContentThreshold)
+	}
+	for _, origin := range c.EmbedAllowedOrigins {
+		if !ValidOrigin(origin) {
+			problem("embed_allowed_origins: %q is not an origin, e.g. https://intranet.example.com", origin)
+		}
+	}
+	for pattern, priority := range c.SitemapPriorities {
+		if priority < 0 || priority > 1 {
+			problem("sitemap_priorities: %s must be between 0 and 1, not %g", pattern, priority)
+		}
+	}
+
+	if _, port, err := net.SplitHostPort(c.Host); err != nil || port == "" {
+		problem("host %q must be an address and port to listen on, e.g. 0.0.0.0:8080", c.Host)
+	}
+
+	if _, err := parseAttributionTemplate(c.AttributionTemplate); err != nil {
+		problem("attribution_template: %v", err)
+	}
+
+	if _, ok := styles.Registry[c.SyntaxHighlighting]; c.SyntaxHighlighting != "" && !ok {
+		problem("syntax_highlighting: there is no %q style; choose from %s", c.SyntaxHighlighting, strings.Join(styles.Names(), ", "))
+	}
+
+	if _, err := exec.LookPath(c.DotPath); c.Diagrams && err != nil {
+		problem("dot_path: can't run %q to draw diagrams: %v", c.DotPath, err)
+	}
+
+	switch c.TitleCasing {
+	case "", TitleCasingFirstLetter, TitleCasingSentence, TitleCasingTitle:
+	default:
+		problem("title_casing must be %s, %s or %s, not %q", TitleCasingFirstLetter, TitleCasingSentence, TitleCasingTitle, c.TitleCasing)
+	}
+
+	if c.Theme != "" && !ValidThemeName(c.Theme) {
+		problem("theme may only contain lower case letters, digits, dashes and underscores, not %q", c.Theme)
+	}
+	if c.DiffMode != "" && !ValidDiffMode(c.DiffMode) {
+		problem("diff_mode must be %s, %s or %s, not %q", DiffModeInline, DiffModeWord, DiffModeSplit, c.DiffMode)
+	}
+
+	for _, name := range c.EditorToolbar {
+		if _, ok := toolbarButton(name); !ok {
+			problem("editor_toolbar: there is no %q button; choose from %s", name, strings.Join(DefaultEditorToolbar, ", "))
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problem("trusted_proxies: %q is neither an IP address nor a CIDR range", proxy)
+		}
+	}
+
+	if err := checkDatabaseFile(c.DatabaseFile); err != nil {
+		problem("dbfile %q: %v", c.DatabaseFile, err)
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// checkDatabaseFile reports whether the SQLite database at path can be opened
+// for writing, or created if it doesn't exist yet.
+func checkDatabaseFile(path string) error {
+	if path == "" {
+		return fmt.Errorf("no database file given")
+	}
+	if path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err == nil {
+		return f.Close()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("its directory can't be read: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package wiki
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig(t *testing.T) *Config {
+	return &Config{
+		CookieSecret:          []byte("secret"),
+		CookieExpiry:          86400,
+		RememberMeExpiry:      30 * 24 * time.Hour,
+		DatabaseFile:          filepath.Join(t.TempDir(), "periwiki.db"),
+		MinimumPasswordLength: 8,
+		Host:                  "0.0.0.0:8080",
+		RenderWorkers:         2,
+		EditorTabSize:         4,
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	conf := validConfig(t)
+	conf.CookieSecret = nil
+	conf.MinimumPasswordLength = 0
+	conf.RenderWorkers = -1
+	conf.Host = "localhost"
+	conf.DatabaseFile = filepath.Join(t.TempDir(), "missing", "periwiki.db")
+	conf.EditorToolbar = []string{"bold", "blink"}
+	conf.SyntaxHighlighting = "neon"
+	conf.Diagrams = true
+	conf.DotPath = filepath.Join(t.TempDir(), "dot")
+
+	err := conf.Validate()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected a *ConfigError, got %v", err)
+	}
+
+	for _, key := range []string{"cookie_secret", "min_password_length", "render_workers", "host", "dbfile", "editor_toolbar", "syntax_highlighting", "dot_path"} {
+		found := false
+		for _, problem := range configErr.Problems {
+			found = found || strings.HasPrefix(problem, key)
+		}
+		if !found {
+			t.Errorf("expected a problem with %s, got:\n%v", key, err)
+		}
+	}
+	if len(configErr.Problems) != 8 {
+		t.Errorf("expected exactly 8 problems, got:\n%v", err)
+	}
+	if !strings.Contains(err.Error(), "\n  - render_workers") {
+		t.Errorf("expected one problem per line, got:\n%v", err)
+	}
+}
+
+func TestValidateDatabaseFile(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := validConfig(t)
+	conf.DatabaseFile = dir
+	if err := conf.Validate(); err == nil {
+		t.Error("expected a directory to be rejected as the database file")
+	}
+
+	existing := filepath.Join(dir, "periwiki.db")
+	if err := os.WriteFile(existing, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf.DatabaseFile = existing
+	if err := conf.Validate(); err != nil {
+		t.Errorf("expected an existing database file to be accepted, got %v", err)
+	}
+}
+
+func TestValidateEmbedAllowedOrigins(t *testing.T) {
+	for _, origin := range []string{"https://intranet.example.com", "http://localhost:3000", "https://[::1]:8443"} {
+		conf := validConfig(t)
+		conf.EmbedAllowedOrigins = []string{origin}
+		if err := conf.Validate(); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", origin, err)
+		}
+	}
+
+	for _, origin := range []string{
+		"https://example.com; script-src *",
+		"https://example.com 'unsafe-inline'",
+		"https://example.com/path",
+		"https://*.example.com",
+		"*",
+		"'self'",
+		"example.com",
+		"javascript:alert(1)",
+	} {
+		conf := validConfig(t)
+		conf.EmbedAllowedOrigins = []string{origin}
+		if err := conf.Validate(); err == nil || !strings.Contains(err.Error(), "embed_allowed_origins") {
+			t.Errorf("expected %q to be rejected, got %v", origin, err)
+		}
+	}
+}
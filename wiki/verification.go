@@ -0,0 +1,107 @@
+package wiki
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+)
+
+var ErrEmailNotVerified = errors.New("your email address hasn't been verified yet; follow your verification link, or request a new one")
+var ErrBadVerificationToken = errors.New("this verification link is invalid, has expired or has already been used")
+
+// VerificationLinkLifetime is how long a verification link works for.
+const VerificationLinkLifetime = 48 * time.Hour
+
+// VerificationSender delivers the link that verifies user's email address.
+// The link is a path on the wiki, such as /user/verify/abc.
+type VerificationSender func(user *User, link string) error
+
+// logVerification is the VerificationSender used when none is set. Periwiki
+// doesn't send mail itself, so the link is logged for an admin to pass on.
+func logVerification(user *User, link string) error {
+	log.Printf("Email verification for %s <%s>: %s", user.ScreenName, user.Email, link)
+	return nil
+}
+
+// newVerificationToken returns a random token for a verification link.
+func newVerificationToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// issueVerificationToken gives user a new verification token, valid for
+// VerificationLinkLifetime.
+func issueVerificationToken(user *User) error {
+	token, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+	user.VerificationToken = token
+	user.VerificationExpires = time.Now().Add(VerificationLinkLifetime)
+	return nil
+}
+
+// VerificationLink returns the path that verifies the email address of the
+// user given token.
+func VerificationLink(token string) string {
+	return "/user/verify/" + url.PathEscape(token)
+}
+
+// sendVerification sends user the link with their verification token.
+func (model *WikiModel) sendVerification(user *User) error {
+	send := model.SendVerification
+	if send == nil {
+		send = logVerification
+	}
+	return send(user, VerificationLink(user.VerificationToken))
+}
+
+// MailsVerification reports whether verification links are delivered to
+// users, rather than logged for an admin to pass on.
+func (model *WikiModel) MailsVerification() bool {
+	return model.SendVerification != nil
+}
+
+// RequiresVerification reports whether user is kept from logging in, and
+// from editing where an account is needed, until they verify their email
+// address.
+func (model *WikiModel) RequiresVerification(user *User) bool {
+	return model.Settings().RequireEmailVerification && !user.IsAnonymous() && !user.EmailVerified
+}
+
+// VerifyEmail marks the email address of the user with token as verified and
+// returns their screenname, unless the token has expired.
+func (model *WikiModel) VerifyEmail(token string) (string, error) {
+	screenname, err := model.db.UpdateEmailVerified(token, time.Now())
+	if err == sql.ErrNoRows {
+		return "", ErrBadVerificationToken
+	}
+	return screenname, err
+}
+
+// ResendVerification sends the user a new verification link, unless their
+// email address is already verified.
+func (model *WikiModel) ResendVerification(screenname string) error {
+	user, err := model.GetUserByScreenName(screenname)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	if err := issueVerificationToken(user); err != nil {
+		return err
+	}
+	if err := model.db.UpdateVerificationToken(user.ID, user.VerificationToken, user.VerificationExpires); err != nil {
+		return err
+	}
+	return model.sendVerification(user)
+}
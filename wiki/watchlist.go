@@ -0,0 +1,41 @@
+package wiki
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAnonymousWatchlist = errors.New("log in to watch pages")
+
+// Watch adds the page at url, which needn't exist yet, to the watchlist of
+// the user with userID.
+func (model *WikiModel) Watch(userID int, url string) error {
+	if userID == AnonymousUser().ID {
+		return ErrAnonymousWatchlist
+	}
+	return model.db.InsertWatch(userID, model.canonicalURL(url))
+}
+
+// Unwatch removes the page at url from the watchlist of the user with
+// userID.
+func (model *WikiModel) Unwatch(userID int, url string) error {
+	if userID == AnonymousUser().ID {
+		return ErrAnonymousWatchlist
+	}
+	return model.db.DeleteWatch(userID, model.canonicalURL(url))
+}
+
+// IsWatching reports whether the page at url is on the watchlist of the user
+// with userID. Anonymous users watch nothing.
+func (model *WikiModel) IsWatching(userID int, url string) (bool, error) {
+	if userID == AnonymousUser().ID {
+		return false, nil
+	}
+	return model.db.SelectWatching(userID, model.canonicalURL(url))
+}
+
+// GetWatchlistChanges is GetRecentChanges limited to the pages on the
+// watchlist of the user with userID.
+func (model *WikiModel) GetWatchlistChanges(userID, limit int, before time.Time) ([]*RevisionSummary, error) {
+	return model.db.SelectWatchedRevisions(userID, limit, before)
+}
@@ -0,0 +1,117 @@
+package wiki
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CountWords counts the words a reader sees in markdown: its frontmatter,
+// fenced code blocks, HTML tags, link destinations, images, footnote
+// references, transclusions and categories are left out, and WikiLinks count
+// as their labels.
+func CountWords(markdown string) int {
+	_, body := ParseFrontmatter(markdown)
+
+	words := 0
+	fence := ""
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		words += countProseWords(prose(line))
+	}
+	return words
+}
+
+// ReadingMinutes estimates how long words take to read at wordsPerMinute,
+// rounding up. It is 0 only for no words.
+func ReadingMinutes(words, wordsPerMinute int) int {
+	if words <= 0 || wordsPerMinute <= 0 {
+		return 0
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// prose returns the text of a line of markdown that is read, less the
+// syntax around it.
+func prose(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch {
+		case strings.HasPrefix(rest, "[["):
+			inner, n := enclosed(rest, "[[", "]]")
+			if !strings.HasPrefix(inner, "Category:") {
+				if _, label, ok := strings.Cut(inner, "|"); ok {
+					inner = label
+				}
+				b.WriteString(strings.ReplaceAll(inner, "_", " "))
+			}
+			i += n
+		case strings.HasPrefix(rest, "{{"):
+			_, n := enclosed(rest, "{{", "}}")
+			i += n
+		case strings.HasPrefix(rest, "[^"):
+			_, n := enclosed(rest, "[^", "]")
+			i += n
+		case strings.HasPrefix(rest, "!["):
+			_, n := enclosed(rest, "![", "]")
+			i += n
+			if strings.HasPrefix(line[i:], "(") {
+				_, n = enclosed(line[i:], "(", ")")
+				i += n
+			}
+		case strings.HasPrefix(rest, "]("):
+			_, n := enclosed(rest, "](", ")")
+			i += n
+		case rest[0] == '<' && len(rest) > 1 && (rest[1] == '/' || isASCIILetter(rest[1])):
+			_, n := enclosed(rest, "<", ">")
+			i += n
+		default:
+			b.WriteByte(rest[0])
+			i++
+			continue
+		}
+		// Syntax that was skipped still separates words.
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// enclosed returns what s, which starts with open, holds before close, and
+// how many bytes that takes with its delimiters. Unclosed, it runs to the
+// end of s.
+func enclosed(s, open, close string) (string, int) {
+	inner := s[len(open):]
+	end := strings.Index(inner, close)
+	if end < 0 {
+		return inner, len(s)
+	}
+	return inner[:end], len(open) + end + len(close)
+}
+
+// countProseWords counts the runs of letters and digits in s, allowing the
+// apostrophes and hyphens inside words such as "don't" and "well-known".
+func countProseWords(s string) int {
+	words := 0
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’' || r == '-')
+	}) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,49 @@
+package wiki
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		md   string
+		want int
+	}{
+		{"", 0},
+		{"   \n\n", 0},
+		{"One two three.", 3},
+		{"Don't split well-known words — or dashes.", 6},
+		{"---\ndisplay_title: Many words in the title\n---\nTwo words.", 2},
+		{"# Heading\n\n- one\n- *two*\n\n| a | b |\n|---|---|", 5},
+		{"Before.\n\n```go\nfunc main() { fmt.Println(\"ignored\") }\n```\n\nAfter.", 2},
+		{"See [[Render_queue]] and [[Glossary#Terms|the glossary]].", 6},
+		{"Filed under [[Category:Guides]] nothing.", 3},
+		{"Claim[^1] here {{Infobox}}.\n\n[^1]: A source.", 4},
+		{"A [link](https://example.com/a/b) and ![an image](pic.png).", 3},
+		{"Some <span class=\"x\">marked up</span> text, 3 < 4.", 6},
+	}
+	for _, test := range tests {
+		if got := CountWords(test.md); got != test.want {
+			t.Errorf("CountWords(%q) = %d, want %d", test.md, got, test.want)
+		}
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	for _, test := range []struct{ words, want int }{{0, 0}, {1, 1}, {200, 1}, {201, 2}} {
+		if got := ReadingMinutes(test.words, 200); got != test.want {
+			t.Errorf("ReadingMinutes(%d, 200) = %d, want %d", test.words, got, test.want)
+		}
+	}
+}
+
+func TestCountWordsIsFast(t *testing.T) {
+	md := strings.Repeat("Some [[Linked_page|text]] with `code`, [^1] and [links](x) <b>too</b>.\n", 20000) + "[[" + strings.Repeat("[", 100000)
+	start := time.Now()
+	CountWords(md)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected a large article to be counted within 100ms, took %v", elapsed)
+	}
+}